@@ -416,3 +416,63 @@ func GenerateTenantCerts(certClient []CertClient, path, tenant, serviceCidr stri
 
 	return certFiles, nil
 }
+
+/*
+ *  Function generate certs for new worker nodes of existing tenant.
+ *  Tenant CA must be already generated by GenerateTenantCerts.
+ */
+func GenerateWorkerCerts(certClient []CertClient, dir, tenant string) (map[string]string, error) {
+
+	if len(dir) == 0 || len(tenant) == 0 {
+		return nil, fmt.Errorf("invalid path or tenant name")
+	}
+
+	cfsslLoc, err := system.GetExecLocation("cfssl")
+	if err != nil {
+		return nil, err
+	}
+	cfssljsonLoc, err := system.GetExecLocation("cfssljson")
+	if err != nil {
+		return nil, err
+	}
+	openssl, err := system.GetExecLocation("openssl")
+	if err != nil {
+		return nil, err
+	}
+
+	certRequest := &CertRequest{}
+	certRequest.path = dir
+	certRequest.tenant = tenant
+	certRequest.cfssl = cfsslLoc
+	certRequest.cfssljson = cfssljsonLoc
+
+	caDir := path.Join(dir, tenant, "ca")
+	cacert := path.Join(caDir, "ca.pem")
+	cakey := path.Join(caDir, "ca-key.pem")
+	config := path.Join(caDir, tenant+".ca-config.json")
+	for _, f := range []string{cacert, cakey, config} {
+		if !osutil.CheckIfExist(f) {
+			return nil, fmt.Errorf("tenant %s has no ca, missing %s", tenant, f)
+		}
+	}
+
+	var certFiles = make(map[string]string, 0)
+	for _, v := range certClient {
+		if v.GetNodeType() != jettypes.WorkerType {
+			continue
+		}
+		hostnames := v.GetHostname() + "," + v.GetIpAddress()
+		caResp, err := MakeNodeCertificateReq(certRequest,
+			cacert, cakey, config, v.GetHostname(), hostnames, v.GetIpAddress())
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := verify(openssl, cacert, caResp.getCertificate()); ok && err == nil {
+			log.Println("Generated certificates for worker node", v.GetHostname(), ": verified")
+		}
+		certFiles[v.GetHostname()+".cert"] = caResp.getCertificate()
+		certFiles[v.GetHostname()+".akey"] = caResp.getKey()
+	}
+
+	return certFiles, nil
+}
@@ -0,0 +1,140 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+	"strings"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+/**
+  Function stores a drs rule created for a deployment, rule with same
+  name replaced.
+*/
+func AddAffinityRule(db *sql.DB, projectName string, rule *jettypes.AffinityRule) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	query := `INSERT OR REPLACE INTO affinity (id, RuleName, Cluster, Kind, HostGroup, Vms)
+		VALUES ((SELECT id FROM deployment WHERE DeploymentName is ?), ?, ?, ?, ?, ?)`
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName, rule.Name, rule.Cluster, rule.Kind, rule.HostGroup, strings.Join(rule.Vms, ","))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function deletes a drs rule of a deployment.
+*/
+func DeleteAffinityRule(db *sql.DB, projectName string, ruleName string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	return deleteAffinityRules(db, projectName, ruleName)
+}
+
+/**
+  Function deletes a drs rule of a deployment, for empty rule name
+  all rules of a deployment deleted.
+*/
+func deleteAffinityRules(db preparer, projectName string, ruleName string) error {
+
+	query := `DELETE FROM affinity WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+	args := []interface{}{projectName}
+	if len(ruleName) > 0 {
+		query += ` AND RuleName is ?`
+		args = append(args, ruleName)
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns drs rules created for a deployment.
+*/
+func GetAffinityRules(db *sql.DB, projectName string) ([]*jettypes.AffinityRule, error) {
+
+	var rules []*jettypes.AffinityRule
+
+	if db == nil {
+		return rules, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return rules, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT RuleName, Cluster, Kind, HostGroup, Vms FROM affinity
+		WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+
+	rows, err := db.Query(query, projectName)
+	if err != nil {
+		return rules, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			vms  string
+			rule jettypes.AffinityRule
+		)
+		err = rows.Scan(&rule.Name, &rule.Cluster, &rule.Kind, &rule.HostGroup, &vms)
+		if err != nil {
+			return rules, errors.Trace(err)
+		}
+		if len(vms) > 0 {
+			rule.Vms = strings.Split(vms, ",")
+		}
+		rules = append(rules, &rule)
+	}
+
+	return rules, errors.Trace(rows.Err())
+}
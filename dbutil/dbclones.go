@@ -0,0 +1,148 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+// a linked clone and a template snapshot it created from
+type LinkedClone struct {
+	Node       string
+	VmTemplate string
+	Snapshot   string
+}
+
+/**
+  Function stores a template snapshot of a linked clone, full clone has no record.
+*/
+func setLinkedClone(db preparer, depId int64, node *jettypes.NodeTemplate) error {
+
+	if !node.LinkedClone() {
+		return nil
+	}
+
+	query := `INSERT OR REPLACE INTO clones (id, JettisonUuid, VmTemplate, Snapshot) VALUES (?, ?, ?, ?)`
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(depId, node.Name, node.VmTemplateName, node.SnapshotName())
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function deletes a linked clone record of a node, for empty node name
+  records of all nodes of a deployment deleted.
+*/
+func deleteLinkedClones(db preparer, projectName string, nodeName string) error {
+
+	query := `DELETE FROM clones WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+	args := []interface{}{projectName}
+	if len(nodeName) > 0 {
+		query += ` AND JettisonUuid is ?`
+		args = append(args, nodeName)
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns linked clones of a deployment.
+*/
+func GetLinkedClones(db *sql.DB, projectName string) ([]LinkedClone, error) {
+
+	var clones []LinkedClone
+
+	if db == nil {
+		return clones, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return clones, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT JettisonUuid, VmTemplate, Snapshot FROM clones
+		WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+
+	rows, err := db.Query(query, projectName)
+	if err != nil {
+		return clones, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var c LinkedClone
+		err = rows.Scan(&c.Node, &c.VmTemplate, &c.Snapshot)
+		if err != nil {
+			return clones, errors.Trace(err)
+		}
+		clones = append(clones, c)
+	}
+
+	return clones, errors.Trace(rows.Err())
+}
+
+/**
+  Function returns number of linked clones other deployments created
+  from a template snapshot.
+*/
+func CountLinkedClones(db *sql.DB, vmTemplate string, snapshot string, excludeProject string) (int, error) {
+
+	if db == nil {
+		return 0, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return 0, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT COUNT(*) FROM clones WHERE VmTemplate is ? AND Snapshot is ?
+		AND id IS NOT (SELECT id FROM deployment WHERE DeploymentName is ?)`
+
+	var count int
+	err = db.QueryRow(query, vmTemplate, snapshot, excludeProject).Scan(&count)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,133 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+/**
+  Function stores hardware a node cloned with, node that keeps template
+  hardware has no record.
+*/
+func setHardware(db preparer, depId int64, node *jettypes.NodeTemplate) error {
+
+	hw := node.Hardware
+	if hw.IsZero() {
+		return nil
+	}
+
+	var disks []string
+	for _, size := range hw.DataDisksGB {
+		disks = append(disks, strconv.FormatInt(size, 10))
+	}
+
+	query := `INSERT OR REPLACE INTO hardware
+		(id, JettisonUuid, Cpus, CoresPerSocket, MemoryMB, DiskGB, DataDisksGB) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(depId, node.Name, hw.Cpus, hw.CoresPerSocket, hw.MemoryMB, hw.DiskGB, strings.Join(disks, ","))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function deletes hardware of a node, for empty node name hardware
+  of all nodes of a deployment deleted.
+*/
+func deleteHardware(db preparer, projectName string, nodeName string) error {
+
+	query := `DELETE FROM hardware WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+	args := []interface{}{projectName}
+	if len(nodeName) > 0 {
+		query += ` AND JettisonUuid is ?`
+		args = append(args, nodeName)
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns hardware of nodes of a deployment keyed by node name.
+*/
+func GetDeploymentHardware(db *sql.DB, projectName string) (map[string]jettypes.Hardware, error) {
+
+	hardware := make(map[string]jettypes.Hardware)
+
+	if db == nil {
+		return hardware, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return hardware, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT JettisonUuid, Cpus, CoresPerSocket, MemoryMB, DiskGB, DataDisksGB FROM hardware
+		WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+
+	rows, err := db.Query(query, projectName)
+	if err != nil {
+		return hardware, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			name  string
+			disks string
+			hw    jettypes.Hardware
+		)
+		err = rows.Scan(&name, &hw.Cpus, &hw.CoresPerSocket, &hw.MemoryMB, &hw.DiskGB, &disks)
+		if err != nil {
+			return hardware, errors.Trace(err)
+		}
+		for _, v := range strings.Split(disks, ",") {
+			if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+				hw.DataDisksGB = append(hw.DataDisksGB, size)
+			}
+		}
+		hardware[name] = hw
+	}
+
+	return hardware, errors.Trace(rows.Err())
+}
@@ -84,6 +84,191 @@ func CreateTablesIfNeed(db *sql.DB) error {
 		return errors.Trace(err)
 	}
 
+	// deployment steps, a deployment record created only in the middle
+	// of deployment so steps refer to deployment by name.
+	query = `CREATE TABLE IF NOT EXISTS steps
+	(
+		stepid         INTEGER PRIMARY KEY AUTOINCREMENT,
+		DeploymentName TEXT not null,
+		Step           TEXT not null,
+		Status         TEXT not null,
+		Inputs         TEXT not null,
+		Updated        DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (DeploymentName, Step)
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	// compensation journal, each object created by a deployment in order of creation
+	query = `CREATE TABLE IF NOT EXISTS journal
+	(
+		entryid        INTEGER PRIMARY KEY AUTOINCREMENT,
+		DeploymentName TEXT not null,
+		Kind           TEXT not null,
+		Ref            TEXT not null,
+		Data           TEXT not null,
+		Created        DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	// vm template each type of node cloned from
+	query = `CREATE TABLE IF NOT EXISTS templates
+	(
+		templateid     INTEGER PRIMARY KEY AUTOINCREMENT,
+		DeploymentName TEXT not null,
+		Type           TEXT not null,
+		VmTemplate     TEXT not null,
+		UNIQUE (DeploymentName, Type)
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	// hardware each node cloned with
+	query = `CREATE TABLE IF NOT EXISTS hardware
+	(
+		hardwareid     INTEGER PRIMARY KEY AUTOINCREMENT,
+		id             INTEGER not null constraint hardware_deployment__fk references deployment,
+		JettisonUuid   TEXT not null,
+		Cpus           INTEGER not null,
+		CoresPerSocket INTEGER not null,
+		MemoryMB       INTEGER not null,
+		DiskGB         INTEGER not null,
+		DataDisksGB    TEXT not null,
+		UNIQUE (id, JettisonUuid)
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	// template snapshot each linked clone created from
+	query = `CREATE TABLE IF NOT EXISTS clones
+	(
+		cloneid      INTEGER PRIMARY KEY AUTOINCREMENT,
+		id           INTEGER not null constraint clones_deployment__fk references deployment,
+		JettisonUuid TEXT not null,
+		VmTemplate   TEXT not null,
+		Snapshot     TEXT not null,
+		UNIQUE (id, JettisonUuid)
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	// nodes attached to a network jettison didn't create, teardown keeps it
+	query = `CREATE TABLE IF NOT EXISTS networks
+	(
+		networkid    INTEGER PRIMARY KEY AUTOINCREMENT,
+		id           INTEGER not null constraint networks_deployment__fk references deployment,
+		JettisonUuid TEXT not null,
+		UNIQUE (id, JettisonUuid)
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	// drs rules created for groups of nodes
+	query = `CREATE TABLE IF NOT EXISTS affinity
+	(
+		ruleid    INTEGER PRIMARY KEY AUTOINCREMENT,
+		id        INTEGER not null constraint affinity_deployment__fk references deployment,
+		RuleName  TEXT not null,
+		Cluster   TEXT not null,
+		Kind      TEXT not null,
+		HostGroup TEXT not null,
+		Vms       TEXT not null,
+		UNIQUE (id, RuleName)
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	// deployments torn down with network objects kept, gc leaves their objects
+	query = `CREATE TABLE IF NOT EXISTS kept
+	(
+		keptid         INTEGER PRIMARY KEY AUTOINCREMENT,
+		DeploymentName TEXT not null UNIQUE,
+		Created        DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	statement, err = db.Prepare(query)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	_, err = statement.Exec()
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
 	return nil
 }
 
@@ -308,6 +493,30 @@ func DeleteDeployment(db *sql.DB, projectName string) error {
 		return errors.Trace(err)
 	}
 
+	err = deleteHardware(db, projectName, "")
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	err = deleteAffinityRules(db, projectName, "")
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	err = deleteLinkedClones(db, projectName, "")
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	err = deleteExistingNetworks(db, projectName, "")
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
 	query = `DELETE FROM deployment WHERE DeploymentName = ?`
 
 	stmt, err = db.Prepare(query)
@@ -327,6 +536,20 @@ func DeleteDeployment(db *sql.DB, projectName string) error {
 		logging.ErrorLogging(err)
 		return errors.Trace(err)
 	}
+
+	// deployment checkpoints
+	err = DeleteSteps(db, projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
+	err = DeleteTemplates(db, projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return errors.Trace(err)
+	}
+
 	return nil
 }
 
@@ -414,9 +637,99 @@ func GetDeploymentNodes(db *sql.DB, depName string) ([]*jettypes.NodeTemplate, b
 		return nodes, false, err
 	}
 
+	existing, err := getExistingNetworks(db, depName)
+	if err != nil {
+		return nodes, false, err
+	}
+	for _, node := range nodes {
+		node.SetExistingNetwork(existing[node.Name])
+	}
+
 	return nodes, true, nil
 }
 
+/**
+  Function deletes a single node of a deployment and releases
+  pod subnet allocated to the node in one transaction.
+*/
+func DeleteNode(db *sql.DB, projectName string, nodeName string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	if len(projectName) == 0 || len(nodeName) == 0 {
+		return fmt.Errorf("empty deployment or node name")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = deleteSubnetAllocation(tx, nodeName)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	err = deleteHardware(tx, projectName, nodeName)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	err = deleteLinkedClones(tx, projectName, nodeName)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	err = deleteExistingNetworks(tx, projectName, nodeName)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	query := `DELETE FROM nodes WHERE JettisonUuid is ? AND id = 
+				(SELECT id FROM deployment WHERE DeploymentName is ?)`
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	r, err := stmt.Exec(nodeName, projectName)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+	if n == 0 {
+		_ = tx.Rollback()
+		return fmt.Errorf("node %s not found in deployment %s", nodeName, projectName)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
 /**
   Function return existing deployment stored in database and number of nodes.
   bool flag set to a true when
@@ -531,6 +844,24 @@ func AddNode(db *sql.DB, node *jettypes.NodeTemplate, depId int) error {
 		return errors.Trace(err)
 	}
 
+	err = setHardware(tx, int64(depId), node)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	err = setLinkedClone(tx, int64(depId), node)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	err = setExistingNetwork(tx, int64(depId), node)
+	if err != nil {
+		_ = tx.Rollback()
+		return errors.Trace(err)
+	}
+
 	// if ok commit
 	err = tx.Commit()
 	if err != nil {
@@ -642,6 +973,24 @@ func CreateDeployment(db *sql.DB, nodes []*jettypes.NodeTemplate, depName string
 			_ = tx.Rollback()
 			return errors.Trace(err)
 		}
+
+		err = setHardware(tx, depId, node)
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.Trace(err)
+		}
+
+		err = setLinkedClone(tx, depId, node)
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.Trace(err)
+		}
+
+		err = setExistingNetwork(tx, depId, node)
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.Trace(err)
+		}
 	}
 
 	// if ok commit
@@ -652,3 +1001,61 @@ func CreateDeployment(db *sql.DB, nodes []*jettypes.NodeTemplate, depName string
 
 	return nil
 }
+
+/**
+  A row in deployment table with number of nodes that belong to a deployment.
+*/
+type DeploymentRecord struct {
+	Id             int    `json:"id" yaml:"id"`
+	DeploymentName string `json:"name" yaml:"name"`
+	NumNodes       int    `json:"nodes" yaml:"nodes"`
+}
+
+/**
+  Function returns all deployments stored in database, deployment
+  that has no nodes reported with zero node count.
+*/
+func GetDeployments(db *sql.DB) ([]DeploymentRecord, error) {
+
+	var deployments []DeploymentRecord
+
+	if db == nil {
+		return deployments, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return deployments, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT deployment.id, deployment.DeploymentName, count(nodes.nodeid) AS numNodes
+		FROM deployment LEFT JOIN nodes ON deployment.id = nodes.id
+		GROUP BY deployment.id ORDER BY deployment.DeploymentName`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return deployments, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var r DeploymentRecord
+		err = rows.Scan(&r.Id, &r.DeploymentName, &r.NumNodes)
+		if err != nil {
+			return deployments, errors.Trace(err)
+		}
+		deployments = append(deployments, r)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return deployments, errors.Trace(err)
+	}
+
+	return deployments, nil
+}
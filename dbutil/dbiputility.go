@@ -147,6 +147,16 @@ func DeleteSubnetAllocation(db *sql.DB, nodeName string) error {
 		return fmt.Errorf("database connector is nil")
 	}
 
+	return deleteSubnetAllocation(db, nodeName)
+}
+
+// preparer implemented by both database and transaction
+type preparer interface {
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+func deleteSubnetAllocation(db preparer, nodeName string) error {
+
 	query := `DELETE FROM podipblock WHERE nodeid = 
 				(SELECT nodeid FROM nodes WHERE JettisonUuid is ?)`
 
@@ -231,3 +241,55 @@ func GetSubnetAllocation(db *sql.DB) ([]SubnetAllocation, bool, error) {
 
 	return models, true, nil
 }
+
+/**
+  Returns pod ip blocks allocated to nodes in a deployment, key is a node name.
+*/
+func GetDeploymentAllocations(db *sql.DB, projectName string) (map[string]string, error) {
+
+	allocations := make(map[string]string)
+
+	if db == nil {
+		return allocations, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return allocations, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT nodes.JettisonUuid, podipblock.ipblock
+				FROM deployment, nodes, podipblock
+			WHERE deployment.DeploymentName = ? AND deployment.id = nodes.id
+				AND podipblock.nodeid = nodes.nodeid`
+
+	rows, err := db.Query(query, projectName)
+	if err != nil {
+		return allocations, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			nodeName = ""
+			ipblock  = ""
+		)
+		err = rows.Scan(&nodeName, &ipblock)
+		if err != nil {
+			return allocations, errors.Trace(err)
+		}
+		allocations[nodeName] = ipblock
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return allocations, errors.Trace(err)
+	}
+
+	return allocations, nil
+}
@@ -0,0 +1,203 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+)
+
+// A single object created by a deployment.
+type JournalEntry struct {
+	Id      int
+	Kind    string
+	Ref     string
+	Data    string
+	Created string
+}
+
+/**
+  Function appends an object created by a deployment to a journal.
+  An object already in a journal is not added second time, so a resumed
+  deployment doesn't record same object twice.
+*/
+func AppendJournal(db *sql.DB, projectName string, kind string, ref string, data string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	if len(projectName) == 0 || len(kind) == 0 {
+		return fmt.Errorf("empty deployment name or kind")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	query := `INSERT INTO journal (DeploymentName, Kind, Ref, Data)
+				SELECT ?, ?, ?, ? WHERE NOT EXISTS
+				(SELECT 1 FROM journal WHERE DeploymentName = ? AND Kind = ? AND Ref = ?)`
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName, kind, ref, data, projectName, kind, ref)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns a journal of a deployment in order entries were added.
+*/
+func GetJournal(db *sql.DB, projectName string) ([]JournalEntry, error) {
+
+	var entries []JournalEntry
+
+	if db == nil {
+		return entries, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return entries, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT entryid, Kind, Ref, Data, Created FROM journal
+				WHERE DeploymentName is ? ORDER BY entryid`
+
+	rows, err := db.Query(query, projectName)
+	if err != nil {
+		return entries, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var e JournalEntry
+		err = rows.Scan(&e.Id, &e.Kind, &e.Ref, &e.Data, &e.Created)
+		if err != nil {
+			return entries, errors.Trace(err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, errors.Trace(rows.Err())
+}
+
+/**
+  Function deletes a single journal entry, entry removed once object undone.
+*/
+func DeleteJournalEntry(db *sql.DB, id int) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	stmt, err := db.Prepare(`DELETE FROM journal WHERE entryid = ?`)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(id)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function deletes entire journal of a deployment.
+*/
+func DeleteJournal(db *sql.DB, projectName string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	stmt, err := db.Prepare(`DELETE FROM journal WHERE DeploymentName = ?`)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns names of deployments that have journal entries, objects of
+  such deployment can still be rolled back.
+*/
+func GetJournalProjects(db *sql.DB) ([]string, error) {
+
+	var projects []string
+
+	if db == nil {
+		return projects, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return projects, fmt.Errorf("failed create tables")
+	}
+
+	rows, err := db.Query(`SELECT DISTINCT DeploymentName FROM journal ORDER BY DeploymentName`)
+	if err != nil {
+		return projects, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return projects, errors.Trace(err)
+		}
+		projects = append(projects, name)
+	}
+
+	return projects, errors.Trace(rows.Err())
+}
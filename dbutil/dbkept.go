@@ -0,0 +1,118 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+)
+
+/**
+  Function marks a deployment torn down with network objects kept, so objects
+  not garbage collected and can be re-used by next deploy.
+*/
+func SetKeptNetwork(db *sql.DB, projectName string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	if len(projectName) == 0 {
+		return fmt.Errorf("empty deployment name")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	stmt, err := db.Prepare(`INSERT OR IGNORE INTO kept (DeploymentName) VALUES (?)`)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns names of deployments torn down with network objects kept.
+*/
+func GetKeptNetworks(db *sql.DB) ([]string, error) {
+
+	var projects []string
+
+	if db == nil {
+		return projects, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return projects, fmt.Errorf("failed create tables")
+	}
+
+	rows, err := db.Query(`SELECT DeploymentName FROM kept ORDER BY DeploymentName`)
+	if err != nil {
+		return projects, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return projects, errors.Trace(err)
+		}
+		projects = append(projects, name)
+	}
+
+	return projects, errors.Trace(rows.Err())
+}
+
+/**
+  Function removes a mark of kept network objects of a deployment.
+*/
+func DeleteKeptNetwork(db *sql.DB, projectName string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	stmt, err := db.Prepare(`DELETE FROM kept WHERE DeploymentName = ?`)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+/**
+  Function records a node attached to a network jettison didn't create,
+  node attached to a segment of a deployment has no record.
+*/
+func setExistingNetwork(db preparer, depId int64, node *jettypes.NodeTemplate) error {
+
+	if !node.IsExistingNetwork() {
+		return nil
+	}
+
+	query := `INSERT OR REPLACE INTO networks (id, JettisonUuid) VALUES (?, ?)`
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(depId, node.Name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function deletes an existing network record of a node, for empty node name
+  records of all nodes of a deployment deleted.
+*/
+func deleteExistingNetworks(db preparer, projectName string, nodeName string) error {
+
+	query := `DELETE FROM networks WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+	args := []interface{}{projectName}
+	if len(nodeName) > 0 {
+		query += ` AND JettisonUuid is ?`
+		args = append(args, nodeName)
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns names of nodes of a deployment attached to a network
+  jettison didn't create.
+*/
+func getExistingNetworks(db *sql.DB, projectName string) (map[string]bool, error) {
+
+	existing := make(map[string]bool)
+
+	if db == nil {
+		return existing, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return existing, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT JettisonUuid FROM networks WHERE id = (SELECT id FROM deployment WHERE DeploymentName is ?)`
+
+	rows, err := db.Query(query, projectName)
+	if err != nil {
+		return existing, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return existing, errors.Trace(err)
+		}
+		existing[name] = true
+	}
+
+	return existing, errors.Trace(rows.Err())
+}
@@ -0,0 +1,137 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+)
+
+const (
+	StepRunning = "running"
+	StepDone    = "done"
+	StepFailed  = "failed"
+)
+
+// A checkpoint of single deployment step.
+type StepRecord struct {
+	Step    string
+	Status  string
+	Inputs  string
+	Updated string
+}
+
+/**
+  Function stores a status of deployment step and inputs required to
+  resume deployment after that step.  Existing record for same step replaced.
+*/
+func SetStepStatus(db *sql.DB, projectName string, step string, status string, inputs string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	if len(projectName) == 0 || len(step) == 0 {
+		return fmt.Errorf("empty deployment or step name")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	query := `INSERT OR REPLACE INTO steps (DeploymentName, Step, Status, Inputs, Updated)
+				VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName, step, status, inputs)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns all steps recorded for a deployment keyed by step name.
+*/
+func GetSteps(db *sql.DB, projectName string) (map[string]StepRecord, error) {
+
+	steps := make(map[string]StepRecord)
+
+	if db == nil {
+		return steps, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return steps, fmt.Errorf("failed create tables")
+	}
+
+	query := `SELECT Step, Status, Inputs, Updated FROM steps WHERE DeploymentName is ?`
+
+	rows, err := db.Query(query, projectName)
+	if err != nil {
+		return steps, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var r StepRecord
+		err = rows.Scan(&r.Step, &r.Status, &r.Inputs, &r.Updated)
+		if err != nil {
+			return steps, errors.Trace(err)
+		}
+		steps[r.Step] = r
+	}
+
+	return steps, errors.Trace(rows.Err())
+}
+
+/**
+  Function deletes all steps recorded for a deployment.
+*/
+func DeleteSteps(db *sql.DB, projectName string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	stmt, err := db.Prepare(`DELETE FROM steps WHERE DeploymentName = ?`)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package dbutil
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/juju/errors"
+	"log"
+)
+
+/**
+  Function stores a vm template a type of node of deployment cloned from.
+  Existing record for same type replaced.
+*/
+func SetTemplate(db *sql.DB, projectName string, nodeType string, vmTemplate string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	if len(projectName) == 0 || len(nodeType) == 0 {
+		return fmt.Errorf("empty deployment name or node type")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	query := `INSERT OR REPLACE INTO templates (DeploymentName, Type, VmTemplate) VALUES (?, ?, ?)`
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName, nodeType, vmTemplate)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
+
+/**
+  Function returns vm templates of a deployment keyed by node type.
+*/
+func GetTemplates(db *sql.DB, projectName string) (map[string]string, error) {
+
+	templates := make(map[string]string)
+
+	if db == nil {
+		return templates, fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return templates, fmt.Errorf("failed create tables")
+	}
+
+	rows, err := db.Query(`SELECT Type, VmTemplate FROM templates WHERE DeploymentName is ?`, projectName)
+	if err != nil {
+		return templates, errors.Trace(err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	for rows.Next() {
+		var nodeType, vmTemplate string
+		err = rows.Scan(&nodeType, &vmTemplate)
+		if err != nil {
+			return templates, errors.Trace(err)
+		}
+		templates[nodeType] = vmTemplate
+	}
+
+	return templates, errors.Trace(rows.Err())
+}
+
+/**
+  Function deletes vm templates recorded for a deployment.
+*/
+func DeleteTemplates(db *sql.DB, projectName string) error {
+
+	if db == nil {
+		return fmt.Errorf("database connector is nil")
+	}
+
+	err := CreateTablesIfNeed(db)
+	if err != nil {
+		return fmt.Errorf("failed create tables")
+	}
+
+	stmt, err := db.Prepare(`DELETE FROM templates WHERE DeploymentName = ?`)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			log.Println("failed to close db smtm", err)
+		}
+	}()
+
+	_, err = stmt.Exec(projectName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return nil
+}
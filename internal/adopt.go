@@ -0,0 +1,232 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Adoption of vms that built outside of jettison. Vms in a folder classified by
+a vSphere tag or a name prefix and written to database and ansible inventory,
+so scale, status and teardown work on them as on deployed nodes.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+)
+
+//
+//  Returns a role of a vm. A vSphere tag named after a node type wins over
+//  a name prefix, false if vm matches neither.
+//
+func classifyVm(vm *jettypes.VmInfo, prefixes map[jettypes.NodeType]string) (jettypes.NodeType, bool) {
+
+	for _, tag := range vm.Tags {
+		t := jettypes.GetNodeType(tag)
+		for _, applyType := range applyTypes {
+			if t == applyType {
+				return t, true
+			}
+		}
+	}
+
+	for _, t := range applyTypes {
+		prefix := prefixes[t]
+		if len(prefix) > 0 && strings.HasPrefix(vm.Name, prefix) {
+			return t, true
+		}
+	}
+
+	return jettypes.Unknown, false
+}
+
+//
+//  Builds a node from a vm, node attached to a first nsx-t segment of a vm.
+//  Dhcp server, router and address of a node set once segment described.
+//  Segment of adopted node created outside of jettison, teardown keeps it.
+//
+func adoptedNode(vm *jettypes.VmInfo, nodeType jettypes.NodeType, folder string) (*jettypes.NodeTemplate, error) {
+
+	switchUuid := ""
+	for _, s := range vm.SwitchUuids {
+		if len(s) > 0 {
+			switchUuid = s
+			break
+		}
+	}
+	if len(switchUuid) == 0 {
+		return nil, fmt.Errorf("vm %s is not attached to nsx-t segment", vm.Name)
+	}
+	if len(vm.Mac) == 0 {
+		return nil, fmt.Errorf("vm %s has no network adapter", vm.Name)
+	}
+
+	node := &jettypes.NodeTemplate{
+		Name:        vm.Name,
+		UUID:        vm.UUID,
+		VimCluster:  vm.Cluster,
+		Type:        nodeType,
+		Mac:         []string{vm.Mac[0]},
+		NetworksRef: vm.Networks,
+	}
+	node.SetVimName(vm.VimName)
+	node.SetFolderPath(folder)
+	node.SetGenericSwitch(jettypes.NewGenericSwitch("", switchUuid, "", ""))
+	node.SetGenericRouter(jettypes.NewGenericRouter("", ""))
+	node.SetExistingNetwork(true)
+
+	return node, nil
+}
+
+//
+//  Adopts vms from a vim folder into a project. Each vm classified by a vSphere
+//  tag or by a prefix of a scenario template of same type, vm that matches
+//  neither is skipped. Node address taken from a dhcp binding, a vm without
+//  a binding keeps address guest reports.
+//
+func (d *Deployer) Adopt(projectName string, folder string) error {
+
+	err := d.adopt(projectName, folder)
+	if err != nil {
+		return d.compensate(projectName, err)
+	}
+
+	err = dbutil.DeleteJournal(d.vim.db, projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+	}
+
+	return nil
+}
+
+func (d *Deployer) adopt(projectName string, folder string) error {
+
+	if d.scenario == nil || d.scenario.DeploymentName != projectName {
+		return fmt.Errorf("project %s is not in configuration", projectName)
+	}
+
+	_, _, ok, err := dbutil.GetDeployment(d.vim.db, projectName)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("project %s already deployed", projectName)
+	}
+
+	prefixes := make(map[jettypes.NodeType]string)
+	for _, t := range applyTypes {
+		if ok, template := d.scenario.Template(t); ok {
+			prefixes[t] = template.Prefix
+		}
+	}
+
+	vms, err := d.vim.DiscoverFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	var nodes []*jettypes.NodeTemplate
+	guestAddr := make(map[string]string)
+	controllers := 0
+	for _, vm := range vms {
+		nodeType, ok := classifyVm(vm, prefixes)
+		if !ok {
+			logging.Notification("Skipping vm", vm.Name, "no role tag or known prefix")
+			continue
+		}
+		node, err := adoptedNode(vm, nodeType, folder)
+		if err != nil {
+			return err
+		}
+		if nodeType == jettypes.ControlType {
+			controllers++
+		}
+		guestAddr[node.Name] = vm.IPv4Addr
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 0 {
+		return fmt.Errorf("folder %s has no vms to adopt", folder)
+	}
+	if controllers == 0 {
+		return fmt.Errorf("folder %s has no controller vm", folder)
+	}
+
+	// each segment described once, nodes of a segment get own copy of switch and router
+	type segment struct {
+		s *jettypes.GenericSwitch
+		r *jettypes.GenericRouter
+	}
+	segments := make(map[string]segment)
+	for _, n := range nodes {
+		seg, ok := segments[n.SwitchUuid()]
+		if !ok {
+			s, r, err := d.vim.DescribeSegment(n.SwitchUuid())
+			if err != nil {
+				return err
+			}
+			seg = segment{s: s, r: r}
+			segments[n.SwitchUuid()] = seg
+		}
+		s, r := *seg.s, *seg.r
+		n.SetGenericSwitch(&s)
+		n.SetGenericRouter(&r)
+	}
+
+	// segment without nsx-t dhcp server has no bindings, guest address used
+	var dhcpNodes []*jettypes.NodeTemplate
+	for _, n := range nodes {
+		if len(n.DhcpServerUuid()) > 0 {
+			dhcpNodes = append(dhcpNodes, n)
+		}
+	}
+	bindings, err := d.vim.DescribeBindings(dhcpNodes)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		addr, ok := bindings[n.Name]
+		if !ok {
+			addr = guestAddr[n.Name]
+			logging.CriticalMessage("vm", n.Name, "has no dhcp binding, using guest address", addr)
+		}
+		if net.ParseIP(addr) == nil {
+			return fmt.Errorf("vm %s has no dhcp binding and no guest address", n.Name)
+		}
+		n.IPv4AddrStr = addr
+		n.IPv4Addr = net.ParseIP(addr)
+	}
+
+	ok, err = d.vim.CreateDeployment(projectName, nodes)
+	if !ok {
+		return err
+	}
+	d.journal(JournalDeployment, projectName, journalData{})
+
+	if ok, err = d.createAnsibleInventory(nodes); !ok {
+		return err
+	}
+
+	logging.Notification("Adopted", strconv.Itoa(len(nodes)), "vms into", projectName)
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+)
+
+func Test_classifyVm(t *testing.T) {
+
+	prefixes := map[jettypes.NodeType]string{
+		jettypes.ControlType: "ctrl",
+		jettypes.WorkerType:  "work",
+	}
+
+	tests := []struct {
+		name   string
+		vm     *jettypes.VmInfo
+		want   jettypes.NodeType
+		wantOk bool
+	}{
+		{
+			name:   "prefix",
+			vm:     &jettypes.VmInfo{Name: "work-1"},
+			want:   jettypes.WorkerType,
+			wantOk: true,
+		},
+		{
+			name:   "tag wins over prefix",
+			vm:     &jettypes.VmInfo{Name: "work-1", Tags: []string{"controller"}},
+			want:   jettypes.ControlType,
+			wantOk: true,
+		},
+		{
+			name:   "tag without prefix",
+			vm:     &jettypes.VmInfo{Name: "edge-1", Tags: []string{"Ingress"}},
+			want:   jettypes.IngressType,
+			wantOk: true,
+		},
+		{
+			name:   "unknown tag falls back to prefix",
+			vm:     &jettypes.VmInfo{Name: "ctrl-1", Tags: []string{"backup", "template"}},
+			want:   jettypes.ControlType,
+			wantOk: true,
+		},
+		{
+			// ingress has no prefix, empty prefix matches nothing
+			name:   "no match",
+			vm:     &jettypes.VmInfo{Name: "db-1"},
+			want:   jettypes.Unknown,
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := classifyVm(tt.vm, prefixes)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("classifyVm() = %v %v, want %v %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_adoptedNode(t *testing.T) {
+	tests := []struct {
+		name       string
+		vm         *jettypes.VmInfo
+		wantSwitch string
+		wantErr    bool
+	}{
+		{
+			name: "first segment",
+			vm: &jettypes.VmInfo{Name: "work-1", UUID: "uuid", VimName: "vm-1", Cluster: "cluster",
+				Mac: []string{"00:50:56:00:00:01", "00:50:56:00:00:02"}, SwitchUuids: []string{"", "switch-1", "switch-2"}},
+			wantSwitch: "switch-1",
+		},
+		{
+			name:    "not attached to a segment",
+			vm:      &jettypes.VmInfo{Name: "work-1", Mac: []string{"00:50:56:00:00:01"}, SwitchUuids: []string{""}},
+			wantErr: true,
+		},
+		{
+			name:    "no network adapter",
+			vm:      &jettypes.VmInfo{Name: "work-1", SwitchUuids: []string{"switch-1"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := adoptedNode(tt.vm, jettypes.WorkerType, "/dc/vm/manual")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("adoptedNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Name != tt.vm.Name || got.UUID != tt.vm.UUID || got.GetVimName() != tt.vm.VimName ||
+				got.VimCluster != tt.vm.Cluster || got.Type != jettypes.WorkerType {
+				t.Errorf("adoptedNode() = %v, want facts of %v", got, tt.vm)
+			}
+			if len(got.Mac) != 1 || got.Mac[0] != tt.vm.Mac[0] {
+				t.Errorf("adoptedNode() mac = %v, want %s", got.Mac, tt.vm.Mac[0])
+			}
+			if got.SwitchUuid() != tt.wantSwitch {
+				t.Errorf("adoptedNode() switch = %s, want %s", got.SwitchUuid(), tt.wantSwitch)
+			}
+			if got.GetFolderPath() != "/dc/vm/manual" {
+				t.Errorf("adoptedNode() folder = %s", got.GetFolderPath())
+			}
+			if !got.IsExistingNetwork() {
+				t.Errorf("adoptedNode() node not marked as attached to existing network")
+			}
+		})
+	}
+}
+
+//
+//  Adopted vms attached to a segment built by hand, teardown deletes vms
+//  and keeps a segment.
+//
+func TestDeployer_AdoptTeardown(t *testing.T) {
+
+	const folder = "/dc/vm/manual"
+
+	tests := []struct {
+		name string
+		dhcp bool
+	}{
+		{
+			name: "segment with dhcp server",
+			dhcp: true,
+		},
+		{
+			name: "segment without dhcp server",
+			dhcp: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, p, teardown := setupDeployer(t,
+				testTemplate(jettypes.ControlType, 1), testTemplate(jettypes.WorkerType, 1))
+			defer teardown()
+
+			switchUuid := p.AddSegment("manual", true, tt.dhcp)
+			vms := []jettypes.VmInfo{
+				{Name: "manual-1", Tags: []string{"controller"}, IPv4Addr: "172.16.81.10"},
+				{Name: "manual-2", Tags: []string{"worker"}, IPv4Addr: "172.16.81.11"},
+			}
+			for _, vm := range vms {
+				vm.Cluster = "cluster"
+				vm.PoweredOn = true
+				vm.SwitchUuids = []string{switchUuid}
+				if err := p.AddVm(folder, vm); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := d.Adopt(testProject, folder); err != nil {
+				t.Fatalf("Adopt() error = %v", err)
+			}
+
+			nodes, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(nodes) != len(vms) {
+				t.Fatalf("Adopt() stored %d nodes, want %d", len(nodes), len(vms))
+			}
+			for _, n := range nodes {
+				if !n.IsExistingNetwork() {
+					t.Errorf("Adopt() node %s not stored as attached to existing network", n.Name)
+				}
+				if n.SwitchUuid() != switchUuid || len(n.RouterUuid()) == 0 {
+					t.Errorf("Adopt() node %s attached to switch %s router %s", n.Name, n.SwitchUuid(), n.RouterUuid())
+				}
+			}
+
+			if err = d.Teardown(testProject, false, true); err != nil {
+				t.Fatalf("Teardown() error = %v", err)
+			}
+
+			s, r, err := p.DescribeSegment(context.Background(), switchUuid)
+			if err != nil {
+				t.Fatalf("Teardown() deleted segment built by hand %v", err)
+			}
+			if len(r.Uuid()) == 0 || (len(s.DhcpUuid()) > 0) != tt.dhcp {
+				t.Errorf("Teardown() deleted router or dhcp server of segment built by hand")
+			}
+
+			infos, err := d.vim.DescribeVms(nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, vm := range infos {
+				if vm.Exists {
+					t.Errorf("Teardown() left vm %s", vm.Name)
+				}
+			}
+		})
+	}
+}
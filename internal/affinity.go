@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+	"github.com/spyroot/jettison/providers"
+)
+
+//
+//  Creates drs rules for groups of nodes, by default controllers spread across
+//  hosts of a cluster. Each rule recorded in a journal and in a database.
+//
+func (d *Deployer) createAffinityRules(projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	rules, err := jettypes.AffinityRules(projectName, nodes)
+	if err != nil {
+		return err
+	}
+	if len(rules) > 0 && !d.vim.Supports(providers.CapAffinityRules) {
+		logging.Notification("vim provider has no drs rules, skipping rules of", projectName)
+		return nil
+	}
+
+	for _, rule := range rules {
+		err = d.vim.CreateAffinityRule(rule)
+		if err != nil {
+			return err
+		}
+		d.journal(JournalAffinityRule, rule.Name, journalData{Rule: rule})
+
+		err = dbutil.AddAffinityRule(d.vim.Database(), projectName, rule)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//
+//  Recomputes drs rules of a project after nodes added or removed. Database
+//  doesn't keep affinity of a node, it taken from a template of a node type.
+//  Rule updated in place, rule that no longer has enough vms deleted.
+//
+func (d *Deployer) updateAffinityRules(projectName string) error {
+
+	if !d.vim.Supports(providers.CapAffinityRules) {
+		return nil
+	}
+
+	nodes, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), projectName)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if ok, template := d.scenario.Template(n.Type); ok {
+			n.Affinity = template.Affinity
+			n.HostGroup = template.HostGroup
+		}
+	}
+
+	rules, err := jettypes.AffinityRules(projectName, nodes)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool)
+	for _, rule := range rules {
+		err = d.vim.CreateAffinityRule(rule)
+		if err != nil {
+			return err
+		}
+		err = dbutil.AddAffinityRule(d.vim.Database(), projectName, rule)
+		if err != nil {
+			return err
+		}
+		current[rule.Name] = true
+	}
+
+	stored, err := dbutil.GetAffinityRules(d.vim.Database(), projectName)
+	if err != nil {
+		return err
+	}
+	for _, rule := range stored {
+		if current[rule.Name] {
+			continue
+		}
+		err = d.vim.DeleteAffinityRule(rule)
+		if err != nil {
+			return err
+		}
+		err = dbutil.DeleteAffinityRule(d.vim.Database(), projectName, rule.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//
+//  Deletes drs rules of a project stored in a database. Rule that failed
+//  is logged, it doesn't stop a teardown.
+//
+func (d *Deployer) deleteAffinityRules(projectName string) {
+
+	rules, err := dbutil.GetAffinityRules(d.vim.Database(), projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return
+	}
+
+	for _, rule := range rules {
+		err = d.vim.DeleteAffinityRule(rule)
+		if err != nil {
+			logging.CriticalMessage("Failed delete drs rule", rule.Name)
+			continue
+		}
+		err = dbutil.DeleteAffinityRule(d.vim.Database(), projectName, rule.Name)
+		if err != nil {
+			logging.ErrorLogging(err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+)
+
+func TestDeployer_updateAffinityRules(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		add     int
+		remove  int
+		wantVms int
+	}{
+		{
+			name:    "worker added",
+			add:     1,
+			wantVms: 4,
+		},
+		{
+			name:    "worker removed",
+			remove:  1,
+			wantVms: 2,
+		},
+		{
+			// anti-affinity of a single vm is not a rule
+			name:    "rule deleted",
+			remove:  2,
+			wantVms: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			worker := testTemplate(jettypes.WorkerType, 3)
+			worker.Affinity = jettypes.AffinityAnti
+			worker.DesiredAddress = "172.16.81.0/24"
+			d, p, teardown := setupDeployer(t, testTemplate(jettypes.ControlType, 1), worker)
+			defer teardown()
+			d.vim.jetConfig.Infra.Cluster.ClusterCidr = "10.200.0.0/16"
+			d.vim.jetConfig.Infra.Cluster.AllocateSize = 24
+
+			nodes := []*jettypes.NodeTemplate{
+				testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+				testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+				testNode("test-worker-2", jettypes.WorkerType, "172.16.81.12"),
+				testNode("test-worker-3", jettypes.WorkerType, "172.16.81.13"),
+			}
+			for _, n := range nodes[1:] {
+				n.Affinity = worker.Affinity
+			}
+			deployNodes(t, d, nodes...)
+			if err := d.createAffinityRules(testProject, nodes); err != nil {
+				t.Fatalf("createAffinityRules() error = %v", err)
+			}
+
+			// new workers stored before ssh to them fails
+			if tt.add > 0 {
+				if err := d.scaleWorkers(testProject, tt.add); err == nil {
+					t.Fatalf("scaleWorkers() expected error of ssh")
+				}
+			}
+			for _, n := range nodes[len(nodes)-tt.remove:] {
+				if err := p.DeleteVm(ctx, testProject, n); err != nil {
+					t.Fatal(err)
+				}
+				if err := d.removeWorker(testProject, nodes[0], n, false); err != nil {
+					t.Fatalf("removeWorker() error = %v", err)
+				}
+			}
+
+			stored, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for _, n := range stored {
+				if n.Type == jettypes.WorkerType {
+					want = append(want, n.GetVimName())
+				}
+			}
+			sort.Strings(want)
+
+			rules := p.AffinityRules()
+			dbRules, err := dbutil.GetAffinityRules(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantVms == 0 {
+				if len(rules) != 0 || len(dbRules) != 0 {
+					t.Errorf("drs rules = %v, stored %v, want none", rules, dbRules)
+				}
+				return
+			}
+			if len(rules) != 1 || len(dbRules) != 1 {
+				t.Fatalf("drs rules = %v, stored %v, want one", rules, dbRules)
+			}
+
+			for _, got := range [][]string{rules[0].Vms, dbRules[0].Vms} {
+				sort.Strings(got)
+				if len(got) != tt.wantVms || !equalKinds(got, want) {
+					t.Errorf("drs rule vms = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,486 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Declarative apply. Node templates and desired counts of a scenario compared
+with nodes stored in database, vms and network objects that vim reports,
+actions required to converge a deployment computed and executed.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+	"github.com/spyroot/jettison/providers"
+)
+
+const (
+	ApplyDeploy  = "deploy"
+	ApplyCreate  = "create"
+	ApplyDelete  = "delete"
+	ApplyReplace = "replace"
+	ApplyPowerOn = "poweron"
+	ApplyBinding = "binding"
+	ApplyManual  = "manual"
+)
+
+/*
+   A single action apply executes, node is empty for a node that is not cloned yet.
+*/
+type ApplyAction struct {
+	Action string `json:"action" yaml:"action"`
+	Node   string `json:"node,omitempty" yaml:"node,omitempty"`
+	Type   string `json:"type" yaml:"type"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// node types apply converges, in order actions reported
+var applyTypes = []jettypes.NodeType{
+	jettypes.ControlType,
+	jettypes.IngressType,
+	jettypes.WorkerType,
+}
+
+//
+//  Computes actions for a single type of node. Worker nodes created, deleted or
+//  replaced, for controller and ingress nodes a change reported as manual action
+//  since control plane can't be changed without redeploy.
+//
+//  Missing segment, router or dhcp server shared with other nodes, so reported as
+//  manual action. Missing or changed dhcp binding recreated. Nil tagged objects or
+//  bindings skip a network check.
+//
+func reconcileType(template *jettypes.NodeTemplate, deployedTemplate string,
+	existing []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+	tagged map[string]bool, bindings map[string]string) []ApplyAction {
+
+	var actions []ApplyAction
+
+	nodeType := template.Type.String()
+	templateChanged := len(deployedTemplate) > 0 && deployedTemplate != template.VmTemplateName
+
+	// nodes that must be replaced first, so surplus taken from them
+	var missing, outdated, current []*jettypes.NodeTemplate
+	for _, n := range existing {
+		vm, ok := vms[n.Name]
+		switch {
+		case ok && !vm.Exists:
+			missing = append(missing, n)
+		case templateChanged:
+			outdated = append(outdated, n)
+		default:
+			current = append(current, n)
+		}
+	}
+
+	if template.Type != jettypes.WorkerType {
+		if len(existing) != template.DesiredCount {
+			actions = append(actions, ApplyAction{Action: ApplyManual, Type: nodeType,
+				Reason: fmt.Sprintf("desired count %d, deployed %d, requires redeploy",
+					template.DesiredCount, len(existing))})
+		}
+		for _, n := range missing {
+			actions = append(actions, ApplyAction{Action: ApplyManual, Node: n.Name, Type: nodeType,
+				Reason: "vm not found in vim, requires redeploy"})
+		}
+		for _, n := range outdated {
+			actions = append(actions, ApplyAction{Action: ApplyManual, Node: n.Name, Type: nodeType,
+				Reason: fmt.Sprintf("template changed from %s to %s, requires redeploy",
+					deployedTemplate, template.VmTemplateName)})
+		}
+	} else {
+		// surplus deleted in order missing, outdated and newest current nodes
+		surplus := len(existing) - template.DesiredCount
+		ordered := make([]*jettypes.NodeTemplate, 0, len(existing))
+		ordered = append(ordered, missing...)
+		ordered = append(ordered, outdated...)
+		ordered = append(ordered, current...)
+		for i, n := range ordered {
+			reason := ""
+			switch {
+			case i < len(missing):
+				reason = "vm not found in vim"
+			case i < len(missing)+len(outdated):
+				reason = fmt.Sprintf("template changed from %s to %s", deployedTemplate, template.VmTemplateName)
+			}
+
+			if surplus > 0 && (len(reason) > 0 || i >= len(ordered)-surplus) {
+				if len(reason) == 0 {
+					reason = "desired count " + strconv.Itoa(template.DesiredCount)
+				}
+				actions = append(actions, ApplyAction{Action: ApplyDelete, Node: n.Name, Type: nodeType, Reason: reason})
+				surplus--
+				continue
+			}
+			if len(reason) > 0 {
+				actions = append(actions, ApplyAction{Action: ApplyReplace, Node: n.Name, Type: nodeType, Reason: reason})
+			}
+		}
+
+		for i := len(existing); i < template.DesiredCount; i++ {
+			actions = append(actions, ApplyAction{Action: ApplyCreate, Type: nodeType,
+				Reason: "desired count " + strconv.Itoa(template.DesiredCount)})
+		}
+	}
+
+	removed := make(map[string]bool)
+	for _, a := range actions {
+		removed[a.Node] = true
+	}
+
+	// vm that exists but powered off just powered on
+	for _, n := range existing {
+		if vm, ok := vms[n.Name]; ok && vm.Exists && !vm.PoweredOn && !removed[n.Name] {
+			actions = append(actions, ApplyAction{Action: ApplyPowerOn, Node: n.Name, Type: nodeType,
+				Reason: "vm powered off"})
+		}
+	}
+
+	for _, n := range existing {
+		if vm, ok := vms[n.Name]; !ok || !vm.Exists || removed[n.Name] {
+			continue
+		}
+		actions = append(actions, reconcileNetwork(n, nodeType, tagged, bindings)...)
+	}
+
+	return actions
+}
+
+//
+//  Computes network actions of a node. Segment adopted node attached to is not
+//  tagged with a project, neither segment nor binding checked.
+//
+func reconcileNetwork(n *jettypes.NodeTemplate, nodeType string,
+	tagged map[string]bool, bindings map[string]string) []ApplyAction {
+
+	var actions []ApplyAction
+
+	if tagged != nil && !n.IsExistingNetwork() {
+		objects := []struct {
+			kind string
+			id   string
+		}{
+			{jettypes.ObjectSwitch, n.SwitchUuid()},
+			{jettypes.ObjectRouter, n.RouterUuid()},
+			{jettypes.ObjectDhcpServer, n.DhcpServerUuid()},
+		}
+		for _, o := range objects {
+			if len(o.id) > 0 && !tagged[o.id] {
+				actions = append(actions, ApplyAction{Action: ApplyManual, Node: n.Name, Type: nodeType,
+					Reason: fmt.Sprintf("%s %s not found, requires redeploy", o.kind, o.id)})
+			}
+		}
+		if len(actions) > 0 {
+			return actions
+		}
+	}
+
+	if bindings == nil || n.Static || n.IsExistingNetwork() || len(n.DhcpServerUuid()) == 0 {
+		return actions
+	}
+
+	addr, ok := bindings[n.Name]
+	switch {
+	case !ok:
+		actions = append(actions, ApplyAction{Action: ApplyBinding, Node: n.Name, Type: nodeType,
+			Reason: "dhcp binding missing"})
+	case addr != n.IPv4AddrStr:
+		actions = append(actions, ApplyAction{Action: ApplyBinding, Node: n.Name, Type: nodeType,
+			Reason: fmt.Sprintf("dhcp binding %s, expected %s", addr, n.IPv4AddrStr)})
+	}
+
+	return actions
+}
+
+//
+//  Compares a scenario with a deployment stored in database and live vms
+//  and returns actions required to converge a deployment.
+//
+func (d *Deployer) ApplyActions(projectName string) ([]ApplyAction, error) {
+
+	var actions []ApplyAction
+
+	if d.scenario == nil || d.scenario.DeploymentName != projectName {
+		return actions, fmt.Errorf("project %s is not in configuration", projectName)
+	}
+
+	db := d.vim.Database()
+	existing, _, err := dbutil.GetDeploymentNodes(db, projectName)
+	if err != nil {
+		return actions, err
+	}
+
+	// nothing deployed yet, entire scenario deployed
+	if len(existing) == 0 {
+		for _, t := range applyTypes {
+			ok, template := d.scenario.Template(t)
+			if !ok {
+				continue
+			}
+			for i := 0; i < template.DesiredCount; i++ {
+				actions = append(actions, ApplyAction{Action: ApplyDeploy, Type: t.String(),
+					Reason: "project not deployed"})
+			}
+		}
+		return actions, nil
+	}
+
+	deployed, err := dbutil.GetTemplates(db, projectName)
+	if err != nil {
+		return actions, err
+	}
+
+	vms, err := d.vim.DescribeVms(existing)
+	if err != nil {
+		return actions, err
+	}
+
+	tagged, bindings, err := d.networkState(projectName, existing, vms)
+	if err != nil {
+		return actions, err
+	}
+
+	for _, t := range applyTypes {
+		ok, template := d.scenario.Template(t)
+		if !ok {
+			continue
+		}
+
+		var nodes []*jettypes.NodeTemplate
+		for _, n := range existing {
+			if n.Type == t {
+				nodes = append(nodes, n)
+			}
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+		actions = append(actions, reconcileType(template, deployed[t.String()], nodes, vms, tagged, bindings)...)
+	}
+
+	return actions, nil
+}
+
+//
+//  Returns ids of network objects tagged with a project and dhcp bindings of nodes
+//  which vm exists. Addressing mode taken from a template, static node has no binding.
+//  Nil returned for a state vim provider can't report.
+//
+func (d *Deployer) networkState(projectName string, existing []*jettypes.NodeTemplate,
+	vms map[string]*jettypes.VmInfo) (map[string]bool, map[string]string, error) {
+
+	var (
+		tagged   map[string]bool
+		bindings map[string]string
+	)
+
+	if d.vim.Supports(providers.CapSegments) {
+		objects, err := d.vim.TaggedObjects(projectName)
+		if err != nil {
+			return nil, nil, err
+		}
+		tagged = make(map[string]bool)
+		for _, o := range objects {
+			tagged[o.Id] = true
+		}
+	}
+
+	if d.vim.Supports(providers.CapDhcpBindings) {
+		var nodes []*jettypes.NodeTemplate
+		for _, n := range existing {
+			if ok, template := d.scenario.Template(n.Type); ok {
+				n.Static = template.Static
+			}
+			if vm, ok := vms[n.Name]; ok && vm.Exists && len(n.DhcpServerUuid()) > 0 {
+				nodes = append(nodes, n)
+			}
+		}
+
+		var err error
+		bindings, err = d.vim.DescribeBindings(boundNodes(nodes))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return tagged, bindings, nil
+}
+
+//
+//  Records a vm template each type of node cloned from, so apply can detect
+//  a template change.
+//
+func (d *Deployer) recordTemplates() error {
+
+	for _, t := range applyTypes {
+		ok, template := d.scenario.Template(t)
+		if !ok {
+			continue
+		}
+		err := dbutil.SetTemplate(d.vim.Database(), d.scenario.DeploymentName, t.String(), template.VmTemplateName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//
+//  Converges a deployment to a scenario. Project that is not deployed yet deployed,
+//  otherwise new workers added before outdated workers removed so a cluster keeps
+//  its capacity. Actions that require redeploy fail apply before anything changed.
+//
+func (d *Deployer) Apply(projectName string, dryRun bool) ([]ApplyAction, error) {
+
+	actions, err := d.ApplyActions(projectName)
+	if err != nil {
+		return actions, err
+	}
+
+	if dryRun || len(actions) == 0 {
+		return actions, nil
+	}
+
+	var (
+		creates  = 0
+		removals []ApplyAction
+		rebinds  []ApplyAction
+	)
+	for _, a := range actions {
+		switch a.Action {
+		case ApplyDeploy:
+			return actions, d.Deploy(false)
+		case ApplyManual:
+			return actions, fmt.Errorf("%s %s: %s", a.Type, a.Node, a.Reason)
+		case ApplyCreate:
+			creates++
+		case ApplyReplace:
+			creates++
+			removals = append(removals, a)
+		case ApplyDelete:
+			removals = append(removals, a)
+		case ApplyBinding:
+			rebinds = append(rebinds, a)
+		}
+	}
+
+	existing, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), projectName)
+	if err != nil {
+		return actions, err
+	}
+
+	byName := make(map[string]*jettypes.NodeTemplate)
+	var controller *jettypes.NodeTemplate
+	for _, n := range existing {
+		byName[n.Name] = n
+		if n.Type == jettypes.ControlType && controller == nil {
+			controller = n
+		}
+	}
+	if controller == nil {
+		return actions, fmt.Errorf("project %s has no controller node", projectName)
+	}
+
+	for _, a := range actions {
+		if a.Action != ApplyPowerOn {
+			continue
+		}
+		_, err = d.vim.ChangePowerState(byName[a.Node], jettypes.PowerOn)
+		if err != nil {
+			return actions, err
+		}
+	}
+
+	// stale binding removed before a binding recreated
+	if len(rebinds) > 0 {
+		var nodes []*jettypes.NodeTemplate
+		for _, a := range rebinds {
+			nodes = append(nodes, byName[a.Node])
+		}
+		owned, err := d.vim.ownedObjects(projectName)
+		if err != nil {
+			return actions, err
+		}
+		err = d.vim.DhcpCleanup(projectName, nodes, owned)
+		if err != nil {
+			return actions, err
+		}
+		err = d.vim.CreateDhcpBindings(projectName, nodes)
+		if err != nil {
+			return actions, err
+		}
+	}
+
+	if creates > 0 {
+		err = d.ScaleWorkers(projectName, creates)
+		if err != nil {
+			return actions, err
+		}
+	}
+
+	vms, err := d.vim.DescribeVms(existing)
+	if err != nil {
+		return actions, err
+	}
+
+	for _, a := range removals {
+		n := byName[a.Node]
+		logging.Notification("Removing worker", n.Name, a.Reason)
+		err = d.removeWorker(projectName, controller, n, vms[n.Name].Exists)
+		if err != nil {
+			return actions, err
+		}
+	}
+
+	err = d.recordTemplates()
+	if err != nil {
+		return actions, err
+	}
+
+	logging.Notification("Project", projectName, "converged,", strconv.Itoa(len(actions)), "actions applied")
+
+	return actions, nil
+}
+
+//
+// Writes actions of apply in requested format.
+//
+func WriteApplyActions(w io.Writer, format string, actions []ApplyAction) error {
+
+	if actions == nil {
+		actions = []ApplyAction{}
+	}
+
+	if done, err := writeEncoded(w, format, actions); done {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ACTION\tNODE\tTYPE\tREASON")
+	for _, a := range actions {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.Action, a.Node, a.Type, a.Reason)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,457 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/fake"
+)
+
+func actionNames(actions []ApplyAction) []string {
+	var names []string
+	for _, a := range actions {
+		names = append(names, a.Action)
+	}
+	return names
+}
+
+// Node attached to switch-1, router-1 and dhcp-1 that vim reports running.
+func reconcileNode(name string, nodeType jettypes.NodeType, ip string) *jettypes.NodeTemplate {
+	n := testNode(name, nodeType, ip)
+	n.SetGenericSwitch(jettypes.NewGenericSwitch("segment", "switch-1", "dhcp-1", "router-1"))
+	n.SetGenericRouter(jettypes.NewGenericRouter("router", "router-1"))
+	return n
+}
+
+func Test_reconcileType(t *testing.T) {
+
+	workers := func() []*jettypes.NodeTemplate {
+		return []*jettypes.NodeTemplate{
+			reconcileNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+			reconcileNode("test-worker-2", jettypes.WorkerType, "172.16.81.12"),
+		}
+	}
+	running := func(nodes []*jettypes.NodeTemplate) map[string]*jettypes.VmInfo {
+		vms := make(map[string]*jettypes.VmInfo)
+		for _, n := range nodes {
+			vms[n.Name] = &jettypes.VmInfo{Name: n.Name, Exists: true, PoweredOn: true}
+		}
+		return vms
+	}
+	allTagged := map[string]bool{"switch-1": true, "router-1": true, "dhcp-1": true}
+	allBound := map[string]string{"test-worker-1": "172.16.81.11", "test-worker-2": "172.16.81.12",
+		"test-controller-1": "172.16.81.10"}
+
+	tests := []struct {
+		name     string
+		nodeType jettypes.NodeType
+		desired  int
+		deployed string
+		existing []*jettypes.NodeTemplate
+		change   func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+			tagged map[string]bool, bindings map[string]string)
+		noNetwork bool
+		want      []string
+		wantNodes []string
+	}{
+		{
+			name:     "converged",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			deployed: "ubuntu",
+			existing: workers(),
+		},
+		{
+			name:     "scale up",
+			nodeType: jettypes.WorkerType,
+			desired:  3,
+			existing: workers(),
+			want:     []string{ApplyCreate},
+		},
+		{
+			name:      "scale down removes newest",
+			nodeType:  jettypes.WorkerType,
+			desired:   1,
+			existing:  workers(),
+			want:      []string{ApplyDelete},
+			wantNodes: []string{"test-worker-2"},
+		},
+		{
+			name:     "deleted vm replaced",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				vms["test-worker-1"].Exists = false
+			},
+			want:      []string{ApplyReplace},
+			wantNodes: []string{"test-worker-1"},
+		},
+		{
+			name:     "deleted vm taken as surplus",
+			nodeType: jettypes.WorkerType,
+			desired:  1,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				vms["test-worker-1"].Exists = false
+				delete(bindings, "test-worker-1")
+			},
+			want:      []string{ApplyDelete},
+			wantNodes: []string{"test-worker-1"},
+		},
+		{
+			name:      "template changed",
+			nodeType:  jettypes.WorkerType,
+			desired:   2,
+			deployed:  "centos",
+			existing:  workers(),
+			want:      []string{ApplyReplace, ApplyReplace},
+			wantNodes: []string{"test-worker-1", "test-worker-2"},
+		},
+		{
+			name:     "powered off",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				vms["test-worker-2"].PoweredOn = false
+			},
+			want:      []string{ApplyPowerOn},
+			wantNodes: []string{"test-worker-2"},
+		},
+		{
+			name:     "controller count changed",
+			nodeType: jettypes.ControlType,
+			desired:  3,
+			existing: []*jettypes.NodeTemplate{reconcileNode("test-controller-1", jettypes.ControlType, "172.16.81.10")},
+			want:     []string{ApplyManual},
+		},
+		{
+			name:     "controller vm deleted",
+			nodeType: jettypes.ControlType,
+			desired:  1,
+			existing: []*jettypes.NodeTemplate{reconcileNode("test-controller-1", jettypes.ControlType, "172.16.81.10")},
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				vms["test-controller-1"].Exists = false
+			},
+			want:      []string{ApplyManual},
+			wantNodes: []string{"test-controller-1"},
+		},
+		{
+			name:     "binding missing",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				delete(bindings, "test-worker-1")
+			},
+			want:      []string{ApplyBinding},
+			wantNodes: []string{"test-worker-1"},
+		},
+		{
+			name:     "binding changed",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				bindings["test-worker-2"] = "172.16.81.99"
+			},
+			want:      []string{ApplyBinding},
+			wantNodes: []string{"test-worker-2"},
+		},
+		{
+			name:     "static nodes have no binding",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				for _, n := range nodes {
+					n.Static = true
+					delete(bindings, n.Name)
+				}
+			},
+		},
+		{
+			// router shared by both workers, no binding recreated on a broken segment
+			name:     "router deleted",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				delete(tagged, "router-1")
+				delete(bindings, "test-worker-1")
+			},
+			want:      []string{ApplyManual, ApplyManual},
+			wantNodes: []string{"test-worker-1", "test-worker-2"},
+		},
+		{
+			name:     "segment and dhcp server deleted",
+			nodeType: jettypes.ControlType,
+			desired:  1,
+			existing: []*jettypes.NodeTemplate{reconcileNode("test-controller-1", jettypes.ControlType, "172.16.81.10")},
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				delete(tagged, "switch-1")
+				delete(tagged, "dhcp-1")
+			},
+			want:      []string{ApplyManual, ApplyManual},
+			wantNodes: []string{"test-controller-1", "test-controller-1"},
+		},
+		{
+			// segment built by hand carries no project tag
+			name:     "adopted node",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				for _, n := range nodes {
+					n.SetExistingNetwork(true)
+				}
+				for id := range tagged {
+					delete(tagged, id)
+				}
+			},
+		},
+		{
+			// binding on a segment built by hand left to its owner
+			name:     "adopted node without binding",
+			nodeType: jettypes.WorkerType,
+			desired:  2,
+			existing: workers(),
+			change: func(nodes []*jettypes.NodeTemplate, vms map[string]*jettypes.VmInfo,
+				tagged map[string]bool, bindings map[string]string) {
+				for _, n := range nodes {
+					n.SetExistingNetwork(true)
+					delete(bindings, n.Name)
+				}
+			},
+		},
+		{
+			name:      "network not reported",
+			nodeType:  jettypes.WorkerType,
+			desired:   2,
+			existing:  workers(),
+			noNetwork: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			template := testTemplate(tt.nodeType, tt.desired)
+			vms := running(tt.existing)
+			tagged := make(map[string]bool)
+			for k, v := range allTagged {
+				tagged[k] = v
+			}
+			bindings := make(map[string]string)
+			for k, v := range allBound {
+				bindings[k] = v
+			}
+			if tt.change != nil {
+				tt.change(tt.existing, vms, tagged, bindings)
+			}
+			if tt.noNetwork {
+				tagged, bindings = nil, nil
+			}
+
+			got := reconcileType(template, tt.deployed, tt.existing, vms, tagged, bindings)
+			if !equalKinds(actionNames(got), tt.want) {
+				t.Fatalf("reconcileType() = %v, want %v", got, tt.want)
+			}
+			if tt.wantNodes == nil {
+				return
+			}
+			for i, a := range got {
+				if a.Node != tt.wantNodes[i] || a.Type != tt.nodeType.String() || len(a.Reason) == 0 {
+					t.Errorf("reconcileType() action %d = %+v, want node %s", i, a, tt.wantNodes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDeployer_Apply(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		deployed    bool
+		controllers int
+		workers     int
+		dryRun      bool
+		change      func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error
+		want        []string
+		wantErr     bool
+		wantNodes   int
+		wantRunning int
+		// dhcp bindings left, checked if set
+		wantBound int
+	}{
+		{
+			name:        "project not deployed",
+			controllers: 1,
+			workers:     2,
+			dryRun:      true,
+			want:        []string{ApplyDeploy, ApplyDeploy, ApplyDeploy},
+		},
+		{
+			name:        "converged",
+			deployed:    true,
+			controllers: 1,
+			workers:     2,
+			wantNodes:   3,
+			wantRunning: 3,
+		},
+		{
+			name:        "worker powered off",
+			deployed:    true,
+			controllers: 1,
+			workers:     2,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				_, err := p.ChangePowerState(ctx, nodes[2], jettypes.PowerOff)
+				return err
+			},
+			want:        []string{ApplyPowerOn},
+			wantNodes:   3,
+			wantRunning: 3,
+		},
+		{
+			// drain fails since vm is gone, worker still removed
+			name:        "deleted worker removed",
+			deployed:    true,
+			controllers: 1,
+			workers:     1,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				return p.DeleteVm(ctx, testProject, nodes[2])
+			},
+			want:        []string{ApplyDelete},
+			wantNodes:   2,
+			wantRunning: 2,
+		},
+		{
+			name:        "deleted worker replaced dry run",
+			deployed:    true,
+			controllers: 1,
+			workers:     2,
+			dryRun:      true,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				return p.DeleteVm(ctx, testProject, nodes[2])
+			},
+			want:        []string{ApplyReplace},
+			wantNodes:   3,
+			wantRunning: 2,
+		},
+		{
+			name:        "binding deleted",
+			deployed:    true,
+			controllers: 1,
+			workers:     2,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				return p.DhcpCleanup(ctx, testProject, nodes[1:2])
+			},
+			want:        []string{ApplyBinding},
+			wantNodes:   3,
+			wantRunning: 3,
+			wantBound:   3,
+		},
+		{
+			name:        "router deleted",
+			deployed:    true,
+			controllers: 1,
+			workers:     2,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				_, err := p.DeleteRouter(ctx, nodes[0])
+				return err
+			},
+			want:        []string{ApplyManual, ApplyManual, ApplyManual},
+			wantErr:     true,
+			wantNodes:   3,
+			wantRunning: 3,
+			wantBound:   3,
+		},
+		{
+			name:        "controller count changed",
+			deployed:    true,
+			controllers: 2,
+			workers:     2,
+			want:        []string{ApplyManual},
+			wantErr:     true,
+			wantNodes:   3,
+			wantRunning: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, p, teardown := setupDeployer(t,
+				testTemplate(jettypes.ControlType, tt.controllers), testTemplate(jettypes.WorkerType, tt.workers))
+			defer teardown()
+
+			nodes := []*jettypes.NodeTemplate{
+				testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+				testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+				testNode("test-worker-2", jettypes.WorkerType, "172.16.81.12"),
+			}
+			if tt.deployed {
+				deployNodes(t, d, nodes...)
+			}
+			if tt.change != nil {
+				if err := tt.change(p, nodes); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := d.Apply(testProject, tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !equalKinds(actionNames(got), tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+
+			stored, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != tt.wantNodes {
+				t.Errorf("Apply() left %d nodes in database, want %d", len(stored), tt.wantNodes)
+			}
+
+			vms, err := d.vim.DescribeVms(nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			running := 0
+			for _, vm := range vms {
+				if vm.Exists && vm.PoweredOn {
+					running++
+				}
+			}
+			if running != tt.wantRunning {
+				t.Errorf("Apply() left %d vms running, want %d", running, tt.wantRunning)
+			}
+
+			if tt.wantBound > 0 {
+				bindings, err := d.vim.DescribeBindings(stored)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(bindings) != tt.wantBound {
+					t.Errorf("Apply() left %d dhcp bindings, want %d", len(bindings), tt.wantBound)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Deployment checkpoints. Each deployment step stored in database with a status
+and a snapshot of segments and nodes, so a failed deployment can be resumed
+from a first step that didn't complete.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+)
+
+const (
+	StepSegments  = "segments"
+	StepClone     = "clone"
+	StepDhcp      = "dhcp"
+	StepDatabase  = "database"
+	StepAffinity  = "affinity"
+	StepPowerOn   = "poweron"
+	StepIpAddress = "ipaddress"
+	StepSshKeys   = "sshkeys"
+	StepPodNet    = "podnetwork"
+	StepInventory = "inventory"
+	StepCerts     = "certs"
+	StepPlaybook  = "playbook"
+)
+
+// deployment steps in order they executed
+var deploySteps = []string{
+	StepSegments,
+	StepClone,
+	StepDhcp,
+	StepDatabase,
+	StepAffinity,
+	StepPowerOn,
+	StepIpAddress,
+	StepSshKeys,
+	StepPodNet,
+	StepInventory,
+	StepCerts,
+	StepPlaybook,
+}
+
+// network objects created for a segment, segment is a network of the segment
+type segmentCheckpoint struct {
+	Segment        string `json:"segment"`
+	SwitchName     string `json:"switchName"`
+	SwitchUuid     string `json:"switchUuid"`
+	DhcpUuid       string `json:"dhcpUuid"`
+	RouterPortUuid string `json:"routerPortUuid"`
+	RouterName     string `json:"routerName"`
+	RouterUuid     string `json:"routerUuid"`
+	SwitchPortUuid string `json:"switchPortUuid"`
+}
+
+// node facts, group is a key of node group in a scenario
+type nodeCheckpoint struct {
+	Group      string   `json:"group"`
+	Name       string   `json:"name"`
+	IPv4Addr   string   `json:"ipv4Address"`
+	UUID       string   `json:"uuid"`
+	VimName    string   `json:"vimName"`
+	VimCluster string   `json:"vimCluster"`
+	FolderPath string   `json:"folderPath"`
+	Mac        []string `json:"mac"`
+	SwitchName string   `json:"switchName"`
+	SwitchUuid string   `json:"switchUuid"`
+	DhcpUuid   string   `json:"dhcpUuid"`
+	RouterName string   `json:"routerName"`
+	RouterUuid string   `json:"routerUuid"`
+	PodCidr    string   `json:"podCidr,omitempty"`
+	PodSize    int      `json:"podSize,omitempty"`
+}
+
+// inputs stored with each step
+type stepInputs struct {
+	Segments []segmentCheckpoint `json:"segments,omitempty"`
+	Nodes    []nodeCheckpoint    `json:"nodes,omitempty"`
+}
+
+//
+//  Takes a snapshot of segments and nodes of a scenario.
+//
+func (d *Deployer) snapshot() (string, error) {
+
+	var inputs stepInputs
+
+	if d.networkSegments != nil {
+		for key, seg := range d.networkSegments.Segments() {
+			templates := seg.Segments()
+			if len(templates) == 0 || templates[0].GenericSwitch() == nil || templates[0].GenericRouter() == nil {
+				continue
+			}
+			s := templates[0].GenericSwitch()
+			r := templates[0].GenericRouter()
+			inputs.Segments = append(inputs.Segments, segmentCheckpoint{
+				Segment:        key,
+				SwitchName:     s.Name(),
+				SwitchUuid:     s.Uuid(),
+				DhcpUuid:       s.DhcpUuid(),
+				RouterPortUuid: s.RouterPortUuid(),
+				RouterName:     r.Name(),
+				RouterUuid:     r.Uuid(),
+				SwitchPortUuid: r.SwitchPortUuid(),
+			})
+		}
+	}
+
+	for k, group := range d.scenario.nodesGroup {
+		for _, n := range group {
+			inputs.Nodes = append(inputs.Nodes, *newNodeCheckpoint(k, n))
+		}
+	}
+
+	b, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+//
+//  Restores network objects of each segment to templates that share a segment.
+//
+func (d *Deployer) restoreSegments(segments []segmentCheckpoint) {
+
+	for _, c := range segments {
+		seg, ok := d.networkSegments.Segments()[c.Segment]
+		if !ok {
+			logging.CriticalMessage("checkpoint segment", c.Segment, "not in scenario")
+			continue
+		}
+
+		s := jettypes.NewGenericSwitch(c.SwitchName, c.SwitchUuid, c.DhcpUuid, c.RouterUuid)
+		s.SetRouterPortUuid(c.RouterPortUuid)
+		r := jettypes.NewGenericRouter(c.RouterName, c.RouterUuid)
+		r.SetSwitchPortUuid(c.SwitchPortUuid)
+
+		for _, v := range seg.Segments() {
+			v.SetGenericSwitch(s)
+			v.SetGenericRouter(r)
+		}
+	}
+}
+
+//
+//  Replaces nodes generated for a scenario with nodes from a checkpoint,
+//  so names and addresses stay same as in first run.
+//
+func (d *Deployer) restoreNodes(nodes []nodeCheckpoint) error {
+
+	groups := make(map[string][]*jettypes.NodeTemplate)
+
+	for _, c := range nodes {
+		template, ok := d.scenario.nodeTemplates[c.Group]
+		if !ok {
+			return fmt.Errorf("checkpoint node %s refers unknown group %s", c.Name, c.Group)
+		}
+
+		n := template.Clone()
+		c.apply(n)
+
+		groups[c.Group] = append(groups[c.Group], n)
+	}
+
+	for k := range d.scenario.nodesGroup {
+		if _, ok := groups[k]; !ok {
+			return fmt.Errorf("checkpoint has no nodes for group %s, scenario changed", k)
+		}
+	}
+
+	d.scenario.nodesGroup = groups
+
+	return nil
+}
+
+//
+//  Loads steps of a deployment and restores a state recorded in a last checkpoint.
+//
+func (d *Deployer) restoreCheckpoint() error {
+
+	steps, err := dbutil.GetSteps(d.vim.Database(), d.scenario.DeploymentName)
+	if err != nil {
+		return err
+	}
+
+	if len(steps) == 0 {
+		return fmt.Errorf("project %s has no checkpoints to resume from", d.scenario.DeploymentName)
+	}
+
+	d.steps = steps
+
+	// most recent snapshot, a step that failed still holds nodes with names
+	// that might already exist in vim.
+	var last *stepInputs
+	for _, step := range deploySteps {
+		record, ok := steps[step]
+		if !ok {
+			continue
+		}
+		var inputs stepInputs
+		err := json.Unmarshal([]byte(record.Inputs), &inputs)
+		if err != nil {
+			return fmt.Errorf("failed parse checkpoint %s %v", step, err)
+		}
+		last = &inputs
+	}
+
+	if last == nil {
+		return nil
+	}
+
+	d.restoreSegments(last.Segments)
+	if len(last.Nodes) > 0 {
+		return d.restoreNodes(last.Nodes)
+	}
+
+	return nil
+}
+
+//
+//  Runs a single deployment step and records its status. On resume a completed step skipped.
+//
+func (d *Deployer) runStep(step string, fn func() error) error {
+
+	db := d.vim.Database()
+	project := d.scenario.DeploymentName
+
+	if record, ok := d.steps[step]; ok && record.Status == dbutil.StepDone {
+		logging.Notification("Skipping completed step", step)
+		return nil
+	}
+
+	if d.vim.Interrupted() {
+		return fmt.Errorf("deployment interrupted before step %s", step)
+	}
+
+	inputs, err := d.snapshot()
+	if err != nil {
+		return err
+	}
+
+	err = dbutil.SetStepStatus(db, project, step, dbutil.StepRunning, inputs)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if err != nil {
+		inputs, _ = d.snapshot()
+		if serr := dbutil.SetStepStatus(db, project, step, dbutil.StepFailed, inputs); serr != nil {
+			logging.ErrorLogging(serr)
+		}
+		if m, ok := err.(*jettypes.MultiError); ok {
+			for _, r := range m.Failed {
+				logging.CriticalMessage("Step", step, r.String())
+			}
+		}
+		logging.CriticalMessage("Step", step, "failed. Resume with deploy --resume")
+		return fmt.Errorf("step %s failed: %v", step, err)
+	}
+
+	inputs, err = d.snapshot()
+	if err != nil {
+		return err
+	}
+
+	return dbutil.SetStepStatus(db, project, step, dbutil.StepDone, inputs)
+}
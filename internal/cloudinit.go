@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+)
+
+// meta data of a node that has no own meta data template
+const defaultMetaData = `instance-id: {{ .Name }}
+local-hostname: {{ .Hostname }}
+`
+
+/**
+  Returns facts of a node cloud-init templates rendered with. Pod cidr taken
+  only if a pod network of a node reserved before clone.
+*/
+func (p *Vim) cloudInitFacts(projectName string, node *jettypes.NodeTemplate) jettypes.CloudInitFacts {
+
+	facts := jettypes.CloudInitFacts{
+		Project:    projectName,
+		Name:       node.Name,
+		Hostname:   strings.Replace(strings.TrimSuffix(node.Name, "."+node.DomainSuffix), ".", "-", -1),
+		Domain:     node.DomainSuffix,
+		Role:       node.GetNodeTypeAsString(),
+		IPv4Addr:   node.IPv4AddrStr,
+		Gateway:    node.Gateway,
+		DnsServers: node.DnsServers,
+	}
+	if len(node.GetCidr()) > 0 {
+		facts.PodCidr = fmt.Sprintf("%s/%d", node.GetCidr(), node.GetAllocation())
+	}
+
+	if p.jetConfig == nil {
+		return facts
+	}
+	if len(facts.DnsServers) == 0 {
+		facts.DnsServers = p.jetConfig.GetDnsServers()
+	}
+
+	sshDefaults := p.jetConfig.GetSshDefault()
+	facts.SshUsername = sshDefaults.Username()
+	if len(sshDefaults.PublicKey()) > 0 {
+		key, err := ioutil.ReadFile(sshDefaults.PublicKey())
+		if err != nil {
+			logging.CriticalMessage("failed read ssh public key", sshDefaults.PublicKey(), err.Error())
+		} else {
+			facts.SshPublicKey = strings.TrimSpace(string(key))
+		}
+	}
+
+	return facts
+}
+
+// Renders a cloud-init template over facts of a node.
+func renderCloudInit(name string, text string, facts jettypes.CloudInitFacts) (string, error) {
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed parse %s %v", name, err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, facts)
+	if err != nil {
+		return "", fmt.Errorf("failed render %s for node %s %v", name, facts.Name, err)
+	}
+
+	return buf.String(), nil
+}
+
+/**
+  Renders user data and meta data of a node that has cloud-init section,
+  rendered data injected by a plugin during a clone.
+*/
+func (p *Vim) renderNodeCloudInit(projectName string, node *jettypes.NodeTemplate) error {
+
+	ci := node.CloudInit
+	if ci == nil {
+		return nil
+	}
+
+	if len(ci.UserDataFile) > 0 && len(ci.UserData) > 0 {
+		return fmt.Errorf("node %s sets both userDataFile and userData", node.Name)
+	}
+
+	userData := ci.UserData
+	if len(ci.UserDataFile) > 0 {
+		data, err := ioutil.ReadFile(ci.UserDataFile)
+		if err != nil {
+			return fmt.Errorf("failed read cloud-init user data %v", err)
+		}
+		userData = string(data)
+	}
+
+	metaData := ci.MetaData
+	if len(metaData) == 0 {
+		metaData = defaultMetaData
+	}
+
+	facts := p.cloudInitFacts(projectName, node)
+	renderedUser, err := renderCloudInit("userData", userData, facts)
+	if err != nil {
+		return err
+	}
+	renderedMeta, err := renderCloudInit("metaData", metaData, facts)
+	if err != nil {
+		return err
+	}
+
+	node.SetCloudInitData(renderedUser, renderedMeta)
+	return nil
+}
@@ -0,0 +1,386 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spyroot/jettison/ansibleutil"
+	"github.com/spyroot/jettison/certsutil"
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/fake"
+	"github.com/spyroot/jettison/sshclient"
+)
+
+// commands a deployer ran in a test, nothing executed
+type commands struct {
+	lock      sync.Mutex
+	ssh       []string
+	playbooks [][]string
+}
+
+/**
+  Replaces ssh, ansible and cfssl a deployer runs with stubs that succeed and
+  record each command. Returned func restores real commands.
+*/
+func stubCommands() (*commands, func()) {
+
+	c := &commands{}
+
+	savedCopyId, savedRemote, savedPing := sshCopyId, runRemoteCommand, ansiblePing
+	savedAnsible, savedTenant, savedWorker := runAnsible, generateTenantCerts, generateWorkerCerts
+
+	sshCopyId = func(ssh sshclient.SshEnvironments, host string) error {
+		return nil
+	}
+	runRemoteCommand = func(ssh sshclient.SshEnvironments, host string, cmd string) (string, error) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.ssh = append(c.ssh, host+" "+cmd)
+		if cmd == ReadCheckCmd {
+			return RespondOutput, nil
+		}
+		return "", nil
+	}
+	ansiblePing = func(cmd ansibleutil.AnsibleCommand) (string, error) {
+		return "", nil
+	}
+	runAnsible = func(cmd ansibleutil.AnsibleCommand) (string, error) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.playbooks = append(c.playbooks, cmd.CMD)
+		return "", nil
+	}
+	generateTenantCerts = func(clients []certsutil.CertClient, path, tenant, serviceCidr string) (map[string]string, error) {
+		return map[string]string{"ca": "cert"}, nil
+	}
+	generateWorkerCerts = func(clients []certsutil.CertClient, path, tenant string) (map[string]string, error) {
+		return map[string]string{"worker": "cert"}, nil
+	}
+
+	return c, func() {
+		sshCopyId, runRemoteCommand, ansiblePing = savedCopyId, savedRemote, savedPing
+		runAnsible, generateTenantCerts, generateWorkerCerts = savedAnsible, savedTenant, savedWorker
+	}
+}
+
+/**
+  Creates a deployer with a scenario of a controller and two workers that share
+  a segment, same as CreateScenario builds from config. Commands stubbed.
+*/
+func setupScenario(t *testing.T) (*Deployer, *fake.FakeVim, *commands, func()) {
+
+	_, network, _ := net.ParseCIDR("172.16.81.0/24")
+
+	controller := testTemplate(jettypes.ControlType, 1)
+	worker := testTemplate(jettypes.WorkerType, 2)
+	for _, v := range []*jettypes.NodeTemplate{controller, worker} {
+		v.IPv4Net = network
+		v.Gateway = "172.16.81.1"
+		v.SetTemplate(true)
+	}
+
+	d, p, teardown := setupDeployer(t, controller, worker)
+	d.vim.jetConfig.Infra.Cluster.ClusterCidr = "10.200.0.0/16"
+	d.vim.jetConfig.Infra.Cluster.AllocateSize = 24
+
+	d.scenario.nodesGroup[controller.Type.String()] = []*jettypes.NodeTemplate{
+		testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+	}
+	d.scenario.nodesGroup[worker.Type.String()] = []*jettypes.NodeTemplate{
+		testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+		testNode("test-worker-2", jettypes.WorkerType, "172.16.81.12"),
+	}
+
+	c, restore := stubCommands()
+
+	return d, p, c, func() {
+		restore()
+		teardown()
+	}
+}
+
+var scenarioNodes = []string{"test-controller-1", "test-worker-1", "test-worker-2"}
+
+// Checks that nodes of a scenario stored in database, bound, in inventory and every step done.
+func checkDeployed(t *testing.T, d *Deployer, p *fake.FakeVim) {
+
+	db := d.vim.Database()
+
+	nodes, _, err := dbutil.GetDeploymentNodes(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != len(scenarioNodes) {
+		t.Fatalf("Deploy() stored %d nodes, want %d", len(nodes), len(scenarioNodes))
+	}
+
+	inventory, err := ansibleutil.CreateFromInventory(d.vim.jetConfig.GetAnsible().AnsibleInventory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range nodes {
+		if !contains(scenarioNodes, n.Name) {
+			t.Errorf("Deploy() stored unexpected node %s", n.Name)
+		}
+		if len(n.SwitchUuid()) == 0 || len(n.RouterUuid()) == 0 || len(n.DhcpServerUuid()) == 0 {
+			t.Errorf("Deploy() stored node %s without network objects", n.Name)
+		}
+		ip, ok, err := p.DescribeBinding(context.Background(), n)
+		if err != nil || !ok || ip != n.IPv4AddrStr {
+			t.Errorf("DescribeBinding(%s) = %s %v %v, want %s", n.Name, ip, ok, err, n.IPv4AddrStr)
+		}
+		if _, ok := inventory.FindSaveHost(n.Name); !ok {
+			t.Errorf("Deploy() didn't add %s to ansible inventory", n.Name)
+		}
+	}
+
+	steps, err := dbutil.GetSteps(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range deploySteps {
+		if steps[step].Status != dbutil.StepDone {
+			t.Errorf("step %s status = %q, want %s", step, steps[step].Status, dbutil.StepDone)
+		}
+	}
+
+	// deployment completed, nothing to roll back
+	entries, err := dbutil.GetJournal(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Deploy() left %d journal entries", len(entries))
+	}
+}
+
+func TestDeployer_Deploy(t *testing.T) {
+
+	d, p, c, teardown := setupScenario(t)
+	defer teardown()
+
+	if err := d.Deploy(false); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	checkDeployed(t, d, p)
+
+	if countCalls(p, "DeploySegment") != 1 {
+		t.Errorf("Deploy() deployed %d segments, want 1", countCalls(p, "DeploySegment"))
+	}
+	if countCalls(p, "CloneVms") != len(scenarioNodes) {
+		t.Errorf("Deploy() cloned %d vms, want %d", countCalls(p, "CloneVms"), len(scenarioNodes))
+	}
+
+	// each node pinged over ssh and playbook of a project run once
+	for _, name := range []string{"172.16.81.10", "172.16.81.11", "172.16.81.12"} {
+		if !contains(c.ssh, name+" "+ReadCheckCmd) {
+			t.Errorf("Deploy() didn't check ssh of %s", name)
+		}
+	}
+	if len(c.playbooks) != 1 || !contains(c.playbooks[0], testProject+".yml") {
+		t.Errorf("Deploy() ran playbooks %v, want %s.yml", c.playbooks, testProject)
+	}
+}
+
+/**
+  Deployment that fails with cleanupOnFailure set replays a journal, every
+  vm and network object it created and its steps deleted.
+*/
+func TestDeployer_DeployCompensation(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		fault    fake.Fault
+		wantStep string
+	}{
+		{
+			name:     "clone failed",
+			fault:    fake.Fault{Method: "CloneVms", Node: "test-worker-2", Err: errInjected, Times: 1},
+			wantStep: StepClone,
+		},
+		{
+			name:     "ip address failed",
+			fault:    fake.Fault{Method: "AcquireIpAddress", Node: "test-worker-1", Err: errInjected, Times: 1},
+			wantStep: StepIpAddress,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, p, _, teardown := setupScenario(t)
+			defer teardown()
+			d.vim.jetConfig.Infra.CleanupOnFailure = true
+
+			p.InjectFault(tt.fault)
+			err := d.Deploy(false)
+			if err == nil {
+				t.Fatalf("Deploy() expected error")
+			}
+			if !strings.Contains(err.Error(), "step "+tt.wantStep+" failed") {
+				t.Errorf("Deploy() error = %v, want step %s failed", err, tt.wantStep)
+			}
+
+			ctx := context.Background()
+			for _, name := range scenarioNodes {
+				info, err := p.DescribeVm(ctx, &jettypes.NodeTemplate{Name: name})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Exists {
+					t.Errorf("Deploy() left vm %s after compensation", name)
+				}
+			}
+			objects, err := p.TaggedObjects(ctx, testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != 0 {
+				t.Errorf("Deploy() left %d network objects after compensation %v", len(objects), objects)
+			}
+
+			db := d.vim.Database()
+			_, _, ok, err := dbutil.GetDeployment(db, testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				t.Errorf("Deploy() left deployment in database after compensation")
+			}
+			entries, err := dbutil.GetJournal(db, testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("Deploy() left %d journal entries after compensation", len(entries))
+			}
+			steps, err := dbutil.GetSteps(db, testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// rolled back deployment has nothing to resume
+			if len(steps) != 0 {
+				t.Errorf("Deploy() left %d steps after compensation", len(steps))
+			}
+		})
+	}
+}
+
+/**
+  Pod network of each worker reserved before clone, so checkpoint of clone
+  step holds it, and stored in database once worker nodes stored.
+*/
+func TestDeployer_DeployPodNetwork(t *testing.T) {
+
+	d, _, _, teardown := setupScenario(t)
+	defer teardown()
+
+	if err := d.Deploy(false); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	db := d.vim.Database()
+	steps, err := dbutil.GetSteps(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs stepInputs
+	if err := json.Unmarshal([]byte(steps[StepClone].Inputs), &inputs); err != nil {
+		t.Fatal(err)
+	}
+	cloned := make(map[string]string)
+	for _, c := range inputs.Nodes {
+		cloned[c.Name] = c.PodCidr
+	}
+
+	allocations, err := dbutil.GetDeploymentAllocations(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, clusterCidr, _ := net.ParseCIDR("10.200.0.0/16")
+	seen := make(map[string]bool)
+	for _, n := range d.nodeSlice() {
+		podNetwork, ok := allocations[n.GetUuidName()]
+		if n.Type != jettypes.WorkerType {
+			if ok || len(cloned[n.Name]) > 0 {
+				t.Errorf("Deploy() allocated pod network %s to %s", podNetwork, n.Name)
+			}
+			continue
+		}
+		if len(cloned[n.Name]) == 0 {
+			t.Errorf("Deploy() cloned %s before pod network reserved", n.Name)
+		}
+		ip, _, err := net.ParseCIDR(podNetwork)
+		if !ok || err != nil || !clusterCidr.Contains(ip) || !strings.HasSuffix(podNetwork, "/24") {
+			t.Errorf("Deploy() stored pod network %q of %s, want /24 in %s", podNetwork, n.Name, clusterCidr)
+		}
+		if !strings.HasPrefix(podNetwork, cloned[n.Name]+"/") {
+			t.Errorf("Deploy() stored pod network %s of %s, reserved %s", podNetwork, n.Name, cloned[n.Name])
+		}
+		if seen[podNetwork] {
+			t.Errorf("Deploy() allocated pod network %s twice", podNetwork)
+		}
+		seen[podNetwork] = true
+	}
+}
+
+/**
+  Deployment that failed at power on resumed, steps done in a first run
+  skipped and nodes restored from a checkpoint, not generated again.
+*/
+func TestDeployer_DeployResume(t *testing.T) {
+
+	d, p, _, teardown := setupScenario(t)
+	defer teardown()
+
+	p.InjectFault(fake.Fault{Method: "ChangePowerState", Node: "test-worker-1", Err: errInjected, Times: 1})
+	err := d.Deploy(false)
+	if err == nil || !strings.Contains(err.Error(), "step "+StepPowerOn+" failed") {
+		t.Fatalf("Deploy() error = %v, want step %s failed", err, StepPowerOn)
+	}
+
+	steps, err := dbutil.GetSteps(d.vim.Database(), testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps[StepPowerOn].Status != dbutil.StepFailed {
+		t.Errorf("step %s status = %q, want %s", StepPowerOn, steps[StepPowerOn].Status, dbutil.StepFailed)
+	}
+
+	// a new run generates other names, resume must use nodes it cloned
+	d.scenario.nodesGroup[jettypes.WorkerType.String()] = []*jettypes.NodeTemplate{
+		testNode("test-worker-3", jettypes.WorkerType, "172.16.81.13"),
+		testNode("test-worker-4", jettypes.WorkerType, "172.16.81.14"),
+	}
+
+	segments, clones := countCalls(p, "DeploySegment"), countCalls(p, "CloneVms")
+	if err := d.Deploy(true); err != nil {
+		t.Fatalf("Deploy(true) error = %v", err)
+	}
+
+	if n := countCalls(p, "DeploySegment"); n != segments {
+		t.Errorf("Deploy(true) deployed %d segments, want step %s skipped", n-segments, StepSegments)
+	}
+	if n := countCalls(p, "CloneVms"); n != clones {
+		t.Errorf("Deploy(true) cloned %d vms, want step %s skipped", n-clones, StepClone)
+	}
+
+	for _, name := range []string{"test-worker-3", "test-worker-4"} {
+		info, err := p.DescribeVm(context.Background(), &jettypes.NodeTemplate{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Exists {
+			t.Errorf("Deploy(true) cloned %s, nodes not restored from checkpoint", name)
+		}
+	}
+
+	checkDeployed(t, d, p)
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/spyroot/jettison/ansibleutil"
@@ -18,6 +20,7 @@ import (
 	"github.com/spyroot/jettison/logging"
 	"github.com/spyroot/jettison/netpool"
 	"github.com/spyroot/jettison/osutil"
+	"github.com/spyroot/jettison/providers"
 	"github.com/spyroot/jettison/sshclient"
 )
 
@@ -26,6 +29,9 @@ type Deployer struct {
 	scenario        *Deployment2
 	networkSegments *jettypes.NetworkSegments
 	taskStack       []jettypes.DeployerCmd
+
+	// steps recorded by previous run, set only when deployment resumed
+	steps map[string]dbutil.StepRecord
 }
 
 func NewDeployer(scenario *Deployment2, vim *Vim) *Deployer {
@@ -60,7 +66,7 @@ func (d *Deployer) deployNetworks() error {
 
 	s := d.networkSegments.Segments()
 
-	for _, seg := range s {
+	for key, seg := range s {
 		gateway, sharedNet, err := sharedAttributes(seg.Segments())
 		if err != nil {
 			logging.ErrorLogging(err)
@@ -73,7 +79,10 @@ func (d *Deployer) deployNetworks() error {
 			return e
 		}
 
-		segmentSwitch, segmentRouter, err := d.vim.DeploySegment(d.scenario.DeploymentName, seg.SegmentName(), gateway, prefixLen)
+		// static nodes addressed by guest customization, segment needs no dhcp server
+		dhcp := len(dhcpNodes(seg.Segments())) > 0
+		segmentSwitch, segmentRouter, err := d.vim.DeploySegment(d.scenario.DeploymentName,
+			seg.SegmentName(), gateway, prefixLen, dhcp, seg.Segments()[0].DomainSuffix)
 		if err != nil {
 			logging.ErrorLogging(err)
 			return err
@@ -83,6 +92,7 @@ func (d *Deployer) deployNetworks() error {
 			v.SetGenericSwitch(segmentSwitch)
 			v.SetGenericRouter(segmentRouter)
 		}
+		d.journalSegment(key, seg.Segments()[0])
 	}
 
 	return nil
@@ -152,6 +162,19 @@ func (d *Deployer) nodeSlice() []*jettypes.NodeTemplate {
 	return nodes
 }
 
+//
+//  Commands a deployer runs on nodes and on a host of jettison, tests replace
+//  them so a deployment runs without ssh, ansible and cfssl.
+//
+var (
+	sshCopyId           = sshclient.SshCopyId
+	runRemoteCommand    = sshclient.RunRemoteCommand
+	ansiblePing         = ansibleutil.Ping
+	runAnsible          = ansibleutil.RunAnsible
+	generateTenantCerts = certsutil.GenerateTenantCerts
+	generateWorkerCerts = certsutil.GenerateWorkerCerts
+)
+
 const (
 	WriteCheckCmd = "/bin/touch sshclientping"
 	ReadCheckCmd  = "/bin/ls sshclientping"
@@ -164,10 +187,13 @@ const (
  */
 func (d *Deployer) deployMgmtChannel(nodes []*jettypes.NodeTemplate) (bool, error) {
 
-	// copy ssh key to each host
+	// copy ssh key to each host, cloud-init of a node installs key itself
 	sshDefaults := d.vim.jetConfig.GetSshDefault()
 	for _, n := range nodes {
-		err := sshclient.SshCopyId(sshDefaults, n.IPv4AddrStr)
+		if n.CloudInit != nil {
+			continue
+		}
+		err := sshCopyId(sshDefaults, n.IPv4AddrStr)
 		if err != nil {
 			return false, fmt.Errorf("failed copy ssh key, error: %v", err)
 		}
@@ -178,18 +204,18 @@ func (d *Deployer) deployMgmtChannel(nodes []*jettypes.NodeTemplate) (bool, erro
 	for _, h := range nodes {
 		logging.Notification("Sending ssh ping to a host \t", h.Name, "\t", h.IPv4AddrStr)
 
-		out, err := sshclient.RunRemoteCommand(sshDefaults, h.IPv4AddrStr, WriteCheckCmd)
+		out, err := runRemoteCommand(sshDefaults, h.IPv4AddrStr, WriteCheckCmd)
 		if err != nil {
 			return false, fmt.Errorf("failed execute command on remote host %s %v", h.IPv4AddrStr, err)
 		}
-		out, err = sshclient.RunRemoteCommand(sshDefaults, h.IPv4AddrStr, ReadCheckCmd)
+		out, err = runRemoteCommand(sshDefaults, h.IPv4AddrStr, ReadCheckCmd)
 		if err != nil {
 			return false, fmt.Errorf("failed execute command on remote host %s %v", h.IPv4AddrStr, err)
 		}
 		if !strings.Contains(out, RespondOutput) {
 			return false, fmt.Errorf("ssh key injection failed for host %s %v", h.Name, h.IPv4AddrStr)
 		}
-		_, err = sshclient.RunRemoteCommand(sshDefaults, h.IPv4AddrStr, DeleteCmd)
+		_, err = runRemoteCommand(sshDefaults, h.IPv4AddrStr, DeleteCmd)
 		if err != nil {
 			return false, fmt.Errorf("failed execute command on remote host %s %v", h.IPv4AddrStr, err)
 		}
@@ -221,7 +247,8 @@ func (d *Deployer) setFacts() error {
 			return fmt.Errorf("switch needs to be discovered. ")
 		}
 
-		if len(dhcpUuid) == 0 {
+		// group of static nodes may sit on a segment without dhcp server
+		if len(dhcpUuid) == 0 && len(dhcpNodes(v1)) > 0 {
 			return fmt.Errorf("dhcp server needs to be discovered. ")
 		}
 
@@ -263,12 +290,9 @@ func (d *Deployer) deployDhcpBindings(nodes []*jettypes.NodeTemplate) (bool, err
 	for _, v := range d.scenario.nodesGroup {
 		err := d.vim.CreateDhcpBindings(d.scenario.DeploymentName, v)
 		if err != nil {
-			err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-			if err != nil {
-				return false, fmt.Errorf("failed cleanup environment error: %v", err)
-			}
-			return false, fmt.Errorf("failed cleanup environment error: %v", err)
+			return false, fmt.Errorf("failed create dhcp bindings error: %v", err)
 		}
+		d.journalNodes(JournalDhcpBinding, dhcpNodes(v))
 	}
 	return true, nil
 }
@@ -282,7 +306,7 @@ func (d *Deployer) ansibleDiscovery(nodes []*jettypes.NodeTemplate) (bool, error
 	for _, role := range nodeRoles {
 		// each section looks project name-group name
 		roleInProject := d.scenario.DeploymentName + role
-		jsonRespond, err := ansibleutil.Ping(ansibleutil.AnsibleCommand{
+		jsonRespond, err := ansiblePing(ansibleutil.AnsibleCommand{
 			Path:   "/usr/local/bin/ansible",
 			CMD:    []string{roleInProject, "-m", "ping"},
 			Config: "",
@@ -338,41 +362,96 @@ func (d *Deployer) ansibleAddWorkers(nodes []*jettypes.NodeTemplate) (bool, erro
 }
 
 //
-// Allocate a network per each pod
+// Reserve a network per each worker that has no pod network yet, blocks
+// already allocated to the project are skipped. Nothing stored in database.
 //
-func (d *Deployer) AllocatePodNetwork(nodes []*jettypes.NodeTemplate) (bool, error) {
+func (d *Deployer) reservePodNetworks(nodes []*jettypes.NodeTemplate) error {
 
 	jetConfig := d.vim.jetConfig
 	clusterCidr := jetConfig.GetCluster().ClusterCidr
-	projectName := d.scenario.DeploymentName
 
 	// create IP pool based on client cluster cidr
 	pool, err := netpool.NewSubnetPool(clusterCidr, uint(jetConfig.GetCluster().AllocateSize))
 	if err != nil {
-		return false, fmt.Errorf("failed create subnet pool manager %v", err)
+		return fmt.Errorf("failed create subnet pool manager %v", err)
 	}
 
-	for i, node := range nodes {
-		if node.Type == jettypes.WorkerType {
-			addrBlock, err := pool.AllocateSubnet()
-			if err != nil {
-				logging.ErrorLogging(err)
-				return false, fmt.Errorf("failed allocate ip block for a pod error: %v", err)
+	allocations, err := dbutil.GetDeploymentAllocations(d.vim.db, d.scenario.DeploymentName)
+	if err != nil {
+		return fmt.Errorf("failed read pod allocations %v", err)
+	}
+	for _, block := range allocations {
+		if err := pool.SetInUse(block); err != nil {
+			logging.CriticalMessage("pod allocation", block, "outside of cluster cidr", clusterCidr)
+		}
+	}
+	for _, node := range nodes {
+		if node.Type == jettypes.WorkerType && len(node.GetCidr()) > 0 {
+			if err := pool.SetInUse(node.GetCidr()); err != nil {
+				logging.CriticalMessage("pod network", node.GetCidr(), "outside of cluster cidr", clusterCidr)
 			}
+		}
+	}
+
+	for i, node := range nodes {
+		if node.Type != jettypes.WorkerType || len(node.GetCidr()) > 0 {
+			continue
+		}
+		addrBlock, err := pool.AllocateSubnet()
+		if err != nil {
+			logging.ErrorLogging(err)
+			return fmt.Errorf("failed allocate ip block for a pod error: %v", err)
+		}
+		nodes[i].SetPodCidr(addrBlock.String())
+		nodes[i].PodAllocationSize(jetConfig.GetCluster().AllocateSize)
+	}
+
+	return nil
+}
+
+//
+// Allocate a network per each pod, network reserved before clone is kept.
+// Worker that already has a network in database skipped, so a resumed step
+// doesn't store same network twice.
+//
+func (d *Deployer) AllocatePodNetwork(nodes []*jettypes.NodeTemplate) (bool, error) {
+
+	jetConfig := d.vim.jetConfig
+	clusterCidr := jetConfig.GetCluster().ClusterCidr
+	projectName := d.scenario.DeploymentName
+
+	if err := d.reservePodNetworks(nodes); err != nil {
+		return false, err
+	}
 
-			nodes[i].SetPodCidr(addrBlock.String())
-			nodes[i].PodAllocationSize(jetConfig.GetCluster().AllocateSize)
+	allocations, err := dbutil.GetDeploymentAllocations(d.vim.db, projectName)
+	if err != nil {
+		return false, fmt.Errorf("failed read pod allocations %v", err)
+	}
 
-			podNetwork := fmt.Sprintf("%s/%d", addrBlock.String(), jetConfig.GetCluster().AllocateSize)
-			_, err = dbutil.MakeAllocation(d.vim.db, node, projectName, podNetwork, clusterCidr)
+	for _, node := range nodes {
+		if _, ok := allocations[node.GetUuidName()]; ok {
+			continue
+		}
+		if node.Type == jettypes.WorkerType {
+			podNetwork := fmt.Sprintf("%s/%d", node.GetCidr(), jetConfig.GetCluster().AllocateSize)
+			_, err := dbutil.MakeAllocation(d.vim.db, node, projectName, podNetwork, clusterCidr)
 			if err != nil {
 				logging.ErrorLogging(err)
 				return false, fmt.Errorf("failed allocate cidr block to a pod")
 			}
+			d.journal(JournalPodAllocation, node.Name, journalData{})
+
+			if !d.vim.Supports(providers.CapStaticRoutes) {
+				continue
+			}
 
 			_, err = d.vim.AddStaticRoute(d.scenario.DeploymentName, node, podNetwork)
 			if err != nil {
 				logging.CriticalMessage("failed to add static route")
+			} else {
+				d.journal(JournalStaticRoute, podNetwork,
+					journalData{Node: newNodeCheckpoint("", node), Network: podNetwork})
 			}
 		}
 	}
@@ -387,7 +466,6 @@ func (d *Deployer) AllocatePodNetwork(nodes []*jettypes.NodeTemplate) (bool, err
 func (d *Deployer) createAnsibleInventory(nodes []*jettypes.NodeTemplate) (bool, error) {
 
 	ansibleConfig := d.vim.jetConfig.GetAnsible()
-	sshConfig := d.vim.jetConfig.GetSshDefault()
 
 	ansibleInventory, err := ansibleutil.CreateFromInventory(ansibleConfig.AnsibleInventory)
 	if err != nil {
@@ -396,13 +474,38 @@ func (d *Deployer) createAnsibleInventory(nodes []*jettypes.NodeTemplate) (bool,
 			ansibleConfig.AnsibleInventory)
 	}
 
-	// add all node to ansible inventory
+	if osutil.CheckIfExist(ansibleConfig.AnsibleInventory) == false {
+		log.Println("Generating a new ansible inventory")
+	}
+
+	err = d.addInventoryHosts(ansibleInventory, d.scenario.DeploymentName, nodes)
+	d.journalNodes(JournalInventory, nodes)
+	if err != nil {
+		return false, err
+	}
+
+	// write to a file
+	err = ansibleInventory.WriteToFile()
+	if err != nil {
+		return false, fmt.Errorf("failed create ansible inventory file %v", err)
+	}
+
+	logging.Notification("Ansible successfully generated")
+
+	return true, nil
+}
+
+//
+//   Adds each node to ansible inventory in a project group that match node type.
+//
+func (d *Deployer) addInventoryHosts(inventory *ansibleutil.AnsibleInventoryHosts,
+	projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	sshConfig := d.vim.jetConfig.GetSshDefault()
+
 	for _, n := range nodes {
 		// get ansible group name for a given project
-		var groupName = ansibleutil.GetAnsibleGroupName(n.Type, d.scenario.DeploymentName)
-		if osutil.CheckIfExist(ansibleConfig.AnsibleInventory) == false {
-			log.Println("Generating a new ansible inventory")
-		}
+		var groupName = ansibleutil.GetAnsibleGroupName(n.Type, projectName)
 		// add ansible host to ansible inventory
 		ansibleHost := ansibleutil.AnsibleHosts{
 			Name:     n.Name,
@@ -411,21 +514,13 @@ func (d *Deployer) createAnsibleInventory(nodes []*jettypes.NodeTemplate) (bool,
 			User:     sshConfig.SshUsername,
 			Group:    groupName,
 		}
-		err := ansibleInventory.AddSlaveHost(&ansibleHost, d.scenario.DeploymentName)
+		err := inventory.AddSlaveHost(&ansibleHost, projectName)
 		if err != nil {
-			return false, fmt.Errorf("failed create ansible inventory %v", err)
+			return fmt.Errorf("failed create ansible inventory %v", err)
 		}
 	}
 
-	// write to a file
-	err = ansibleInventory.WriteToFile()
-	if err != nil {
-		return false, fmt.Errorf("failed create ansible inventory file %v", err)
-	}
-
-	logging.Notification("Ansible successfully generated")
-
-	return true, nil
+	return nil
 }
 
 //
@@ -463,7 +558,7 @@ func (d *Deployer) createAnsibleGlobals(nodes []*jettypes.NodeTemplate) (bool, e
 	}
 
 	// generate certs
-	keys, err := certsutil.GenerateTenantCerts(certClients,
+	keys, err := generateTenantCerts(certClients,
 		ansibleEnv.AnsibleTemplates, projectName, serviceCidr)
 	if err != nil {
 		logging.ErrorLogging(err)
@@ -497,16 +592,26 @@ func (d *Deployer) createAnsibleGlobals(nodes []*jettypes.NodeTemplate) (bool, e
 }
 
 //
+//  Generates a project playbook from playbook template and writes it to a writer.
+//  If template is not yet unpacked to ansible home dir a build in template used.
 //
-//
-func (d *Deployer) createAnsiblePlaybook() (bool, error) {
+func (d *Deployer) writeAnsiblePlaybook(w io.Writer) error {
 
 	baseDir := d.vim.jetConfig.GetAnsible().AnsibleConfig
 	filePath := path.Join(baseDir, consts.PlaybookTemplate)
 
-	playbook, err := ansibleutil.MakeNewFromFile(filePath)
+	var (
+		playbook *ansibleutil.Playbook
+		err      error
+	)
+
+	if osutil.CheckIfExist(filePath) {
+		playbook, err = ansibleutil.MakeNewFromFile(filePath)
+	} else {
+		playbook, err = ansibleutil.MakeNewPlaybook(ansibleutil.GenerateTemplate())
+	}
 	if err != nil {
-		return false, err
+		return err
 	}
 
 	for key, _ := range d.scenario.nodesGroup {
@@ -514,9 +619,19 @@ func (d *Deployer) createAnsiblePlaybook() (bool, error) {
 		playbook.Transform(key, groupName)
 	}
 
+	return playbook.Write(w)
+}
+
+//
+//
+//
+func (d *Deployer) createAnsiblePlaybook() (bool, error) {
+
+	baseDir := d.vim.jetConfig.GetAnsible().AnsibleConfig
+
 	fiName := d.scenario.DeploymentName + ".yml"
 	fiPath := path.Join(baseDir, fiName)
-	logging.Notification("Generating playbook ", filePath)
+	logging.Notification("Generating playbook ", fiPath)
 
 	fi, err := os.OpenFile(fiPath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
@@ -524,7 +639,7 @@ func (d *Deployer) createAnsiblePlaybook() (bool, error) {
 	}
 	defer fi.Close()
 
-	err = playbook.Write(fi)
+	err = d.writeAnsiblePlaybook(fi)
 	if err != nil {
 		return false, err
 	}
@@ -533,13 +648,30 @@ func (d *Deployer) createAnsiblePlaybook() (bool, error) {
 }
 
 //
-// Main routine called by jettison to deploy a given scenario.
+// Main routine called by jettison to deploy a given scenario.  Objects created
+// by a failed deployment deleted if cleanupOnFailure set, otherwise kept
+// so deployment can be resumed or rolled back.
 //
-func (d *Deployer) Deploy() error {
+func (d *Deployer) Deploy(resume bool) error {
+
+	err := d.deploy(resume)
+	if err != nil {
+		return d.compensate(d.scenario.DeploymentName, err)
+	}
+
+	// deployment completed nothing to compensate
+	err = dbutil.DeleteJournal(d.vim.Database(), d.scenario.DeploymentName)
+	if err != nil {
+		logging.ErrorLogging(err)
+	}
+
+	return nil
+}
+
+func (d *Deployer) deploy(resume bool) error {
 
 	err := d.buildSegments()
 	if err != nil {
-		//		d.taskStack = append(d.taskStack, "networksegments")
 		logging.ErrorLogging(err)
 		return fmt.Errorf("failed build network segment list")
 	}
@@ -549,35 +681,47 @@ func (d *Deployer) Deploy() error {
 		return nil
 	}
 
-	err = d.deployNetworks()
-	if err != nil {
-		return nil
-	}
-
-	var isNew = false
-	_, _, ok, err = dbutil.GetDeployment(d.vim.Database(), d.scenario.DeploymentName)
-	if err != nil {
-		log.Fatal("error ", err)
-	}
-
-	if ok {
-		isNew, err = d.promptForRedeploy()
+	if resume {
+		err = d.restoreCheckpoint()
 		if err != nil {
-			return fmt.Errorf("error %v", err)
+			return err
 		}
-		if isNew == false {
-			return nil
+	} else {
+		_, _, ok, err = dbutil.GetDeployment(d.vim.Database(), d.scenario.DeploymentName)
+		if err != nil {
+			log.Fatal("error ", err)
 		}
 
-		ok, err := d.promptDeploy()
-		if err != nil {
-			return fmt.Errorf("error %v", err)
+		if ok {
+			isNew, err := d.promptForRedeploy()
+			if err != nil {
+				return fmt.Errorf("error %v", err)
+			}
+			if isNew == false {
+				return nil
+			}
+
+			ok, err := d.promptDeploy()
+			if err != nil {
+				return fmt.Errorf("error %v", err)
+			}
+			if !ok {
+				return nil
+			}
 		}
-		if !ok {
-			return nil
+
+		// new deployment, old checkpoints no longer valid
+		err = dbutil.DeleteSteps(d.vim.Database(), d.scenario.DeploymentName)
+		if err != nil {
+			return err
 		}
 	}
 
+	err = d.runStep(StepSegments, d.deployNetworks)
+	if err != nil {
+		return err
+	}
+
 	// attach templates
 	err = d.attachTemplates()
 	if err != nil {
@@ -590,13 +734,23 @@ func (d *Deployer) Deploy() error {
 		return err
 	}
 
-	// for each group of node deploy
-	//	d.taskStack = append(d.taskStack, "clonevm")
-	for _, v := range d.scenario.nodesGroup {
-		err = d.vim.CloneVms(d.scenario.DeploymentName, v)
-		if err != nil {
-			return fmt.Errorf("failed clone a vms err: %v", err)
+	// for each group of node deploy, pod networks known before clone so
+	// cloud-init of a worker can refer to it
+	err = d.runStep(StepClone, func() error {
+		if err := d.reservePodNetworks(d.nodeSlice()); err != nil {
+			return err
 		}
+		for k, v := range d.scenario.nodesGroup {
+			results, err := d.vim.CloneVms(d.scenario.DeploymentName, v)
+			d.journalVms(k, results.Succeeded(v))
+			if err != nil {
+				return fmt.Errorf("failed clone a vms err: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	nodes := d.nodeSlice()
@@ -607,82 +761,101 @@ func (d *Deployer) Deploy() error {
 		}
 	}
 
-	if ok, err = d.deployDhcpBindings(nodes); !ok {
-		//		d.taskStack = append(d.taskStack, "dhcp")
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
-			return err
+	// adapts (bool, error) step to a step that return only error
+	check := func(ok bool, err error) error {
+		if !ok && err == nil {
+			return fmt.Errorf("step didn't complete")
 		}
+		return err
 	}
 
-	if ok, err = d.vim.CreateDeployment(d.scenario.DeploymentName, nodes); !ok {
-		//		d.taskStack = append(d.taskStack, "database")
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
-			return err
-		}
+	err = d.runStep(StepDhcp, func() error {
+		return check(d.deployDhcpBindings(nodes))
+	})
+	if err != nil {
+		return err
 	}
 
-	if ok, err = d.vim.PowerChangeAll(nodes, jettypes.PowerOn); !ok {
-		//		d.taskStack = append(d.taskStack, "poweredon")
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
-			return err
+	err = d.runStep(StepDatabase, func() error {
+		ok, err := d.vim.CreateDeployment(d.scenario.DeploymentName, nodes)
+		if ok {
+			d.journal(JournalDeployment, d.scenario.DeploymentName, journalData{})
+			err = d.recordTemplates()
 		}
+		return check(ok, err)
+	})
+	if err != nil {
+		return err
 	}
 
-	log.Println("Acquiring ip addresses please wait...")
-	if ok, err = d.vim.AcquireIpAddresses(nodes); !ok {
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
-			return err
-		}
+	err = d.runStep(StepAffinity, func() error {
+		return d.createAffinityRules(d.scenario.DeploymentName, nodes)
+	})
+	if err != nil {
+		return err
 	}
 
-	log.Println("All addresses acquired")
+	err = d.runStep(StepPowerOn, func() error {
+		return check(d.vim.PowerChangeAll(nodes, jettypes.PowerOn))
+	})
+	if err != nil {
+		return err
+	}
 
-	//	d.taskStack = append(d.taskStack, "sshkeys")
-	if ok, err = d.deployMgmtChannel(nodes); !ok {
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
-			return err
-		}
+	log.Println("Acquiring ip addresses please wait...")
+	err = d.runStep(StepIpAddress, func() error {
+		return check(d.vim.AcquireIpAddresses(nodes))
+	})
+	if err != nil {
+		return err
 	}
 
-	//	d.taskStack = append(d.taskStack, "ansibleinventory")
-	if ok, err = d.createAnsibleInventory(nodes); !ok {
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
-			return err
-		}
+	log.Println("All addresses acquired")
+
+	err = d.runStep(StepSshKeys, func() error {
+		return check(d.deployMgmtChannel(nodes))
+	})
+	if err != nil {
+		return err
 	}
 
-	if ok, err = d.ansibleDiscovery(nodes); !ok {
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
-			return err
-		}
+	// pod networks reserved before clone stored once nodes are in database
+	err = d.runStep(StepPodNet, func() error {
+		return check(d.AllocatePodNetwork(nodes))
+	})
+	if err != nil {
+		return err
 	}
 
-	if ok, err = d.createAnsibleGlobals(nodes); !ok {
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
+	err = d.runStep(StepInventory, func() error {
+		if err := check(d.createAnsibleInventory(nodes)); err != nil {
 			return err
 		}
+		return check(d.ansibleDiscovery(nodes))
+	})
+	if err != nil {
+		return err
 	}
 
-	if ok, err = d.ansibleAddWorkers(nodes); !ok {
-		err = d.vim.ComputeCleanup(d.scenario.DeploymentName, nodes)
-		if err != nil {
+	err = d.runStep(StepCerts, func() error {
+		if err := check(d.createAnsibleGlobals(nodes)); err != nil {
 			return err
 		}
+		return check(d.ansibleAddWorkers(nodes))
+	})
+	if err != nil {
+		return err
 	}
 
-	if ok, err := d.Execute(jettypes.AnsibleDeploy); !ok {
-		if err != nil {
-			return err
+	err = d.runStep(StepPlaybook, func() error {
+		ok, err := d.Execute(jettypes.AnsibleDeploy)
+		if !ok && err == nil {
+			logging.Notification("looks like we have failed task.")
 		}
-		logging.Notification("looks like we have failed task.")
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
 	return nil
@@ -825,7 +998,12 @@ func (d *Deployer) cleanupFromDb() (bool, error) {
  */
 func (d *Deployer) Cleanup(nodes []*jettypes.NodeTemplate) (bool, error) {
 
-	err := d.vim.DhcpCleanup(d.scenario.DeploymentName, nodes)
+	owned, err := d.vim.ownedObjects(d.scenario.DeploymentName)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.vim.DhcpCleanup(d.scenario.DeploymentName, nodes, owned)
 	if err != nil {
 		logging.CriticalMessage("Failed delete dhcp binding from dhcp server")
 		return false, err
@@ -873,6 +1051,158 @@ func (d *Deployer) promptDeleteNetworking() bool {
 	return false
 }
 
+/**
+  Prompts a yes/no question, empty answer treated as no.
+*/
+func promptConfirm(question string) bool {
+
+	fmt.Print(question + " (yes/no) ")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	if scanner.Scan() {
+		answer := strings.TrimSpace(strings.ToLower(scanner.Text()))
+		return answer == "yes" || answer == "y"
+	}
+
+	return false
+}
+
+/*
+   Tear down entire deployment based on a snapshot stored in database.
+   Objects removed in order dhcp bindings, drs rules, vms and folders, template
+   snapshots of linked clones, nsx-t dhcp servers, routers and switches, ansible
+   inventory and host vars, pod cidr allocations and finally database records.
+
+   keepNetwork leaves nsx-t objects in place so they can be re-used by next deploy,
+   project marked so gc doesn't collect them, killing a project that has no nodes
+   drops a mark. assumeYes skips all confirmation prompts so teardown can run
+   non-interactively.
+*/
+func (d *Deployer) Teardown(projectName string, keepNetwork bool, assumeYes bool) error {
+
+	if len(projectName) == 0 {
+		return fmt.Errorf("empty project name")
+	}
+
+	nodes, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return err
+	}
+
+	if len(nodes) == 0 {
+		logging.Notification("Project", projectName, "has no nodes in database")
+		err = dbutil.DeleteDeployment(d.vim.Database(), projectName)
+		if err != nil {
+			return err
+		}
+		// journal left by a failed deployment, otherwise gc keeps project known
+		err = dbutil.DeleteJournal(d.vim.Database(), projectName)
+		if err != nil || keepNetwork {
+			return err
+		}
+		// network objects kept by earlier teardown left to gc
+		return dbutil.DeleteKeptNetwork(d.vim.Database(), projectName)
+	}
+
+	logging.Notification("Found project", projectName, "with", strconv.Itoa(len(nodes)), "nodes")
+	if !assumeYes && !promptConfirm("Do you want kill deployment "+projectName+" ?:") {
+		return nil
+	}
+
+	// objects project owns listed once, cleanups below skip everything else
+	owned, err := d.vim.ownedObjects(projectName)
+	if err != nil {
+		return err
+	}
+
+	// remove dhcp binding
+	err = d.vim.DhcpCleanup(projectName, nodes, owned)
+	if err != nil {
+		logging.CriticalMessage("Failed delete dhcp binding")
+		return err
+	}
+
+	// drs rules removed before vms, rule left in a cluster only logged
+	d.deleteAffinityRules(projectName)
+
+	clones, err := dbutil.GetLinkedClones(d.vim.Database(), projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+	}
+
+	// remove all vm and folders
+	err = d.vim.ComputeCleanup(projectName, nodes)
+	if err != nil {
+		logging.CriticalMessage("Failed delete vms")
+		return err
+	}
+
+	// template snapshots no longer used by linked clones
+	d.releaseSnapshots(projectName, clones)
+
+	keepNetwork = keepNetwork || !(assumeYes || d.promptDeleteNetworking())
+	if !keepNetwork {
+		_, err = d.vim.CleanupDhcp(projectName, nodes, owned)
+		if err != nil {
+			logging.CriticalMessage("Failed delete dhcp servers")
+			return err
+		}
+		_, err = d.vim.CleanupRouting(projectName, nodes, owned)
+		if err != nil {
+			logging.CriticalMessage("Failed delete logical routers")
+			return err
+		}
+		_, err = d.vim.CleanupSwitching(projectName, nodes, owned)
+		if err != nil {
+			logging.CriticalMessage("Failed delete logical switches")
+			return err
+		}
+	}
+
+	// remove from ansible inventory and host vars
+	err = d.ansibleCleanup(nodes)
+	if err != nil {
+		logging.CriticalMessage("Failed delete host from ansible inventory")
+		return err
+	}
+
+	// release pod cidr blocks
+	for _, node := range nodes {
+		if node.Type != jettypes.WorkerType {
+			continue
+		}
+		err = dbutil.DeleteSubnetAllocation(d.vim.Database(), node.Name)
+		if err != nil {
+			logging.CriticalMessage("Failed release pod cidr for node", node.Name)
+			return err
+		}
+	}
+
+	err = dbutil.DeleteDeployment(d.vim.Database(), projectName)
+	if err != nil {
+		return err
+	}
+
+	err = dbutil.DeleteJournal(d.vim.Database(), projectName)
+	if err != nil {
+		return err
+	}
+
+	if keepNetwork {
+		err = dbutil.SetKeptNetwork(d.vim.Database(), projectName)
+	} else {
+		err = dbutil.DeleteKeptNetwork(d.vim.Database(), projectName)
+	}
+	if err != nil {
+		return err
+	}
+
+	logging.Notification("Project", projectName, "deleted")
+
+	return nil
+}
+
 //
 //  Clean up all object from NSX based on snapshot take and stored in database.
 //  before deleting nsx-t object it will prompt for confirmation
@@ -886,7 +1216,13 @@ func (d *Deployer) networkCleanupFromDb() error {
 	}
 
 	if ok {
-		err := d.vim.DhcpCleanup(d.scenario.DeploymentName, nodes)
+		owned, err := d.vim.ownedObjects(d.scenario.DeploymentName)
+		if err != nil {
+			logging.ErrorLogging(err)
+			return err
+		}
+
+		err = d.vim.DhcpCleanup(d.scenario.DeploymentName, nodes, owned)
 		if err != nil {
 			logging.ErrorLogging(err)
 			return err
@@ -897,17 +1233,17 @@ func (d *Deployer) networkCleanupFromDb() error {
 			return nil
 		}
 
-		_, err = d.vim.CleanupDhcp(d.scenario.DeploymentName, nodes)
+		_, err = d.vim.CleanupDhcp(d.scenario.DeploymentName, nodes, owned)
 		if err != nil {
 			logging.ErrorLogging(err)
 		}
 
-		_, err = d.vim.CleanupRouting(d.scenario.DeploymentName, nodes)
+		_, err = d.vim.CleanupRouting(d.scenario.DeploymentName, nodes, owned)
 		if err != nil {
 			logging.ErrorLogging(err)
 		}
 
-		_, err = d.vim.CleanupSwitching(d.scenario.DeploymentName, nodes)
+		_, err = d.vim.CleanupSwitching(d.scenario.DeploymentName, nodes, owned)
 		if err != nil {
 			logging.ErrorLogging(err)
 		}
@@ -1098,13 +1434,23 @@ func (d *Deployer) AnsibleInventoryCmd() (bool, error) {
 
 // run ansible playbook.
 func (d *Deployer) AnsibleDeployCmd() (bool, error) {
+	return d.runPlaybook(nil)
+}
+
+// run ansible playbook, if hosts not empty play limited only to the hosts
+func (d *Deployer) runPlaybook(hosts []string) (bool, error) {
+
+	args := []string{"-b", d.scenario.DeploymentName + ".yml"}
+	if len(hosts) > 0 {
+		args = append(args, "--limit", strings.Join(hosts, ","))
+	}
 
 	cmd := ansibleutil.AnsibleCommand{Path: "/usr/local/bin/ansible-playbook",
-		CMD:    []string{"-b", d.scenario.DeploymentName + ".yml"},
+		CMD:    args,
 		Config: "",
 	}
 
-	_, err := ansibleutil.RunAnsible(cmd)
+	_, err := runAnsible(cmd)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,326 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/fake"
+)
+
+const testProject = "test"
+
+var errInjected = errors.New("injected fault")
+
+/**
+  Creates a deployer backed by fake vim and a database in a temporary directory.
+  Scenario holds given templates keyed by a node type. Ansible inventory written
+  to a same directory, ssh key doesn't exist so ssh fails right away.
+*/
+func setupDeployer(t *testing.T, templates ...*jettypes.NodeTemplate) (*Deployer, *fake.FakeVim, func()) {
+
+	dir, err := ioutil.TempDir("", "jettison")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := dbutil.Connect(filepath.Join(dir, "jettison.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dbutil.CreateTablesIfNeed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	jetConfig := &AppConfig{}
+	jetConfig.Infra.DeploymentName = testProject
+	jetConfig.Infra.AnsibleDefaults.AnsibleConfig = dir
+	jetConfig.Infra.AnsibleDefaults.AnsibleInventory = dir
+	jetConfig.Infra.SshDefaults.SshPrivateKey = filepath.Join(dir, "id_rsa")
+
+	pool := jettypes.NewWorkerPool(jettypes.DefaultParallelJobs, nil)
+	plugin := fake.New()
+	plugin.SetWorkerPool(pool)
+
+	vim := &Vim{
+		db:           db,
+		jetConfig:    jetConfig,
+		pluggableVim: plugin,
+		pool:         pool,
+		ctx:          context.Background(),
+	}
+
+	scenario := &Deployment2{
+		DeploymentName: testProject,
+		nodesGroup:     make(map[string][]*jettypes.NodeTemplate),
+		nodeTemplates:  make(map[string]*jettypes.NodeTemplate),
+	}
+	for _, v := range templates {
+		scenario.nodeTemplates[v.Type.String()] = v
+	}
+
+	return NewDeployer(scenario, vim), plugin, func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func testTemplate(nodeType jettypes.NodeType, count int) *jettypes.NodeTemplate {
+	return &jettypes.NodeTemplate{
+		Type:           nodeType,
+		Prefix:         nodeType.String(),
+		VmTemplateName: "ubuntu",
+		VimCluster:     "cluster",
+		DesiredCount:   count,
+	}
+}
+
+func testNode(name string, nodeType jettypes.NodeType, ip string) *jettypes.NodeTemplate {
+	return &jettypes.NodeTemplate{
+		Name:           name,
+		Type:           nodeType,
+		Prefix:         nodeType.String(),
+		VmTemplateName: "ubuntu",
+		VimCluster:     "cluster",
+		IPv4AddrStr:    ip,
+		IPv4Addr:       net.ParseIP(ip),
+	}
+}
+
+// Returns a number of calls of a method fake received.
+func countCalls(p *fake.FakeVim, method string) int {
+	n := 0
+	for _, c := range p.Calls() {
+		if c == method || strings.HasPrefix(c, method+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+/**
+  Deploys a segment, clones nodes attached to it, creates dhcp bindings, powers
+  nodes on and stores a deployment in database. Segment of static nodes has no
+  dhcp server.
+*/
+func deployNodes(t *testing.T, d *Deployer, nodes ...*jettypes.NodeTemplate) {
+
+	dhcp := len(dhcpNodes(nodes)) > 0
+	sw, router, err := d.vim.DeploySegment(testProject, "segment", "172.16.81.1", 24, dhcp, "")
+	if err != nil {
+		t.Fatalf("DeploySegment() error = %v", err)
+	}
+	for _, n := range nodes {
+		n.SetGenericSwitch(sw)
+		n.SetGenericRouter(router)
+	}
+
+	if _, err = d.vim.CloneVms(testProject, nodes); err != nil {
+		t.Fatalf("CloneVms() error = %v", err)
+	}
+	if err = d.vim.CreateDhcpBindings(testProject, nodes); err != nil {
+		t.Fatalf("CreateDhcpBindings() error = %v", err)
+	}
+	if _, err = d.vim.PowerChangeAll(nodes, jettypes.PowerOn); err != nil {
+		t.Fatalf("PowerChangeAll() error = %v", err)
+	}
+	if _, err = d.vim.CreateDeployment(testProject, nodes); err != nil {
+		t.Fatalf("CreateDeployment() error = %v", err)
+	}
+}
+
+func TestDeployer_Teardown(t *testing.T) {
+	tests := []struct {
+		name        string
+		project     string
+		keepNetwork bool
+		fault       *fake.Fault
+		wantErr     bool
+		wantVms     int
+		wantObjects int
+		wantNodes   int
+	}{
+		{
+			name:    "delete everything",
+			project: testProject,
+		},
+		{
+			name:        "keep network",
+			project:     testProject,
+			keepNetwork: true,
+			// switch, router, downlink port, dhcp server and profile
+			wantObjects: 5,
+		},
+		{
+			name:    "project not deployed",
+			project: "unknown",
+			// deployment of other project left untouched
+			wantVms:     2,
+			wantObjects: 7,
+			wantNodes:   2,
+		},
+		{
+			name:        "vm delete failed",
+			project:     testProject,
+			fault:       &fake.Fault{Method: "ComputeCleanup", Node: "test-worker-1", Err: errInjected},
+			wantErr:     true,
+			wantVms:     1,
+			wantObjects: 5,
+			wantNodes:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, p, teardown := setupDeployer(t)
+			defer teardown()
+
+			nodes := []*jettypes.NodeTemplate{
+				testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+				testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+			}
+			deployNodes(t, d, nodes...)
+
+			if tt.fault != nil {
+				p.InjectFault(*tt.fault)
+			}
+
+			listed := countCalls(p, "TaggedObjects")
+			err := d.Teardown(tt.project, tt.keepNetwork, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Teardown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n := countCalls(p, "TaggedObjects") - listed; n > 1 {
+				t.Errorf("Teardown() listed network objects %d times, want once", n)
+			}
+
+			vms, err := d.vim.DescribeVms(nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			existing := 0
+			for _, vm := range vms {
+				if vm.Exists {
+					existing++
+				}
+			}
+			if existing != tt.wantVms {
+				t.Errorf("Teardown() left %d vms, want %d", existing, tt.wantVms)
+			}
+
+			objects, err := p.TaggedObjects(context.Background(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != tt.wantObjects {
+				t.Errorf("Teardown() left %d network objects, want %d %v", len(objects), tt.wantObjects, objects)
+			}
+
+			stored, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != tt.wantNodes {
+				t.Errorf("Teardown() left %d nodes in database, want %d", len(stored), tt.wantNodes)
+			}
+		})
+	}
+}
+
+//
+//  Static nodes addressed by guest customization, segment of static nodes
+//  needs no dhcp server and nodes get no binding.
+//
+func TestDeployer_staticSegment(t *testing.T) {
+	tests := []struct {
+		name         string
+		static       bool
+		dhcp         bool
+		wantErr      bool
+		wantBindings int
+	}{
+		{
+			name:         "dhcp nodes",
+			dhcp:         true,
+			wantBindings: 2,
+		},
+		{
+			name:   "static nodes without dhcp server",
+			static: true,
+		},
+		{
+			name:    "dhcp nodes without dhcp server",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			template := testTemplate(jettypes.WorkerType, 2)
+			template.Static = tt.static
+			d, p, teardown := setupDeployer(t, template)
+			defer teardown()
+			d.vim.jetConfig.Infra.DnsServers = []string{"10.0.0.53"}
+
+			sw, router, err := d.vim.DeploySegment(testProject, "segment", "172.16.81.1", 24, tt.dhcp, "vmwarelab.edu")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dnsServers, domain, ok := p.DhcpOptions(sw.DhcpUuid())
+			if ok != tt.dhcp {
+				t.Fatalf("DeploySegment() dhcp server = %v, want %v", ok, tt.dhcp)
+			}
+			if ok && (!reflect.DeepEqual(dnsServers, []string{"10.0.0.53"}) || domain != "vmwarelab.edu") {
+				t.Errorf("DeploySegment() dhcp options = %v %s, want name servers of config", dnsServers, domain)
+			}
+			template.SetGenericSwitch(sw)
+			template.SetGenericRouter(router)
+
+			nodes := []*jettypes.NodeTemplate{
+				testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+				testNode("test-worker-2", jettypes.WorkerType, "172.16.81.12"),
+			}
+			for _, n := range nodes {
+				n.Static = tt.static
+			}
+			d.scenario.nodesGroup[template.Type.String()] = nodes
+
+			err = d.setFacts()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setFacts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if _, err = d.vim.CloneVms(testProject, nodes); err != nil {
+				t.Fatal(err)
+			}
+			if _, err = d.deployDhcpBindings(nodes); err != nil {
+				t.Fatalf("deployDhcpBindings() error = %v", err)
+			}
+
+			entries, err := dbutil.GetJournal(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bindings := 0
+			for _, e := range entries {
+				if e.Kind == JournalDhcpBinding {
+					bindings++
+				}
+			}
+			if bindings != tt.wantBindings {
+				t.Errorf("deployDhcpBindings() journaled %d bindings, want %d", bindings, tt.wantBindings)
+			}
+		})
+	}
+}
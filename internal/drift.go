@@ -0,0 +1,243 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Drift detection. Nodes stored in database compared with vms, dhcp bindings
+and network objects that vim reports for a project.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+)
+
+const (
+	DriftVmDeleted      = "vm-deleted"
+	DriftVmMoved        = "vm-moved"
+	DriftVmReplaced     = "vm-replaced"
+	DriftIpChanged      = "ip-changed"
+	DriftMacChanged     = "mac-changed"
+	DriftSwitchChanged  = "switch-changed"
+	DriftBindingMissing = "binding-missing"
+	DriftBindingChanged = "binding-changed"
+	DriftObjectMissing  = "object-missing"
+	DriftObjectExtra    = "object-extra"
+)
+
+/*
+   A single difference between database and vim. Object is a kind and
+   id of network object, node is empty for network objects.
+*/
+type Drift struct {
+	Kind     string `json:"kind" yaml:"kind"`
+	Node     string `json:"node,omitempty" yaml:"node,omitempty"`
+	Object   string `json:"object,omitempty" yaml:"object,omitempty"`
+	Expected string `json:"expected" yaml:"expected"`
+	Actual   string `json:"actual" yaml:"actual"`
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+//
+//  Compares a node stored in database with a vm and dhcp binding.
+//  Binding address is empty if node has no binding, static node has none.
+//  Binding on a segment jettison didn't create, for example a segment adopted
+//  node attached to, is not checked.
+//
+func nodeDrift(n *jettypes.NodeTemplate, vm *jettypes.VmInfo, binding string) []Drift {
+
+	var drift []Drift
+
+	if vm == nil || !vm.Exists {
+		return append(drift, Drift{Kind: DriftVmDeleted, Node: n.Name, Expected: n.GetVimName()})
+	}
+
+	if len(n.VimCluster) > 0 && vm.Cluster != n.VimCluster {
+		drift = append(drift, Drift{Kind: DriftVmMoved, Node: n.Name, Expected: n.VimCluster, Actual: vm.Cluster})
+	}
+
+	// vm with same name re-created outside of jettison
+	if len(n.UUID) > 0 && vm.UUID != n.UUID {
+		drift = append(drift, Drift{Kind: DriftVmReplaced, Node: n.Name, Expected: n.UUID, Actual: vm.UUID})
+	}
+
+	// guest reports address only when vm running
+	if len(vm.IPv4Addr) > 0 && vm.IPv4Addr != n.IPv4AddrStr {
+		drift = append(drift, Drift{Kind: DriftIpChanged, Node: n.Name, Expected: n.IPv4AddrStr, Actual: vm.IPv4Addr})
+	}
+
+	mac := ""
+	if len(n.Mac) > 0 {
+		mac = n.Mac[0]
+	}
+	if len(mac) > 0 && !contains(vm.Mac, mac) {
+		actual := ""
+		if len(vm.Mac) > 0 {
+			actual = vm.Mac[0]
+		}
+		drift = append(drift, Drift{Kind: DriftMacChanged, Node: n.Name, Expected: mac, Actual: actual})
+	}
+
+	if len(n.SwitchUuid()) > 0 && !contains(vm.SwitchUuids, n.SwitchUuid()) {
+		actual := ""
+		if len(vm.SwitchUuids) > 0 {
+			actual = vm.SwitchUuids[0]
+		}
+		drift = append(drift, Drift{Kind: DriftSwitchChanged, Node: n.Name, Expected: n.SwitchUuid(), Actual: actual})
+	}
+
+	if n.Static || n.IsExistingNetwork() {
+		return drift
+	}
+
+	if len(binding) == 0 {
+		drift = append(drift, Drift{Kind: DriftBindingMissing, Node: n.Name, Expected: n.IPv4AddrStr})
+	} else if binding != n.IPv4AddrStr {
+		drift = append(drift, Drift{Kind: DriftBindingChanged, Node: n.Name, Expected: n.IPv4AddrStr, Actual: binding})
+	}
+
+	return drift
+}
+
+//
+//  Compares network objects nodes refer to with objects tagged with a project.
+//  Segment adopted node attached to is not tagged with a project and not checked.
+//
+func objectDrift(nodes []*jettypes.NodeTemplate, objects []jettypes.NetworkObject) []Drift {
+
+	var drift []Drift
+
+	expected := make(map[string]string)
+	var order []string
+	add := func(kind string, id string) {
+		if _, ok := expected[id]; len(id) > 0 && !ok {
+			expected[id] = kind
+			order = append(order, id)
+		}
+	}
+	for _, n := range nodes {
+		if n.IsExistingNetwork() {
+			continue
+		}
+		add(jettypes.ObjectSwitch, n.SwitchUuid())
+		add(jettypes.ObjectRouter, n.RouterUuid())
+		add(jettypes.ObjectDhcpServer, n.DhcpServerUuid())
+	}
+
+	tagged := make(map[string]bool)
+	for _, o := range objects {
+		// nodes refer only to switches, routers and dhcp servers
+		if o.Kind != jettypes.ObjectSwitch && o.Kind != jettypes.ObjectRouter && o.Kind != jettypes.ObjectDhcpServer {
+			continue
+		}
+		tagged[o.Id] = true
+		if _, ok := expected[o.Id]; !ok {
+			drift = append(drift, Drift{Kind: DriftObjectExtra, Object: o.Kind + " " + o.Id, Actual: o.Name})
+		}
+	}
+
+	for _, id := range order {
+		if !tagged[id] {
+			drift = append(drift, Drift{Kind: DriftObjectMissing, Object: expected[id] + " " + id, Expected: id})
+		}
+	}
+
+	return drift
+}
+
+//
+//  Compares a deployment stored in database with vim and returns each difference.
+//
+func (d *Deployer) DetectDrift(projectName string) ([]Drift, error) {
+
+	var drift []Drift
+
+	nodes, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), projectName)
+	if err != nil {
+		return drift, err
+	}
+	if len(nodes) == 0 {
+		return drift, fmt.Errorf("project %s not found", projectName)
+	}
+
+	// database doesn't keep addressing mode, it taken from a template
+	for _, n := range nodes {
+		if d.scenario == nil || d.scenario.DeploymentName != projectName {
+			break
+		}
+		if ok, template := d.scenario.Template(n.Type); ok {
+			n.Static = template.Static
+		}
+	}
+
+	vms, err := d.vim.DescribeVms(nodes)
+	if err != nil {
+		return drift, err
+	}
+
+	// static node has no binding, its segment may have no dhcp server to ask
+	bindings, err := d.vim.DescribeBindings(boundNodes(nodes))
+	if err != nil {
+		return drift, err
+	}
+
+	objects, err := d.vim.TaggedObjects(projectName)
+	if err != nil {
+		return drift, err
+	}
+
+	for _, n := range nodes {
+		drift = append(drift, nodeDrift(n, vms[n.Name], bindings[n.Name])...)
+	}
+	drift = append(drift, objectDrift(nodes, objects)...)
+
+	return drift, nil
+}
+
+//
+// Writes each drift in requested format.
+//
+func WriteDrift(w io.Writer, format string, drift []Drift) error {
+
+	if drift == nil {
+		drift = []Drift{}
+	}
+
+	if done, err := writeEncoded(w, format, drift); done {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KIND\tNODE\tOBJECT\tEXPECTED\tACTUAL")
+	for _, v := range drift {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.Kind, v.Node, v.Object, v.Expected, v.Actual)
+	}
+
+	return tw.Flush()
+}
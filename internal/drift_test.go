@@ -0,0 +1,453 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/fake"
+)
+
+func driftKinds(drift []Drift) []string {
+	var kinds []string
+	for _, v := range drift {
+		kinds = append(kinds, v.Kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func equalKinds(got []string, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDeployer_DetectDrift(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		project string
+		static  bool
+		change  func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "no drift",
+			project: testProject,
+		},
+		{
+			// segment of static nodes has no dhcp server to ask for bindings
+			name:    "static nodes",
+			project: testProject,
+			static:  true,
+		},
+		{
+			name:    "vm deleted",
+			project: testProject,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				return p.DeleteVm(ctx, testProject, nodes[1])
+			},
+			want: []string{DriftVmDeleted},
+		},
+		{
+			name:    "binding deleted",
+			project: testProject,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				return p.DhcpCleanup(ctx, testProject, nodes[1:])
+			},
+			want: []string{DriftBindingMissing},
+		},
+		{
+			name:    "extra segment",
+			project: testProject,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				_, _, err := p.DeploySegment(ctx, testProject, "extra", "172.16.82.1", 24, true, nil, "")
+				return err
+			},
+			want: []string{DriftObjectExtra, DriftObjectExtra, DriftObjectExtra},
+		},
+		{
+			name:    "router deleted",
+			project: testProject,
+			change: func(p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				_, err := p.DeleteRouter(ctx, nodes[0])
+				return err
+			},
+			want: []string{DriftObjectMissing},
+		},
+		{
+			name:    "project not deployed",
+			project: "unknown",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			templates := []*jettypes.NodeTemplate{
+				testTemplate(jettypes.ControlType, 1), testTemplate(jettypes.WorkerType, 1),
+			}
+			nodes := []*jettypes.NodeTemplate{
+				testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+				testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+			}
+			for i := range nodes {
+				templates[i].Static = tt.static
+				nodes[i].Static = tt.static
+			}
+
+			d, p, teardown := setupDeployer(t, templates...)
+			defer teardown()
+			deployNodes(t, d, nodes...)
+
+			if tt.change != nil {
+				if err := tt.change(p, nodes); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := d.DetectDrift(tt.project)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectDrift() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !equalKinds(driftKinds(got), tt.want) {
+				t.Errorf("DetectDrift() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nodeDrift(t *testing.T) {
+
+	node := func() *jettypes.NodeTemplate {
+		n := reconcileNode("test-worker-1", jettypes.WorkerType, "172.16.81.11")
+		n.UUID = "uuid-1"
+		n.Mac = []string{"00:50:56:00:00:01"}
+		return n
+	}
+	vm := func() *jettypes.VmInfo {
+		return &jettypes.VmInfo{Name: "test-worker-1", UUID: "uuid-1", Exists: true, PoweredOn: true,
+			Cluster: "cluster", IPv4Addr: "172.16.81.11", Mac: []string{"00:50:56:00:00:01"},
+			SwitchUuids: []string{"switch-1"}}
+	}
+
+	tests := []struct {
+		name         string
+		static       bool
+		existing     bool
+		change       func(vm *jettypes.VmInfo)
+		deleted      bool
+		binding      string
+		want         string
+		wantExpected string
+		wantActual   string
+	}{
+		{
+			name:    "no drift",
+			binding: "172.16.81.11",
+		},
+		{
+			name:         "vm deleted",
+			deleted:      true,
+			binding:      "172.16.81.11",
+			want:         DriftVmDeleted,
+			wantExpected: "test-worker-1",
+		},
+		{
+			name:         "vm not found",
+			change:       func(vm *jettypes.VmInfo) { vm.Exists = false },
+			want:         DriftVmDeleted,
+			wantExpected: "test-worker-1",
+		},
+		{
+			name:         "vm moved",
+			change:       func(vm *jettypes.VmInfo) { vm.Cluster = "other" },
+			binding:      "172.16.81.11",
+			want:         DriftVmMoved,
+			wantExpected: "cluster",
+			wantActual:   "other",
+		},
+		{
+			name:         "vm replaced",
+			change:       func(vm *jettypes.VmInfo) { vm.UUID = "uuid-2" },
+			binding:      "172.16.81.11",
+			want:         DriftVmReplaced,
+			wantExpected: "uuid-1",
+			wantActual:   "uuid-2",
+		},
+		{
+			name:         "ip changed",
+			change:       func(vm *jettypes.VmInfo) { vm.IPv4Addr = "172.16.81.99" },
+			binding:      "172.16.81.11",
+			want:         DriftIpChanged,
+			wantExpected: "172.16.81.11",
+			wantActual:   "172.16.81.99",
+		},
+		{
+			// powered off guest reports no address
+			name:    "no guest address",
+			change:  func(vm *jettypes.VmInfo) { vm.IPv4Addr = "" },
+			binding: "172.16.81.11",
+		},
+		{
+			name:         "mac changed",
+			change:       func(vm *jettypes.VmInfo) { vm.Mac = []string{"00:50:56:00:00:02"} },
+			binding:      "172.16.81.11",
+			want:         DriftMacChanged,
+			wantExpected: "00:50:56:00:00:01",
+			wantActual:   "00:50:56:00:00:02",
+		},
+		{
+			name:         "switch changed",
+			change:       func(vm *jettypes.VmInfo) { vm.SwitchUuids = []string{"switch-2"} },
+			binding:      "172.16.81.11",
+			want:         DriftSwitchChanged,
+			wantExpected: "switch-1",
+			wantActual:   "switch-2",
+		},
+		{
+			name:         "binding missing",
+			want:         DriftBindingMissing,
+			wantExpected: "172.16.81.11",
+		},
+		{
+			name:         "binding changed",
+			binding:      "172.16.81.99",
+			want:         DriftBindingChanged,
+			wantExpected: "172.16.81.11",
+			wantActual:   "172.16.81.99",
+		},
+		{
+			name:   "static node has no binding",
+			static: true,
+		},
+		{
+			// binding on a segment built by hand left to its owner
+			name:     "existing network node has no binding",
+			existing: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			n, v := node(), vm()
+			n.Static = tt.static
+			n.SetExistingNetwork(tt.existing)
+			n.VimName = n.Name
+			if tt.change != nil {
+				tt.change(v)
+			}
+			if tt.deleted {
+				v = nil
+			}
+
+			got := nodeDrift(n, v, tt.binding)
+			if len(tt.want) == 0 {
+				if len(got) != 0 {
+					t.Errorf("nodeDrift() = %v, want no drift", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("nodeDrift() = %v, want %s", got, tt.want)
+			}
+			if got[0].Kind != tt.want || got[0].Node != n.Name ||
+				got[0].Expected != tt.wantExpected || got[0].Actual != tt.wantActual {
+				t.Errorf("nodeDrift() = %+v, want %s expected %s actual %s",
+					got[0], tt.want, tt.wantExpected, tt.wantActual)
+			}
+		})
+	}
+}
+
+func Test_objectDrift(t *testing.T) {
+
+	nodes := []*jettypes.NodeTemplate{
+		reconcileNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+		reconcileNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+	}
+	segment := []jettypes.NetworkObject{
+		{Kind: jettypes.ObjectSwitch, Id: "switch-1", Name: "segment"},
+		{Kind: jettypes.ObjectRouter, Id: "router-1", Name: "router"},
+		{Kind: jettypes.ObjectDhcpServer, Id: "dhcp-1", Name: "dhcp"},
+		// nodes refer to none of them, never extra
+		{Kind: jettypes.ObjectRouterPort, Id: "port-1"},
+		{Kind: jettypes.ObjectDhcpProfile, Id: "profile-1"},
+		{Kind: jettypes.ObjectDhcpBinding, Id: "binding-1", Parent: "dhcp-1"},
+	}
+	without := func(id string) []jettypes.NetworkObject {
+		var objects []jettypes.NetworkObject
+		for _, o := range segment {
+			if o.Id != id {
+				objects = append(objects, o)
+			}
+		}
+		return objects
+	}
+
+	tests := []struct {
+		name        string
+		existing    bool
+		objects     []jettypes.NetworkObject
+		want        []string
+		wantObjects []string
+	}{
+		{
+			name:    "no drift",
+			objects: segment,
+		},
+		{
+			name:        "switch missing",
+			objects:     without("switch-1"),
+			want:        []string{DriftObjectMissing},
+			wantObjects: []string{jettypes.ObjectSwitch + " switch-1"},
+		},
+		{
+			name:        "router missing",
+			objects:     without("router-1"),
+			want:        []string{DriftObjectMissing},
+			wantObjects: []string{jettypes.ObjectRouter + " router-1"},
+		},
+		{
+			name:        "dhcp server missing",
+			objects:     without("dhcp-1"),
+			want:        []string{DriftObjectMissing},
+			wantObjects: []string{jettypes.ObjectDhcpServer + " dhcp-1"},
+		},
+		{
+			name: "extra objects",
+			objects: append(without(""),
+				jettypes.NetworkObject{Kind: jettypes.ObjectSwitch, Id: "switch-2", Name: "extra"},
+				jettypes.NetworkObject{Kind: jettypes.ObjectRouter, Id: "router-2", Name: "extra"}),
+			want:        []string{DriftObjectExtra, DriftObjectExtra},
+			wantObjects: []string{jettypes.ObjectSwitch + " switch-2", jettypes.ObjectRouter + " router-2"},
+		},
+		{
+			// extra reported in order vim lists objects, missing in order nodes refer to them
+			name: "extra and missing",
+			objects: []jettypes.NetworkObject{
+				{Kind: jettypes.ObjectDhcpServer, Id: "dhcp-2"},
+				{Kind: jettypes.ObjectRouter, Id: "router-1"},
+			},
+			want: []string{DriftObjectExtra, DriftObjectMissing, DriftObjectMissing},
+			wantObjects: []string{jettypes.ObjectDhcpServer + " dhcp-2",
+				jettypes.ObjectSwitch + " switch-1", jettypes.ObjectDhcpServer + " dhcp-1"},
+		},
+		{
+			name:    "nothing tagged",
+			objects: nil,
+			want:    []string{DriftObjectMissing, DriftObjectMissing, DriftObjectMissing},
+			wantObjects: []string{jettypes.ObjectSwitch + " switch-1",
+				jettypes.ObjectRouter + " router-1", jettypes.ObjectDhcpServer + " dhcp-1"},
+		},
+		{
+			// segment built by hand carries no project tag
+			name:     "existing network not tagged",
+			existing: true,
+			objects:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, n := range nodes {
+				n.SetExistingNetwork(tt.existing)
+			}
+			got := objectDrift(nodes, tt.objects)
+			if len(got) != len(tt.want) {
+				t.Fatalf("objectDrift() = %v, want %v", got, tt.want)
+			}
+			for i, d := range got {
+				if d.Kind != tt.want[i] || d.Object != tt.wantObjects[i] || len(d.Node) > 0 {
+					t.Errorf("objectDrift() %d = %+v, want %s %s", i, d, tt.want[i], tt.wantObjects[i])
+				}
+			}
+		})
+	}
+}
+
+//
+//  Adopted vms attached to a segment built by hand, segment has no project
+//  tag and adopted nodes have no binding, neither reported as drift.
+//
+func TestDeployer_DetectDriftAdopted(t *testing.T) {
+
+	const folder = "/dc/vm/manual"
+
+	tests := []struct {
+		name   string
+		dhcp   bool
+		change func(d *Deployer, p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error
+		want   []string
+	}{
+		{
+			name: "segment with dhcp server",
+			dhcp: true,
+		},
+		{
+			name: "segment without dhcp server",
+		},
+		{
+			name: "vm deleted",
+			dhcp: true,
+			change: func(d *Deployer, p *fake.FakeVim, nodes []*jettypes.NodeTemplate) error {
+				return p.DeleteVm(context.Background(), testProject, nodes[0])
+			},
+			want: []string{DriftVmDeleted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, p, teardown := setupDeployer(t,
+				testTemplate(jettypes.ControlType, 1), testTemplate(jettypes.WorkerType, 1))
+			defer teardown()
+
+			switchUuid := p.AddSegment("manual", true, tt.dhcp)
+			vms := []jettypes.VmInfo{
+				{Name: "manual-1", Tags: []string{"controller"}, IPv4Addr: "172.16.81.10"},
+				{Name: "manual-2", Tags: []string{"worker"}, IPv4Addr: "172.16.81.11"},
+			}
+			for _, vm := range vms {
+				vm.Cluster = "cluster"
+				vm.PoweredOn = true
+				vm.SwitchUuids = []string{switchUuid}
+				if err := p.AddVm(folder, vm); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := d.Adopt(testProject, folder); err != nil {
+				t.Fatalf("Adopt() error = %v", err)
+			}
+
+			if tt.change != nil {
+				nodes, _, err := dbutil.GetDeploymentNodes(d.vim.Database(), testProject)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = tt.change(d, p, nodes); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := d.DetectDrift(testProject)
+			if err != nil {
+				t.Fatalf("DetectDrift() error = %v", err)
+			}
+			if !equalKinds(driftKinds(got), tt.want) {
+				t.Errorf("DetectDrift() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
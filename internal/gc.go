@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Garbage collection of network objects. Every object jettison tagged with a project
+that is not in database is an orphan left by a failed run, orphans deleted in
+order so object deleted only after objects that refer to it.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+)
+
+const (
+	GcDeleted = "deleted"
+	GcDryRun  = "dry-run"
+	GcFailed  = "failed"
+)
+
+// order orphans deleted in
+var gcOrder = []string{
+	jettypes.ObjectDhcpBinding,
+	jettypes.ObjectDhcpServer,
+	jettypes.ObjectDhcpProfile,
+	jettypes.ObjectRouterPort,
+	jettypes.ObjectRouter,
+	jettypes.ObjectSwitch,
+}
+
+/*
+   An orphan network object and a result of its deletion.
+*/
+type GcObject struct {
+	Kind    string `json:"kind" yaml:"kind"`
+	Id      string `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	Project string `json:"project" yaml:"project"`
+	Age     string `json:"age" yaml:"age"`
+	Status  string `json:"status" yaml:"status"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+//
+//  Returns objects of projects that are not known and older than olderThan,
+//  sorted in order they must be deleted.
+//
+func orphans(objects []jettypes.NetworkObject, known map[string]bool,
+	olderThan time.Duration, now time.Time) []jettypes.NetworkObject {
+
+	rank := make(map[string]int)
+	for i, k := range gcOrder {
+		rank[k] = i
+	}
+
+	var result []jettypes.NetworkObject
+	for _, o := range objects {
+		if known[o.Project] {
+			continue
+		}
+		if _, ok := rank[o.Kind]; !ok {
+			continue
+		}
+		if olderThan > 0 && now.Sub(o.Created) < olderThan {
+			continue
+		}
+		result = append(result, o)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return rank[result[i].Kind] < rank[result[j].Kind]
+	})
+
+	return result
+}
+
+//
+//  Deletes network objects tagged with projects that are not in database.
+//  A project that has a journal is known, its objects removed by rollback.
+//  A project torn down with network kept is known, its objects re-used by next deploy.
+//  Only objects older than olderThan collected, zero collects all orphans.
+//
+func (d *Deployer) CollectGarbage(olderThan time.Duration, dryRun bool) ([]GcObject, error) {
+
+	var collected []GcObject
+
+	db := d.vim.Database()
+
+	known := make(map[string]bool)
+	deployments, err := dbutil.GetDeployments(db)
+	if err != nil {
+		return collected, err
+	}
+	for _, dep := range deployments {
+		known[dep.DeploymentName] = true
+	}
+
+	journals, err := dbutil.GetJournalProjects(db)
+	if err != nil {
+		return collected, err
+	}
+	for _, p := range journals {
+		known[p] = true
+	}
+
+	kept, err := dbutil.GetKeptNetworks(db)
+	if err != nil {
+		return collected, err
+	}
+	for _, p := range kept {
+		known[p] = true
+	}
+
+	objects, err := d.vim.TaggedObjects("")
+	if err != nil {
+		return collected, err
+	}
+
+	now := time.Now()
+	failed := 0
+	for _, o := range orphans(objects, known, olderThan, now) {
+		c := GcObject{
+			Kind:    o.Kind,
+			Id:      o.Id,
+			Name:    o.Name,
+			Project: o.Project,
+			Age:     now.Sub(o.Created).Round(time.Second).String(),
+			Status:  GcDryRun,
+		}
+
+		if !dryRun {
+			if d.vim.Interrupted() {
+				return collected, fmt.Errorf("garbage collection interrupted")
+			}
+			c.Status = GcDeleted
+			err = d.vim.DeleteObject(o)
+			if err != nil {
+				c.Status = GcFailed
+				c.Error = err.Error()
+				failed++
+			}
+		}
+
+		collected = append(collected, c)
+	}
+
+	if failed > 0 {
+		return collected, fmt.Errorf("failed delete %d of %d orphan objects", failed, len(collected))
+	}
+
+	if !dryRun {
+		logging.Notification("Deleted", strconv.Itoa(len(collected)), "orphan objects")
+	}
+
+	return collected, nil
+}
+
+//
+// Writes orphan objects in requested format.
+//
+func WriteGcObjects(w io.Writer, format string, objects []GcObject) error {
+
+	if objects == nil {
+		objects = []GcObject{}
+	}
+
+	if done, err := writeEncoded(w, format, objects); done {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KIND\tID\tNAME\tPROJECT\tAGE\tSTATUS\tERROR")
+	for _, o := range objects {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			o.Kind, o.Id, o.Name, o.Project, o.Age, o.Status, o.Error)
+	}
+
+	return tw.Flush()
+}
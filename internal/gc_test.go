@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/fake"
+)
+
+func TestDeployer_CollectGarbage(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		olderThan time.Duration
+		dryRun    bool
+		fault     *fake.Fault
+		wantErr   bool
+		// objects reported and objects of orphan project left after collection
+		wantCollected int
+		wantLeft      int
+		wantStatus    string
+	}{
+		{
+			name:          "collect orphans",
+			wantCollected: 5,
+			wantStatus:    GcDeleted,
+		},
+		{
+			name:          "dry run",
+			dryRun:        true,
+			wantCollected: 5,
+			wantLeft:      5,
+			wantStatus:    GcDryRun,
+		},
+		{
+			name:      "orphans too young",
+			olderThan: time.Hour,
+			wantLeft:  5,
+		},
+		{
+			name:          "delete failed",
+			fault:         &fake.Fault{Method: "DeleteObject", Err: errInjected, Times: 1},
+			wantErr:       true,
+			wantCollected: 5,
+			wantLeft:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, p, teardown := setupDeployer(t)
+			defer teardown()
+
+			// deployed project, project with a journal and a project jettison lost track of
+			deployNodes(t, d, testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"))
+			if _, _, err := p.DeploySegment(ctx, "failed", "segment", "172.16.82.1", 24, true, nil, ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := dbutil.AppendJournal(d.vim.Database(), "failed", JournalSwitch, "switch", "{}"); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := p.DeploySegment(ctx, "orphan", "segment", "172.16.83.1", 24, true, nil, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.fault != nil {
+				p.InjectFault(*tt.fault)
+			}
+
+			got, err := d.CollectGarbage(tt.olderThan, tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CollectGarbage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantCollected {
+				t.Fatalf("CollectGarbage() collected %d objects, want %d %v", len(got), tt.wantCollected, got)
+			}
+			for _, o := range got {
+				if o.Project != "orphan" {
+					t.Errorf("CollectGarbage() collected %s %s of project %s", o.Kind, o.Id, o.Project)
+				}
+				if len(tt.wantStatus) > 0 && o.Status != tt.wantStatus {
+					t.Errorf("CollectGarbage() status of %s = %s, want %s", o.Kind, o.Status, tt.wantStatus)
+				}
+			}
+
+			left, err := p.TaggedObjects(ctx, "orphan")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(left) != tt.wantLeft {
+				t.Errorf("CollectGarbage() left %d orphan objects, want %d", len(left), tt.wantLeft)
+			}
+
+			// objects of known projects never collected
+			for _, project := range []string{testProject, "failed"} {
+				objects, err := p.TaggedObjects(ctx, project)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(objects) < 5 {
+					t.Errorf("CollectGarbage() deleted objects of known project %s", project)
+				}
+			}
+		})
+	}
+}
+
+func Test_orphans(t *testing.T) {
+
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+	young := now.Add(-time.Minute)
+	known := map[string]bool{testProject: true}
+
+	// objects listed in order vim reports them, switch first
+	objects := []jettypes.NetworkObject{
+		{Kind: jettypes.ObjectSwitch, Id: "switch-1", Project: "orphan", Created: old},
+		{Kind: jettypes.ObjectRouter, Id: "router-1", Project: "orphan", Created: old},
+		{Kind: jettypes.ObjectRouterPort, Id: "port-1", Project: "orphan", Created: old},
+		{Kind: jettypes.ObjectDhcpProfile, Id: "profile-1", Project: "orphan", Created: old},
+		{Kind: jettypes.ObjectDhcpServer, Id: "dhcp-1", Project: "orphan", Created: old},
+		{Kind: jettypes.ObjectDhcpBinding, Id: "binding-1", Project: "orphan", Created: old},
+		{Kind: jettypes.ObjectDhcpBinding, Id: "binding-2", Project: "orphan", Created: young},
+		{Kind: jettypes.ObjectSwitch, Id: "switch-2", Project: "young", Created: young},
+		{Kind: jettypes.ObjectSwitch, Id: "switch-3", Project: testProject, Created: old},
+		{Kind: jettypes.ObjectRouter, Id: "router-3", Project: testProject, Created: old},
+		{Kind: "vm", Id: "vm-1", Project: "orphan", Created: old},
+	}
+
+	tests := []struct {
+		name      string
+		objects   []jettypes.NetworkObject
+		olderThan time.Duration
+		want      []string
+	}{
+		{
+			// bindings, servers, profiles, ports, routers and switches, stable within a kind
+			name:    "all orphans in delete order",
+			objects: objects,
+			want: []string{"binding-1", "binding-2", "dhcp-1", "profile-1",
+				"port-1", "router-1", "switch-1", "switch-2"},
+		},
+		{
+			name:      "older than",
+			objects:   objects,
+			olderThan: time.Hour,
+			want:      []string{"binding-1", "dhcp-1", "profile-1", "port-1", "router-1", "switch-1"},
+		},
+		{
+			name:      "all too young",
+			objects:   objects,
+			olderThan: 24 * time.Hour,
+		},
+		{
+			name:    "known project skipped",
+			objects: objects[8:10],
+		},
+		{
+			name:    "no objects",
+			objects: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orphans(tt.objects, known, tt.olderThan, now)
+			var ids []string
+			for _, o := range got {
+				ids = append(ids, o.Id)
+			}
+			if !equalKinds(ids, tt.want) {
+				t.Errorf("orphans() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+//
+//  Network objects of a project killed with network kept are re-used by next
+//  deploy and not collected until project killed again without network kept.
+//
+func TestDeployer_CollectGarbageKeptNetwork(t *testing.T) {
+
+	ctx := context.Background()
+
+	d, p, teardown := setupDeployer(t)
+	defer teardown()
+
+	deployNodes(t, d, testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"))
+	if _, _, err := p.DeploySegment(ctx, testProject, "segment", "172.16.81.1", 24, true, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Teardown(testProject, true, true); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+
+	got, err := d.CollectGarbage(0, false)
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("CollectGarbage() collected %d objects of kept network, want 0 %v", len(got), got)
+	}
+	objects, err := p.TaggedObjects(ctx, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) == 0 {
+		t.Fatalf("Teardown() deleted kept network objects")
+	}
+
+	// project has no nodes, kill without network kept hands objects to gc
+	if err := d.Teardown(testProject, false, true); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	kept, err := dbutil.GetKeptNetworks(d.vim.Database())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 0 {
+		t.Errorf("Teardown() left kept network mark %v", kept)
+	}
+
+	got, err = d.CollectGarbage(0, false)
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if len(got) != len(objects) {
+		t.Errorf("CollectGarbage() collected %d objects, want %d", len(got), len(objects))
+	}
+}
@@ -150,9 +150,22 @@ type AppConfig struct {
 		Vcenter ComputeConnector `yaml:"vcenter"`
 		//	Nsxt             NsxtConfig       `yaml:"nsxt"`
 		ParallelJobs     int    `yaml:"parallelJobs"`
+		CloneJobs        int    `yaml:"cloneJobs"`
+		PowerJobs        int    `yaml:"powerJobs"`
+		NetworkJobs      int    `yaml:"networkJobs"`
 		CleanupOnFailure bool   `yaml:"cleanupOnFailure"`
 		DeploymentName   string `yaml:"deploymentName"`
 
+		// name servers guest customization sets on static nodes and segment dhcp servers hand out
+		DnsServers []string `yaml:"dnsServers"`
+
+		// registered vim provider, shared object of out-of-tree provider takes precedence
+		Provider     string `yaml:"provider"`
+		ProviderPath string `yaml:"providerPath"`
+
+		// executable and arguments of out-of-process provider, takes precedence over both
+		ProviderCommand []string `yaml:"providerCommand"`
+
 		Cluster         KubernetesCluster                 `yaml:"cluster"`
 		Scenario        map[string]*jettypes.NodeTemplate `yaml:"deployment"`
 		Controllers     jettypes.NodeTemplate             `yaml:"controllers1"`
@@ -178,6 +191,38 @@ func (a *AppConfig) GetMaxThreads() int {
 	return a.Infra.ParallelJobs
 }
 
+// Returns limits for each kind of concurrent vim job, a zero limit means
+// a kind bounded only by parallelJobs.
+func (a *AppConfig) GetJobLimits() map[jettypes.JobKind]int {
+	return map[jettypes.JobKind]int{
+		jettypes.CloneJob:   a.Infra.CloneJobs,
+		jettypes.PowerJob:   a.Infra.PowerJobs,
+		jettypes.NetworkJob: a.Infra.NetworkJobs,
+	}
+}
+
+// Returns true if objects created by failed deployment must be deleted.
+func (a *AppConfig) GetCleanupOnFailure() bool {
+	return a.Infra.CleanupOnFailure
+}
+
+// Returns name servers of static nodes.
+func (a *AppConfig) GetDnsServers() []string {
+	return a.Infra.DnsServers
+}
+
+func (a *AppConfig) GetProvider() string {
+	return a.Infra.Provider
+}
+
+func (a *AppConfig) GetProviderPath() string {
+	return a.Infra.ProviderPath
+}
+
+func (a *AppConfig) GetProviderCommand() []string {
+	return a.Infra.ProviderCommand
+}
+
 func (a *AppConfig) GetAnsible() AnsibleEnvironments {
 	return a.Infra.AnsibleDefaults
 }
@@ -0,0 +1,344 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Compensation journal. Each object created by a deployment appended to a journal
+as it created, on failure journal replayed in reverse order so only objects
+created by a deployment are deleted.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+)
+
+const (
+	JournalFolder        = "folder"
+	JournalVm            = "vm"
+	JournalSwitch        = "switch"
+	JournalRouter        = "router"
+	JournalRouterPort    = "routerport"
+	JournalDhcpServer    = "dhcpserver"
+	JournalDhcpBinding   = "dhcpbinding"
+	JournalStaticRoute   = "staticroute"
+	JournalPodAllocation = "podallocation"
+	JournalInventory     = "inventory"
+	JournalNode          = "node"
+	JournalDeployment    = "deployment"
+	JournalAffinityRule  = "affinityrule"
+)
+
+// data required to undo a journal entry
+type journalData struct {
+	Node    *nodeCheckpoint        `json:"node,omitempty"`
+	Segment *segmentCheckpoint     `json:"segment,omitempty"`
+	Network string                 `json:"network,omitempty"`
+	Rule    *jettypes.AffinityRule `json:"rule,omitempty"`
+}
+
+//
+//  Takes node facts required to delete objects created for a node.
+//
+func newNodeCheckpoint(group string, n *jettypes.NodeTemplate) *nodeCheckpoint {
+
+	c := &nodeCheckpoint{
+		Group:      group,
+		Name:       n.Name,
+		IPv4Addr:   n.IPv4AddrStr,
+		UUID:       n.UUID,
+		VimName:    n.GetVimName(),
+		VimCluster: n.VimCluster,
+		FolderPath: n.GetFolderPath(),
+		Mac:        n.Mac,
+		PodCidr:    n.GetCidr(),
+		PodSize:    n.GetAllocation(),
+	}
+	if n.GenericSwitch() != nil {
+		c.SwitchName = n.GenericSwitch().Name()
+		c.SwitchUuid = n.GenericSwitch().Uuid()
+		c.DhcpUuid = n.GenericSwitch().DhcpUuid()
+	}
+	if n.GenericRouter() != nil {
+		c.RouterName = n.GenericRouter().Name()
+		c.RouterUuid = n.GenericRouter().Uuid()
+	}
+
+	return c
+}
+
+//
+//  Sets node facts from a checkpoint.
+//
+func (c *nodeCheckpoint) apply(n *jettypes.NodeTemplate) {
+
+	n.Name = c.Name
+	n.IPv4AddrStr = c.IPv4Addr
+	n.IPv4Addr = net.ParseIP(c.IPv4Addr)
+	n.UUID = c.UUID
+	n.SetVimName(c.VimName)
+	n.VimCluster = c.VimCluster
+	n.SetFolderPath(c.FolderPath)
+	n.Mac = c.Mac
+	if len(c.PodCidr) > 0 {
+		n.SetPodCidr(c.PodCidr)
+		n.PodAllocationSize(c.PodSize)
+	}
+
+	n.SetGenericSwitch(jettypes.NewGenericSwitch(c.SwitchName, c.SwitchUuid, c.DhcpUuid, c.RouterUuid))
+	n.SetGenericRouter(jettypes.NewGenericRouter(c.RouterName, c.RouterUuid))
+}
+
+//
+//  Node that holds network objects of a segment, vim delete calls take a node.
+//
+func (c *segmentCheckpoint) node() *jettypes.NodeTemplate {
+
+	s := jettypes.NewGenericSwitch(c.SwitchName, c.SwitchUuid, c.DhcpUuid, c.RouterUuid)
+	s.SetRouterPortUuid(c.RouterPortUuid)
+	r := jettypes.NewGenericRouter(c.RouterName, c.RouterUuid)
+	r.SetSwitchPortUuid(c.SwitchPortUuid)
+
+	n := &jettypes.NodeTemplate{}
+	n.SetGenericSwitch(s)
+	n.SetGenericRouter(r)
+
+	return n
+}
+
+//
+//  Appends an object created by a deployment to the journal. A failure to record
+//  an entry is not fatal for deployment, object just won't be compensated.
+//
+func (d *Deployer) journal(kind string, ref string, data journalData) {
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return
+	}
+
+	err = dbutil.AppendJournal(d.vim.Database(), d.scenario.DeploymentName, kind, ref, string(b))
+	if err != nil {
+		logging.CriticalMessage("failed record", kind, ref, "in journal", err.Error())
+	}
+}
+
+//
+//  Records network objects of a segment, template is any template attached to a segment.
+//
+func (d *Deployer) journalSegment(key string, template *jettypes.NodeTemplate) {
+
+	s := template.GenericSwitch()
+	r := template.GenericRouter()
+	if s == nil || r == nil {
+		return
+	}
+
+	c := &segmentCheckpoint{
+		Segment:        key,
+		SwitchName:     s.Name(),
+		SwitchUuid:     s.Uuid(),
+		DhcpUuid:       s.DhcpUuid(),
+		RouterPortUuid: s.RouterPortUuid(),
+		RouterName:     r.Name(),
+		RouterUuid:     r.Uuid(),
+		SwitchPortUuid: r.SwitchPortUuid(),
+	}
+
+	// order matters, replay deletes dhcp server and router port before router and switch
+	d.journal(JournalSwitch, s.Uuid(), journalData{Segment: c})
+	d.journal(JournalRouter, r.Uuid(), journalData{Segment: c})
+	d.journal(JournalRouterPort, r.SwitchPortUuid(), journalData{Segment: c})
+	if len(s.DhcpUuid()) > 0 {
+		d.journal(JournalDhcpServer, s.DhcpUuid(), journalData{Segment: c})
+	}
+}
+
+//
+//  Records a folder and vms cloned for a group of nodes.
+//
+func (d *Deployer) journalVms(group string, nodes []*jettypes.NodeTemplate) {
+
+	folders := make(map[string]bool)
+	for _, n := range nodes {
+		f := n.GetFolderPath()
+		if len(f) > 0 && !folders[f] {
+			folders[f] = true
+			d.journal(JournalFolder, f, journalData{Node: newNodeCheckpoint(group, n)})
+		}
+	}
+
+	for _, n := range nodes {
+		d.journal(JournalVm, n.Name, journalData{Node: newNodeCheckpoint(group, n)})
+	}
+}
+
+//
+//  Records an object that belongs to a single node.
+//
+func (d *Deployer) journalNodes(kind string, nodes []*jettypes.NodeTemplate) {
+	for _, n := range nodes {
+		d.journal(kind, n.Name, journalData{Node: newNodeCheckpoint("", n)})
+	}
+}
+
+//
+//  Undo a single journal entry.
+//
+func (d *Deployer) undo(projectName string, e dbutil.JournalEntry, owned map[string]bool) error {
+
+	var data journalData
+	err := json.Unmarshal([]byte(e.Data), &data)
+	if err != nil {
+		return fmt.Errorf("failed parse journal entry %d %v", e.Id, err)
+	}
+
+	node := &jettypes.NodeTemplate{}
+	if data.Node != nil {
+		data.Node.apply(node)
+	} else if data.Segment != nil {
+		node = data.Segment.node()
+	}
+	nodes := []*jettypes.NodeTemplate{node}
+
+	switch e.Kind {
+	case JournalFolder:
+		return d.vim.DeleteFolder(projectName, e.Ref)
+	case JournalVm:
+		return d.vim.DeleteVm(projectName, nodes)
+	case JournalDhcpBinding:
+		return d.vim.DhcpCleanup(projectName, nodes, owned)
+	case JournalDhcpServer:
+		_, err = d.vim.DeleteDhcpServer(node)
+	case JournalRouterPort:
+		_, err = d.vim.DeleteRouterPort(node)
+	case JournalRouter:
+		_, err = d.vim.DeleteRouter(node)
+	case JournalSwitch:
+		_, err = d.vim.DeleteSwitch(node)
+	case JournalStaticRoute:
+		_, err = d.vim.DeleteStaticRoute(projectName, node, data.Network)
+	case JournalPodAllocation:
+		return dbutil.DeleteSubnetAllocation(d.vim.Database(), e.Ref)
+	case JournalInventory:
+		return d.ansibleCleanup(nodes)
+	case JournalNode:
+		return dbutil.DeleteNode(d.vim.Database(), projectName, e.Ref)
+	case JournalAffinityRule:
+		if data.Rule == nil {
+			return fmt.Errorf("journal entry %d has no rule", e.Id)
+		}
+		if err = d.vim.DeleteAffinityRule(data.Rule); err != nil {
+			return err
+		}
+		return dbutil.DeleteAffinityRule(d.vim.Database(), projectName, e.Ref)
+	case JournalDeployment:
+		return dbutil.DeleteDeployment(d.vim.Database(), projectName)
+	default:
+		return fmt.Errorf("unknown journal entry kind %s", e.Kind)
+	}
+
+	return err
+}
+
+//
+//  Replays a journal of a project in reverse order. Entry removed from a journal
+//  once object deleted, entries that failed are kept so replay can be repeated.
+//
+func (d *Deployer) ReplayJournal(projectName string) error {
+
+	db := d.vim.Database()
+
+	entries, err := dbutil.GetJournal(db, projectName)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		logging.Notification("Project", projectName, "has nothing to roll back")
+		return nil
+	}
+
+	logging.Notification("Rolling back", strconv.Itoa(len(entries)), "objects of", projectName)
+
+	// objects project owns listed once for all entries, only bindings checked against it
+	var owned map[string]bool
+	for _, e := range entries {
+		if e.Kind == JournalDhcpBinding {
+			owned, err = d.vim.ownedObjects(projectName)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	failed := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		logging.Notification("Deleting", e.Kind, e.Ref)
+		err := d.undo(projectName, e, owned)
+		if err != nil {
+			logging.CriticalMessage("failed delete", e.Kind, e.Ref, err.Error())
+			failed++
+			continue
+		}
+		err = dbutil.DeleteJournalEntry(db, e.Id)
+		if err != nil {
+			logging.ErrorLogging(err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed roll back %d objects of %s, replay with rollback %s",
+			failed, projectName, projectName)
+	}
+
+	return dbutil.DeleteSteps(db, projectName)
+}
+
+//
+//  Called when deployment failed, either replays a journal or keeps it for manual replay
+//  based on cleanupOnFailure flag.
+//
+func (d *Deployer) compensate(projectName string, cause error) error {
+
+	// interrupted by user, state kept for deploy --resume or rollback
+	if d.vim.Interrupted() {
+		logging.CriticalMessage("Deployment interrupted. Resume with deploy --resume or delete objects with rollback", projectName)
+		return cause
+	}
+
+	if !d.vim.jetConfig.GetCleanupOnFailure() {
+		logging.CriticalMessage("Deployment failed, created objects kept. Delete them with rollback", projectName)
+		return cause
+	}
+
+	err := d.ReplayJournal(projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+	}
+
+	return cause
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/fake"
+)
+
+/**
+  Deploys nodes and records each object in a journal the way deploy does,
+  returns deployed nodes.
+*/
+func journalNodesDeployed(t *testing.T, d *Deployer) []*jettypes.NodeTemplate {
+
+	nodes := []*jettypes.NodeTemplate{
+		testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+		testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+	}
+
+	sw, router, err := d.vim.DeploySegment(testProject, "segment", "172.16.81.1", 24, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		n.SetGenericSwitch(sw)
+		n.SetGenericRouter(router)
+	}
+	d.journalSegment("segment", nodes[0])
+
+	if _, err = d.vim.CloneVms(testProject, nodes); err != nil {
+		t.Fatal(err)
+	}
+	d.journalVms("nodes", nodes)
+
+	if err = d.vim.CreateDhcpBindings(testProject, nodes); err != nil {
+		t.Fatal(err)
+	}
+	d.journalNodes(JournalDhcpBinding, nodes)
+
+	return nodes
+}
+
+func TestDeployer_ReplayJournal(t *testing.T) {
+	tests := []struct {
+		name        string
+		fault       *fake.Fault
+		wantErr     bool
+		wantEntries []string
+		wantVms     int
+		wantObjects int
+	}{
+		{
+			name: "replay all",
+		},
+		{
+			name:        "vm delete failed",
+			fault:       &fake.Fault{Method: "DeleteVm", Node: "test-worker-1", Err: errInjected},
+			wantErr:     true,
+			wantEntries: []string{JournalVm},
+			wantVms:     1,
+		},
+		{
+			// switch deleted by a user, entry kept so replay can be repeated
+			name:        "switch delete failed",
+			fault:       &fake.Fault{Method: "DeleteSwitch", Err: errInjected},
+			wantErr:     true,
+			wantEntries: []string{JournalSwitch},
+			wantObjects: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, p, teardown := setupDeployer(t)
+			defer teardown()
+
+			nodes := journalNodesDeployed(t, d)
+			if tt.fault != nil {
+				p.InjectFault(*tt.fault)
+			}
+
+			listed := countCalls(p, "TaggedObjects")
+			err := d.ReplayJournal(testProject)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReplayJournal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n := countCalls(p, "TaggedObjects") - listed; n > 1 {
+				t.Errorf("ReplayJournal() listed network objects %d times, want once", n)
+			}
+
+			entries, err := dbutil.GetJournal(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.wantEntries) {
+				t.Fatalf("ReplayJournal() left %d entries, want %d", len(entries), len(tt.wantEntries))
+			}
+			for i, e := range entries {
+				if e.Kind != tt.wantEntries[i] {
+					t.Errorf("ReplayJournal() left entry %s, want %s", e.Kind, tt.wantEntries[i])
+				}
+			}
+
+			vms, err := d.vim.DescribeVms(nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			existing := 0
+			for _, vm := range vms {
+				if vm.Exists {
+					existing++
+				}
+			}
+			if existing != tt.wantVms {
+				t.Errorf("ReplayJournal() left %d vms, want %d", existing, tt.wantVms)
+			}
+
+			objects, err := p.TaggedObjects(context.Background(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != tt.wantObjects {
+				t.Errorf("ReplayJournal() left %d network objects, want %d %v", len(objects), tt.wantObjects, objects)
+			}
+
+			// failed entries replayed once vim recovered
+			p.ClearFaults()
+			if err = d.ReplayJournal(testProject); err != nil {
+				t.Errorf("ReplayJournal() second run error = %v", err)
+			}
+			entries, _ = dbutil.GetJournal(d.vim.Database(), testProject)
+			if len(entries) != 0 {
+				t.Errorf("ReplayJournal() second run left %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestDeployer_compensate(t *testing.T) {
+	tests := []struct {
+		name             string
+		cleanupOnFailure bool
+		wantEntries      bool
+	}{
+		{
+			name:             "rollback on failure",
+			cleanupOnFailure: true,
+			wantEntries:      false,
+		},
+		{
+			name:             "keep for manual rollback",
+			cleanupOnFailure: false,
+			wantEntries:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, _, teardown := setupDeployer(t)
+			defer teardown()
+
+			d.vim.jetConfig.Infra.CleanupOnFailure = tt.cleanupOnFailure
+			journalNodesDeployed(t, d)
+
+			err := d.compensate(testProject, errInjected)
+			if err != errInjected {
+				t.Errorf("compensate() error = %v, want %v", err, errInjected)
+			}
+
+			entries, err := dbutil.GetJournal(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(entries) > 0) != tt.wantEntries {
+				t.Errorf("compensate() left %d entries, want entries %v", len(entries), tt.wantEntries)
+			}
+		})
+	}
+}
+
+//
+//  Scale journals a vm and a binding only once vim created it,
+//  failed clone or binding has nothing to undo.
+//
+func TestDeployer_scaleWorkersJournal(t *testing.T) {
+	tests := []struct {
+		name         string
+		fault        fake.Fault
+		wantVms      int
+		wantBindings int
+	}{
+		{
+			name:    "clone failed",
+			fault:   fake.Fault{Method: "CloneVms", Err: errInjected, Times: 1},
+			wantVms: 1,
+		},
+		{
+			name:    "binding failed",
+			fault:   fake.Fault{Method: "CreateDhcpBindings", Err: errInjected, Times: 1},
+			wantVms: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			worker := testTemplate(jettypes.WorkerType, 1)
+			worker.DesiredAddress = "172.16.81.0/24"
+			d, p, teardown := setupDeployer(t, testTemplate(jettypes.ControlType, 1), worker)
+			defer teardown()
+			d.vim.jetConfig.Infra.Cluster.ClusterCidr = "10.200.0.0/16"
+			d.vim.jetConfig.Infra.Cluster.AllocateSize = 24
+
+			deployNodes(t, d,
+				testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+				testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"))
+
+			p.InjectFault(tt.fault)
+			if err := d.scaleWorkers(testProject, 2); err == nil {
+				t.Fatalf("scaleWorkers() expected error")
+			}
+
+			entries, err := dbutil.GetJournal(d.vim.Database(), testProject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vms, bindings := 0, 0
+			for _, e := range entries {
+				switch e.Kind {
+				case JournalVm:
+					vms++
+				case JournalDhcpBinding:
+					bindings++
+				}
+			}
+			if vms != tt.wantVms || bindings != tt.wantBindings {
+				t.Errorf("scaleWorkers() journaled %d vms %d bindings, want %d %d",
+					vms, bindings, tt.wantVms, tt.wantBindings)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Dry-run of a deployment. A plan computes node names, addresses, pod
+networks, network objects and ansible artifacts without calling a vim.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+
+	"github.com/spyroot/jettison/ansibleutil"
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/netpool"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	PlanCreate  = "create"
+	PlanDestroy = "destroy"
+	PlanKeep    = "keep"
+)
+
+/*
+   A node that a deployment would clone.
+*/
+type PlanNode struct {
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type" yaml:"type"`
+	IPv4Addr string `json:"ipv4Address" yaml:"ipv4Address"`
+	PodCidr  string `json:"podCidr,omitempty" yaml:"podCidr,omitempty"`
+	Segment  string `json:"segment" yaml:"segment"`
+}
+
+/*
+   Network objects DeploySegment would create for a single segment.
+*/
+type PlanSegment struct {
+	Name           string   `json:"name" yaml:"name"`
+	Tags           []string `json:"tags" yaml:"tags"`
+	DownlinkPort   string   `json:"downlinkPort" yaml:"downlinkPort"`
+	Tier0Link      bool     `json:"tier0Link" yaml:"tier0Link"`
+	DhcpServerName string   `json:"dhcpServerName,omitempty" yaml:"dhcpServerName,omitempty"`
+	DhcpServer     string   `json:"dhcpServer,omitempty" yaml:"dhcpServer,omitempty"`
+	DhcpBindings   []string `json:"dhcpBindings" yaml:"dhcpBindings"`
+	StaticRoutes   []string `json:"staticRoutes,omitempty" yaml:"staticRoutes,omitempty"`
+}
+
+/*
+   A difference between a plan and a state stored in database.
+*/
+type PlanChange struct {
+	Action   string `json:"action" yaml:"action"`
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type" yaml:"type"`
+	IPv4Addr string `json:"ipv4Address" yaml:"ipv4Address"`
+}
+
+type DeploymentPlan struct {
+	ProjectName string        `json:"project" yaml:"project"`
+	Nodes       []PlanNode    `json:"nodes" yaml:"nodes"`
+	Segments    []PlanSegment `json:"segments" yaml:"segments"`
+	Inventory   string        `json:"inventory" yaml:"inventory"`
+	Playbook    string        `json:"playbook" yaml:"playbook"`
+	Changes     []PlanChange  `json:"changes,omitempty" yaml:"changes,omitempty"`
+}
+
+//
+//  Assigns address to each node from the same pools a deployment uses.
+//  Address that a node already has is marked in use, the rest
+//  allocated from a pool of node template.
+//
+func (d *Deployer) planAddresses(nodes []*jettypes.NodeTemplate) error {
+
+	ok, workers := d.scenario.Template(jettypes.WorkerType)
+	if !ok {
+		return fmt.Errorf("scenario has no worker template")
+	}
+	ok, controllers := d.scenario.Template(jettypes.ControlType)
+	if !ok {
+		return fmt.Errorf("scenario has no controller template")
+	}
+	ok, ingress := d.scenario.Template(jettypes.IngressType)
+	if !ok {
+		return fmt.Errorf("scenario has no ingress template")
+	}
+
+	var dep Deployment
+	dep.DeploymentName = d.scenario.DeploymentName
+	dep.AddressPools = make(map[string]netpool.SimpleIpManager)
+
+	err := dep.buildPools(workers.DesiredAddress, workers.IPv4Net.String(),
+		controllers.DesiredAddress, controllers.IPv4Net.String(),
+		ingress.DesiredAddress, ingress.IPv4Net.String())
+	if err != nil {
+		return fmt.Errorf("failed initilize ip pools %s", err)
+	}
+
+	if ingress.IPv4Addr != nil {
+		if pool, ok := dep.AddressPools[ingress.IPv4Net.String()]; ok {
+			pool.SetInUse(ingress.IPv4Addr.String())
+		}
+	}
+
+	for _, n := range nodes {
+		if n.IPv4Net == nil || len(n.IPv4AddrStr) == 0 {
+			continue
+		}
+		if pool, ok := dep.AddressPools[n.IPv4Net.String()]; ok {
+			pool.SetInUse(n.IPv4AddrStr)
+		}
+	}
+
+	for _, n := range nodes {
+		if len(n.IPv4AddrStr) > 0 {
+			continue
+		}
+		if n.Type == jettypes.IngressType && ingress.IPv4Addr != nil {
+			n.IPv4Addr = ingress.IPv4Addr
+			n.IPv4AddrStr = ingress.IPv4Addr.String()
+			continue
+		}
+		if n.IPv4Net == nil {
+			return fmt.Errorf("node %s has no network", n.Name)
+		}
+		ipAddr, err := dep.allocateAddress(n.IPv4Net.String())
+		if err != nil {
+			return fmt.Errorf("failed allocate address for %s: %v", n.Name, err)
+		}
+		n.IPv4Addr = net.ParseIP(ipAddr)
+		n.IPv4AddrStr = ipAddr
+	}
+
+	return nil
+}
+
+//
+//  Allocates a pod network to each worker, same way AllocatePodNetwork does
+//  but nothing is stored in database.
+//
+func (d *Deployer) planPodNetworks(nodes []*jettypes.NodeTemplate) (map[string]string, error) {
+
+	cluster := d.vim.jetConfig.GetCluster()
+	podNetworks := make(map[string]string)
+
+	pool, err := netpool.NewSubnetPool(cluster.ClusterCidr, uint(cluster.AllocateSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed create subnet pool manager %v", err)
+	}
+
+	for _, n := range nodes {
+		if n.Type != jettypes.WorkerType {
+			continue
+		}
+		addrBlock, err := pool.AllocateSubnet()
+		if err != nil {
+			return nil, fmt.Errorf("failed allocate ip block for a pod error: %v", err)
+		}
+		podNetworks[n.Name] = fmt.Sprintf("%s/%d", addrBlock.String(), cluster.AllocateSize)
+	}
+
+	return podNetworks, nil
+}
+
+//
+//  Describes network objects for each segment, the same objects DeploySegment creates.
+//
+func (d *Deployer) planSegments(nodes []*jettypes.NodeTemplate,
+	podNetworks map[string]string) ([]PlanSegment, map[string]string, error) {
+
+	projectName := d.scenario.DeploymentName
+	nodeSegment := make(map[string]string)
+	var segments []PlanSegment
+
+	// template to segment mapping, nodes inherit segment from a template
+	templateSegment := make(map[*jettypes.NodeTemplate]string)
+	for _, seg := range d.networkSegments.Segments() {
+		for _, t := range seg.Segments() {
+			templateSegment[t] = seg.SegmentName()
+		}
+	}
+	groupSegment := make(map[string]string)
+	for k, t := range d.scenario.DeploymentTemplates() {
+		groupSegment[k] = templateSegment[t]
+	}
+	for k, group := range d.scenario.nodesGroup {
+		for _, n := range group {
+			nodeSegment[n.Name] = groupSegment[k]
+		}
+	}
+
+	for _, seg := range d.networkSegments.Segments() {
+		gateway, sharedNet, err := sharedAttributes(seg.Segments())
+		if err != nil {
+			return nil, nil, err
+		}
+		prefixLen, _ := sharedNet.Mask.Size()
+		if prefixLen < 8 || prefixLen >= 32 {
+			return nil, nil, fmt.Errorf(" subnet mask need to between larger than 7 bit and less than 32 bits")
+		}
+
+		gwAddr := net.ParseIP(gateway)
+		if gwAddr == nil {
+			return nil, nil, fmt.Errorf("invalid gateway format")
+		}
+
+		s := PlanSegment{
+			Name:         seg.SegmentName(),
+			Tags:         []string{"jettison-tenant=" + projectName, "segment=" + seg.SegmentName()},
+			DownlinkPort: fmt.Sprintf("%s/%d", gateway, prefixLen),
+			Tier0Link:    true,
+		}
+		// segment of static nodes deployed without dhcp server
+		if len(dhcpNodes(seg.Segments())) > 0 {
+			s.DhcpServerName = projectName + "-" + seg.SegmentName()
+			s.DhcpServer = fmt.Sprintf("%s/%d", netpool.NextIP(gwAddr, 1).String(), prefixLen)
+		}
+
+		for _, n := range nodes {
+			if nodeSegment[n.Name] != seg.SegmentName() {
+				continue
+			}
+			if !n.Static {
+				s.DhcpBindings = append(s.DhcpBindings, n.Name+" "+n.IPv4AddrStr)
+			}
+			if podNetwork, ok := podNetworks[n.Name]; ok {
+				s.StaticRoutes = append(s.StaticRoutes, podNetwork+" via "+n.IPv4AddrStr)
+			}
+		}
+		segments = append(segments, s)
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Name < segments[j].Name })
+
+	return segments, nodeSegment, nil
+}
+
+//
+//  Compares a plan with nodes stored in database. Node names are generated,
+//  so a node matched by type and address.
+//
+func planChanges(planned []PlanNode, existing []*jettypes.NodeTemplate) []PlanChange {
+
+	var changes []PlanChange
+
+	deployed := make(map[string]*jettypes.NodeTemplate)
+	for _, n := range existing {
+		deployed[n.GetNodeTypeAsString()+"/"+n.IPv4AddrStr] = n
+	}
+
+	for _, n := range planned {
+		key := n.Type + "/" + n.IPv4Addr
+		if old, ok := deployed[key]; ok {
+			changes = append(changes, PlanChange{PlanKeep, old.Name, n.Type, n.IPv4Addr})
+			delete(deployed, key)
+			continue
+		}
+		changes = append(changes, PlanChange{PlanCreate, n.Name, n.Type, n.IPv4Addr})
+	}
+
+	for _, n := range existing {
+		key := n.GetNodeTypeAsString() + "/" + n.IPv4AddrStr
+		if _, ok := deployed[key]; ok {
+			changes = append(changes, PlanChange{PlanDestroy, n.Name, n.GetNodeTypeAsString(), n.IPv4AddrStr})
+		}
+	}
+
+	return changes
+}
+
+//
+//  Computes entire deployment without touching vim or nsx-t.
+//  Inventory and playbook rendered in memory, nothing written to disk or database.
+//
+func (d *Deployer) Plan() (*DeploymentPlan, error) {
+
+	if d.scenario == nil {
+		return nil, fmt.Errorf("scenario is nil")
+	}
+
+	err := d.buildSegments()
+	if err != nil {
+		return nil, fmt.Errorf("failed build network segment list %v", err)
+	}
+
+	nodes := d.nodeSlice()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	err = d.planAddresses(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	podNetworks, err := d.planPodNetworks(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DeploymentPlan{ProjectName: d.scenario.DeploymentName}
+
+	segments, nodeSegment, err := d.planSegments(nodes, podNetworks)
+	if err != nil {
+		return nil, err
+	}
+	plan.Segments = segments
+
+	for _, n := range nodes {
+		plan.Nodes = append(plan.Nodes, PlanNode{
+			Name:     n.Name,
+			Type:     n.GetNodeTypeAsString(),
+			IPv4Addr: n.IPv4AddrStr,
+			PodCidr:  podNetworks[n.Name],
+			Segment:  nodeSegment[n.Name],
+		})
+	}
+
+	inventory := &ansibleutil.AnsibleInventoryHosts{}
+	err = d.addInventoryHosts(inventory, d.scenario.DeploymentName, nodes)
+	if err != nil {
+		return nil, err
+	}
+	out, err := yaml.Marshal(inventory)
+	if err != nil {
+		return nil, err
+	}
+	plan.Inventory = string(out)
+
+	var playbook bytes.Buffer
+	err = d.writeAnsiblePlaybook(&playbook)
+	if err != nil {
+		return nil, fmt.Errorf("failed generate playbook %v", err)
+	}
+	plan.Playbook = playbook.String()
+
+	existing, _, err := dbutil.GetDeploymentNodes(d.vim.db, d.scenario.DeploymentName)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		plan.Changes = planChanges(plan.Nodes, existing)
+	}
+
+	return plan, nil
+}
+
+//
+//  Writes a plan in requested format.
+//
+func WritePlan(w io.Writer, format string, plan *DeploymentPlan) error {
+
+	if done, err := writeEncoded(w, format, plan); done {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(w, "Project: %s\n\nNodes:\n", plan.ProjectName)
+	for _, n := range plan.Nodes {
+		_, _ = fmt.Fprintf(w, "  + %-20s %-10s %-15s %-18s %s\n",
+			n.Name, n.Type, n.IPv4Addr, n.PodCidr, n.Segment)
+	}
+
+	_, _ = fmt.Fprintln(w, "\nNetwork:")
+	for _, s := range plan.Segments {
+		_, _ = fmt.Fprintf(w, "  segment %s tags %v\n", s.Name, s.Tags)
+		_, _ = fmt.Fprintln(w, "    + logical switch")
+		_, _ = fmt.Fprintf(w, "    + tier-1 router downlink %s\n", s.DownlinkPort)
+		if s.Tier0Link {
+			_, _ = fmt.Fprintln(w, "    + tier-0 link")
+		}
+		if len(s.DhcpServerName) > 0 {
+			_, _ = fmt.Fprintf(w, "    + dhcp server %s %s\n", s.DhcpServerName, s.DhcpServer)
+		}
+		for _, b := range s.DhcpBindings {
+			_, _ = fmt.Fprintf(w, "    + dhcp binding %s\n", b)
+		}
+		for _, r := range s.StaticRoutes {
+			_, _ = fmt.Fprintf(w, "    + static route %s\n", r)
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "\nAnsible inventory:\n%s\nAnsible playbook:\n%s\n", plan.Inventory, plan.Playbook)
+
+	if len(plan.Changes) > 0 {
+		_, _ = fmt.Fprintln(w, "Changes against deployed state:")
+		for _, c := range plan.Changes {
+			var mark = "="
+			switch c.Action {
+			case PlanCreate:
+				mark = "+"
+			case PlanDestroy:
+				mark = "-"
+			}
+			_, _ = fmt.Fprintf(w, "  %s %-20s %-10s %s\n", mark, c.Name, c.Type, c.IPv4Addr)
+		}
+	}
+
+	return nil
+}
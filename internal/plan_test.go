@@ -0,0 +1,344 @@
+package internal
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+/**
+  Creates a deployer with controller, worker and ingress templates each on
+  own network, nodes take addresses from a /29 pool of a template.
+*/
+func setupPlan(t *testing.T) (*Deployer, func()) {
+
+	templates := []struct {
+		nodeType jettypes.NodeType
+		network  string
+		pool     string
+	}{
+		{jettypes.ControlType, "172.16.82.0/24", "172.16.82.8/29"},
+		{jettypes.WorkerType, "172.16.81.0/24", "172.16.81.8/29"},
+		{jettypes.IngressType, "172.16.83.0/24", "172.16.83.8/29"},
+	}
+
+	var list []*jettypes.NodeTemplate
+	for _, v := range templates {
+		template := testTemplate(v.nodeType, 1)
+		_, template.IPv4Net, _ = net.ParseCIDR(v.network)
+		template.DesiredAddress = v.pool
+		list = append(list, template)
+	}
+
+	d, _, teardown := setupDeployer(t, list...)
+	d.vim.jetConfig.Infra.Cluster.ClusterCidr = "10.200.0.0/16"
+	d.vim.jetConfig.Infra.Cluster.AllocateSize = 24
+
+	return d, teardown
+}
+
+// Returns a node attached to a network of a template, empty ip is allocated by a plan.
+func planNode(d *Deployer, name string, nodeType jettypes.NodeType, ip string) *jettypes.NodeTemplate {
+	_, template := d.scenario.Template(nodeType)
+	n := testNode(name, nodeType, ip)
+	if len(ip) == 0 {
+		n.IPv4Addr = nil
+	}
+	n.IPv4Net = template.IPv4Net
+	return n
+}
+
+func TestDeployer_planAddresses(t *testing.T) {
+
+	type node struct {
+		name     string
+		nodeType jettypes.NodeType
+		ip       string
+	}
+
+	tests := []struct {
+		name    string
+		nodes   []node
+		ingress string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "new deployment",
+			nodes: []node{
+				{"test-controller-1", jettypes.ControlType, ""},
+				{"test-worker-1", jettypes.WorkerType, ""},
+				{"test-worker-2", jettypes.WorkerType, ""},
+			},
+			want: map[string]string{
+				"test-controller-1": "172.16.82.9",
+				"test-worker-1":     "172.16.81.9",
+				"test-worker-2":     "172.16.81.10",
+			},
+		},
+		{
+			name: "existing deployment keeps addresses",
+			nodes: []node{
+				{"test-controller-1", jettypes.ControlType, "172.16.82.9"},
+				{"test-worker-1", jettypes.WorkerType, "172.16.81.9"},
+				{"test-worker-2", jettypes.WorkerType, "172.16.81.11"},
+				{"test-worker-3", jettypes.WorkerType, ""},
+				{"test-worker-4", jettypes.WorkerType, ""},
+			},
+			want: map[string]string{
+				"test-controller-1": "172.16.82.9",
+				"test-worker-1":     "172.16.81.9",
+				"test-worker-2":     "172.16.81.11",
+				"test-worker-3":     "172.16.81.10",
+				"test-worker-4":     "172.16.81.12",
+			},
+		},
+		{
+			name: "ingress takes address of a template",
+			nodes: []node{
+				{"test-controller-1", jettypes.ControlType, ""},
+				{"test-ingress-1", jettypes.IngressType, ""},
+			},
+			ingress: "172.16.83.100",
+			want: map[string]string{
+				"test-controller-1": "172.16.82.9",
+				"test-ingress-1":    "172.16.83.100",
+			},
+		},
+		{
+			name: "pool exhausted",
+			nodes: []node{
+				{"test-worker-1", jettypes.WorkerType, "172.16.81.9"},
+				{"test-worker-2", jettypes.WorkerType, ""},
+				{"test-worker-3", jettypes.WorkerType, ""},
+				{"test-worker-4", jettypes.WorkerType, ""},
+				{"test-worker-5", jettypes.WorkerType, ""},
+				{"test-worker-6", jettypes.WorkerType, ""},
+				{"test-worker-7", jettypes.WorkerType, ""},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, teardown := setupPlan(t)
+			defer teardown()
+
+			if len(tt.ingress) > 0 {
+				_, ingress := d.scenario.Template(jettypes.IngressType)
+				ingress.IPv4Addr = net.ParseIP(tt.ingress)
+			}
+
+			var nodes []*jettypes.NodeTemplate
+			for _, n := range tt.nodes {
+				nodes = append(nodes, planNode(d, n.name, n.nodeType, n.ip))
+			}
+
+			err := d.planAddresses(nodes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planAddresses() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := make(map[string]string)
+			for _, n := range nodes {
+				got[n.Name] = n.IPv4AddrStr
+				if !n.IPv4Addr.Equal(net.ParseIP(n.IPv4AddrStr)) {
+					t.Errorf("planAddresses() node %s address %v, string %s", n.Name, n.IPv4Addr, n.IPv4AddrStr)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planAddresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployer_planAddressesErrors(t *testing.T) {
+
+	t.Run("node without network", func(t *testing.T) {
+		d, teardown := setupPlan(t)
+		defer teardown()
+
+		n := planNode(d, "test-worker-1", jettypes.WorkerType, "")
+		n.IPv4Net = nil
+		if err := d.planAddresses([]*jettypes.NodeTemplate{n}); err == nil {
+			t.Errorf("planAddresses() expected error")
+		}
+	})
+
+	t.Run("scenario without ingress", func(t *testing.T) {
+		d, teardown := setupPlan(t)
+		defer teardown()
+
+		delete(d.scenario.nodeTemplates, jettypes.IngressType.String())
+		n := planNode(d, "test-worker-1", jettypes.WorkerType, "")
+		if err := d.planAddresses([]*jettypes.NodeTemplate{n}); err == nil {
+			t.Errorf("planAddresses() expected error")
+		}
+	})
+}
+
+func TestDeployer_planPodNetworks(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		clusterCidr  string
+		allocateSize int
+		want         map[string]string
+		wantErr      bool
+	}{
+		{
+			name:         "worker gets a block of cluster cidr",
+			clusterCidr:  "10.200.0.0/16",
+			allocateSize: 24,
+			want: map[string]string{
+				"test-worker-1": "10.200.0.0/24",
+				"test-worker-2": "10.200.1.0/24",
+			},
+		},
+		{
+			name:         "smaller blocks",
+			clusterCidr:  "10.200.0.0/16",
+			allocateSize: 26,
+			want: map[string]string{
+				"test-worker-1": "10.200.0.0/26",
+				"test-worker-2": "10.200.0.64/26",
+			},
+		},
+		{
+			name:         "cluster cidr exhausted",
+			clusterCidr:  "10.200.0.0/24",
+			allocateSize: 24,
+			wantErr:      true,
+		},
+		{
+			name:         "invalid cluster cidr",
+			clusterCidr:  "10.200.0.0",
+			allocateSize: 24,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			d, teardown := setupPlan(t)
+			defer teardown()
+			d.vim.jetConfig.Infra.Cluster.ClusterCidr = tt.clusterCidr
+			d.vim.jetConfig.Infra.Cluster.AllocateSize = tt.allocateSize
+
+			// controller of an existing deployment has no pod network
+			nodes := []*jettypes.NodeTemplate{
+				planNode(d, "test-controller-1", jettypes.ControlType, "172.16.82.9"),
+				planNode(d, "test-worker-1", jettypes.WorkerType, "172.16.81.9"),
+				planNode(d, "test-worker-2", jettypes.WorkerType, ""),
+			}
+
+			got, err := d.planPodNetworks(nodes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planPodNetworks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planPodNetworks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_planChanges(t *testing.T) {
+
+	existing := func() []*jettypes.NodeTemplate {
+		return []*jettypes.NodeTemplate{
+			testNode("test-controller-1", jettypes.ControlType, "172.16.82.9"),
+			testNode("test-worker-1", jettypes.WorkerType, "172.16.81.9"),
+			testNode("test-worker-2", jettypes.WorkerType, "172.16.81.10"),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		planned  []PlanNode
+		existing []*jettypes.NodeTemplate
+		want     []PlanChange
+	}{
+		{
+			name: "nothing deployed",
+			planned: []PlanNode{
+				{Name: "test-controller-1", Type: "Controller", IPv4Addr: "172.16.82.9"},
+			},
+			want: []PlanChange{
+				{PlanCreate, "test-controller-1", "Controller", "172.16.82.9"},
+			},
+		},
+		{
+			name: "same deployment kept under deployed names",
+			planned: []PlanNode{
+				{Name: "new-controller-1", Type: "Controller", IPv4Addr: "172.16.82.9"},
+				{Name: "new-worker-1", Type: "Worker", IPv4Addr: "172.16.81.9"},
+				{Name: "new-worker-2", Type: "Worker", IPv4Addr: "172.16.81.10"},
+			},
+			existing: existing(),
+			want: []PlanChange{
+				{PlanKeep, "test-controller-1", "Controller", "172.16.82.9"},
+				{PlanKeep, "test-worker-1", "Worker", "172.16.81.9"},
+				{PlanKeep, "test-worker-2", "Worker", "172.16.81.10"},
+			},
+		},
+		{
+			name: "worker added",
+			planned: []PlanNode{
+				{Name: "test-controller-1", Type: "Controller", IPv4Addr: "172.16.82.9"},
+				{Name: "test-worker-1", Type: "Worker", IPv4Addr: "172.16.81.9"},
+				{Name: "test-worker-2", Type: "Worker", IPv4Addr: "172.16.81.10"},
+				{Name: "test-worker-3", Type: "Worker", IPv4Addr: "172.16.81.11"},
+			},
+			existing: existing(),
+			want: []PlanChange{
+				{PlanKeep, "test-controller-1", "Controller", "172.16.82.9"},
+				{PlanKeep, "test-worker-1", "Worker", "172.16.81.9"},
+				{PlanKeep, "test-worker-2", "Worker", "172.16.81.10"},
+				{PlanCreate, "test-worker-3", "Worker", "172.16.81.11"},
+			},
+		},
+		{
+			name: "worker removed",
+			planned: []PlanNode{
+				{Name: "test-controller-1", Type: "Controller", IPv4Addr: "172.16.82.9"},
+				{Name: "test-worker-1", Type: "Worker", IPv4Addr: "172.16.81.9"},
+			},
+			existing: existing(),
+			want: []PlanChange{
+				{PlanKeep, "test-controller-1", "Controller", "172.16.82.9"},
+				{PlanKeep, "test-worker-1", "Worker", "172.16.81.9"},
+				{PlanDestroy, "test-worker-2", "Worker", "172.16.81.10"},
+			},
+		},
+		{
+			name: "address taken by other type replaced",
+			planned: []PlanNode{
+				{Name: "test-controller-1", Type: "Controller", IPv4Addr: "172.16.82.9"},
+				{Name: "test-ingress-1", Type: "Ingress", IPv4Addr: "172.16.81.10"},
+				{Name: "test-worker-1", Type: "Worker", IPv4Addr: "172.16.81.9"},
+			},
+			existing: existing(),
+			want: []PlanChange{
+				{PlanKeep, "test-controller-1", "Controller", "172.16.82.9"},
+				{PlanCreate, "test-ingress-1", "Ingress", "172.16.81.10"},
+				{PlanKeep, "test-worker-1", "Worker", "172.16.81.9"},
+				{PlanDestroy, "test-worker-2", "Worker", "172.16.81.10"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planChanges(tt.planned, tt.existing); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
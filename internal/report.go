@@ -0,0 +1,230 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Reporting routines for deployments stored in jettison database.
+Each report can be rendered as a table, json or yaml.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	OutputTable = "table"
+	OutputJson  = "json"
+	OutputYaml  = "yaml"
+)
+
+/*
+   A state of single node as it stored in database.
+*/
+type NodeStatus struct {
+	Name       string `json:"name" yaml:"name"`
+	Type       string `json:"type" yaml:"type"`
+	VimName    string `json:"vimName" yaml:"vimName"`
+	IPv4Addr   string `json:"ipv4Address" yaml:"ipv4Address"`
+	MacAddr    string `json:"macAddress" yaml:"macAddress"`
+	SwitchUuid string `json:"switchUuid" yaml:"switchUuid"`
+	RouterUuid string `json:"routerUuid" yaml:"routerUuid"`
+	DhcpUuid   string `json:"dhcpUuid" yaml:"dhcpUuid"`
+	PodCidr    string `json:"podCidr,omitempty" yaml:"podCidr,omitempty"`
+	// empty for a node that keeps template hardware
+	Hardware *jettypes.Hardware `json:"hardware,omitempty" yaml:"hardware,omitempty"`
+}
+
+//
+// Returns status of each node in a project based on snapshot stored in database.
+//
+func DeploymentStatus(db *sql.DB, projectName string) ([]NodeStatus, error) {
+
+	var status []NodeStatus
+
+	nodes, _, err := dbutil.GetDeploymentNodes(db, projectName)
+	if err != nil {
+		return status, err
+	}
+
+	if len(nodes) == 0 {
+		return status, fmt.Errorf("project %s not found", projectName)
+	}
+
+	allocations, err := dbutil.GetDeploymentAllocations(db, projectName)
+	if err != nil {
+		return status, err
+	}
+
+	hardware, err := dbutil.GetDeploymentHardware(db, projectName)
+	if err != nil {
+		return status, err
+	}
+
+	for _, n := range nodes {
+		s := NodeStatus{
+			Name:       n.Name,
+			Type:       n.GetNodeTypeAsString(),
+			VimName:    n.GetVimName(),
+			IPv4Addr:   n.IPv4AddrStr,
+			SwitchUuid: n.SwitchUuid(),
+			RouterUuid: n.RouterUuid(),
+			DhcpUuid:   n.DhcpServerUuid(),
+			PodCidr:    allocations[n.Name],
+		}
+		if len(n.Mac) > 0 {
+			s.MacAddr = n.Mac[0]
+		}
+		if hw, ok := hardware[n.Name]; ok {
+			s.Hardware = &hw
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// serialize a value in json or yaml format
+func writeEncoded(w io.Writer, format string, v interface{}) (bool, error) {
+
+	switch format {
+	case OutputJson:
+		p, err := json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			return true, err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", p)
+		return true, err
+	case OutputYaml:
+		p, err := yaml.Marshal(v)
+		if err != nil {
+			return true, err
+		}
+		_, err = w.Write(p)
+		return true, err
+	case OutputTable, "":
+		return false, nil
+	default:
+		return true, fmt.Errorf("unknown output format %s", format)
+	}
+}
+
+//
+// Writes list of deployment in requested format.
+//
+func WriteDeployments(w io.Writer, format string, deployments []dbutil.DeploymentRecord) error {
+
+	if deployments == nil {
+		deployments = []dbutil.DeploymentRecord{}
+	}
+
+	if done, err := writeEncoded(w, format, deployments); done {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNAME\tNODES")
+	for _, d := range deployments {
+		_, _ = fmt.Fprintln(tw, strconv.Itoa(d.Id)+"\t"+d.DeploymentName+"\t"+strconv.Itoa(d.NumNodes))
+	}
+
+	return tw.Flush()
+}
+
+//
+// Writes status of each node in requested format.
+//
+func WriteNodeStatus(w io.Writer, format string, status []NodeStatus) error {
+
+	if status == nil {
+		status = []NodeStatus{}
+	}
+
+	if done, err := writeEncoded(w, format, status); done {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tTYPE\tVIM NAME\tIPV4\tMAC\tSWITCH\tROUTER\tDHCP\tPOD CIDR\tHARDWARE")
+	for _, s := range status {
+		hw := "template"
+		if s.Hardware != nil {
+			hw = s.Hardware.String()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Name, s.Type, s.VimName, s.IPv4Addr, s.MacAddr,
+			s.SwitchUuid, s.RouterUuid, s.DhcpUuid, s.PodCidr, hw)
+	}
+
+	return tw.Flush()
+}
+
+/*
+   A result of vim operation executed for a node.
+*/
+type NodeResultStatus struct {
+	Op       string `json:"op" yaml:"op"`
+	Node     string `json:"node" yaml:"node"`
+	TaskId   string `json:"taskId,omitempty" yaml:"taskId,omitempty"`
+	Duration string `json:"duration" yaml:"duration"`
+	Status   string `json:"status" yaml:"status"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+//
+// Writes result of each node operation in requested format.
+//
+func WriteNodeResults(w io.Writer, format string, results jettypes.NodeResults) error {
+
+	status := []NodeResultStatus{}
+	for _, r := range results {
+		s := NodeResultStatus{
+			Op:       r.Op,
+			Node:     r.Node,
+			TaskId:   r.TaskId,
+			Duration: r.Duration.Round(time.Millisecond).String(),
+			Status:   "ok",
+		}
+		if r.Err != nil {
+			s.Status = "failed"
+			s.Error = r.Err.Error()
+		}
+		status = append(status, s)
+	}
+
+	if done, err := writeEncoded(w, format, status); done {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "OP\tNODE\tTASK\tDURATION\tSTATUS\tERROR")
+	for _, s := range status {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Op, s.Node, s.TaskId, s.Duration, s.Status, s.Error)
+	}
+
+	return tw.Flush()
+}
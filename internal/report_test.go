@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+)
+
+func TestWriteDeployments(t *testing.T) {
+
+	deployments := []dbutil.DeploymentRecord{
+		{Id: 1, DeploymentName: "test", NumNodes: 3},
+		{Id: 2, DeploymentName: "empty", NumNodes: 0},
+	}
+
+	tests := []struct {
+		name        string
+		format      string
+		deployments []dbutil.DeploymentRecord
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "table",
+			format:      OutputTable,
+			deployments: deployments,
+			want: "ID  NAME   NODES\n" +
+				"1   test   3\n" +
+				"2   empty  0\n",
+		},
+		{
+			name:        "default format is table",
+			format:      "",
+			deployments: nil,
+			want:        "ID  NAME  NODES\n",
+		},
+		{
+			name:        "json",
+			format:      OutputJson,
+			deployments: deployments,
+			want: "[\n" +
+				"\t{\n\t\t\"id\": 1,\n\t\t\"name\": \"test\",\n\t\t\"nodes\": 3\n\t},\n" +
+				"\t{\n\t\t\"id\": 2,\n\t\t\"name\": \"empty\",\n\t\t\"nodes\": 0\n\t}\n" +
+				"]\n",
+		},
+		{
+			name:        "json without deployments",
+			format:      OutputJson,
+			deployments: nil,
+			want:        "[]\n",
+		},
+		{
+			name:        "yaml",
+			format:      OutputYaml,
+			deployments: deployments,
+			want: "- id: 1\n  name: test\n  nodes: 3\n" +
+				"- id: 2\n  name: empty\n  nodes: 0\n",
+		},
+		{
+			name:        "yaml without deployments",
+			format:      OutputYaml,
+			deployments: nil,
+			want:        "[]\n",
+		},
+		{
+			name:        "unknown format",
+			format:      "xml",
+			deployments: deployments,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := WriteDeployments(w, tt.format, tt.deployments)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteDeployments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && w.String() != tt.want {
+				t.Errorf("WriteDeployments() = %q, want %q", w.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteNodeStatus(t *testing.T) {
+
+	status := []NodeStatus{
+		{
+			Name:       "test-controller-1",
+			Type:       "Controller",
+			VimName:    "vm-1",
+			IPv4Addr:   "172.16.81.10",
+			MacAddr:    "00:50:56:00:00:01",
+			SwitchUuid: "switch",
+			RouterUuid: "router",
+			DhcpUuid:   "dhcp",
+		},
+		{
+			Name:       "test-worker-1",
+			Type:       "Worker",
+			VimName:    "vm-2",
+			IPv4Addr:   "172.16.81.11",
+			MacAddr:    "00:50:56:00:00:02",
+			SwitchUuid: "switch",
+			RouterUuid: "router",
+			DhcpUuid:   "dhcp",
+			PodCidr:    "10.200.0.0/24",
+			Hardware:   &jettypes.Hardware{Cpus: 4, MemoryMB: 8192},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		status  []NodeStatus
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "table",
+			format: OutputTable,
+			status: status,
+			want: "NAME               TYPE        VIM NAME  IPV4          MAC                SWITCH  ROUTER  DHCP  POD CIDR       HARDWARE\n" +
+				"test-controller-1  Controller  vm-1      172.16.81.10  00:50:56:00:00:01  switch  router  dhcp                 template\n" +
+				"test-worker-1      Worker      vm-2      172.16.81.11  00:50:56:00:00:02  switch  router  dhcp  10.200.0.0/24  4cpu/8192MB\n",
+		},
+		{
+			name:   "json",
+			format: OutputJson,
+			status: status[1:],
+			want: "[\n\t{\n" +
+				"\t\t\"name\": \"test-worker-1\",\n" +
+				"\t\t\"type\": \"Worker\",\n" +
+				"\t\t\"vimName\": \"vm-2\",\n" +
+				"\t\t\"ipv4Address\": \"172.16.81.11\",\n" +
+				"\t\t\"macAddress\": \"00:50:56:00:00:02\",\n" +
+				"\t\t\"switchUuid\": \"switch\",\n" +
+				"\t\t\"routerUuid\": \"router\",\n" +
+				"\t\t\"dhcpUuid\": \"dhcp\",\n" +
+				"\t\t\"podCidr\": \"10.200.0.0/24\",\n" +
+				"\t\t\"hardware\": {\n\t\t\t\"cpus\": 4,\n\t\t\t\"memoryMB\": 8192\n\t\t}\n" +
+				"\t}\n]\n",
+		},
+		{
+			name:   "json without nodes",
+			format: OutputJson,
+			status: nil,
+			want:   "[]\n",
+		},
+		{
+			name:   "yaml",
+			format: OutputYaml,
+			status: status[:1],
+			want: "- name: test-controller-1\n" +
+				"  type: Controller\n" +
+				"  vimName: vm-1\n" +
+				"  ipv4Address: 172.16.81.10\n" +
+				"  macAddress: \"00:50:56:00:00:01\"\n" +
+				"  switchUuid: switch\n" +
+				"  routerUuid: router\n" +
+				"  dhcpUuid: dhcp\n",
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			status:  status,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			err := WriteNodeStatus(w, tt.format, tt.status)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteNodeStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && w.String() != tt.want {
+				t.Errorf("WriteNodeStatus() = %q, want %q", w.String(), tt.want)
+			}
+		})
+	}
+}
+
+/**
+  Deployments listed by name with a number of nodes, a project which
+  nodes all removed still listed with zero nodes.
+*/
+func TestGetDeployments(t *testing.T) {
+
+	d, _, teardown := setupDeployer(t)
+	defer teardown()
+
+	db := d.vim.Database()
+
+	deployments, err := dbutil.GetDeployments(db)
+	if err != nil {
+		t.Fatalf("GetDeployments() error = %v", err)
+	}
+	if len(deployments) != 0 {
+		t.Errorf("GetDeployments() of empty database = %v", deployments)
+	}
+
+	deployNodes(t, d,
+		testNode("test-controller-1", jettypes.ControlType, "172.16.81.10"),
+		testNode("test-worker-1", jettypes.WorkerType, "172.16.81.11"),
+		testNode("test-worker-2", jettypes.WorkerType, "172.16.81.12"))
+
+	empty := testNode("empty-worker-1", jettypes.WorkerType, "172.16.82.10")
+	empty.Mac = []string{"00:50:56:00:00:99"}
+	empty.SetGenericSwitch(jettypes.NewGenericSwitch("empty", "switch", "", "router"))
+	empty.SetGenericRouter(jettypes.NewGenericRouter("empty", "router"))
+	if err := dbutil.CreateDeployment(db, []*jettypes.NodeTemplate{empty}, "empty"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbutil.DeleteNode(db, "empty", empty.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	deployments, err = dbutil.GetDeployments(db)
+	if err != nil {
+		t.Fatalf("GetDeployments() error = %v", err)
+	}
+
+	want := []struct {
+		name  string
+		nodes int
+	}{
+		{"empty", 0},
+		{testProject, 3},
+	}
+	if len(deployments) != len(want) {
+		t.Fatalf("GetDeployments() = %v, want %d deployments", deployments, len(want))
+	}
+	for i, w := range want {
+		if deployments[i].DeploymentName != w.name || deployments[i].NumNodes != w.nodes || deployments[i].Id == 0 {
+			t.Errorf("GetDeployments()[%d] = %+v, want %s with %d nodes", i, deployments[i], w.name, w.nodes)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Scaling of existing deployment. New worker nodes cloned from worker template
+and attached to the network that was created for the project.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package internal
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/spyroot/jettison/ansibleutil"
+	"github.com/spyroot/jettison/certsutil"
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+	"github.com/spyroot/jettison/netpool"
+)
+
+//
+//  Creates new worker nodes from worker template. Addresses allocated from worker pool,
+//  all addresses already used by a project are skipped.
+//
+func (d *Deployer) newWorkers(existing []*jettypes.NodeTemplate, count int) ([]*jettypes.NodeTemplate, error) {
+
+	ok, template := d.scenario.Template(jettypes.WorkerType)
+	if !ok {
+		return nil, fmt.Errorf("scenario has no worker template")
+	}
+
+	// worker that already deployed, new workers share same network
+	var ref *jettypes.NodeTemplate
+	for _, n := range existing {
+		if n.Type == jettypes.WorkerType {
+			ref = n
+			break
+		}
+	}
+	if ref == nil {
+		return nil, fmt.Errorf("project %s has no worker nodes", d.scenario.DeploymentName)
+	}
+
+	pool, err := netpool.NewPool(template.DesiredAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed initilize ip pool %s", err)
+	}
+
+	names := make(map[string]bool)
+	for _, n := range existing {
+		pool.SetInUse(n.IPv4AddrStr)
+		names[n.Name] = true
+	}
+	if template.IPv4Addr != nil {
+		pool.SetInUse(template.IPv4Addr.String())
+	}
+
+	var nodes []*jettypes.NodeTemplate
+	for i := 0; i < count; i++ {
+		newNode := template.Clone()
+		newNode.GenerateName()
+		for names[newNode.Name] {
+			newNode.GenerateName()
+		}
+		names[newNode.Name] = true
+
+		ipAddr, err := pool.Allocate()
+		if err != nil {
+			return nil, fmt.Errorf("no free address left in worker pool %s", template.DesiredAddress)
+		}
+		newNode.IPv4Addr = net.ParseIP(ipAddr)
+		newNode.IPv4AddrStr = ipAddr
+		newNode.Type = jettypes.WorkerType
+		newNode.Mac = nil
+
+		// switch, dhcp and routing facts
+		s := &jettypes.GenericSwitch{}
+		newNode.SetGenericSwitch(s)
+		newNode.GenericSwitch().SetUuid(ref.SwitchUuid())
+		newNode.GenericSwitch().SetDhcpUuid(ref.DhcpServerUuid())
+
+		r := &jettypes.GenericRouter{}
+		newNode.SetGenericRouter(r)
+		newNode.GenericRouter().SetUuid(ref.RouterUuid())
+		if ref.IsExistingNetwork() {
+			newNode.SetExistingNetwork(true)
+		}
+
+		nodes = append(nodes, newNode)
+	}
+
+	return nodes, nil
+}
+
+//
+//  Writes certificates for new workers to project ansible vars.
+//
+func (d *Deployer) createWorkerCerts(nodes []*jettypes.NodeTemplate) (bool, error) {
+
+	ansibleEnv := d.vim.jetConfig.GetAnsible()
+
+	certClients := make([]certsutil.CertClient, 0)
+	for _, n := range nodes {
+		certClients = append(certClients, n)
+	}
+
+	keys, err := generateWorkerCerts(certClients,
+		ansibleEnv.AnsibleTemplates, d.scenario.DeploymentName)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return false, err
+	}
+
+	ansibleGlobal := ansibleutil.NewAnsibleGlobalVars()
+	ansibleGlobal.HomePath = ansibleEnv.AnsibleConfig
+	err = ansibleGlobal.WriteVars(keys)
+	if err != nil {
+		return false, fmt.Errorf("failed to write ansible vars to a file")
+	}
+
+	return true, nil
+}
+
+//
+//  Adds count worker nodes to existing project. Only new nodes cloned,
+//  existing nodes and network untouched. The workers play limited to new hosts.
+//  Objects created for new workers compensated same way as for deploy.
+//
+func (d *Deployer) ScaleWorkers(projectName string, count int) error {
+
+	err := d.scaleWorkers(projectName, count)
+	if err != nil {
+		return d.compensate(projectName, err)
+	}
+
+	err = dbutil.DeleteJournal(d.vim.db, projectName)
+	if err != nil {
+		logging.ErrorLogging(err)
+	}
+
+	return nil
+}
+
+func (d *Deployer) scaleWorkers(projectName string, count int) error {
+
+	if count < 1 {
+		return fmt.Errorf("number of workers must be positive")
+	}
+
+	if d.scenario == nil || d.scenario.DeploymentName != projectName {
+		return fmt.Errorf("project %s is not in configuration", projectName)
+	}
+
+	existing, _, err := dbutil.GetDeploymentNodes(d.vim.db, projectName)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return fmt.Errorf("project %s not found", projectName)
+	}
+
+	depId, _, ok, err := dbutil.GetDeployment(d.vim.db, projectName)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("project %s not found", projectName)
+	}
+
+	nodes, err := d.newWorkers(existing, count)
+	if err != nil {
+		return err
+	}
+
+	// pod networks known before clone so cloud-init of a worker can refer to it
+	if err = d.reservePodNetworks(nodes); err != nil {
+		return err
+	}
+
+	results, err := d.vim.CloneVms(projectName, nodes)
+	d.journalVms("", results.Succeeded(nodes))
+	if err != nil {
+		return fmt.Errorf("failed clone a vms err: %v", err)
+	}
+
+	err = d.vim.CreateDhcpBindings(projectName, nodes)
+	if err != nil {
+		return err
+	}
+	d.journalNodes(JournalDhcpBinding, dhcpNodes(nodes))
+
+	for _, n := range nodes {
+		err = dbutil.AddNode(d.vim.db, n, depId)
+		if err != nil {
+			return fmt.Errorf("failed add node %s to database %v", n.Name, err)
+		}
+		d.journal(JournalNode, n.Name, journalData{})
+	}
+
+	// same as deploy, drs rules follow nodes stored in database
+	if err = d.updateAffinityRules(projectName); err != nil {
+		return err
+	}
+
+	if ok, err = d.vim.PowerChangeAll(nodes, jettypes.PowerOn); !ok {
+		return fmt.Errorf("failed power on new workers %v", err)
+	}
+
+	log.Println("Acquiring ip addresses please wait...")
+	if ok, err = d.vim.AcquireIpAddresses(nodes); !ok {
+		return fmt.Errorf("failed acquire ip addresses %v", err)
+	}
+
+	if ok, err = d.deployMgmtChannel(nodes); !ok {
+		return err
+	}
+
+	if ok, err = d.AllocatePodNetwork(nodes); !ok {
+		return err
+	}
+
+	if ok, err = d.createAnsibleInventory(nodes); !ok {
+		return err
+	}
+
+	if ok, err = d.ansibleAddWorkers(nodes); !ok {
+		return err
+	}
+
+	if ok, err = d.createWorkerCerts(nodes); !ok {
+		return err
+	}
+
+	var hosts []string
+	for _, n := range nodes {
+		hosts = append(hosts, n.Name)
+	}
+
+	if ok, err = d.runPlaybook(hosts); !ok {
+		if err != nil {
+			return err
+		}
+		logging.Notification("looks like we have failed task.")
+	}
+
+	logging.Notification("Added", strconv.Itoa(len(nodes)), "workers to", projectName)
+
+	return nil
+}
+
+//
+//  Drains a node through kubectl on a controller node and removes it from a cluster.
+//
+func (d *Deployer) drainNode(controller *jettypes.NodeTemplate, node *jettypes.NodeTemplate) error {
+
+	sshDefaults := d.vim.jetConfig.GetSshDefault()
+
+	cmd := fmt.Sprintf("kubectl drain %s --ignore-daemonsets --delete-local-data --force && kubectl delete node %s",
+		node.Name, node.Name)
+
+	logging.Notification("Draining node", node.Name, "via controller", controller.IPv4AddrStr)
+	_, err := runRemoteCommand(sshDefaults, controller.IPv4AddrStr, cmd)
+	if err != nil {
+		return fmt.Errorf("failed drain node %s on controller %s %v", node.Name, controller.IPv4AddrStr, err)
+	}
+
+	return nil
+}
+
+//
+//  Removes a single worker node from existing project. Node drained, vm destroyed,
+//  dhcp binding and pod static route deleted, node removed from ansible inventory
+//  and database.
+//
+func (d *Deployer) RemoveWorker(projectName string, nodeName string) error {
+
+	if len(projectName) == 0 || len(nodeName) == 0 {
+		return fmt.Errorf("empty project or node name")
+	}
+
+	nodes, _, err := dbutil.GetDeploymentNodes(d.vim.db, projectName)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("project %s not found", projectName)
+	}
+
+	var (
+		target     *jettypes.NodeTemplate
+		controller *jettypes.NodeTemplate
+		workers    = 0
+	)
+	for _, n := range nodes {
+		if n.Name == nodeName {
+			target = n
+		}
+		if n.Type == jettypes.ControlType && controller == nil {
+			controller = n
+		}
+		if n.Type == jettypes.WorkerType {
+			workers++
+		}
+	}
+
+	if target == nil {
+		return fmt.Errorf("node %s not found in project %s", nodeName, projectName)
+	}
+	if target.Type != jettypes.WorkerType {
+		return fmt.Errorf("node %s is not a worker node", nodeName)
+	}
+	if workers < 2 {
+		return fmt.Errorf("node %s is last worker in project %s", nodeName, projectName)
+	}
+	if controller == nil {
+		return fmt.Errorf("project %s has no controller node", projectName)
+	}
+
+	return d.removeWorker(projectName, controller, target, true)
+}
+
+//
+//  Removes a worker that already validated. A worker which vm no longer exists
+//  in vim only removed from a cluster, dhcp, routing, inventory and database.
+//
+func (d *Deployer) removeWorker(projectName string, controller *jettypes.NodeTemplate,
+	target *jettypes.NodeTemplate, vmExists bool) error {
+
+	err := d.drainNode(controller, target)
+	if err != nil {
+		if vmExists {
+			return err
+		}
+		logging.CriticalMessage("failed drain node", target.Name, err.Error())
+	}
+
+	if vmExists {
+		err = d.vim.DeleteVm(projectName, []*jettypes.NodeTemplate{target})
+		if err != nil {
+			return err
+		}
+	}
+
+	owned, err := d.vim.ownedObjects(projectName)
+	if err != nil {
+		return err
+	}
+	err = d.vim.DhcpCleanup(projectName, []*jettypes.NodeTemplate{target}, owned)
+	if err != nil {
+		return err
+	}
+
+	allocations, err := dbutil.GetDeploymentAllocations(d.vim.db, projectName)
+	if err != nil {
+		return err
+	}
+	if podNetwork, ok := allocations[target.Name]; ok {
+		ok, err = d.vim.DeleteStaticRoute(projectName, target, podNetwork)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			logging.CriticalMessage("no static route for", podNetwork, "found")
+		}
+	}
+
+	err = d.ansibleCleanup([]*jettypes.NodeTemplate{target})
+	if err != nil {
+		return err
+	}
+
+	err = dbutil.DeleteNode(d.vim.db, projectName, target.Name)
+	if err != nil {
+		return err
+	}
+
+	err = d.updateAffinityRules(projectName)
+	if err != nil {
+		return err
+	}
+
+	logging.Notification("Removed worker", target.Name, "from", projectName)
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/spyroot/jettison/ansibleutil"
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/jettypes"
+)
+
+func TestDeployer_newWorkers(t *testing.T) {
+
+	// node stored in database with network objects of a segment
+	deployed := func(name string, nodeType jettypes.NodeType, ip string, existingNetwork bool) *jettypes.NodeTemplate {
+		n := testNode(name, nodeType, ip)
+		n.Mac = []string{"00:50:56:00:00:01"}
+		n.SetGenericSwitch(jettypes.NewGenericSwitch("segment", "switch-uuid", "dhcp-uuid", "router-uuid"))
+		n.SetGenericRouter(jettypes.NewGenericRouter("router", "router-uuid"))
+		n.SetExistingNetwork(existingNetwork)
+		return n
+	}
+
+	tests := []struct {
+		name     string
+		existing []*jettypes.NodeTemplate
+		count    int
+		ingress  string
+		wantIps  []string
+		wantErr  bool
+	}{
+		{
+			name: "addresses of deployed nodes skipped",
+			existing: []*jettypes.NodeTemplate{
+				deployed("test-controller-1", jettypes.ControlType, "172.16.81.9", false),
+				deployed("test-worker-1", jettypes.WorkerType, "172.16.81.11", false),
+			},
+			count:   2,
+			wantIps: []string{"172.16.81.10", "172.16.81.12"},
+		},
+		{
+			name: "address of ingress skipped",
+			existing: []*jettypes.NodeTemplate{
+				deployed("test-worker-1", jettypes.WorkerType, "172.16.81.9", false),
+			},
+			count:   1,
+			ingress: "172.16.81.10",
+			wantIps: []string{"172.16.81.11"},
+		},
+		{
+			name: "worker attached to existing network",
+			existing: []*jettypes.NodeTemplate{
+				deployed("test-controller-1", jettypes.ControlType, "172.16.81.9", true),
+				deployed("test-worker-1", jettypes.WorkerType, "172.16.81.10", true),
+			},
+			count:   1,
+			wantIps: []string{"172.16.81.11"},
+		},
+		{
+			name: "pool exhausted",
+			existing: []*jettypes.NodeTemplate{
+				deployed("test-controller-1", jettypes.ControlType, "172.16.81.9", false),
+				deployed("test-worker-1", jettypes.WorkerType, "172.16.81.10", false),
+			},
+			count:   5,
+			wantErr: true,
+		},
+		{
+			name: "project without workers",
+			existing: []*jettypes.NodeTemplate{
+				deployed("test-controller-1", jettypes.ControlType, "172.16.81.9", false),
+			},
+			count:   1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			worker := testTemplate(jettypes.WorkerType, 1)
+			worker.DesiredAddress = "172.16.81.8/29"
+			worker.IPv4Addr = net.ParseIP(tt.ingress)
+
+			d, _, teardown := setupDeployer(t, worker)
+			defer teardown()
+
+			nodes, err := d.newWorkers(tt.existing, tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newWorkers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(nodes) != tt.count {
+				t.Fatalf("newWorkers() created %d workers, want %d", len(nodes), tt.count)
+			}
+
+			ref := tt.existing[len(tt.existing)-1]
+			names := make(map[string]bool)
+			for _, n := range tt.existing {
+				names[n.Name] = true
+			}
+			for i, n := range nodes {
+				if names[n.Name] || !strings.HasPrefix(n.Name, worker.Prefix+".") {
+					t.Errorf("newWorkers() name %s not unique or without prefix %s", n.Name, worker.Prefix)
+				}
+				names[n.Name] = true
+				if n.IPv4AddrStr != tt.wantIps[i] || !n.IPv4Addr.Equal(net.ParseIP(tt.wantIps[i])) {
+					t.Errorf("newWorkers() address of %s = %s, want %s", n.Name, n.IPv4AddrStr, tt.wantIps[i])
+				}
+				if n.Type != jettypes.WorkerType || len(n.Mac) != 0 {
+					t.Errorf("newWorkers() node %s type %s mac %v", n.Name, n.Type, n.Mac)
+				}
+				if n.SwitchUuid() != ref.SwitchUuid() || n.RouterUuid() != ref.RouterUuid() ||
+					n.DhcpServerUuid() != ref.DhcpServerUuid() {
+					t.Errorf("newWorkers() node %s not attached to segment of %s", n.Name, ref.Name)
+				}
+				if n.IsExistingNetwork() != ref.IsExistingNetwork() {
+					t.Errorf("newWorkers() node %s existing network = %v, want %v",
+						n.Name, n.IsExistingNetwork(), ref.IsExistingNetwork())
+				}
+			}
+		})
+	}
+}
+
+/**
+  Workers added to a deployed scenario cloned on segment of a project, bound,
+  stored in database with a pod network and only new hosts played.
+*/
+func TestDeployer_ScaleWorkers(t *testing.T) {
+
+	d, p, c, teardown := setupScenario(t)
+	defer teardown()
+
+	_, worker := d.scenario.Template(jettypes.WorkerType)
+	worker.DesiredAddress = "172.16.81.8/29"
+
+	if err := d.Deploy(false); err != nil {
+		t.Fatalf("Deploy() error = %v", err)
+	}
+
+	db := d.vim.Database()
+	before, _, err := dbutil.GetDeploymentNodes(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clones := countCalls(p, "CloneVms")
+
+	if err := d.ScaleWorkers(testProject, 2); err != nil {
+		t.Fatalf("ScaleWorkers() error = %v", err)
+	}
+
+	nodes, _, err := dbutil.GetDeploymentNodes(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != len(before)+2 {
+		t.Fatalf("ScaleWorkers() stored %d nodes, want %d", len(nodes), len(before)+2)
+	}
+	if n := countCalls(p, "CloneVms"); n != clones+2 {
+		t.Errorf("ScaleWorkers() cloned %d vms, want 2", n-clones)
+	}
+
+	allocations, err := dbutil.GetDeploymentAllocations(db, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inventory, err := ansibleutil.CreateFromInventory(d.vim.jetConfig.GetAnsible().AnsibleInventory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		added []*jettypes.NodeTemplate
+		ips   []string
+	)
+	for _, n := range nodes {
+		if contains(scenarioNodes, n.Name) {
+			continue
+		}
+		added = append(added, n)
+		ips = append(ips, n.IPv4AddrStr)
+	}
+
+	// .10 - .12 held by deployed nodes
+	if len(added) != 2 || !contains(ips, "172.16.81.9") || !contains(ips, "172.16.81.13") {
+		t.Fatalf("ScaleWorkers() added workers with addresses %v, want 172.16.81.9 172.16.81.13", ips)
+	}
+
+	var hosts []string
+	for _, n := range added {
+		hosts = append(hosts, n.Name)
+		if n.Type != jettypes.WorkerType || !strings.HasPrefix(n.Name, worker.Prefix+".") {
+			t.Errorf("ScaleWorkers() stored node %s of type %s", n.Name, n.Type)
+		}
+		if n.SwitchUuid() != before[0].SwitchUuid() || n.DhcpServerUuid() != before[0].DhcpServerUuid() {
+			t.Errorf("ScaleWorkers() attached %s to other segment", n.Name)
+		}
+		if len(n.Mac) == 0 || len(n.Mac[0]) == 0 {
+			t.Errorf("ScaleWorkers() stored %s without mac address", n.Name)
+		}
+		ip, ok, err := p.DescribeBinding(context.Background(), n)
+		if err != nil || !ok || ip != n.IPv4AddrStr {
+			t.Errorf("DescribeBinding(%s) = %s %v %v, want %s", n.Name, ip, ok, err, n.IPv4AddrStr)
+		}
+		if _, ok := allocations[n.Name]; !ok {
+			t.Errorf("ScaleWorkers() didn't store pod network of %s", n.Name)
+		}
+		if _, ok := inventory.FindSaveHost(n.Name); !ok {
+			t.Errorf("ScaleWorkers() didn't add %s to ansible inventory", n.Name)
+		}
+	}
+
+	playbook := c.playbooks[len(c.playbooks)-1]
+	limit := ""
+	for i, arg := range playbook {
+		if arg == "--limit" && i+1 < len(playbook) {
+			limit = playbook[i+1]
+		}
+	}
+	for _, h := range hosts {
+		if !strings.Contains(limit, h) {
+			t.Errorf("ScaleWorkers() played hosts %q, want %s", limit, h)
+		}
+	}
+	for _, name := range scenarioNodes {
+		if strings.Contains(limit, name) {
+			t.Errorf("ScaleWorkers() played deployed host %s", name)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"strconv"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/logging"
+)
+
+//
+//  Releases template snapshots linked clones of a project created from, called
+//  once clones deleted. Snapshot that still backs linked clones of other projects
+//  kept with a warning, failure to delete a snapshot doesn't stop a teardown.
+//
+func (d *Deployer) releaseSnapshots(projectName string, clones []dbutil.LinkedClone) {
+
+	released := make(map[string]bool)
+	for _, c := range clones {
+		key := c.VmTemplate + "/" + c.Snapshot
+		if released[key] {
+			continue
+		}
+		released[key] = true
+
+		count, err := dbutil.CountLinkedClones(d.vim.Database(), c.VmTemplate, c.Snapshot, projectName)
+		if err != nil {
+			logging.ErrorLogging(err)
+			continue
+		}
+		if count > 0 {
+			logging.CriticalMessage("Snapshot", c.Snapshot, "of template", c.VmTemplate, "still backs",
+				strconv.Itoa(count), "linked clones of other projects, snapshot kept")
+			continue
+		}
+
+		_ = d.vim.DeleteTemplateSnapshot(c.VmTemplate, c.Snapshot)
+	}
+}
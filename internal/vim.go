@@ -1,25 +1,20 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"github.com/spyroot/jettison/dbutil"
 	"github.com/spyroot/jettison/jettypes"
 	"github.com/spyroot/jettison/logging"
+	"github.com/spyroot/jettison/providers"
+	"github.com/spyroot/jettison/providers/remote"
 	"github.com/spyroot/jettison/system"
 	"log"
-	"os"
-	"plugin"
 	"strconv"
-	"sync"
 )
 
-type VimChanMessage struct {
-	Msg string
-	Ok  bool
-	Err error
-}
-
 /*
    Main data structure that holds all infra related data.
 */
@@ -30,10 +25,17 @@ type Vim struct {
 	// Jettison deployment scenario a jet pack
 	jetConfig *AppConfig
 
-	vimPlugin *plugin.Plugin
-
 	// a vim plugin that VIM Manager will use
 	pluggableVim jettypes.VimPlugin
+
+	// bounds concurrent jobs of vim and a plugin
+	pool *jettypes.WorkerPool
+
+	// passed to each plugin call, cancelled on interrupt
+	ctx context.Context
+
+	// result of each node operation executed by vim
+	results jettypes.NodeResults
 }
 
 //
@@ -55,15 +57,53 @@ func (p *Vim) Database() *sql.DB {
 	return nil
 }
 
+// Closes database and stops vim provider running as a separate process
+func (p *Vim) Close() error {
+
+	if p == nil {
+		return nil
+	}
+
+	if closer, ok := p.pluggableVim.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logging.ErrorLogging(err)
+		}
+	}
+
+	if p.db != nil {
+		return p.db.Close()
+	}
+
+	return nil
+}
+
+// Returns result of each node operation executed by vim so far
+func (p *Vim) Results() jettypes.NodeResults {
+	if p != nil {
+		return p.results
+	}
+	return nil
+}
+
+func (p *Vim) record(results jettypes.NodeResults) {
+	p.results = append(p.results, results...)
+}
+
+// Returns true if vim context cancelled, for example by interrupt
+func (p *Vim) Interrupted() bool {
+	return p != nil && p.ctx.Err() != nil
+}
+
 /*
    Initialize initial configuration, checks dependency that jettison required.
    (Ansible , ssh client etc)
 
   - It reads configuration yaml file that contain entire configuration semantics
   - During initialization phase NewVIm loads a plugin that used to interact with VIM
+  - Every plugin call made with ctx, cancel ctx to abort in-flight vim tasks
 
 */
-func NewVim() (*Vim, error) {
+func NewVim(ctx context.Context) (*Vim, error) {
 
 	// check that we have all require dependency
 	err := system.CheckDependence()
@@ -82,36 +122,78 @@ func NewVim() (*Vim, error) {
 		return nil, err
 	}
 
-	var vim Vim
-	vim.jetConfig = &jetConfig
-
-	p, err := plugin.Open("./plugins/vmwarevim.so")
+	pluggableVim, err := newPluggableVim(ctx, &jetConfig)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
 
-	vim.vimPlugin = p
-	symbol, err := p.Lookup("Init")
+	db, err := dbutil.CreateDatabase()
 	if err != nil {
-		panic("Failed lookup init")
+		return nil, fmt.Errorf("failed to connect to database")
 	}
 
-	vim.db, err = dbutil.CreateDatabase()
+	return NewVimWithPlugin(ctx, &jetConfig, db, pluggableVim)
+}
+
+//
+//  Creates a vim from configuration already read, database and vim provider.
+//  Provider initialized with vcenter endpoint of configuration.
+//
+func NewVimWithPlugin(ctx context.Context, jetConfig *AppConfig,
+	db *sql.DB, pluggableVim jettypes.VimPlugin) (*Vim, error) {
+
+	var vim Vim
+	vim.ctx = ctx
+	vim.jetConfig = jetConfig
+	vim.db = db
+	vim.pool = jettypes.NewWorkerPool(jetConfig.GetMaxThreads(), jetConfig.GetJobLimits())
+
+	vim.pluggableVim = pluggableVim
+	pluggableVim.SetWorkerPool(vim.pool)
+	err := pluggableVim.InitPlugin(ctx, &jetConfig.Infra.Vcenter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database")
+		return nil, fmt.Errorf("failed initilize vim")
+	}
+
+	return &vim, nil
+}
+
+//
+//  Creates a vim provider selected by configuration. Shared object of out-of-tree
+//  provider loaded if configured, otherwise provider registered by name created.
+//
+func newPluggableVim(ctx context.Context, jetConfig *AppConfig) (jettypes.VimPlugin, error) {
+
+	if command := jetConfig.GetProviderCommand(); len(command) > 0 {
+		return remote.Start(ctx, remote.Config{Path: command[0], Args: command[1:]})
 	}
 
-	pluggableVim, err := symbol.(func() (jettypes.VimPlugin, error))()
+	if path := jetConfig.GetProviderPath(); len(path) > 0 {
+		return providers.Open(path)
+	}
+
+	return providers.New(jetConfig.GetProvider())
+}
+
+//
+//  Creates a vim that only reads configuration and database, no plugin loaded.
+//  Used by commands that must not touch vim, for example plan.
+//
+func NewOfflineVim() (*Vim, error) {
+
+	jetConfig, err := ReadConfig()
 	if err != nil {
-		fmt.Println("unexpected type from module symbol")
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to read configuration %s", err)
 	}
 
-	vim.pluggableVim = pluggableVim
-	err = pluggableVim.InitPlugin(&jetConfig.Infra.Vcenter)
+	var vim Vim
+	vim.ctx = context.Background()
+	vim.jetConfig = &jetConfig
+	vim.pool = jettypes.NewWorkerPool(jetConfig.GetMaxThreads(), jetConfig.GetJobLimits())
+
+	vim.db, err = dbutil.CreateDatabase()
 	if err != nil {
-		return nil, fmt.Errorf("failed initilize vim")
+		return nil, fmt.Errorf("failed to connect to database")
 	}
 
 	return &vim, nil
@@ -127,7 +209,7 @@ func (p *Vim) DiscoverVmTemplate(node *jettypes.NodeTemplate) error {
 		return fmt.Errorf("node is nil")
 	}
 
-	err := p.pluggableVim.DiscoverVmTemplate(node)
+	err := p.pluggableVim.DiscoverVmTemplate(p.ctx, node)
 	if err != nil {
 		logging.CriticalMessage("vim failed discover a vm template", node.VmTemplateName)
 		return err
@@ -137,29 +219,69 @@ func (p *Vim) DiscoverVmTemplate(node *jettypes.NodeTemplate) error {
 }
 
 //
-func (p *Vim) CloneVms(projectName string, nodes []*jettypes.NodeTemplate) error {
+// Ask vim to clone vms of nodes, returns a result of each clone
+// vim started, a node failed before clone has no result.
+//
+func (p *Vim) CloneVms(projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
 
 	if len(nodes) == 0 {
-		return fmt.Errorf("node is nil")
+		return nil, fmt.Errorf("node is nil")
 	}
 
-	err := p.pluggableVim.CloneVms(projectName, nodes)
+	// static node customized with name servers of config unless template sets own
+	for _, node := range nodes {
+		if node.Static && len(node.DnsServers) == 0 && p.jetConfig != nil {
+			node.DnsServers = p.jetConfig.GetDnsServers()
+		}
+	}
+
+	for _, node := range nodes {
+		if err := p.renderNodeCloudInit(projectName, node); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := p.pluggableVim.CloneVms(p.ctx, projectName, nodes)
+	p.record(results)
 	if err != nil {
 		logging.CriticalMessage("vim deploy nodes group")
-		return err
+		return results, err
 	}
 
-	err = p.pluggableVim.DiscoverVms(projectName, nodes)
+	err = p.pluggableVim.DiscoverVms(p.ctx, projectName, nodes)
 	if err != nil {
 		logging.CriticalMessage("failed discover deployed vms")
-		return err
+		return results, err
 	}
 
-	return nil
+	return results, nil
 }
 
 //
 func (p *Vim) DeleteVm(projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	for _, node := range nodes {
+		err := p.pluggableVim.DeleteVm(p.ctx, projectName, node)
+		if err != nil {
+			logging.CriticalMessage("vim failed delete vm", node.Name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+//
+// Ask vim to destroy a folder of a deployment.
+//
+func (p *Vim) DeleteFolder(projectName string, folder string) error {
+
+	err := p.pluggableVim.DeleteFolder(p.ctx, projectName, folder)
+	if err != nil {
+		logging.CriticalMessage("vim failed delete folder", folder)
+		return err
+	}
+
 	return nil
 }
 
@@ -169,7 +291,7 @@ func (p *Vim) DeleteVm(projectName string, nodes []*jettypes.NodeTemplate) error
 //
 func (p *Vim) DisconnectVm(projectName string, node *jettypes.NodeTemplate) (bool, error) {
 
-	ok, err := p.pluggableVim.DisconnectVm(node.VmTemplateName, node)
+	ok, err := p.pluggableVim.DisconnectVm(p.ctx, node.VmTemplateName, node)
 	if err != nil {
 		logging.CriticalMessage("vim failed connect vm")
 		return false, err
@@ -192,7 +314,7 @@ func (p *Vim) ConnectVm(projectName string, node *jettypes.NodeTemplate) (bool,
 	}
 
 	// shared or not
-	ok, err := p.pluggableVim.ConnectVm(node.VmTemplateName, node)
+	ok, err := p.pluggableVim.ConnectVm(p.ctx, node.VmTemplateName, node)
 	if err != nil {
 		logging.CriticalMessage("vim failed connect vm")
 		return false, err
@@ -215,7 +337,8 @@ func (p *Vim) ComputeCleanup(projectName string, nodes []*jettypes.NodeTemplate)
 	logging.Notification("Deployment",
 		projectName, "contains", strconv.Itoa(len(nodes)), "nodes")
 
-	err := p.pluggableVim.ComputeCleanup(projectName, nodes)
+	results, err := p.pluggableVim.ComputeCleanup(p.ctx, projectName, nodes)
+	p.record(results)
 	if err != nil {
 		logging.CriticalMessage("vim failed delete vm")
 		return err
@@ -224,10 +347,23 @@ func (p *Vim) ComputeCleanup(projectName string, nodes []*jettypes.NodeTemplate)
 	return nil
 }
 
+//
+// Ask vim to create static dhcp binding for each node, static nodes addressed
+// by guest customization and skipped.
 //
 func (p *Vim) CreateDhcpBindings(projectName string, nodes []*jettypes.NodeTemplate) error {
 
-	err := p.pluggableVim.CreateDhcpBindings(projectName, nodes)
+	dhcpNodes := dhcpNodes(nodes)
+	if len(dhcpNodes) == 0 {
+		return nil
+	}
+
+	if !p.Supports(providers.CapDhcpBindings) {
+		logging.Notification("vim provider has no dhcp bindings, skipping bindings of", projectName)
+		return nil
+	}
+
+	err := p.pluggableVim.CreateDhcpBindings(p.ctx, projectName, dhcpNodes)
 	if err != nil {
 		logging.CriticalMessage("vim failed delete vm")
 		return err
@@ -248,11 +384,24 @@ func (p *Vim) CreateDhcpBindings(projectName string, nodes []*jettypes.NodeTempl
 //
 //  clean up all state dhcp information based on nodes
 //  vim can perform lookup based on mac / switch pair and
-//  remove each stale records
+//  remove each stale records, only bindings in owned set deleted
 //
-func (p *Vim) DhcpCleanup(projectName string, nodes []*jettypes.NodeTemplate) error {
+func (p *Vim) DhcpCleanup(projectName string, nodes []*jettypes.NodeTemplate, owned map[string]bool) error {
 
-	err := p.pluggableVim.DhcpCleanup(projectName, nodes)
+	// binding of a hand-built dhcp server left to whoever created it
+	var tagged []*jettypes.NodeTemplate
+	for _, v := range nodes {
+		if v.IsExistingNetwork() || !owned[bindingKey(v.DhcpServerUuid(), v.Name)] {
+			logging.Notification("Keeping dhcp binding of", v.Name, "not created by", projectName)
+			continue
+		}
+		tagged = append(tagged, v)
+	}
+	if len(tagged) == 0 {
+		return nil
+	}
+
+	err := p.pluggableVim.DhcpCleanup(p.ctx, projectName, tagged)
 	if err != nil {
 		logging.CriticalMessage("vim failed delete vm")
 		return err
@@ -262,45 +411,145 @@ func (p *Vim) DhcpCleanup(projectName string, nodes []*jettypes.NodeTemplate) er
 }
 
 //
+// Returns nodes addressed by a dhcp binding, static node has no binding.
+//
+func dhcpNodes(nodes []*jettypes.NodeTemplate) []*jettypes.NodeTemplate {
+
+	var dhcpNodes []*jettypes.NodeTemplate
+	for _, node := range nodes {
+		if !node.Static {
+			dhcpNodes = append(dhcpNodes, node)
+		}
+	}
+
+	return dhcpNodes
+}
+
+//
+// Returns nodes which binding jettison checks, static node has no binding and
+// binding on a segment jettison didn't create left to whoever created it.
+//
+func boundNodes(nodes []*jettypes.NodeTemplate) []*jettypes.NodeTemplate {
+
+	var bound []*jettypes.NodeTemplate
+	for _, node := range dhcpNodes(nodes) {
+		if !node.IsExistingNetwork() {
+			bound = append(bound, node)
+		}
+	}
+
+	return bound
+}
+
+//
+// Key of a dhcp binding in a set of owned objects, binding named after a host.
+//
+func bindingKey(dhcpUuid string, host string) string {
+	return dhcpUuid + "/" + host
+}
+
 //
+// Returns ids of network objects vim tagged with a project, binding keyed by
+// a dhcp server and a host. Object without a tag, for example a segment adopted
+// nodes attached to, is not owned by a project and never deleted. Listing walks
+// every tagged object, so caller lists once per teardown or replay.
 //
-func (p *Vim) CleanupRouting(projectName string, nodes []*jettypes.NodeTemplate) (bool, error) {
+func (p *Vim) ownedObjects(projectName string) (map[string]bool, error) {
 
+	owned := make(map[string]bool)
+	if len(projectName) == 0 {
+		return owned, fmt.Errorf("empty project name")
+	}
+
+	objects, err := p.TaggedObjects(projectName)
+	if err != nil {
+		return owned, err
+	}
+	for _, o := range objects {
+		if o.Kind == jettypes.ObjectDhcpBinding {
+			owned[bindingKey(o.Parent, o.Name)] = true
+			continue
+		}
+		owned[o.Id] = true
+	}
+
+	return owned, nil
+}
+
+//
+// Deletes all logical routers used by nodes. Nodes that share same
+// network segment share a router, so each router deleted only once.
+// Router not tagged with a project kept, owned set lists tagged objects.
+//
+func (p *Vim) CleanupRouting(projectName string, nodes []*jettypes.NodeTemplate, owned map[string]bool) (bool, error) {
+
+	deleted := make(map[string]bool)
 	for _, v := range nodes {
+		if len(v.RouterUuid()) == 0 || deleted[v.RouterUuid()] {
+			continue
+		}
+		if v.IsExistingNetwork() || !owned[v.RouterUuid()] {
+			logging.Notification("Keeping logical router", v.RouterUuid(), "not created by", projectName)
+			deleted[v.RouterUuid()] = true
+			continue
+		}
 		_, err := p.DeleteRouter(v)
 		if err != nil {
 			return false, err
 		}
+		deleted[v.RouterUuid()] = true
 	}
 
 	return true, nil
 }
 
 //
+// Deletes all logical switches used by nodes, each switch deleted only once.
+// Switch not tagged with a project kept, owned set lists tagged objects.
 //
-//
-func (p *Vim) CleanupSwitching(projectName string, nodes []*jettypes.NodeTemplate) (bool, error) {
+func (p *Vim) CleanupSwitching(projectName string, nodes []*jettypes.NodeTemplate, owned map[string]bool) (bool, error) {
 
+	deleted := make(map[string]bool)
 	for _, v := range nodes {
+		if len(v.SwitchUuid()) == 0 || deleted[v.SwitchUuid()] {
+			continue
+		}
+		if v.IsExistingNetwork() || !owned[v.SwitchUuid()] {
+			logging.Notification("Keeping logical switch", v.SwitchUuid(), "not created by", projectName)
+			deleted[v.SwitchUuid()] = true
+			continue
+		}
 		_, err := p.DeleteSwitch(v)
 		if err != nil {
 			return false, err
 		}
+		deleted[v.SwitchUuid()] = true
 	}
 
 	return true, nil
 }
 
 //
+// Deletes all dhcp servers and profiles used by nodes, each server deleted only once.
+// Server not tagged with a project kept, owned set lists tagged objects.
 //
-//
-func (p *Vim) CleanupDhcp(projectName string, nodes []*jettypes.NodeTemplate) (bool, error) {
+func (p *Vim) CleanupDhcp(projectName string, nodes []*jettypes.NodeTemplate, owned map[string]bool) (bool, error) {
 
+	deleted := make(map[string]bool)
 	for _, v := range nodes {
+		if len(v.DhcpServerUuid()) == 0 || deleted[v.DhcpServerUuid()] {
+			continue
+		}
+		if v.IsExistingNetwork() || !owned[v.DhcpServerUuid()] {
+			logging.Notification("Keeping dhcp server", v.DhcpServerUuid(), "not created by", projectName)
+			deleted[v.DhcpServerUuid()] = true
+			continue
+		}
 		_, err := p.DeleteDhcpServer(v)
 		if err != nil {
 			return false, err
 		}
+		deleted[v.DhcpServerUuid()] = true
 	}
 
 	return true, nil
@@ -311,7 +560,7 @@ func (p *Vim) CleanupDhcp(projectName string, nodes []*jettypes.NodeTemplate) (b
 //
 func (p *Vim) DiscoverClusterDhcpServer(projectName string, nodes *[]*jettypes.NodeTemplate) (bool, error) {
 
-	ok, err := p.pluggableVim.DiscoverClusterDhcpServer(projectName, nodes)
+	ok, err := p.pluggableVim.DiscoverClusterDhcpServer(p.ctx, projectName, nodes)
 	if err != nil {
 		logging.CriticalMessage("vim failed delete vm")
 		return false, err
@@ -320,11 +569,17 @@ func (p *Vim) DiscoverClusterDhcpServer(projectName string, nodes *[]*jettypes.N
 	return ok, nil
 }
 
-/* network interface */
-func (p *Vim) DeploySegment(projectName string,
-	segmentName string, gateway string, prefixLen int) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+/* network interface, dhcp server of a segment hands out name servers of config */
+func (p *Vim) DeploySegment(projectName string, segmentName string, gateway string,
+	prefixLen int, dhcp bool, domain string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	var dnsServers []string
+	if p.jetConfig != nil {
+		dnsServers = p.jetConfig.GetDnsServers()
+	}
 
-	sw, rt, err := p.pluggableVim.DeploySegment(projectName, segmentName, gateway, prefixLen)
+	sw, rt, err := p.pluggableVim.DeploySegment(p.ctx, projectName, segmentName, gateway, prefixLen,
+		dhcp, dnsServers, domain)
 	if err != nil {
 		logging.CriticalMessage("failed deploy network segments " + err.Error())
 		return nil, nil, err
@@ -353,7 +608,7 @@ func (p *Vim) CreateDeployment(projectName string, nodes []*jettypes.NodeTemplat
 //
 func (p *Vim) PowerOn(projectName string, node *jettypes.NodeTemplate) (bool, error) {
 
-	ok, err := p.pluggableVim.ChangePowerState(node, jettypes.PowerOn)
+	ok, err := p.pluggableVim.ChangePowerState(p.ctx, node, jettypes.PowerOn)
 	if err != nil {
 		logging.CriticalMessage("failed deploy network segments " + err.Error())
 		return false, err
@@ -368,7 +623,7 @@ func (p *Vim) PowerOn(projectName string, node *jettypes.NodeTemplate) (bool, er
 //
 func (p *Vim) ChangePowerState(node *jettypes.NodeTemplate, state jettypes.PowerState) (bool, error) {
 
-	ok, err := p.pluggableVim.ChangePowerState(node, state)
+	ok, err := p.pluggableVim.ChangePowerState(p.ctx, node, state)
 	if err != nil {
 		logging.CriticalMessage("failed deploy network segments " + err.Error())
 		return false, err
@@ -382,7 +637,7 @@ func (p *Vim) ChangePowerState(node *jettypes.NodeTemplate, state jettypes.Power
 //
 func (p *Vim) AcquireIpAddress(node *jettypes.NodeTemplate) (bool, error) {
 
-	ok, ip, err := p.pluggableVim.AcquireIpAddress(node)
+	ok, ip, err := p.pluggableVim.AcquireIpAddress(p.ctx, node)
 	if err != nil {
 		logging.CriticalMessage("failed deploy network segments " + err.Error())
 		return ok, err
@@ -393,145 +648,214 @@ func (p *Vim) AcquireIpAddress(node *jettypes.NodeTemplate) (bool, error) {
 }
 
 //
-// Chane VM power state and write to channel a status
+// Asks vim for live state of each node concurrently, result keyed by node name.
+// Vm that not found reported as vm that doesn't exist, error returned only if vim
+// failed describe a vm.
 //
-func (p *Vim) powerChange(node *jettypes.NodeTemplate, state jettypes.PowerState,
-	sem chan int, wg *sync.WaitGroup, statusChan chan VimChanMessage) {
+func (p *Vim) DescribeVms(nodes []*jettypes.NodeTemplate) (map[string]*jettypes.VmInfo, error) {
 
-	defer wg.Done()
-	sem <- 1
+	infos := make([]*jettypes.VmInfo, len(nodes))
+	errs := make([]error, len(nodes))
+	p.pool.Run(p.ctx, jettypes.NetworkJob, len(nodes), func(i int) {
+		infos[i], errs[i] = p.pluggableVim.DescribeVm(p.ctx, nodes[i])
+	})
 
-	ok, err := p.ChangePowerState(node, state)
-	if err != nil {
-		select {
-		case statusChan <- VimChanMessage{
-			Msg: node.Name,
-			Ok:  ok,
-			Err: fmt.Errorf("clone vm task failed")}:
-			break
-		default:
-		}
-	} else {
-		statusChan <- VimChanMessage{
-			Msg: node.Name,
-			Ok:  ok,
-			Err: fmt.Errorf("clone vm task failed")}
+	if p.ctx.Err() != nil {
+		return nil, p.ctx.Err()
 	}
 
-	<-sem
+	vms := make(map[string]*jettypes.VmInfo)
+	for i, n := range nodes {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed describe vm %s %v", n.Name, errs[i])
+		}
+		vms[n.Name] = infos[i]
+	}
 
-	return
+	return vms, nil
 }
 
 //
-// Changes VMs power state for list of nodes and it does it concurrently
-// Underlying semantics semantic need to provide cancellation behavior
-// and powerChange method provides correct reporting back to main thread routine
+// Asks vim for static dhcp binding of each node concurrently, result holds an address
+// of each node that has a binding keyed by node name.
 //
-func (p *Vim) PowerChangeAll(nodes []*jettypes.NodeTemplate, state jettypes.PowerState) (bool, error) {
-
-	sem := make(chan int, 3)
+func (p *Vim) DescribeBindings(nodes []*jettypes.NodeTemplate) (map[string]string, error) {
 
-	var finalStatus []VimChanMessage
-	errChan := make(chan VimChanMessage, len(nodes))
+	addrs := make([]string, len(nodes))
+	found := make([]bool, len(nodes))
+	errs := make([]error, len(nodes))
+	p.pool.Run(p.ctx, jettypes.NetworkJob, len(nodes), func(i int) {
+		addrs[i], found[i], errs[i] = p.pluggableVim.DescribeBinding(p.ctx, nodes[i])
+	})
 
-	var wg sync.WaitGroup
-	wg.Add(len(nodes))
+	if p.ctx.Err() != nil {
+		return nil, p.ctx.Err()
+	}
 
-	for _, v := range nodes {
-		go p.powerChange(v, state, sem, &wg, errChan)
+	bindings := make(map[string]string)
+	for i, n := range nodes {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed describe dhcp binding of %s %v", n.Name, errs[i])
+		}
+		if found[i] {
+			bindings[n.Name] = addrs[i]
+		}
 	}
 
-	wg.Wait()
-	close(errChan)
+	return bindings, nil
+}
+
+//
+// Returns network objects vim tagged with a project, for empty project name
+// objects of all projects returned.
+//
+func (p *Vim) TaggedObjects(projectName string) ([]jettypes.NetworkObject, error) {
 
-	for e := range errChan {
-		finalStatus = append(finalStatus, e)
+	objects, err := p.pluggableVim.TaggedObjects(p.ctx, projectName)
+	if err != nil {
+		logging.CriticalMessage("vim failed list network objects", err.Error())
+		return nil, err
 	}
 
-	succeed := 0
-	for _, v := range finalStatus {
-		if v.Ok {
-			succeed++
-		}
+	return objects, nil
+}
+
+//
+// Asks vim to delete a single network object.
+//
+func (p *Vim) DeleteObject(object jettypes.NetworkObject) error {
+
+	err := p.pluggableVim.DeleteObject(p.ctx, object)
+	if err != nil {
+		logging.CriticalMessage("vim failed delete", object.Kind, object.Id, err.Error())
+		return err
 	}
 
-	if succeed == len(nodes) {
-		return true, nil
+	return nil
+}
+
+//
+// Asks vim for each vm in a folder.
+//
+func (p *Vim) DiscoverFolder(folder string) ([]*jettypes.VmInfo, error) {
+
+	vms, err := p.pluggableVim.DiscoverFolder(p.ctx, folder)
+	if err != nil {
+		logging.CriticalMessage("vim failed discover folder", folder, err.Error())
+		return nil, err
 	}
 
-	return false, nil
+	return vms, nil
 }
 
 //
-// Chane VM power state and write to channel a status
+// Asks vim for a switch, a dhcp server and a router of existing segment.
 //
-func (p *Vim) acquireIp(node *jettypes.NodeTemplate,
-	sem chan int, wg *sync.WaitGroup, statusChan chan VimChanMessage) {
+func (p *Vim) DescribeSegment(switchUuid string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	s, r, err := p.pluggableVim.DescribeSegment(p.ctx, switchUuid)
+	if err != nil {
+		logging.CriticalMessage("vim failed describe segment", switchUuid, err.Error())
+		return nil, nil, err
+	}
+
+	return s, r, nil
+}
 
-	defer wg.Done()
-	sem <- 1
+//
+// Asks vim to delete a snapshot of a template linked clones created from.
+//
+func (p *Vim) DeleteTemplateSnapshot(vmTemplate string, snapshot string) error {
 
-	ok, err := p.AcquireIpAddress(node)
+	err := p.pluggableVim.DeleteTemplateSnapshot(p.ctx, vmTemplate, snapshot)
 	if err != nil {
-		select {
-		case statusChan <- VimChanMessage{
-			Msg: node.Name,
-			Ok:  ok,
-			Err: fmt.Errorf("clone vm task failed")}:
-			break
-		default:
-		}
-	} else {
-		statusChan <- VimChanMessage{
-			Msg: node.Name,
-			Ok:  ok,
-			Err: fmt.Errorf("clone vm task failed")}
+		logging.CriticalMessage("vim failed delete snapshot", snapshot, "of template", vmTemplate, err.Error())
+		return err
 	}
 
-	<-sem
+	return nil
+}
 
-	return
+// Returns true if vim provider supports a capability.
+func (p *Vim) Supports(capability string) bool {
+	return providers.Supports(p.pluggableVim, capability)
 }
 
 //
-// Changes VMs power state for list of nodes and it does it concurrently
-// Underlying semantics semantic need to provide cancellation behavior
-// and powerChange method provides correct reporting back to main thread routine
+// Asks vim to create a drs rule of a cluster.
 //
-func (p *Vim) AcquireIpAddresses(nodes []*jettypes.NodeTemplate) (bool, error) {
+func (p *Vim) CreateAffinityRule(rule *jettypes.AffinityRule) error {
 
-	sem := make(chan int, 3)
+	err := p.pluggableVim.CreateAffinityRule(p.ctx, rule)
+	if err != nil {
+		logging.CriticalMessage("vim failed create drs rule", rule.Name, err.Error())
+		return err
+	}
 
-	var finalStatus []VimChanMessage
-	errChan := make(chan VimChanMessage, len(nodes))
+	return nil
+}
 
-	var wg sync.WaitGroup
-	wg.Add(len(nodes))
+//
+// Asks vim to delete a drs rule of a cluster.
+//
+func (p *Vim) DeleteAffinityRule(rule *jettypes.AffinityRule) error {
 
-	for _, v := range nodes {
-		go p.acquireIp(v, sem, &wg, errChan)
+	err := p.pluggableVim.DeleteAffinityRule(p.ctx, rule)
+	if err != nil {
+		logging.CriticalMessage("vim failed delete drs rule", rule.Name, err.Error())
+		return err
 	}
 
-	wg.Wait()
-	close(errChan)
+	return nil
+}
 
-	for e := range errChan {
-		finalStatus = append(finalStatus, e)
-	}
+//
+// Changes VMs power state for list of nodes and it does it concurrently
+// Underlying semantics semantic need to provide cancellation behavior,
+// each node reported as a result and a failed node reported in error
+//
+func (p *Vim) PowerChangeAll(nodes []*jettypes.NodeTemplate, state jettypes.PowerState) (bool, error) {
 
-	succeed := 0
-	for _, v := range finalStatus {
-		if v.Ok {
-			succeed++
-		}
+	results := p.pool.RunNodes(p.ctx, jettypes.PowerJob, jettypes.OpPower, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			ok, err := p.ChangePowerState(node, state)
+			if err == nil && !ok {
+				err = fmt.Errorf("vm power state didn't change")
+			}
+			return jettypes.NodeResult{Err: err}
+		})
+	p.record(results)
+
+	err := results.Err()
+	if err != nil {
+		return false, err
 	}
 
-	if succeed == len(nodes) {
-		return true, nil
+	return true, nil
+}
+
+//
+// Acquires ip address of each node concurrently, each node reported as a result
+// and a node that didn't get an address reported in error
+//
+func (p *Vim) AcquireIpAddresses(nodes []*jettypes.NodeTemplate) (bool, error) {
+
+	results := p.pool.RunNodes(p.ctx, jettypes.NetworkJob, jettypes.OpIpAddress, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			ok, err := p.AcquireIpAddress(node)
+			if err == nil && !ok {
+				err = fmt.Errorf("vm has no ip address")
+			}
+			return jettypes.NodeResult{Err: err}
+		})
+	p.record(results)
+
+	err := results.Err()
+	if err != nil {
+		return false, err
 	}
 
-	return false, nil
+	return true, nil
 }
 
 func (p *Vim) DeleteDhcpServer(node *jettypes.NodeTemplate) (bool, error) {
@@ -539,7 +863,7 @@ func (p *Vim) DeleteDhcpServer(node *jettypes.NodeTemplate) (bool, error) {
 	if node == nil || node.GenericSwitch() == nil {
 		return false, nil
 	}
-	_, err := p.pluggableVim.DeleteDhcpServer(node)
+	_, err := p.pluggableVim.DeleteDhcpServer(p.ctx, node)
 	if err != nil {
 		logging.CriticalMessage("failed delete dhcp server " + node.GenericSwitch().DhcpUuid() + " " + err.Error())
 		return false, err
@@ -554,7 +878,7 @@ func (p *Vim) DeleteRouter(node *jettypes.NodeTemplate) (bool, error) {
 		return false, nil
 	}
 
-	_, err := p.pluggableVim.DeleteRouter(node)
+	_, err := p.pluggableVim.DeleteRouter(p.ctx, node)
 	if err != nil {
 		logging.CriticalMessage("failed delete router " + node.GenericRouter().Uuid() + " " + err.Error())
 		return false, err
@@ -563,6 +887,22 @@ func (p *Vim) DeleteRouter(node *jettypes.NodeTemplate) (bool, error) {
 	return true, nil
 }
 
+// Delete a router downlink port
+func (p *Vim) DeleteRouterPort(node *jettypes.NodeTemplate) (bool, error) {
+
+	if node == nil || node.GenericRouter() == nil {
+		return false, nil
+	}
+
+	_, err := p.pluggableVim.DeleteRouterPort(p.ctx, node)
+	if err != nil {
+		logging.CriticalMessage("failed delete router port " + node.GenericRouter().SwitchPortUuid() + " " + err.Error())
+		return false, err
+	}
+
+	return true, nil
+}
+
 // Delete a switch
 func (p *Vim) DeleteSwitch(node *jettypes.NodeTemplate) (bool, error) {
 
@@ -570,7 +910,7 @@ func (p *Vim) DeleteSwitch(node *jettypes.NodeTemplate) (bool, error) {
 		return false, nil
 	}
 
-	_, err := p.pluggableVim.DeleteSwitch(node)
+	_, err := p.pluggableVim.DeleteSwitch(p.ctx, node)
 	if err != nil {
 		logging.CriticalMessage("failed delete switch " + node.GenericSwitch().Uuid() + " " + err.Error())
 		return false, err
@@ -583,10 +923,23 @@ func (p *Vim) DeleteSwitch(node *jettypes.NodeTemplate) (bool, error) {
 // have route to pod or vm
 func (p *Vim) AddStaticRoute(projectName string, node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
 
-	_, err := p.pluggableVim.AddStaticRoute(projectName, node, podNetwork)
+	_, err := p.pluggableVim.AddStaticRoute(p.ctx, projectName, node, podNetwork)
 	if err != nil {
 		logging.CriticalMessage("failed deploy network segments " + err.Error())
 		return false, err
 	}
 	return true, nil
 }
+
+//
+// Ask vim to remove a static route for a pod network of a node.
+//
+func (p *Vim) DeleteStaticRoute(projectName string, node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
+
+	ok, err := p.pluggableVim.DeleteStaticRoute(p.ctx, projectName, node, podNetwork)
+	if err != nil {
+		logging.CriticalMessage("failed delete static route " + err.Error())
+		return false, err
+	}
+	return ok, nil
+}
@@ -25,7 +25,6 @@ import (
 	"context"
 	"github.com/google/uuid"
 	"github.com/spyroot/jettison/jettypes"
-	"log"
 
 	"math/rand"
 	"net"
@@ -50,7 +49,7 @@ type TestingEnv struct {
 */
 func VimSetupHelper() *TestingEnv {
 
-	return &TestingEnv{nil, TestImageName, nil, nil}
+	return &TestingEnv{nil, TestImageName, "", nil}
 }
 
 var (
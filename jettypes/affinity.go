@@ -0,0 +1,118 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+DRS rules created for groups of nodes so a single host failure
+doesn't take out all controllers of a cluster.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package jettypes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// kinds of drs rule a node template can set in affinity
+const (
+	AffinityNone      = "none"
+	AffinityAnti      = "anti-affinity"
+	AffinityTogether  = "affinity"
+	AffinityHostGroup = "host-group"
+)
+
+/*
+  DRS rule of a group of nodes. Vms are vim names of nodes, host group
+  is an existing host group of a cluster vms of host-group rule should run on.
+*/
+type AffinityRule struct {
+	Name      string   `json:"name"`
+	Cluster   string   `json:"cluster"`
+	Kind      string   `json:"kind"`
+	HostGroup string   `json:"hostGroup,omitempty"`
+	Vms       []string `json:"vms"`
+}
+
+/*
+  Returns a kind of drs rule of a node. Node that sets host group without kind
+  gets host-group rule, controllers by default spread across hosts.
+*/
+func (node *NodeTemplate) AffinityKind() (string, error) {
+
+	switch node.Affinity {
+	case "":
+		if len(node.HostGroup) > 0 {
+			return AffinityHostGroup, nil
+		}
+		if node.Type == ControlType {
+			return AffinityAnti, nil
+		}
+		return AffinityNone, nil
+	case AffinityHostGroup:
+		if len(node.HostGroup) == 0 {
+			return "", fmt.Errorf("%s rule of %s requires hostGroup", AffinityHostGroup, node.Prefix)
+		}
+		return AffinityHostGroup, nil
+	case AffinityNone, AffinityAnti, AffinityTogether:
+		return node.Affinity, nil
+	}
+
+	return "", fmt.Errorf("unknown affinity %s of %s", node.Affinity, node.Prefix)
+}
+
+/*
+  Groups nodes by type and cluster and returns a drs rule for each group.
+  Affinity and anti-affinity rules need at least two vms, group of a single
+  node gets no rule.
+*/
+func AffinityRules(projectName string, nodes []*NodeTemplate) ([]*AffinityRule, error) {
+
+	rules := make(map[string]*AffinityRule)
+	for _, node := range nodes {
+		kind, err := node.AffinityKind()
+		if err != nil {
+			return nil, err
+		}
+		if kind == AffinityNone {
+			continue
+		}
+
+		name := fmt.Sprintf("%s-%s-%s", projectName, strings.ToLower(node.Type.String()), kind)
+		if len(node.VimCluster) > 0 {
+			name = fmt.Sprintf("%s-%s", name, node.VimCluster)
+		}
+
+		rule, ok := rules[name]
+		if !ok {
+			rule = &AffinityRule{Name: name, Cluster: node.VimCluster, Kind: kind, HostGroup: node.HostGroup}
+			rules[name] = rule
+		}
+		rule.Vms = append(rule.Vms, node.GetVimName())
+	}
+
+	var result []*AffinityRule
+	for _, rule := range rules {
+		if rule.Kind != AffinityHostGroup && len(rule.Vms) < 2 {
+			continue
+		}
+		result = append(result, rule)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package jettypes
+
+import (
+	"testing"
+)
+
+func TestAffinityRules(t *testing.T) {
+
+	node := func(nodeType NodeType, vimName string, affinity string, hostGroup string) *NodeTemplate {
+		return &NodeTemplate{Type: nodeType, VimName: vimName, VimCluster: "mgmt", Affinity: affinity, HostGroup: hostGroup}
+	}
+
+	tests := []struct {
+		name    string
+		nodes   []*NodeTemplate
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "controllers spread by default",
+			nodes: []*NodeTemplate{
+				node(ControlType, "vm-1", "", ""),
+				node(ControlType, "vm-2", "", ""),
+				node(WorkerType, "vm-3", "", ""),
+				node(WorkerType, "vm-4", "", ""),
+			},
+			want: []string{"test-controller-anti-affinity-mgmt"},
+		},
+		{
+			name: "single ingress gets no rule",
+			nodes: []*NodeTemplate{
+				node(IngressType, "vm-1", AffinityAnti, ""),
+			},
+		},
+		{
+			name: "host group without kind",
+			nodes: []*NodeTemplate{
+				node(IngressType, "vm-1", "", "rack-a"),
+				node(ControlType, "vm-2", AffinityNone, ""),
+				node(ControlType, "vm-3", AffinityNone, ""),
+			},
+			want: []string{"test-ingress-host-group-mgmt"},
+		},
+		{
+			name: "host group rule without group",
+			nodes: []*NodeTemplate{
+				node(WorkerType, "vm-1", AffinityHostGroup, ""),
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown kind",
+			nodes: []*NodeTemplate{
+				node(WorkerType, "vm-1", "spread", ""),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := AffinityRules("test", tt.nodes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AffinityRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(rules) != len(tt.want) {
+				t.Fatalf("AffinityRules() = %v rules, want %v", len(rules), len(tt.want))
+			}
+			for i, rule := range rules {
+				if rule.Name != tt.want[i] {
+					t.Errorf("rule name = %v, want %v", rule.Name, tt.want[i])
+				}
+			}
+		})
+	}
+}
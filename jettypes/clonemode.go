@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Clone mode of a node template. Linked clone shares disk of a template
+snapshot and only writes own child disk.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package jettypes
+
+import "fmt"
+
+const (
+	CloneFull   = "full"
+	CloneLinked = "linked"
+
+	// snapshot of a template linked clones created from when template sets none
+	DefaultSnapshot = "jettison-base"
+)
+
+// Returns an error if a clone mode of a node is unknown.
+func (node *NodeTemplate) ValidateCloneMode() error {
+	switch node.CloneMode {
+	case "", CloneFull, CloneLinked:
+		return nil
+	}
+	return fmt.Errorf("unknown clone mode %s of %s", node.CloneMode, node.Prefix)
+}
+
+// Returns true if a node cloned as a linked clone.
+func (node *NodeTemplate) LinkedClone() bool {
+	if node != nil {
+		return node.CloneMode == CloneLinked
+	}
+	return false
+}
+
+// Returns a name of a template snapshot a linked clone created from.
+func (node *NodeTemplate) SnapshotName() string {
+	if node != nil && len(node.Snapshot) > 0 {
+		return node.Snapshot
+	}
+	return DefaultSnapshot
+}
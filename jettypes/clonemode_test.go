@@ -0,0 +1,47 @@
+package jettypes
+
+import (
+	"testing"
+)
+
+func TestCloneMode(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		node     NodeTemplate
+		linked   bool
+		snapshot string
+		wantErr  bool
+	}{
+		{
+			name:     "full by default",
+			node:     NodeTemplate{},
+			snapshot: DefaultSnapshot,
+		},
+		{
+			name:     "linked with own snapshot",
+			node:     NodeTemplate{CloneMode: CloneLinked, Snapshot: "base"},
+			linked:   true,
+			snapshot: "base",
+		},
+		{
+			name:     "unknown mode",
+			node:     NodeTemplate{CloneMode: "instant"},
+			snapshot: DefaultSnapshot,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.node.ValidateCloneMode(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCloneMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.node.LinkedClone() != tt.linked {
+				t.Errorf("LinkedClone() = %v, want %v", tt.node.LinkedClone(), tt.linked)
+			}
+			if tt.node.SnapshotName() != tt.snapshot {
+				t.Errorf("SnapshotName() = %v, want %v", tt.node.SnapshotName(), tt.snapshot)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Cloud-init section of a node template. User data and meta data are
+templates rendered over facts of each node.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package jettypes
+
+/*
+  Cloud-init data of a node. User data read from userDataFile or taken
+  inline from userData, only one of them can be set. Empty meta data
+  replaced by instance id and hostname of a node.
+*/
+type CloudInit struct {
+	UserDataFile string `yaml:"userDataFile"`
+	UserData     string `yaml:"userData"`
+	MetaData     string `yaml:"metaData"`
+}
+
+/*
+  Facts of a node cloud-init templates rendered with, for example
+  {{ .Hostname }} or {{ .PodCidr }}. Pod cidr empty for a node without pod network.
+*/
+type CloudInitFacts struct {
+	Project      string
+	Name         string
+	Hostname     string
+	Domain       string
+	Role         string
+	IPv4Addr     string
+	Gateway      string
+	PodCidr      string
+	DnsServers   []string
+	SshUsername  string
+	SshPublicKey string
+}
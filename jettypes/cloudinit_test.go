@@ -0,0 +1,41 @@
+package jettypes
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestNodeTemplateCloudInit(t *testing.T) {
+
+	var data = `
+      prefix: kubelet
+      vmTemplateName: ubuntu19-template
+      cloudInit:
+        metaData: "local-hostname: {{ .Hostname }}"
+        userData: |
+          #cloud-config
+          hostname: {{ .Hostname }}
+`
+	var node = NodeTemplate{}
+	err := yaml.Unmarshal([]byte(data), &node)
+	if err != nil {
+		t.Fatal("Bad yaml", err)
+	}
+
+	if node.CloudInit == nil {
+		t.Fatal("cloudInit section not parsed")
+	}
+	if node.CloudInit.UserData != "#cloud-config\nhostname: {{ .Hostname }}\n" {
+		t.Errorf("UserData = %q", node.CloudInit.UserData)
+	}
+	if node.CloudInit.MetaData != "local-hostname: {{ .Hostname }}" {
+		t.Errorf("MetaData = %q", node.CloudInit.MetaData)
+	}
+
+	node.SetCloudInitData("user", "meta")
+	userData, metaData := node.Clone().CloudInitData()
+	if userData != "user" || metaData != "meta" {
+		t.Errorf("cloned CloudInitData() = %v, %v", userData, metaData)
+	}
+}
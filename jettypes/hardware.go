@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Hardware sizing of a node. A zero value keeps value of a vm template
+node cloned from.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package jettypes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+  Hardware of a node, root disk is a first disk of a template and data disks
+  added to a clone after root disk.
+*/
+type Hardware struct {
+	Cpus           int32   `yaml:"cpus" json:"cpus,omitempty"`
+	CoresPerSocket int32   `yaml:"coresPerSocket" json:"coresPerSocket,omitempty"`
+	MemoryMB       int64   `yaml:"memoryMB" json:"memoryMB,omitempty"`
+	DiskGB         int64   `yaml:"diskGB" json:"diskGB,omitempty"`
+	DataDisksGB    []int64 `yaml:"dataDisksGB" json:"dataDisksGB,omitempty"`
+}
+
+// true if hardware keeps every value of a template
+func (h Hardware) IsZero() bool {
+	return h.Cpus == 0 && h.CoresPerSocket == 0 && h.MemoryMB == 0 && h.DiskGB == 0 && len(h.DataDisksGB) == 0
+}
+
+/**
+  Returns hardware of a clone, each zero value taken from a template.
+*/
+func (h Hardware) Merge(template Hardware) Hardware {
+
+	merged := template
+	if h.Cpus > 0 {
+		merged.Cpus = h.Cpus
+	}
+	if h.CoresPerSocket > 0 {
+		merged.CoresPerSocket = h.CoresPerSocket
+	}
+	if h.MemoryMB > 0 {
+		merged.MemoryMB = h.MemoryMB
+	}
+	if h.DiskGB > 0 {
+		merged.DiskGB = h.DiskGB
+	}
+	merged.DataDisksGB = append([]int64(nil), h.DataDisksGB...)
+
+	return merged
+}
+
+/**
+  Validates requested hardware against hardware of a template. Root disk can
+  only grow and cores per socket must divide number of cpus of a clone.
+*/
+func (h Hardware) Validate(template Hardware) error {
+
+	if h.Cpus < 0 || h.CoresPerSocket < 0 || h.MemoryMB < 0 || h.DiskGB < 0 {
+		return fmt.Errorf("cpus, coresPerSocket, memoryMB and diskGB can't be negative")
+	}
+
+	merged := h.Merge(template)
+	if merged.CoresPerSocket > 0 && merged.Cpus%merged.CoresPerSocket != 0 {
+		return fmt.Errorf("%d cpus can't be split in sockets of %d cores", merged.Cpus, merged.CoresPerSocket)
+	}
+
+	if h.DiskGB > 0 && h.DiskGB < template.DiskGB {
+		return fmt.Errorf("root disk can't shrink from %dGB to %dGB", template.DiskGB, h.DiskGB)
+	}
+
+	for i, size := range h.DataDisksGB {
+		if size <= 0 {
+			return fmt.Errorf("data disk %d size must be positive", i)
+		}
+	}
+
+	return nil
+}
+
+// short form 4cpu/8192MB/40GB+100GB, zero values omitted
+func (h Hardware) String() string {
+
+	var parts []string
+	if h.Cpus > 0 {
+		parts = append(parts, strconv.Itoa(int(h.Cpus))+"cpu")
+	}
+	if h.MemoryMB > 0 {
+		parts = append(parts, strconv.FormatInt(h.MemoryMB, 10)+"MB")
+	}
+
+	disks := ""
+	if h.DiskGB > 0 {
+		disks = strconv.FormatInt(h.DiskGB, 10) + "GB"
+	}
+	for _, size := range h.DataDisksGB {
+		disks += "+" + strconv.FormatInt(size, 10) + "GB"
+	}
+	if len(disks) > 0 {
+		parts = append(parts, disks)
+	}
+
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,98 @@
+package jettypes
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestHardwareValidate(t *testing.T) {
+
+	template := Hardware{Cpus: 2, CoresPerSocket: 1, MemoryMB: 4096, DiskGB: 40}
+
+	tests := []struct {
+		name    string
+		h       Hardware
+		wantErr bool
+	}{
+		{
+			name: "keep template",
+			h:    Hardware{},
+		},
+		{
+			name: "grow",
+			h:    Hardware{Cpus: 8, CoresPerSocket: 4, MemoryMB: 16384, DiskGB: 100, DataDisksGB: []int64{200}},
+		},
+		{
+			name:    "shrink root disk",
+			h:       Hardware{DiskGB: 20},
+			wantErr: true,
+		},
+		{
+			name:    "cores don't divide cpus",
+			h:       Hardware{Cpus: 6, CoresPerSocket: 4},
+			wantErr: true,
+		},
+		{
+			name:    "cores don't divide template cpus",
+			h:       Hardware{CoresPerSocket: 4},
+			wantErr: true,
+		},
+		{
+			name:    "negative memory",
+			h:       Hardware{MemoryMB: -1},
+			wantErr: true,
+		},
+		{
+			name:    "empty data disk",
+			h:       Hardware{DataDisksGB: []int64{0}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.h.Validate(template); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHardwareMerge(t *testing.T) {
+
+	template := Hardware{Cpus: 2, CoresPerSocket: 1, MemoryMB: 4096, DiskGB: 40}
+
+	got := Hardware{Cpus: 4, DataDisksGB: []int64{100}}.Merge(template)
+	if got.Cpus != 4 || got.CoresPerSocket != 1 || got.MemoryMB != 4096 || got.DiskGB != 40 {
+		t.Errorf("Merge() = %v", got)
+	}
+	if got.String() != "4cpu/4096MB/40GB+100GB" {
+		t.Errorf("String() = %v", got.String())
+	}
+}
+
+func TestNodeTemplateHardware(t *testing.T) {
+
+	var data = `
+      prefix: kubelet
+      vmTemplateName: ubuntu19-template
+      cpus: 4
+      coresPerSocket: 2
+      memoryMB: 8192
+      diskGB: 60
+      dataDisksGB: [100, 200]
+`
+	var node = NodeTemplate{}
+	err := yaml.Unmarshal([]byte(data), &node)
+	if err != nil {
+		t.Fatal("Bad yaml", err)
+	}
+
+	want := "4cpu/8192MB/60GB+100GB+200GB"
+	if node.Hardware.String() != want || node.Hardware.CoresPerSocket != 2 {
+		t.Errorf("Hardware = %v, want %v", node.Hardware, want)
+	}
+	if node.Clone().Hardware.String() != want {
+		t.Errorf("cloned Hardware = %v, want %v", node.Clone().Hardware, want)
+	}
+}
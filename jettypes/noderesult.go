@@ -0,0 +1,134 @@
+package jettypes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// operations reported per node
+const (
+	OpClone     = "clone"
+	OpDestroy   = "destroy"
+	OpPower     = "power"
+	OpIpAddress = "ipaddress"
+	OpBind      = "bind"
+	OpUnbind    = "unbind"
+)
+
+/*
+ A result of single operation executed for a node, task id is
+ a vim task reference if vim created a task for operation.
+*/
+type NodeResult struct {
+	Op       string
+	Node     string
+	TaskId   string
+	Duration time.Duration
+	Err      error
+}
+
+// Returns true if operation succeed
+func (r NodeResult) Ok() bool {
+	return r.Err == nil
+}
+
+func (r NodeResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s: %v", r.Op, r.Node, r.Err)
+	}
+	return fmt.Sprintf("%s %s: ok", r.Op, r.Node)
+}
+
+type NodeResults []NodeResult
+
+// Returns results of operations that failed
+func (r NodeResults) Failed() NodeResults {
+	var failed NodeResults
+	for _, v := range r {
+		if !v.Ok() {
+			failed = append(failed, v)
+		}
+	}
+	return failed
+}
+
+// Returns nodes operation succeed for, node without a result not returned
+func (r NodeResults) Succeeded(nodes []*NodeTemplate) []*NodeTemplate {
+
+	ok := make(map[string]bool)
+	for _, v := range r {
+		if v.Ok() {
+			ok[v.Node] = true
+		}
+	}
+
+	var succeeded []*NodeTemplate
+	for _, n := range nodes {
+		if ok[n.Name] {
+			succeeded = append(succeeded, n)
+		}
+	}
+	return succeeded
+}
+
+// Returns nil if all operations succeed, otherwise a MultiError
+// that holds each failed operation
+func (r NodeResults) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return &MultiError{Total: len(r), Failed: failed}
+}
+
+/*
+ An error of group of operations, each failed operation reported per node.
+*/
+type MultiError struct {
+	Total  int
+	Failed NodeResults
+}
+
+func (m *MultiError) Error() string {
+
+	var errs []string
+	for _, r := range m.Failed {
+		errs = append(errs, r.String())
+	}
+
+	return fmt.Sprintf("%d of %d operations failed: %s", len(m.Failed), m.Total, strings.Join(errs, "; "))
+}
+
+/*
+ Runs op for each node as a job of given kind, fn returns a result of a node.
+ Operation, node name and duration set by RunNodes. Results returned in order
+ of nodes, a node that didn't start because ctx cancelled has error.
+*/
+func (w *WorkerPool) RunNodes(ctx context.Context, kind JobKind, op string,
+	nodes []*NodeTemplate, fn func(node *NodeTemplate) NodeResult) NodeResults {
+
+	results := make(NodeResults, len(nodes))
+
+	w.Run(ctx, kind, len(nodes), func(i int) {
+		start := time.Now()
+		r := fn(nodes[i])
+		r.Op = op
+		r.Node = nodes[i].Name
+		r.Duration = time.Since(start)
+		results[i] = r
+	})
+
+	for i := range results {
+		if len(results[i].Op) == 0 {
+			results[i] = NodeResult{
+				Op:   op,
+				Node: nodes[i].Name,
+				Err:  fmt.Errorf("not started: %v", ctx.Err()),
+			}
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,91 @@
+package jettypes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunNodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodes      []string
+		fail       map[string]bool
+		wantFailed int
+	}{
+		{name: "all succeed", nodes: []string{"a", "b", "c"}, wantFailed: 0},
+		{name: "one failed", nodes: []string{"a", "b", "c"}, fail: map[string]bool{"b": true}, wantFailed: 1},
+		{name: "no nodes", nodes: nil, wantFailed: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var nodes []*NodeTemplate
+			for _, n := range tt.nodes {
+				nodes = append(nodes, &NodeTemplate{Name: n})
+			}
+
+			w := NewWorkerPool(2, nil)
+			results := w.RunNodes(context.Background(), CloneJob, OpClone, nodes,
+				func(node *NodeTemplate) NodeResult {
+					if tt.fail[node.Name] {
+						return NodeResult{TaskId: "task-" + node.Name, Err: fmt.Errorf("failed")}
+					}
+					return NodeResult{TaskId: "task-" + node.Name}
+				})
+
+			if len(results) != len(nodes) {
+				t.Fatalf("RunNodes() returned %v results, want %v", len(results), len(nodes))
+			}
+			for i, r := range results {
+				if r.Node != tt.nodes[i] || r.Op != OpClone || r.TaskId != "task-"+tt.nodes[i] {
+					t.Errorf("RunNodes() result %v = %+v", i, r)
+				}
+			}
+
+			if succeeded := results.Succeeded(nodes); len(succeeded) != len(nodes)-tt.wantFailed {
+				t.Errorf("Succeeded() = %v nodes, want %v", len(succeeded), len(nodes)-tt.wantFailed)
+			}
+
+			err := results.Err()
+			if tt.wantFailed == 0 {
+				if err != nil {
+					t.Errorf("Err() = %v, want nil", err)
+				}
+				return
+			}
+
+			m, ok := err.(*MultiError)
+			if !ok {
+				t.Fatalf("Err() = %T, want *MultiError", err)
+			}
+			if len(m.Failed) != tt.wantFailed || m.Total != len(nodes) {
+				t.Errorf("Err() failed %v of %v, want %v of %v", len(m.Failed), m.Total, tt.wantFailed, len(nodes))
+			}
+			if !strings.Contains(m.Error(), "clone b: failed") {
+				t.Errorf("Error() = %v, want failed node reported", m.Error())
+			}
+		})
+	}
+}
+
+func TestRunNodesCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	nodes := []*NodeTemplate{{Name: "a"}, {Name: "b"}}
+	results := NewWorkerPool(2, nil).RunNodes(ctx, PowerJob, OpPower, nodes,
+		func(node *NodeTemplate) NodeResult {
+			return NodeResult{}
+		})
+
+	if len(results.Failed()) != len(nodes) {
+		t.Errorf("RunNodes() failed %v nodes, want %v", len(results.Failed()), len(nodes))
+	}
+	for i, r := range results {
+		if r.Node != nodes[i].Name || r.Op != OpPower {
+			t.Errorf("RunNodes() result %v = %+v", i, r)
+		}
+	}
+}
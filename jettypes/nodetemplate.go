@@ -56,6 +56,26 @@ type NodeTemplate struct {
 	EdgeCluster    string `yaml:"edgeCluster"`
 	Static         bool   `yaml:"static"`
 
+	// name servers of a static node, empty takes dnsServers of config
+	DnsServers []string `yaml:"dnsServers"`
+
+	// cpu, memory and disks of a clone, zero keeps template value
+	Hardware Hardware `yaml:",inline"`
+
+	// full or linked clone, linked clone created from a snapshot of a template
+	CloneMode string `yaml:"cloneMode"`
+	Snapshot  string `yaml:"snapshot"`
+
+	// datastore, resource pool, host and folder of a clone
+	Placement Placement `yaml:",inline"`
+
+	// cloud-init data rendered for each node, nil if node configured over ssh
+	CloudInit *CloudInit `yaml:"cloudInit"`
+
+	// drs rule of a node group, empty is anti-affinity for controllers and none for others
+	Affinity  string `yaml:"affinity"`
+	HostGroup string `yaml:"hostGroup"`
+
 	IPv4Addr net.IP
 	IPv4Net  *net.IPNet
 
@@ -84,6 +104,10 @@ type NodeTemplate struct {
 	podCidr           string
 	podAllocationSize int
 
+	// rendered cloud-init user and meta data
+	userData string
+	metaData string
+
 	template bool
 }
 
@@ -357,6 +381,22 @@ func (node *NodeTemplate) PodAllocationSize(size int) {
 	}
 }
 
+// Sets cloud-init data rendered for a node.
+func (node *NodeTemplate) SetCloudInitData(userData string, metaData string) {
+	if node != nil {
+		node.userData = userData
+		node.metaData = metaData
+	}
+}
+
+// Returns cloud-init user and meta data rendered for a node.
+func (node *NodeTemplate) CloudInitData() (string, string) {
+	if node != nil {
+		return node.userData, node.metaData
+	}
+	return "", ""
+}
+
 func (node *NodeTemplate) GetCidr() string {
 	if node != nil {
 		return node.podCidr
@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Placement of a clone, where vm of a node template created in a vim.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package jettypes
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// folder of a deployment when a template sets no folder
+const DefaultFolder = "{{ .Project }}-{{ .Uuid }}"
+
+/*
+  Placement of a clone, empty value keeps placement of a vm template.
+  Datastore is a datastore or a datastore cluster, folder is a path template
+  relative to vm folder of a datacenter. Host group placement done by
+  hostGroup drs rule.
+*/
+type Placement struct {
+	Datastore    string `yaml:"datastore" json:"datastore,omitempty"`
+	ResourcePool string `yaml:"resourcePool" json:"resourcePool,omitempty"`
+	Host         string `yaml:"host" json:"host,omitempty"`
+	Folder       string `yaml:"folder" json:"folder,omitempty"`
+}
+
+// facts a folder template rendered with, uuid is unique for each clone call
+type folderFacts struct {
+	Project string
+	Role    string
+	Prefix  string
+	Uuid    string
+}
+
+/*
+  Renders a folder path of a node, for example {{ .Project }}/{{ .Role }}.
+  Leading and trailing slashes removed, path is always relative to vm folder.
+*/
+func (node *NodeTemplate) RenderFolder(projectName string, uuid string) (string, error) {
+
+	text := node.Placement.Folder
+	if len(text) == 0 {
+		text = DefaultFolder
+	}
+
+	tmpl, err := template.New("folder").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed parse folder of %s %v", node.Prefix, err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, folderFacts{
+		Project: projectName,
+		Role:    strings.ToLower(node.Type.String()),
+		Prefix:  node.Prefix,
+		Uuid:    uuid,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed render folder of %s %v", node.Prefix, err)
+	}
+
+	path := strings.Trim(buf.String(), "/")
+	if len(path) == 0 {
+		return "", fmt.Errorf("folder of %s is empty", node.Prefix)
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if len(strings.TrimSpace(elem)) == 0 {
+			return "", fmt.Errorf("folder %s of %s has empty element", path, node.Prefix)
+		}
+	}
+
+	return path, nil
+}
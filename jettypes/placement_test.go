@@ -0,0 +1,75 @@
+package jettypes
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestRenderFolder(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		folder  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default folder",
+			want: "test-1234",
+		},
+		{
+			name:   "nested folder",
+			folder: "/k8s/{{ .Project }}/{{ .Role }}/",
+			want:   "k8s/test/worker",
+		},
+		{
+			name:    "empty element",
+			folder:  "k8s//{{ .Prefix }}",
+			wantErr: true,
+		},
+		{
+			name:    "unknown fact",
+			folder:  "{{ .Cluster }}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &NodeTemplate{Prefix: "kubelet", Type: WorkerType, Placement: Placement{Folder: tt.folder}}
+			got, err := node.RenderFolder("test", "1234")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderFolder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderFolder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeTemplatePlacement(t *testing.T) {
+
+	var data = `
+      prefix: kubelet
+      datastore: vsanDatastore
+      resourcePool: mgmt/Resources/k8s
+      host: esxi01.vmwarelab.edu
+      folder: k8s/{{ .Project }}
+`
+	var node = NodeTemplate{}
+	err := yaml.Unmarshal([]byte(data), &node)
+	if err != nil {
+		t.Fatal("Bad yaml", err)
+	}
+
+	want := Placement{
+		Datastore:    "vsanDatastore",
+		ResourcePool: "mgmt/Resources/k8s",
+		Host:         "esxi01.vmwarelab.edu",
+		Folder:       "k8s/{{ .Project }}",
+	}
+	if node.Placement != want {
+		t.Errorf("Placement = %+v, want %+v", node.Placement, want)
+	}
+}
@@ -1,6 +1,7 @@
 package jettypes
 
 import (
+	"context"
 	"strings"
 )
 
@@ -23,51 +24,95 @@ const (
 type VimPlugin interface {
 	// entry point fo plugin used by vim to load a plugin
 	// TODO remove argument plugin must do own configuration mgmt same as per nsx
-	InitPlugin(VimEndpoint) error
+	InitPlugin(ctx context.Context, endpoint VimEndpoint) error
+
+	// worker pool that bounds concurrent jobs, shared with vim
+	SetWorkerPool(pool *WorkerPool)
 
 	// connect vm to a switch, it should create adapter if no adapter present
 	// vim use a switch that auto discovered
-	ConnectVm(projectName string, node *NodeTemplate) (bool, error)
+	ConnectVm(ctx context.Context, projectName string, node *NodeTemplate) (bool, error)
 
-	DisconnectVm(projectName string, node *NodeTemplate) (bool, error)
+	DisconnectVm(ctx context.Context, projectName string, node *NodeTemplate) (bool, error)
 
-	// plug implementation need provide semantics to cleanup all stale object
-	ComputeCleanup(projectName string, nodes []*NodeTemplate) error
+	// plug implementation need provide semantics to cleanup all stale object,
+	// result reported per node and error holds each failed node
+	ComputeCleanup(ctx context.Context, projectName string, nodes []*NodeTemplate) (NodeResults, error)
 
 	//
-	DhcpCleanup(projectName string, nodes []*NodeTemplate) error
+	DhcpCleanup(ctx context.Context, projectName string, nodes []*NodeTemplate) error
 
-	// network interface
-	DeploySegment(projectName string, segmentName string, gateway string, prefixLen int) (*GenericSwitch, *GenericRouter, error)
+	// network interface, segment of static nodes deployed without dhcp server,
+	// dhcp server hands out given name servers and domain
+	DeploySegment(ctx context.Context, projectName string, segmentName string, gateway string, prefixLen int,
+		dhcp bool, dnsServers []string, domain string) (*GenericSwitch, *GenericRouter, error)
 
 	//
-	CreateDhcpBindings(projectName string, nodes []*NodeTemplate) error
+	CreateDhcpBindings(ctx context.Context, projectName string, nodes []*NodeTemplate) error
 
 	// discovery cluster dhcp
-	DiscoverClusterDhcpServer(projectName string, nodes *[]*NodeTemplate) (bool, error)
+	DiscoverClusterDhcpServer(ctx context.Context, projectName string, nodes *[]*NodeTemplate) (bool, error)
 
 	// discovery vm template
-	DiscoverVmTemplate(node *NodeTemplate) error
+	DiscoverVmTemplate(ctx context.Context, node *NodeTemplate) error
 
 	// discovery vm
-	DiscoverVms(projectName string, nodes []*NodeTemplate) error
+	DiscoverVms(ctx context.Context, projectName string, nodes []*NodeTemplate) error
+
+	// live state of a vm, vm that not found is not an error
+	DescribeVm(ctx context.Context, node *NodeTemplate) (*VmInfo, error)
+
+	// live state of every vm in a vim folder, vSphere tags of each vm included
+	DiscoverFolder(ctx context.Context, folder string) ([]*VmInfo, error)
+
+	// switch, dhcp server and router of an existing segment
+	DescribeSegment(ctx context.Context, switchUuid string) (*GenericSwitch, *GenericRouter, error)
+
+	// ip address of a static dhcp binding of node mac, false if node has no binding
+	DescribeBinding(ctx context.Context, node *NodeTemplate) (string, bool, error)
+
+	// network objects tagged with a project, empty project returns objects of all projects
+	TaggedObjects(ctx context.Context, projectName string) ([]NetworkObject, error)
+
+	// delete a single network object returned by TaggedObjects
+	DeleteObject(ctx context.Context, object NetworkObject) error
 
-	// clone group of vm from nodes
-	CloneVms(projectName string, nodes []*NodeTemplate) error
+	// clone group of vm from nodes, result reported per node and error holds each failed node
+	CloneVms(ctx context.Context, projectName string, nodes []*NodeTemplate) (NodeResults, error)
+
+	// power off and destroy a single vm, folder vm placed in left untouched
+	DeleteVm(ctx context.Context, projectName string, node *NodeTemplate) error
+
+	// destroy a folder created for a deployment
+	DeleteFolder(ctx context.Context, projectName string, folder string) error
+
+	// delete a snapshot of a template linked clones created from, snapshot jettison didn't create kept
+	DeleteTemplateSnapshot(ctx context.Context, vmTemplate string, snapshot string) error
+
+	// create or update a drs rule of a cluster
+	CreateAffinityRule(ctx context.Context, rule *AffinityRule) error
+
+	// delete a drs rule and a vm group of a rule, rule that not found is not an error
+	DeleteAffinityRule(ctx context.Context, rule *AffinityRule) error
 
 	// change vm power state
-	ChangePowerState(node *NodeTemplate, state PowerState) (bool, error)
+	ChangePowerState(ctx context.Context, node *NodeTemplate, state PowerState) (bool, error)
 
 	// acquire vm ip address
-	AcquireIpAddress(node *NodeTemplate) (bool, string, error)
+	AcquireIpAddress(ctx context.Context, node *NodeTemplate) (bool, string, error)
+
+	DeleteDhcpServer(ctx context.Context, node *NodeTemplate) (bool, error)
+
+	DeleteRouter(ctx context.Context, node *NodeTemplate) (bool, error)
 
-	DeleteDhcpServer(node *NodeTemplate) (bool, error)
+	// delete a downlink port that connects router to a segment switch
+	DeleteRouterPort(ctx context.Context, node *NodeTemplate) (bool, error)
 
-	DeleteRouter(node *NodeTemplate) (bool, error)
+	DeleteSwitch(ctx context.Context, node *NodeTemplate) (bool, error)
 
-	DeleteSwitch(node *NodeTemplate) (bool, error)
+	AddStaticRoute(ctx context.Context, projectName string, node *NodeTemplate, podNetwork string) (bool, error)
 
-	AddStaticRoute(projectName string, node *NodeTemplate, podNetwork string) (bool, error)
+	DeleteStaticRoute(ctx context.Context, projectName string, node *NodeTemplate, podNetwork string) (bool, error)
 }
 
 /* node type */
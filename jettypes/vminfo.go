@@ -0,0 +1,47 @@
+package jettypes
+
+import "time"
+
+/*
+ A live state of a vm as a vim reports it, a vm that is not found
+ reported with Exists false rather than an error.
+*/
+type VmInfo struct {
+	Name      string `json:"name" yaml:"name"`
+	Exists    bool   `json:"exists" yaml:"exists"`
+	PoweredOn bool   `json:"poweredOn" yaml:"poweredOn"`
+	UUID      string `json:"uuid,omitempty" yaml:"uuid,omitempty"`
+	VimName   string `json:"vimName,omitempty" yaml:"vimName,omitempty"`
+	// cluster of a host vm running on
+	Cluster string `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	// address reported by guest tools
+	IPv4Addr    string   `json:"ipv4Address,omitempty" yaml:"ipv4Address,omitempty"`
+	Mac         []string `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Networks    []string `json:"networks,omitempty" yaml:"networks,omitempty"`
+	SwitchUuids []string `json:"switchUuids,omitempty" yaml:"switchUuids,omitempty"`
+	// names of vSphere tags attached to a vm
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// kinds of network objects a vim creates for a project
+const (
+	ObjectSwitch      = "switch"
+	ObjectRouter      = "router"
+	ObjectRouterPort  = "routerport"
+	ObjectDhcpServer  = "dhcpserver"
+	ObjectDhcpProfile = "dhcpprofile"
+	ObjectDhcpBinding = "dhcpbinding"
+)
+
+/*
+ A network object tagged with a project that created it. Parent is an object
+ that holds it, a dhcp server of a binding or a router of a port.
+*/
+type NetworkObject struct {
+	Kind    string    `json:"kind" yaml:"kind"`
+	Id      string    `json:"id" yaml:"id"`
+	Name    string    `json:"name" yaml:"name"`
+	Parent  string    `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Project string    `json:"project" yaml:"project"`
+	Created time.Time `json:"created" yaml:"created"`
+}
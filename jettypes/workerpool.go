@@ -0,0 +1,135 @@
+package jettypes
+
+import (
+	"context"
+	"sync"
+)
+
+// number of concurrent jobs when configuration doesn't set one
+const DefaultParallelJobs = 3
+
+type JobKind int
+
+const (
+	// clone and destroy vm
+	CloneJob JobKind = iota
+	// change vm power state
+	PowerJob
+	// acquire vm ip address and network api calls
+	NetworkJob
+)
+
+/*
+ A bounded worker pool shared by vim and a plugin.  Total number of
+ concurrent jobs limited by a pool size, in addition each kind of job
+ has own limit so single kind of job can't take all slots.
+*/
+type WorkerPool struct {
+	// global slots
+	slots chan struct{}
+
+	// slots per kind of job
+	limits map[JobKind]chan struct{}
+}
+
+/*
+ Creates a worker pool with size slots, a limit for a kind of job
+ that is not set or larger than a pool size capped by a pool size.
+*/
+func NewWorkerPool(size int, limits map[JobKind]int) *WorkerPool {
+
+	if size < 1 {
+		size = DefaultParallelJobs
+	}
+
+	pool := &WorkerPool{
+		slots:  make(chan struct{}, size),
+		limits: make(map[JobKind]chan struct{}),
+	}
+
+	for _, kind := range []JobKind{CloneJob, PowerJob, NetworkJob} {
+		n, ok := limits[kind]
+		if !ok || n < 1 || n > size {
+			n = size
+		}
+		pool.limits[kind] = make(chan struct{}, n)
+	}
+
+	return pool
+}
+
+// Returns a pool size
+func (w *WorkerPool) Size() int {
+	if w != nil {
+		return cap(w.slots)
+	}
+	return 0
+}
+
+// Returns a limit for a kind of job
+func (w *WorkerPool) Limit(kind JobKind) int {
+	if w != nil {
+		return cap(w.limits[kind])
+	}
+	return 0
+}
+
+// kind slot always taken before global slot, so jobs waiting
+// for own kind don't hold global slots. Returns false if context
+// cancelled before job got both slots.
+func (w *WorkerPool) acquire(ctx context.Context, kind JobKind) bool {
+
+	select {
+	case w.limits[kind] <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+	case <-ctx.Done():
+		<-w.limits[kind]
+		return false
+	}
+
+	// select picks at random when both ready
+	if ctx.Err() != nil {
+		w.release(kind)
+		return false
+	}
+
+	return true
+}
+
+func (w *WorkerPool) release(kind JobKind) {
+	<-w.slots
+	<-w.limits[kind]
+}
+
+/*
+ Runs n jobs of a given kind, fn called with index of a job.
+ Run blocks until all jobs finished, once ctx cancelled jobs that
+ didn't start are skipped and Run waits only for running jobs.
+*/
+func (w *WorkerPool) Run(ctx context.Context, kind JobKind, n int, fn func(i int)) {
+
+	if w == nil {
+		w = NewWorkerPool(DefaultParallelJobs, nil)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			if !w.acquire(ctx, kind) {
+				return
+			}
+			defer w.release(kind)
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
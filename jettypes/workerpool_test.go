@@ -0,0 +1,131 @@
+package jettypes
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWorkerPool(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		limits    map[JobKind]int
+		wantSize  int
+		wantClone int
+		wantPower int
+	}{
+		{
+			name:      "default size",
+			size:      0,
+			limits:    nil,
+			wantSize:  DefaultParallelJobs,
+			wantClone: DefaultParallelJobs,
+			wantPower: DefaultParallelJobs,
+		},
+		{
+			name:      "limit per kind",
+			size:      10,
+			limits:    map[JobKind]int{CloneJob: 2},
+			wantSize:  10,
+			wantClone: 2,
+			wantPower: 10,
+		},
+		{
+			name:      "limit capped by size",
+			size:      4,
+			limits:    map[JobKind]int{PowerJob: 8},
+			wantSize:  4,
+			wantClone: 4,
+			wantPower: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkerPool(tt.size, tt.limits)
+			if got := w.Size(); got != tt.wantSize {
+				t.Errorf("Size() = %v, want %v", got, tt.wantSize)
+			}
+			if got := w.Limit(CloneJob); got != tt.wantClone {
+				t.Errorf("Limit(CloneJob) = %v, want %v", got, tt.wantClone)
+			}
+			if got := w.Limit(PowerJob); got != tt.wantPower {
+				t.Errorf("Limit(PowerJob) = %v, want %v", got, tt.wantPower)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		limits  map[JobKind]int
+		jobs    int
+		wantMax int
+	}{
+		{name: "bounded by size", size: 4, jobs: 20, wantMax: 4},
+		{name: "bounded by kind", size: 4, limits: map[JobKind]int{CloneJob: 2}, jobs: 20, wantMax: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkerPool(tt.size, tt.limits)
+
+			var (
+				mu      sync.Mutex
+				running int
+				max     int
+				done    = make([]bool, tt.jobs)
+			)
+
+			w.Run(context.Background(), CloneJob, tt.jobs, func(i int) {
+				mu.Lock()
+				running++
+				if running > max {
+					max = running
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				done[i] = true
+				mu.Unlock()
+			})
+
+			if max > tt.wantMax {
+				t.Errorf("Run() ran %v jobs concurrently, want at most %v", max, tt.wantMax)
+			}
+			for i, ok := range done {
+				if !ok {
+					t.Errorf("Run() job %v not executed", i)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkerPoolRunCancel(t *testing.T) {
+
+	w := NewWorkerPool(2, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		mu      sync.Mutex
+		started int
+	)
+
+	w.Run(ctx, PowerJob, 10, func(i int) {
+		mu.Lock()
+		started++
+		mu.Unlock()
+		cancel()
+		time.Sleep(5 * time.Millisecond)
+	})
+
+	if started > 2 {
+		t.Errorf("Run() started %v jobs after cancel, want at most 2", started)
+	}
+}
@@ -21,18 +21,23 @@ mbaraymov@vmware.com
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spyroot/jettison/consts"
+	"github.com/spyroot/jettison/dbutil"
 	"github.com/spyroot/jettison/internal"
 	"github.com/spyroot/jettison/jettypes"
 	"github.com/spyroot/jettison/logging"
+	_ "github.com/spyroot/jettison/providers/vmware"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"path"
 	"syscall"
+	"time"
 )
 
 // unpack all ansible file to target dir
@@ -89,7 +94,7 @@ func SetupAnsible(homeDir string) error {
 // database and all dependency
 func initJettison() (*internal.Vim, *internal.Deployment2, error) {
 
-	jetConfig, err := internal.ReadConfig()
+	jetConfig, err := readConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -114,7 +119,7 @@ func initJettison() (*internal.Vim, *internal.Deployment2, error) {
 	}
 
 	// init a vim
-	vim, err := internal.NewVim()
+	vim, err := newVim(rootCtx)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -122,30 +127,176 @@ func initJettison() (*internal.Vim, *internal.Deployment2, error) {
 	return vim, scenario, nil
 }
 
-// Delete deployment
-// TODO unfinished
+// Delete deployment and all objects it created in vim, nsx-t,
+// ansible inventory and database.
 func DeleteDeployment() *cobra.Command {
 
+	var (
+		keepNetwork bool
+		assumeYes   bool
+	)
+
 	cmd := &cobra.Command{
-		Use: "kill",
+		Use:   "kill <project>",
+		Short: "tear down a deployment",
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			if len(args) == 0 {
 				return fmt.Errorf("kill needs a project name")
 			}
 
-			jetConfig, err := internal.ReadConfig()
+			vim, err := newVim(rootCtx)
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
+			defer vim.Close()
+
+			deployer = internal.NewDeployer(nil, vim)
 
-			if jetConfig.GetDeploymentName() == args[0] {
-				log.Printf("Found exiting project")
+			return deployer.Teardown(args[0], keepNetwork, assumeYes)
+		},
+	}
+
+	cmd.Flags().BoolVar(&keepNetwork, "keep-network", false, "keep nsx-t switches, routers and dhcp servers")
+	cmd.Flags().BoolVar(&assumeYes, "yes", false, "don't prompt for confirmation")
+
+	return cmd
+}
+
+// Delete objects recorded in a journal of failed deployment.
+func Rollback() *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "rollback <project>",
+		Short: "delete objects created by a failed deployment",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if len(args) == 0 {
+				return fmt.Errorf("rollback needs a project name")
 			}
 
-			return nil
+			vim, err := newVim(rootCtx)
+			if err != nil {
+				return err
+			}
+			defer vim.Close()
+
+			deployer = internal.NewDeployer(nil, vim)
+
+			return deployer.ReplayJournal(args[0])
 		},
 	}
+
+	return cmd
+}
+
+// List all deployments stored in database
+func List() *cobra.Command {
+
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list all deployments",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			db, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			deployments, err := dbutil.GetDeployments(db)
+			if err != nil {
+				return err
+			}
+
+			return internal.WriteDeployments(stdout, output, deployments)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "output format table, json or yaml")
+
+	return cmd
+}
+
+// Computes a deployment without touching vim or nsx-t
+func Plan() *cobra.Command {
+
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "show what deploy would create",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			jetConfig, err := readConfig()
+			if err != nil {
+				return err
+			}
+
+			var templateList []*jettypes.NodeTemplate
+			for k, v := range jetConfig.Infra.Scenario {
+				v.Type = jettypes.GetNodeType(k)
+				templateList = append(templateList, v)
+			}
+
+			scenario, err := internal.CreateScenario(templateList, jetConfig.GetDeploymentName())
+			if err != nil {
+				return err
+			}
+
+			vim, err := internal.NewOfflineVim()
+			if err != nil {
+				return err
+			}
+			defer vim.Close()
+
+			plan, err := internal.NewDeployer(scenario, vim).Plan()
+			if err != nil {
+				return err
+			}
+
+			return internal.WritePlan(stdout, output, plan)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "output format table, json or yaml")
+
+	return cmd
+}
+
+// Shows each node in a deployment as it stored in database
+func Status() *cobra.Command {
+
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "status <project>",
+		Short: "show nodes of a deployment",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if len(args) == 0 {
+				return fmt.Errorf("status needs a project name")
+			}
+
+			db, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			status, err := internal.DeploymentStatus(db, args[0])
+			if err != nil {
+				return err
+			}
+
+			return internal.WriteNodeStatus(stdout, output, status)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "output format table, json or yaml")
+
 	return cmd
 }
 
@@ -159,7 +310,7 @@ func RegeneratePlaybook() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			defer vim.Database().Close()
+			defer vim.Close()
 
 			deployer := internal.NewDeployer(scenario, vim)
 
@@ -182,7 +333,7 @@ func RegenerateInventory() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			defer vim.Database().Close()
+			defer vim.Close()
 
 			deployer := internal.NewDeployer(scenario, vim)
 
@@ -197,10 +348,27 @@ func RegenerateInventory() *cobra.Command {
 
 var deployer *internal.Deployer
 
+// cancelled on interrupt, all vim calls made with this context
+var rootCtx, cancelRoot = context.WithCancel(context.Background())
+
+// configuration, vim, database and output commands use, tests replace
+// them to run commands against a fake vim and a database in temp dir
+var (
+	readConfig             = internal.ReadConfig
+	newVim                 = internal.NewVim
+	openDatabase           = dbutil.CreateDatabase
+	stdout       io.Writer = os.Stdout
+)
+
 // Main root deploy command
 // It passed vim to deployer that will start deployment routine
 func Deploy() *cobra.Command {
 
+	var (
+		resume bool
+		output string
+	)
+
 	cmd := &cobra.Command{
 		Use: "deploy",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -209,20 +377,251 @@ func Deploy() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			defer vim.Database().Close()
+			defer vim.Close()
+
+			deployer = internal.NewDeployer(scenario, vim)
+
+			err = deployer.Deploy(resume)
+
+			// result of each vm operation reported even if deployment failed
+			if werr := internal.WriteNodeResults(stdout, output, vim.Results()); werr != nil {
+				log.Println(werr)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&resume, "resume", false, "continue failed deployment from first step that didn't complete")
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "node results format table, json or yaml")
+
+	return cmd
+}
+
+// Scales existing deployment
+func Scale() *cobra.Command {
+
+	var (
+		workers int
+		remove  string
+		output  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scale <project>",
+		Short: "add or remove worker nodes of existing deployment",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if len(args) == 0 {
+				return fmt.Errorf("scale needs a project name")
+			}
+
+			if workers < 1 && len(remove) == 0 {
+				return fmt.Errorf("scale needs --workers or --remove")
+			}
+
+			if workers > 0 && len(remove) > 0 {
+				return fmt.Errorf("--workers and --remove are mutually exclusive")
+			}
+
+			vim, scenario, err := initJettison()
+			if err != nil {
+				return err
+			}
+			defer vim.Close()
+
+			deployer = internal.NewDeployer(scenario, vim)
+
+			if len(remove) > 0 {
+				return deployer.RemoveWorker(args[0], remove)
+			}
+
+			err = deployer.ScaleWorkers(args[0], workers)
+			if werr := internal.WriteNodeResults(stdout, output, vim.Results()); werr != nil {
+				log.Println(werr)
+			}
+
+			return err
+		},
+	}
+
+	cmd.Flags().IntVar(&workers, "workers", 0, "number of worker nodes to add")
+	cmd.Flags().StringVar(&remove, "remove", "", "name of worker node to remove")
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "node results format table, json or yaml")
+
+	return cmd
+}
+
+// Converges a deployment to configuration
+func Apply() *cobra.Command {
+
+	var (
+		dryRun bool
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "create, replace or delete nodes so deployment matches configuration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			vim, scenario, err := initJettison()
+			if err != nil {
+				return err
+			}
+			defer vim.Close()
 
 			deployer = internal.NewDeployer(scenario, vim)
 
-			err = deployer.Deploy()
+			actions, err := deployer.Apply(scenario.DeploymentName, dryRun)
+			if dryRun {
+				if err != nil {
+					return err
+				}
+				return internal.WriteApplyActions(stdout, output, actions)
+			}
+
+			if werr := internal.WriteNodeResults(stdout, output, vim.Results()); werr != nil {
+				log.Println(werr)
+			}
 
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print actions without executing them")
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "output format table, json or yaml")
+
+	return cmd
+}
+
+// Compares a deployment stored in database with vim, exits non-zero on drift
+func Drift() *cobra.Command {
+
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "drift <project>",
+		Short: "show differences between database and vCenter and NSX-T",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if len(args) == 0 {
+				return fmt.Errorf("drift needs a project name")
+			}
+
+			vim, scenario, err := initJettison()
+			if err != nil {
+				return err
+			}
+			defer vim.Close()
+
+			drift, err := internal.NewDeployer(scenario, vim).DetectDrift(args[0])
+			if err != nil {
+				return err
+			}
+
+			err = internal.WriteDrift(stdout, output, drift)
 			if err != nil {
 				return err
 			}
 
+			if len(drift) > 0 {
+				return fmt.Errorf("project %s drifted, %d differences found", args[0], len(drift))
+			}
+
 			return nil
 		},
 	}
 
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "output format table, json or yaml")
+
+	return cmd
+}
+
+// Deletes network objects of projects that are not in database
+func Gc() *cobra.Command {
+
+	var (
+		dryRun    bool
+		olderThan time.Duration
+		output    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "delete nsx-t objects left by failed runs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			vim, scenario, err := initJettison()
+			if err != nil {
+				return err
+			}
+			defer vim.Close()
+
+			collected, err := internal.NewDeployer(scenario, vim).CollectGarbage(olderThan, dryRun)
+			if werr := internal.WriteGcObjects(stdout, output, collected); werr != nil {
+				log.Println(werr)
+			}
+
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list orphan objects without deleting them")
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "collect only objects older than duration, for example 24h")
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "output format table, json or yaml")
+
+	return cmd
+}
+
+// Adopts vms built outside of jettison into a project
+func Adopt() *cobra.Command {
+
+	var (
+		folder string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "adopt <project>",
+		Short: "import existing vms from a vCenter folder into a project",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			if len(args) == 0 {
+				return fmt.Errorf("adopt needs a project name")
+			}
+
+			if len(folder) == 0 {
+				return fmt.Errorf("adopt needs --folder")
+			}
+
+			vim, scenario, err := initJettison()
+			if err != nil {
+				return err
+			}
+			defer vim.Close()
+
+			err = internal.NewDeployer(scenario, vim).Adopt(args[0], folder)
+			if err != nil {
+				return err
+			}
+
+			status, err := internal.DeploymentStatus(vim.Database(), args[0])
+			if err != nil {
+				return err
+			}
+
+			return internal.WriteNodeStatus(stdout, output, status)
+		},
+	}
+
+	cmd.Flags().StringVar(&folder, "folder", "", "vCenter folder that holds vms")
+	cmd.Flags().StringVarP(&output, "output", "o", internal.OutputTable, "output format table, json or yaml")
+
 	return cmd
 }
 
@@ -238,7 +637,7 @@ func Ansible() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			defer vim.Database().Close()
+			defer vim.Close()
 
 			deployer := internal.NewDeployer(scenario, vim)
 
@@ -267,7 +666,7 @@ func Build() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			defer vim.Database().Close()
+			defer vim.Close()
 
 			deployer := internal.NewDeployer(scenario, vim)
 
@@ -300,11 +699,13 @@ func Build() *cobra.Command {
 
 func signalHandler(deployer *internal.Deployer) {
 	log.Println("Cleaning environment")
-	deployer.Stop()
+	if deployer != nil {
+		deployer.Stop()
+	}
 }
 
-// main entry to jettison
-func main() {
+// root command with all jettison commands
+func newRootCommand() *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:          "jettison",
@@ -312,20 +713,40 @@ func main() {
 		SilenceUsage: true,
 	}
 
-	c := make(chan os.Signal)
+	cmd.AddCommand(DeleteDeployment())
+	cmd.AddCommand(Rollback())
+	cmd.AddCommand(Build())
+	cmd.AddCommand(Deploy())
+	cmd.AddCommand(Ansible())
+	cmd.AddCommand(List())
+	cmd.AddCommand(Status())
+	cmd.AddCommand(Plan())
+	cmd.AddCommand(Scale())
+	cmd.AddCommand(Apply())
+	cmd.AddCommand(Drift())
+	cmd.AddCommand(Gc())
+	cmd.AddCommand(Adopt())
+
+	return cmd
+}
+
+// main entry to jettison
+func main() {
+
+	// first signal cancels running vim tasks and lets a command record
+	// its state, second signal exits right away.
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
+		<-c
+		log.Println("Interrupted, waiting for running tasks. Press Ctrl-C again to exit")
+		cancelRoot()
 		<-c
 		signalHandler(deployer)
 		os.Exit(1)
 	}()
 
-	cmd.AddCommand(DeleteDeployment())
-	cmd.AddCommand(Build())
-	cmd.AddCommand(Deploy())
-	cmd.AddCommand(Ansible())
-
-	if err := cmd.Execute(); err != nil {
+	if err := newRootCommand().Execute(); err != nil {
 		os.Exit(1)
 	}
 }
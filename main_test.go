@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spyroot/jettison/dbutil"
+	"github.com/spyroot/jettison/internal"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/fake"
+)
+
+const (
+	testProject = "test"
+	testFolder  = "/dc/vm/manual"
+)
+
+/**
+  Replaces configuration, vim and database commands use with a fake vim that
+  every command shares and a database in temp dir. Output of commands written
+  to returned buffer, returned func restores real ones.
+*/
+func setupCommands(t *testing.T) (*fake.FakeVim, *bytes.Buffer, func()) {
+
+	dir, err := ioutil.TempDir("", "jettison")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(dir, "jettison.db")
+	db, err := dbutil.Connect(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dbutil.CreateTablesIfNeed(db); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	plugin := fake.New()
+	out := &bytes.Buffer{}
+
+	savedConfig, savedVim, savedDatabase, savedStdout := readConfig, newVim, openDatabase, stdout
+
+	// each command reads own copy of configuration, same as from config.yml
+	readConfig = func() (internal.AppConfig, error) {
+		var jetConfig internal.AppConfig
+		jetConfig.Infra.DeploymentName = testProject
+		jetConfig.Infra.ParallelJobs = jettypes.DefaultParallelJobs
+		jetConfig.Infra.AnsibleDefaults.AnsibleConfig = dir
+		jetConfig.Infra.AnsibleDefaults.AnsibleInventory = dir
+		jetConfig.Infra.SshDefaults.SshPrivateKey = filepath.Join(dir, "id_rsa")
+		jetConfig.Infra.Scenario = map[string]*jettypes.NodeTemplate{
+			"controller": {Prefix: "manual", VmTemplateName: "ubuntu", VimCluster: "cluster", DesiredCount: 1},
+			"worker":     {Prefix: "manual", VmTemplateName: "ubuntu", VimCluster: "cluster", DesiredCount: 2},
+		}
+		return jetConfig, nil
+	}
+	newVim = func(ctx context.Context) (*internal.Vim, error) {
+		jetConfig, _ := readConfig()
+		db, err := dbutil.Connect(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		return internal.NewVimWithPlugin(ctx, &jetConfig, db, plugin)
+	}
+	openDatabase = func() (*sql.DB, error) {
+		return dbutil.Connect(dbPath)
+	}
+	stdout = out
+
+	return plugin, out, func() {
+		readConfig, newVim, openDatabase, stdout = savedConfig, savedVim, savedDatabase, savedStdout
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// Runs a jettison command line, output of a previous command discarded.
+func execute(out *bytes.Buffer, args ...string) error {
+	out.Reset()
+	cmd := newRootCommand()
+	cmd.SetArgs(args)
+	cmd.SetOutput(ioutil.Discard)
+	return cmd.Execute()
+}
+
+/**
+  Adopts vms built by hand and drives a project through status, drift,
+  apply and kill commands. Each command opens own vim and database.
+*/
+func TestCommands(t *testing.T) {
+
+	p, out, teardown := setupCommands(t)
+	defer teardown()
+
+	ctx := context.Background()
+	switchUuid := p.AddSegment("manual", true, true)
+	vms := []jettypes.VmInfo{
+		{Name: "manual-1", Tags: []string{"controller"}, IPv4Addr: "172.16.81.10"},
+		{Name: "manual-2", Tags: []string{"worker"}, IPv4Addr: "172.16.81.11"},
+		{Name: "manual-3", Tags: []string{"worker"}, IPv4Addr: "172.16.81.12"},
+	}
+	for _, vm := range vms {
+		vm.Cluster = "cluster"
+		vm.PoweredOn = true
+		vm.SwitchUuids = []string{switchUuid}
+		if err := p.AddVm(testFolder, vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := execute(out, "adopt", testProject, "--folder", testFolder, "-o", "json"); err != nil {
+		t.Fatalf("adopt error = %v", err)
+	}
+
+	// status reads nodes adopt stored
+	if err := execute(out, "status", testProject, "-o", "json"); err != nil {
+		t.Fatalf("status error = %v", err)
+	}
+	var status []internal.NodeStatus
+	if err := json.Unmarshal(out.Bytes(), &status); err != nil {
+		t.Fatalf("status output %q error = %v", out.String(), err)
+	}
+	if len(status) != len(vms) {
+		t.Fatalf("status reported %d nodes, want %d", len(status), len(vms))
+	}
+	for i, s := range status {
+		if s.Name != vms[i].Name || s.IPv4Addr != vms[i].IPv4Addr || s.SwitchUuid != switchUuid {
+			t.Errorf("status node = %s %s %s, want %s %s %s",
+				s.Name, s.IPv4Addr, s.SwitchUuid, vms[i].Name, vms[i].IPv4Addr, switchUuid)
+		}
+	}
+
+	if err := execute(out, "drift", testProject); err != nil {
+		t.Fatalf("drift of adopted project error = %v", err)
+	}
+
+	// apply powers on a worker powered off outside of jettison
+	worker := &jettypes.NodeTemplate{Name: "manual-2"}
+	if _, err := p.ChangePowerState(ctx, worker, jettypes.PowerOff); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := execute(out, "apply", "--dry-run", "-o", "json"); err != nil {
+		t.Fatalf("apply --dry-run error = %v", err)
+	}
+	var actions []internal.ApplyAction
+	if err := json.Unmarshal(out.Bytes(), &actions); err != nil {
+		t.Fatalf("apply output %q error = %v", out.String(), err)
+	}
+	if len(actions) != 1 || actions[0].Action != internal.ApplyPowerOn || actions[0].Node != worker.Name {
+		t.Fatalf("apply --dry-run actions = %v, want %s %s", actions, internal.ApplyPowerOn, worker.Name)
+	}
+
+	if err := execute(out, "apply"); err != nil {
+		t.Fatalf("apply error = %v", err)
+	}
+	info, err := p.DescribeVm(ctx, worker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.PoweredOn {
+		t.Errorf("apply didn't power on %s", worker.Name)
+	}
+
+	// vm deleted outside of jettison reported, drift exits non-zero
+	if err := p.DeleteVm(ctx, testProject, &jettypes.NodeTemplate{Name: "manual-3"}); err != nil {
+		t.Fatal(err)
+	}
+	err = execute(out, "drift", testProject, "-o", "json")
+	if err == nil || !strings.Contains(err.Error(), "drifted") {
+		t.Fatalf("drift of deleted vm error = %v, want drifted", err)
+	}
+	var drift []internal.Drift
+	if err := json.Unmarshal(out.Bytes(), &drift); err != nil {
+		t.Fatalf("drift output %q error = %v", out.String(), err)
+	}
+	if len(drift) != 1 || drift[0].Kind != internal.DriftVmDeleted || drift[0].Node != "manual-3" {
+		t.Errorf("drift = %v, want %s manual-3", drift, internal.DriftVmDeleted)
+	}
+
+	if err := execute(out, "kill", testProject, "--yes"); err != nil {
+		t.Fatalf("kill error = %v", err)
+	}
+	for _, vm := range vms {
+		info, err := p.DescribeVm(ctx, &jettypes.NodeTemplate{Name: vm.Name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Exists {
+			t.Errorf("kill left vm %s", vm.Name)
+		}
+	}
+	if _, _, err := p.DescribeSegment(ctx, switchUuid); err != nil {
+		t.Errorf("kill deleted segment built by hand %v", err)
+	}
+
+	if err := execute(out, "status", testProject); err == nil {
+		t.Errorf("status of killed project expected error")
+	}
+}
@@ -76,16 +76,18 @@ func (p *SimpleSubnetPool) AllocateSubnet() (net.IP, error) {
 
 		if p.subnet.Contains(t.IP) {
 			p.current = t.IP
-			// add to inuse list
-			p.subnets = append(p.subnets, subnetPool{p.current, true})
-			return p.current, nil
+			if !p.IsInUse(p.current) {
+				// add to inuse list
+				p.subnets = append(p.subnets, subnetPool{p.current, true})
+				return p.current, nil
+			}
 		}
 	}
 
 	addr := p.current
 	for index := 0; p.subnet.Contains(addr) != false; index++ {
 		next := NextIP(addr, offset)
-		if p.subnet.Contains(next) {
+		if p.subnet.Contains(next) && !p.IsInUse(next) {
 			p.current = next
 			// add to in use list
 			p.subnets = append(p.subnets, subnetPool{next, true})
@@ -97,6 +99,44 @@ func (p *SimpleSubnetPool) AllocateSubnet() (net.IP, error) {
 	return nil, fmt.Errorf("no more block left in the CIDR")
 }
 
+/**
+  Marks a block as already allocated, so AllocateSubnet will skip it.
+  Accepts a block address or a block in cidr notation.
+*/
+func (p *SimpleSubnetPool) SetInUse(block string) error {
+
+	addr := net.ParseIP(block)
+	if addr == nil {
+		var err error
+		addr, _, err = net.ParseCIDR(block)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !p.subnet.Contains(addr) {
+		return fmt.Errorf("block %s is not part of %s", block, p.cidr)
+	}
+
+	if !p.IsInUse(addr) {
+		p.subnets = append(p.subnets, subnetPool{addr, true})
+	}
+
+	return nil
+}
+
+/**
+
+ */
+func (p *SimpleSubnetPool) IsInUse(addr net.IP) bool {
+	for _, v := range p.subnets {
+		if v.inuse && v.IpAddr.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // TODO Fix me
 func (p *SimpleSubnetPool) generateSubnets(size uint) {
 
@@ -21,6 +21,7 @@ mbaraymov@vmware.com
 package nsxtapi
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/spyroot/jettison/logging"
@@ -41,6 +42,24 @@ func IsUuid(s string) bool {
 	return false
 }
 
+/**
+  Returns a copy of a client that issue requests with a given context, so
+  calls made through a copy cancelled together with a context. Credentials
+  stored in a client context carried over.
+*/
+func WithContext(ctx context.Context, nsxClient *nsxt.APIClient) *nsxt.APIClient {
+
+	c := *nsxClient
+	c.Context = ctx
+	if nsxClient.Context != nil {
+		if auth := nsxClient.Context.Value(nsxt.ContextBasicAuth); auth != nil {
+			c.Context = context.WithValue(ctx, nsxt.ContextBasicAuth, auth)
+		}
+	}
+
+	return &c
+}
+
 // Open NSX connection and return nsxtapi.APIClient context.
 func Connect(managerHost string, user string, password string) (nsxt.APIClient, error) {
 
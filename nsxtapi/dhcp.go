@@ -155,7 +155,7 @@ func GetStaticBinding(nsxClient *nsxt.APIClient,
 		}
 	}
 
-	return nil, fmt.Errorf("failed recieve dhcp static binding for mac %s", SearchVal)
+	return nil, &ObjectNotFound{"dhcp static binding " + SearchVal}
 }
 
 /*
@@ -288,7 +288,7 @@ func CreateStaticBinding(nsxClient *nsxt.APIClient,
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 	}
@@ -466,6 +466,10 @@ func CreateDhcpProfile(nsxClient *nsxt.APIClient,
 func CreateDhcpServer(nsxClient *nsxt.APIClient,
 	req *DhcpServerCreateReq, tags []common.Tag) (*manager.LogicalDhcpServer, error) {
 
+	if nsxClient == nil || req == nil {
+		return nil, fmt.Errorf("nsxt client or dhcp server request is nil")
+	}
+
 	server := manager.IPv4DhcpServer{
 		DhcpServerIp:   req.DhcpServerIp,
 		DnsNameservers: req.DnsNameservers,
@@ -86,7 +86,7 @@ func MakeDhcpTags(tenantName string) []common.Tag {
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantName,
 		},
 	}
@@ -97,7 +97,7 @@ func MakeSwitchTags(tenantName string, segmentName string) []common.Tag {
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantName,
 		},
 		{
@@ -111,7 +111,7 @@ func MakeSwitchTags(tenantName string, segmentName string) []common.Tag {
 func MakeDhcpProfileTag(tenantName, switchId string) []common.Tag {
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantName,
 		},
 		{
@@ -132,7 +132,7 @@ func CreateSwitchIfNeed(nsxtClient *nsxt.APIClient, tenantId, tenantSegment, ove
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 		{
@@ -181,7 +181,7 @@ func CreateRouterIfNeed(nsxtClient *nsxt.APIClient, tenantId, tenantSegment, rou
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 		{
@@ -225,7 +225,7 @@ func CreateLogicalPortIfNeed(nsxtClient *nsxt.APIClient, tenantId, switchId, rou
 
 	var tagScope = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 		{
@@ -279,7 +279,7 @@ func CreateRoutedPortIfNeed(nsxtClient *nsxt.APIClient,
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 		{
@@ -323,7 +323,7 @@ func ConnectTier1IfNeed(nsxtClient *nsxt.APIClient, tenantId, tierOneId, tierZer
 
 	var tier1Tag = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 		{
@@ -334,7 +334,7 @@ func ConnectTier1IfNeed(nsxtClient *nsxt.APIClient, tenantId, tierOneId, tierZer
 
 	var tierZeroTag = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 		{
@@ -356,7 +356,7 @@ func CreateDhcpPortIfNeed(nsxtClient *nsxt.APIClient, tenantId, switchId string)
 
 	var tagScope = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   tenantId,
 		},
 		{
@@ -393,7 +393,7 @@ func CreateDhcpProfileIfNeed(nsxtClient *nsxt.APIClient, req DhcpProfile) (strin
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   req.TenantUuid(),
 		},
 		{
@@ -425,7 +425,7 @@ func CreateDhcpServerIfNeed(nsxtClient *nsxt.APIClient, req *DhcpServerCreateReq
 
 	var newTags = []common.Tag{
 		{
-			Scope: "jettison-tenant",
+			Scope: TenantScope,
 			Tag:   req.TenantId,
 		},
 		{
@@ -0,0 +1,152 @@
+package nsxtsim
+
+import (
+	"net"
+	"net/http"
+)
+
+/*
+  Dhcp server profiles. Profile placed on existing edge cluster and can't be
+  deleted while a dhcp server uses it.
+*/
+func (s *Server) handleProfiles(r *http.Request, path []string, body object) (int, interface{}, *apiError) {
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, s.profiles, nil)
+		case http.MethodPost:
+			if _, ok := s.clusters[body.str("edge_cluster_id")]; !ok {
+				return 0, nil, errorf(http.StatusBadRequest,
+					"edge cluster %s not found", body.str("edge_cluster_id"))
+			}
+			return http.StatusCreated, s.create(s.profiles, body, "DhcpProfile"), nil
+		}
+	}
+
+	if len(path) != 1 {
+		return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+	}
+
+	return s.item(r, s.profiles, path[0], body, func(o object) *apiError {
+		for _, server := range s.servers {
+			if server.str("dhcp_profile_id") == o.str("id") {
+				return errorf(http.StatusBadRequest,
+					"dhcp profile %s used by dhcp server %s", o.str("id"), server.str("id"))
+			}
+		}
+		return nil
+	})
+}
+
+/*
+  Dhcp servers and static bindings of a server. Server needs an existing
+  profile, server created with a logical port attaches the port.
+*/
+func (s *Server) handleServers(r *http.Request, path []string, body object) (int, interface{}, *apiError) {
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, s.servers, nil)
+		case http.MethodPost:
+			if err := s.validateServer(body); err != nil {
+				return 0, nil, err
+			}
+			server := s.create(s.servers, body, "LogicalDhcpServer")
+			s.bindings[server.str("id")] = make(map[string]object)
+			if port, ok := s.ports[server.str("attached_logical_port_id")]; ok {
+				port["attachment"] = map[string]interface{}{
+					"attachment_type": "DHCP_SERVICE",
+					"id":              server.str("id"),
+				}
+			}
+			return http.StatusCreated, server, nil
+		}
+	}
+
+	if len(path) == 1 {
+		if r.Method == http.MethodPut {
+			if err := s.validateServer(body); err != nil {
+				return 0, nil, err
+			}
+		}
+		return s.item(r, s.servers, path[0], body, func(o object) *apiError {
+			if port, ok := s.ports[o.str("attached_logical_port_id")]; ok {
+				delete(port, "attachment")
+			}
+			delete(s.bindings, o.str("id"))
+			return nil
+		})
+	}
+
+	server, ok := s.servers[path[0]]
+	if !ok {
+		return 0, nil, errorf(http.StatusNotFound, "dhcp server %s not found", path[0])
+	}
+	if path[1] == "static-bindings" {
+		return s.handleBindings(r, server, path[2:], body)
+	}
+
+	return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+}
+
+func (s *Server) validateServer(server object) *apiError {
+
+	if _, ok := s.profiles[server.str("dhcp_profile_id")]; !ok {
+		return errorf(http.StatusBadRequest, "dhcp profile %s not found", server.str("dhcp_profile_id"))
+	}
+	config, _ := server["ipv4_dhcp_server"].(map[string]interface{})
+	address, _ := config["dhcp_server_ip"].(string)
+	if _, _, err := net.ParseCIDR(address); err != nil {
+		return errorf(http.StatusBadRequest, "invalid dhcp server ip %s", address)
+	}
+	if id := server.str("attached_logical_port_id"); len(id) > 0 {
+		if _, ok := s.ports[id]; !ok {
+			return errorf(http.StatusBadRequest, "logical port %s not found", id)
+		}
+	}
+
+	return nil
+}
+
+/*
+  Static bindings of a dhcp server, mac and ip address of a binding must be
+  unique per server.
+*/
+func (s *Server) handleBindings(r *http.Request, server object, path []string, body object) (int, interface{}, *apiError) {
+
+	bindings := s.bindings[server.str("id")]
+	if bindings == nil {
+		bindings = make(map[string]object)
+		s.bindings[server.str("id")] = bindings
+	}
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, bindings, nil)
+		case http.MethodPost:
+			if _, err := net.ParseMAC(body.str("mac_address")); err != nil {
+				return 0, nil, errorf(http.StatusBadRequest, "invalid mac address %s", body.str("mac_address"))
+			}
+			if net.ParseIP(body.str("ip_address")) == nil {
+				return 0, nil, errorf(http.StatusBadRequest, "invalid ip address %s", body.str("ip_address"))
+			}
+			for _, b := range bindings {
+				if b.str("mac_address") == body.str("mac_address") || b.str("ip_address") == body.str("ip_address") {
+					return 0, nil, errorf(http.StatusBadRequest,
+						"static binding %s conflicts with binding %s %s", body.str("ip_address"),
+						b.str("mac_address"), b.str("ip_address"))
+				}
+			}
+			return http.StatusCreated, s.create(bindings, body, "DhcpStaticBinding"), nil
+		}
+	}
+
+	if len(path) != 1 {
+		return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+	}
+
+	return s.item(r, bindings, path[0], body, nil)
+}
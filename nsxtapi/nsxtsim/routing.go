@@ -0,0 +1,224 @@
+package nsxtsim
+
+import (
+	"net"
+	"net/http"
+)
+
+// router port types and router type each port type may be created on
+var routerPortTypes = map[string]string{
+	"LogicalRouterDownLinkPort":    "",
+	"LogicalRouterLinkPortOnTIER0": "TIER0",
+	"LogicalRouterLinkPortOnTIER1": "TIER1",
+}
+
+/*
+  Logical routers with static routes and advertisement config. Router with
+  ports deleted only with force, force deletes ports and routes as well.
+*/
+func (s *Server) handleRouters(r *http.Request, path []string, body object) (int, interface{}, *apiError) {
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, s.routers, func(o object) bool {
+				return match(r, o, "router_type", "router_type")
+			})
+		case http.MethodPost:
+			routerType := body.str("router_type")
+			if routerType != "TIER0" && routerType != "TIER1" {
+				return 0, nil, errorf(http.StatusBadRequest, "invalid router type %s", routerType)
+			}
+			clusterId := body.str("edge_cluster_id")
+			if routerType == "TIER0" && len(clusterId) == 0 {
+				return 0, nil, errorf(http.StatusBadRequest, "tier zero router requires edge cluster")
+			}
+			if _, ok := s.clusters[clusterId]; len(clusterId) > 0 && !ok {
+				return 0, nil, errorf(http.StatusBadRequest, "edge cluster %s not found", clusterId)
+			}
+			router := s.create(s.routers, body, "LogicalRouter")
+			s.routes[router.str("id")] = make(map[string]object)
+			return http.StatusCreated, router, nil
+		}
+	}
+
+	if len(path) == 1 {
+		return s.item(r, s.routers, path[0], body, func(o object) *apiError {
+			var ports []string
+			for id, p := range s.routerPorts {
+				if p.str("logical_router_id") == o.str("id") {
+					ports = append(ports, id)
+				}
+			}
+			if len(ports) > 0 && !flag(r, "force") {
+				return errorf(http.StatusBadRequest,
+					"logical router %s has %d ports, delete ports first", o.str("id"), len(ports))
+			}
+			for _, id := range ports {
+				delete(s.routerPorts, id)
+			}
+			delete(s.routes, o.str("id"))
+			delete(s.adverts, o.str("id"))
+			return nil
+		})
+	}
+
+	router, ok := s.routers[path[0]]
+	if !ok {
+		return 0, nil, errorf(http.StatusNotFound, "logical router %s not found", path[0])
+	}
+	if len(path) >= 3 && path[1] == "routing" && path[2] == "static-routes" {
+		return s.handleStaticRoutes(r, router, path[3:], body)
+	}
+	if len(path) == 3 && path[1] == "routing" && path[2] == "advertisement" {
+		return s.handleAdvertisement(r, router, body)
+	}
+
+	return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+}
+
+/*
+  Static routes of a router. Route needs a network in cidr format and next
+  hops with valid addresses. Manager answers add static route with 200.
+*/
+func (s *Server) handleStaticRoutes(r *http.Request, router object, path []string, body object) (int, interface{}, *apiError) {
+
+	routes := s.routes[router.str("id")]
+	if routes == nil {
+		routes = make(map[string]object)
+		s.routes[router.str("id")] = routes
+	}
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, routes, nil)
+		case http.MethodPost:
+			if _, _, err := net.ParseCIDR(body.str("network")); err != nil {
+				return 0, nil, errorf(http.StatusBadRequest, "invalid network %s", body.str("network"))
+			}
+			hops, _ := body["next_hops"].([]interface{})
+			if len(hops) == 0 {
+				return 0, nil, errorf(http.StatusBadRequest, "static route without next hop")
+			}
+			for _, h := range hops {
+				hop, _ := h.(map[string]interface{})
+				address, _ := hop["ip_address"].(string)
+				if net.ParseIP(address) == nil {
+					return 0, nil, errorf(http.StatusBadRequest, "invalid next hop %s", address)
+				}
+			}
+			body["logical_router_id"] = router.str("id")
+			return http.StatusOK, s.create(routes, body, "StaticRoute"), nil
+		}
+	}
+
+	if len(path) != 1 {
+		return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+	}
+
+	return s.item(r, routes, path[0], body, nil)
+}
+
+// Advertisement config of a router, router starts with advertisement disabled.
+func (s *Server) handleAdvertisement(r *http.Request, router object, body object) (int, interface{}, *apiError) {
+
+	id := router.str("id")
+	if _, ok := s.adverts[id]; !ok {
+		s.create(s.adverts, object{
+			"id":      id,
+			"enabled": false,
+		}, "AdvertisementConfig")
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return http.StatusOK, s.adverts[id], nil
+	case http.MethodPut:
+		config, err := s.update(s.adverts, id, body)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, config, nil
+	}
+
+	return 0, nil, errorf(http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+}
+
+/*
+  Router ports. Downlink port attaches a router to a logical port, link
+  ports connect tier one to tier zero and must match router type.
+*/
+func (s *Server) handleRouterPorts(r *http.Request, path []string, body object) (int, interface{}, *apiError) {
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, s.routerPorts, func(o object) bool {
+				if !match(r, o, "logical_router_id", "logical_router_id") ||
+					!match(r, o, "resource_type", "resource_type") {
+					return false
+				}
+				// switch of a router port is a switch of a linked logical port
+				if v, ok := r.URL.Query()["logical_switch_id"]; ok {
+					port, ok := s.ports[o.ref("linked_logical_switch_port_id")]
+					return ok && port.str("logical_switch_id") == v[0]
+				}
+				return true
+			})
+		case http.MethodPost:
+			if err := s.validateRouterPort(body); err != nil {
+				return 0, nil, err
+			}
+			port := s.create(s.routerPorts, body, "")
+			if p, ok := s.ports[port.ref("linked_logical_switch_port_id")]; ok {
+				p["attachment"] = map[string]interface{}{
+					"attachment_type": "LOGICALROUTER",
+					"id":              port.str("id"),
+				}
+			}
+			return http.StatusCreated, port, nil
+		}
+	}
+
+	if len(path) != 1 {
+		return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+	}
+
+	if r.Method == http.MethodPut {
+		if err := s.validateRouterPort(body); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return s.item(r, s.routerPorts, path[0], body, func(o object) *apiError {
+		if p, ok := s.ports[o.ref("linked_logical_switch_port_id")]; ok {
+			delete(p, "attachment")
+		}
+		return nil
+	})
+}
+
+func (s *Server) validateRouterPort(port object) *apiError {
+
+	routerType, ok := routerPortTypes[port.str("resource_type")]
+	if !ok {
+		return errorf(http.StatusBadRequest, "unsupported router port type %s", port.str("resource_type"))
+	}
+	router, ok := s.routers[port.str("logical_router_id")]
+	if !ok {
+		return errorf(http.StatusBadRequest, "logical router %s not found", port.str("logical_router_id"))
+	}
+	if len(routerType) > 0 && router.str("router_type") != routerType {
+		return errorf(http.StatusBadRequest, "port type %s can't be created on %s router",
+			port.str("resource_type"), router.str("router_type"))
+	}
+	if port.str("resource_type") == "LogicalRouterDownLinkPort" {
+		if _, ok := s.ports[port.ref("linked_logical_switch_port_id")]; !ok {
+			return errorf(http.StatusBadRequest, "logical port %s not found",
+				port.ref("linked_logical_switch_port_id"))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package nsxtsim
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+  Search across all objects, a query is a list of field:value terms joined
+  with AND. Supported fields are resource_type, id, display_name, tags.scope
+  and tags.tag, for example resource_type:LogicalSwitch AND tags.tag:tenant01
+*/
+func (s *Server) search(r *http.Request) (int, interface{}, *apiError) {
+
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	if len(query) == 0 {
+		return 0, nil, errorf(http.StatusBadRequest, "search query is empty")
+	}
+
+	type term struct{ field, value string }
+	var terms []term
+	for _, t := range strings.Split(query, " AND ") {
+		kv := strings.SplitN(strings.TrimSpace(t), ":", 2)
+		if len(kv) != 2 || len(kv[1]) == 0 {
+			return 0, nil, errorf(http.StatusBadRequest, "invalid search term %s", t)
+		}
+		switch kv[0] {
+		case "resource_type", "id", "display_name", "tags.scope", "tags.tag":
+		default:
+			return 0, nil, errorf(http.StatusBadRequest, "unsupported search field %s", kv[0])
+		}
+		terms = append(terms, term{kv[0], kv[1]})
+	}
+
+	all := make(map[string]object)
+	collections := []map[string]object{
+		s.zones, s.clusters, s.switches, s.ports, s.routers, s.routerPorts, s.profiles, s.servers,
+	}
+	for _, routes := range s.routes {
+		collections = append(collections, routes)
+	}
+	for _, bindings := range s.bindings {
+		collections = append(collections, bindings)
+	}
+	for _, objects := range collections {
+		for id, o := range objects {
+			all[id] = o
+		}
+	}
+
+	return s.list(r, all, func(o object) bool {
+		for _, t := range terms {
+			switch t.field {
+			case "tags.scope":
+				if !o.hasTag(t.value, "") {
+					return false
+				}
+			case "tags.tag":
+				if !o.hasTag("", t.value) {
+					return false
+				}
+			default:
+				if o.str(t.field) != t.value {
+					return false
+				}
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,577 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+NSX-T manager stand-in. Server implements /api/v1 endpoints jettison uses:
+logical switches and ports, routers, router ports, static routes, dhcp
+profiles, servers and static bindings, transport zones, edge clusters and
+a tag search. State kept in memory, so nsxtapi and nsx-t half of vmware
+provider tested without a manager. Faults injected per method and path.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package nsxtsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmware/go-vmware-nsxt"
+
+	"github.com/spyroot/jettison/nsxtapi"
+)
+
+// credentials server accepts
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "VMware1!VMware1!"
+)
+
+// objects server seeded with, ids and names of a lab nsxtapi tests written against
+const (
+	OverlayZoneName = "overlay-trasport-zone"
+	OverlayZoneUuid = "1b3a2f36-bfd1-443e-a0f6-4de01abc963e"
+	EdgeClusterName = "edge-cluster"
+	EdgeClusterUuid = "133fe9a7-2e87-409a-b1b3-406ab5833986"
+	TierZeroName    = "primary-t0"
+	TierZeroUuid    = "ba95b780-3689-419b-8f20-c7179e05813f"
+	TierOneName     = "primary-t1"
+	TierOneUuid     = "e7f2c53b-4a4f-4f2c-9a8d-0c6c7b1d2f10"
+	// segment vms attached to and dhcp server of a segment
+	SegmentName    = "test-segment"
+	SegmentUuid    = "91c9a86e-20f2-410f-9561-55e5161c1842"
+	DhcpServerUuid = "86622577-a94a-42f9-880c-80aa98a6e0ef"
+	// shared dhcp server with a static binding tagged jettison: SuperCluster
+	SharedDhcpName = "shared-dhcp"
+	SharedDhcpUuid = "459bdabc-9452-465e-b132-d452e8e2a266"
+)
+
+// page size of list calls, same as nsx-t manager
+const DefaultPageSize = 1000
+
+const apiPrefix = "/api/v1"
+
+/*
+  A fault injected into a request. Empty method matches every method and
+  path matched as a prefix of a path under /api/v1. Fault with zero times
+  fires on each request, otherwise only given number of times.
+*/
+type Fault struct {
+	Method string
+	Path   string
+	Status int
+	Times  int
+}
+
+type object map[string]interface{}
+
+// Returns string attribute of an object, empty if not set.
+func (o object) str(key string) string {
+	if s, ok := o[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+// Returns target of a resource reference attribute.
+func (o object) ref(key string) string {
+	if r, ok := o[key].(map[string]interface{}); ok {
+		if s, ok := r["target_id"].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// Returns true if object has a tag with a given scope and value, empty matches any.
+func (o object) hasTag(scope string, tag string) bool {
+	tags, _ := o["tags"].([]interface{})
+	for _, t := range tags {
+		m, ok := t.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if (len(scope) == 0 || m["scope"] == scope) && (len(tag) == 0 || m["tag"] == tag) {
+			return true
+		}
+	}
+	return false
+}
+
+type apiError struct {
+	status  int
+	message string
+}
+
+func errorf(status int, format string, a ...interface{}) *apiError {
+	return &apiError{status, fmt.Sprintf(format, a...)}
+}
+
+type Server struct {
+	*httptest.Server
+
+	Username string
+	Password string
+
+	lock sync.Mutex
+
+	zones       map[string]object
+	clusters    map[string]object
+	switches    map[string]object
+	ports       map[string]object
+	routers     map[string]object
+	routerPorts map[string]object
+	profiles    map[string]object
+	servers     map[string]object
+	// routes and advertisement by router id, bindings by dhcp server id
+	routes   map[string]map[string]object
+	adverts  map[string]object
+	bindings map[string]map[string]object
+
+	faults   []*Fault
+	pageSize int
+	requests []string
+
+	// creation order of objects by id, lists follow it
+	order map[string]int64
+	seq   int64
+}
+
+/*
+  Starts a server seeded with a transport zone, edge cluster, tier zero and
+  tier one routers, a segment with a dhcp server and a shared dhcp server.
+  Caller must close a server.
+*/
+func NewServer() *Server {
+
+	s := &Server{
+		Username:    DefaultUsername,
+		Password:    DefaultPassword,
+		zones:       make(map[string]object),
+		clusters:    make(map[string]object),
+		switches:    make(map[string]object),
+		ports:       make(map[string]object),
+		routers:     make(map[string]object),
+		routerPorts: make(map[string]object),
+		profiles:    make(map[string]object),
+		servers:     make(map[string]object),
+		routes:      make(map[string]map[string]object),
+		adverts:     make(map[string]object),
+		bindings:    make(map[string]map[string]object),
+		pageSize:    DefaultPageSize,
+		order:       make(map[string]int64),
+	}
+	s.seed()
+	s.Server = httptest.NewTLSServer(s)
+
+	return s
+}
+
+// Returns host:port of a server, nsxtapi.Connect takes it as a manager host.
+func (s *Server) Host() string {
+	return s.Listener.Addr().String()
+}
+
+// Opens nsx-t connection to a server.
+func (s *Server) Connect() (nsxt.APIClient, error) {
+	return nsxtapi.Connect(s.Host(), s.Username, s.Password)
+}
+
+func (s *Server) InjectFault(fault Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f := fault
+	s.faults = append(s.faults, &f)
+}
+
+func (s *Server) ClearFaults() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.faults = nil
+}
+
+// Sets a number of objects list calls return per page, zero resets to default.
+func (s *Server) SetPageSize(size int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	s.pageSize = size
+}
+
+// Returns requests server received as "METHOD path" in order.
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	user, password, ok := r.BasicAuth()
+	if !ok || user != s.Username || password != s.Password {
+		writeError(w, errorf(http.StatusForbidden, "the credentials were incorrect or the account specified has been locked"))
+		return
+	}
+
+	// client opens a session before first call
+	if r.URL.Path == "/api/session/create" && r.Method == http.MethodPost {
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: uuid.New().String(), Path: "/"})
+		w.Header().Set("X-XSRF-TOKEN", uuid.New().String())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeError(w, errorf(http.StatusNotFound, "unknown path %s", r.URL.Path))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+
+	var body object
+	if r.Body != nil && (r.Method == http.MethodPost || r.Method == http.MethodPut) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, errorf(http.StatusBadRequest, "failed parse request body: %v", err))
+			return
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+path)
+	if f := s.fault(r.Method, path); f != nil {
+		writeError(w, errorf(f.Status, "injected fault %s %s", r.Method, path))
+		return
+	}
+
+	status, result, apiErr := s.dispatch(r, strings.Split(strings.Trim(path, "/"), "/"), body)
+	if apiErr != nil {
+		writeError(w, apiErr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if result != nil {
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
+
+// Returns a fault that matches a request and counts it down.
+func (s *Server) fault(method string, path string) *Fault {
+	for i, f := range s.faults {
+		if len(f.Method) != 0 && f.Method != method {
+			continue
+		}
+		if !strings.HasPrefix(path, f.Path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) dispatch(r *http.Request, path []string, body object) (int, interface{}, *apiError) {
+
+	switch path[0] {
+	case "transport-zones":
+		if len(path) == 1 && r.Method == http.MethodGet {
+			return s.list(r, s.zones, nil)
+		}
+	case "edge-clusters":
+		if len(path) == 1 && r.Method == http.MethodGet {
+			return s.list(r, s.clusters, nil)
+		}
+	case "logical-switches":
+		return s.handleSwitches(r, path[1:], body)
+	case "logical-ports":
+		return s.handlePorts(r, path[1:], body)
+	case "logical-routers":
+		return s.handleRouters(r, path[1:], body)
+	case "logical-router-ports":
+		return s.handleRouterPorts(r, path[1:], body)
+	case "dhcp":
+		if len(path) > 1 && path[1] == "server-profiles" {
+			return s.handleProfiles(r, path[2:], body)
+		}
+		if len(path) > 1 && path[1] == "servers" {
+			return s.handleServers(r, path[2:], body)
+		}
+	case "search":
+		if len(path) == 1 && r.Method == http.MethodGet {
+			return s.search(r)
+		}
+	}
+
+	return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+}
+
+/**
+  Returns a page of objects that pass a filter in creation order, page
+  selected by cursor and page_size query parameters.
+*/
+func (s *Server) list(r *http.Request, objects map[string]object, filter func(object) bool) (int, interface{}, *apiError) {
+
+	var results []object
+	for _, o := range objects {
+		if filter == nil || filter(o) {
+			results = append(results, o)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return s.order[results[i].str("id")] < s.order[results[j].str("id")]
+	})
+
+	pageSize := s.pageSize
+	if v, err := strconv.Atoi(r.URL.Query().Get("page_size")); err == nil && v > 0 {
+		pageSize = v
+	}
+	start := 0
+	if v := r.URL.Query().Get("cursor"); len(v) > 0 {
+		var err error
+		start, err = strconv.Atoi(v)
+		if err != nil || start < 0 || start > len(results) {
+			return 0, nil, errorf(http.StatusBadRequest, "invalid cursor %s", v)
+		}
+	}
+	end := start + pageSize
+	if end > len(results) {
+		end = len(results)
+	}
+
+	page := object{
+		"result_count": len(results),
+		"results":      append([]object{}, results[start:end]...),
+	}
+	if end < len(results) {
+		page["cursor"] = strconv.Itoa(end)
+	}
+
+	return http.StatusOK, page, nil
+}
+
+// Adds an object to a collection, server assigns id and system attributes.
+func (s *Server) create(objects map[string]object, o object, resourceType string) object {
+	if len(o.str("id")) == 0 {
+		o["id"] = uuid.New().String()
+	}
+	if len(o.str("resource_type")) == 0 {
+		o["resource_type"] = resourceType
+	}
+	s.seq++
+	if _, ok := s.order[o.str("id")]; !ok {
+		s.order[o.str("id")] = s.seq
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	o["_create_time"] = now
+	o["_last_modified_time"] = now
+	o["_create_user"] = s.Username
+	o["_revision"] = 0
+	objects[o.str("id")] = o
+	return o
+}
+
+/**
+  Replaces an object, revision of an update must match revision of a stored
+  object, like nsx-t manager does.
+*/
+func (s *Server) update(objects map[string]object, id string, o object) (object, *apiError) {
+
+	old, ok := objects[id]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "object %s not found", id)
+	}
+	if revision(o) != revision(old) {
+		return nil, errorf(http.StatusPreconditionFailed,
+			"object %s was modified by somebody else, revision %d", id, revision(old))
+	}
+
+	for _, k := range []string{"id", "resource_type", "_create_time", "_create_user"} {
+		o[k] = old[k]
+	}
+	o["_revision"] = revision(old) + 1
+	o["_last_modified_time"] = time.Now().UnixNano() / int64(time.Millisecond)
+	objects[id] = o
+
+	return o, nil
+}
+
+func revision(o object) int64 {
+	switch v := o["_revision"].(type) {
+	case float64:
+		return int64(v)
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
+
+// Reads, updates or deletes an object of a collection addressed by a single id.
+func (s *Server) item(r *http.Request, objects map[string]object, id string,
+	body object, remove func(object) *apiError) (int, interface{}, *apiError) {
+
+	o, ok := objects[id]
+	if !ok {
+		return 0, nil, errorf(http.StatusNotFound, "object %s not found", id)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return http.StatusOK, o, nil
+	case http.MethodPut:
+		o, err := s.update(objects, id, body)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, o, nil
+	case http.MethodDelete:
+		if remove != nil {
+			if err := remove(o); err != nil {
+				return 0, nil, err
+			}
+		}
+		delete(objects, id)
+		return http.StatusOK, nil, nil
+	}
+
+	return 0, nil, errorf(http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+}
+
+// true if query parameter set to true
+func flag(r *http.Request, name string) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get(name))
+	return v
+}
+
+// Matches an attribute of an object against a query parameter, unset parameter matches all.
+func match(r *http.Request, o object, param string, key string) bool {
+	v, ok := r.URL.Query()[param]
+	if !ok {
+		return true
+	}
+	return o.str(key) == v[0]
+}
+
+func writeError(w http.ResponseWriter, e *apiError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	_ = json.NewEncoder(w).Encode(object{
+		"httpStatus":    strings.ToUpper(strings.Replace(http.StatusText(e.status), " ", "_", -1)),
+		"error_code":    e.status,
+		"module_name":   "nsxtsim",
+		"error_message": e.message,
+	})
+}
+
+func (s *Server) seed() {
+
+	s.create(s.zones, object{
+		"id":             OverlayZoneUuid,
+		"display_name":   OverlayZoneName,
+		"transport_type": "OVERLAY",
+		"host_switch_name": "nvds-overlay",
+	}, "TransportZone")
+
+	s.create(s.clusters, object{
+		"id":           EdgeClusterUuid,
+		"display_name": EdgeClusterName,
+	}, "EdgeCluster")
+
+	s.create(s.routers, object{
+		"id":              TierZeroUuid,
+		"display_name":    TierZeroName,
+		"router_type":     nsxtapi.RouteTypeTier0,
+		"edge_cluster_id": EdgeClusterUuid,
+	}, "LogicalRouter")
+	s.create(s.routers, object{
+		"id":              TierOneUuid,
+		"display_name":    TierOneName,
+		"router_type":     nsxtapi.RouteTypeTier1,
+		"edge_cluster_id": EdgeClusterUuid,
+	}, "LogicalRouter")
+
+	profile := s.create(s.profiles, object{
+		"display_name":    "dhcp-profile",
+		"edge_cluster_id": EdgeClusterUuid,
+	}, "DhcpProfile")
+
+	// each dhcp server attached to a port of own switch
+	servers := []struct{ switchId, switchName, serverId, serverName, address string }{
+		{SegmentUuid, SegmentName, DhcpServerUuid, SegmentName + "-dhcp", "172.16.81.2/24"},
+		{"", SharedDhcpName, SharedDhcpUuid, SharedDhcpName, "172.16.84.2/24"},
+	}
+	for _, v := range servers {
+		lds := s.create(s.switches, object{
+			"id":                v.switchId,
+			"display_name":      v.switchName,
+			"transport_zone_id": OverlayZoneUuid,
+			"admin_state":       "UP",
+			"replication_mode":  "MTEP",
+		}, "LogicalSwitch")
+		port := s.create(s.ports, object{
+			"display_name":      v.serverName + "-port",
+			"logical_switch_id": lds.str("id"),
+			"admin_state":       "UP",
+			"attachment": map[string]interface{}{
+				"attachment_type": "DHCP_SERVICE",
+				"id":              v.serverId,
+			},
+		}, "LogicalPort")
+		s.create(s.servers, object{
+			"id":                       v.serverId,
+			"display_name":             v.serverName,
+			"dhcp_profile_id":          profile.str("id"),
+			"attached_logical_port_id": port.str("id"),
+			"ipv4_dhcp_server": map[string]interface{}{
+				"dhcp_server_ip": v.address,
+			},
+		}, "LogicalDhcpServer")
+		s.bindings[v.serverId] = make(map[string]object)
+	}
+
+	s.create(s.bindings[SharedDhcpUuid], object{
+		"display_name": "super-cluster",
+		"host_name":    "super-cluster",
+		"mac_address":  "00:50:56:00:00:01",
+		"ip_address":   "172.16.84.10",
+		"gateway_ip":   "172.16.84.1",
+		"tags": []interface{}{
+			map[string]interface{}{"scope": "jettison", "tag": "SuperCluster"},
+		},
+	}, "DhcpStaticBinding")
+}
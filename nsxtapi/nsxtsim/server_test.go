@@ -0,0 +1,291 @@
+package nsxtsim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/vmware/go-vmware-nsxt"
+
+	"github.com/spyroot/jettison/nsxtapi"
+)
+
+const tenant = "test"
+
+func connect(t *testing.T) (*Server, *nsxt.APIClient) {
+
+	s := NewServer()
+	c, err := s.Connect()
+	if err != nil {
+		s.Close()
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return s, &c
+}
+
+func TestCredentials(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+
+	c, err := nsxtapi.Connect(s.Host(), s.Username, "wrong")
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err := nsxtapi.FindTransportZone(&c, OverlayZoneName); err == nil {
+		t.Errorf("FindTransportZone() with wrong password must fail")
+	}
+}
+
+// Builds a tenant segment the way vmware provider does and tears it down.
+func TestSegment(t *testing.T) {
+
+	s, c := connect(t)
+	defer s.Close()
+
+	switchId, _, err := nsxtapi.CreateSwitchIfNeed(c, tenant, "segment", OverlayZoneName, "switch")
+	if err != nil {
+		t.Fatalf("CreateSwitchIfNeed() error = %v", err)
+	}
+	routerId, err := nsxtapi.CreateRouterIfNeed(c, tenant, "segment", "router", EdgeClusterUuid)
+	if err != nil {
+		t.Fatalf("CreateRouterIfNeed() error = %v", err)
+	}
+	portId, err := nsxtapi.CreateLogicalPortIfNeed(c, tenant, switchId, routerId)
+	if err != nil {
+		t.Fatalf("CreateLogicalPortIfNeed() error = %v", err)
+	}
+	routedPortId, err := nsxtapi.CreateRoutedPortIfNeed(c, tenant, routerId, switchId, portId, "172.16.90.1", 24)
+	if err != nil {
+		t.Fatalf("CreateRoutedPortIfNeed() error = %v", err)
+	}
+	if _, _, err := nsxtapi.ConnectTier1IfNeed(c, tenant, routerId, TierZeroUuid); err != nil {
+		t.Fatalf("ConnectTier1IfNeed() error = %v", err)
+	}
+	if _, err := nsxtapi.DefaultRoutingAdvertisement(c, routerId); err != nil {
+		t.Fatalf("DefaultRoutingAdvertisement() error = %v", err)
+	}
+
+	// second call finds existing objects
+	again, _, err := nsxtapi.CreateSwitchIfNeed(c, tenant, "segment", OverlayZoneName, "switch")
+	if err != nil || again != switchId {
+		t.Errorf("CreateSwitchIfNeed() = %s, %v want %s", again, err, switchId)
+	}
+	port, err := nsxtapi.FindSwitchRouterPort(c, switchId)
+	if err != nil || port.Id != routedPortId {
+		t.Errorf("FindSwitchRouterPort() = %v, %v want %s", port, err, routedPortId)
+	}
+
+	req := &nsxtapi.DhcpServerCreateReq{
+		ServerName:   "dhcp",
+		DhcpServerIp: "172.16.90.2/24",
+		GatewayIp:    "172.16.90.1",
+		ClusterId:    EdgeClusterUuid,
+		SwitchId:     switchId,
+		TenantId:     tenant,
+		Segment:      "segment",
+	}
+	dhcpId, err := nsxtapi.CreateDhcpServiceIfNeed(c, req)
+	if err != nil {
+		t.Fatalf("CreateDhcpServiceIfNeed() error = %v", err)
+	}
+	server, err := nsxtapi.FindAttachedDhcpServerProfile(c, switchId)
+	if err != nil || server.Id != dhcpId {
+		t.Errorf("FindAttachedDhcpServerProfile() = %v, %v want %s", server, err, dhcpId)
+	}
+
+	if _, err := nsxtapi.CreateStaticBinding(c, dhcpId,
+		"00:50:56:00:00:10", "172.16.90.10", "node", "172.16.90.1", tenant); err != nil {
+		t.Fatalf("CreateStaticBinding() error = %v", err)
+	}
+	if _, err := nsxtapi.CreateStaticBinding(c, dhcpId,
+		"00:50:56:00:00:11", "172.16.90.10", "node", "172.16.90.1", tenant); err == nil {
+		t.Errorf("CreateStaticBinding() with duplicate address must fail")
+	}
+	bindings, err := nsxtapi.FindStaticBindingsByTenant(c, dhcpId, tenant)
+	if err != nil || len(bindings) != 1 {
+		t.Errorf("FindStaticBindingsByTenant() = %v, %v", bindings, err)
+	}
+
+	// switch can't go while dhcp server attached to it
+	if _, err := c.LogicalSwitchingApi.DeleteLogicalSwitch(c.Context, switchId, nil); err == nil {
+		t.Errorf("DeleteLogicalSwitch() without cascade must fail")
+	}
+	profileId, dhcpPortId, err := nsxtapi.DeleteDhcpServer(c, dhcpId)
+	if err != nil {
+		t.Fatalf("DeleteDhcpServer() error = %v", err)
+	}
+	if _, _, err := nsxtapi.DeleteDhcpProfile(c, profileId); err != nil {
+		t.Errorf("DeleteDhcpProfile() error = %v", err)
+	}
+	if _, _, err := c.LogicalSwitchingApi.GetLogicalPort(c.Context, dhcpPortId); err == nil {
+		t.Errorf("dhcp port %s must be deleted", dhcpPortId)
+	}
+
+	if _, err := nsxtapi.DeleteLogicalRouter(c, routerId); err != nil {
+		t.Errorf("DeleteLogicalRouter() error = %v", err)
+	}
+	if _, err := nsxtapi.DeleteLogicalSwitch(c, switchId); err != nil {
+		t.Errorf("DeleteLogicalSwitch() error = %v", err)
+	}
+	if _, err := nsxtapi.FindSwitchRouterPort(c, switchId); err == nil {
+		t.Errorf("FindSwitchRouterPort() router port must be deleted with a router")
+	}
+	if ports, _ := nsxtapi.FindRouterPortsByTenant(c, tenant); len(ports) != 1 {
+		// tier zero port left, tier zero owned by nobody
+		t.Errorf("FindRouterPortsByTenant() = %d ports, want port of tier zero", len(ports))
+	}
+}
+
+func TestStaticRoutes(t *testing.T) {
+
+	s, c := connect(t)
+	defer s.Close()
+
+	req := nsxtapi.AddStaticReq{RouterUuid: TierOneUuid, Network: "10.20.0.0/24"}
+	if _, err := nsxtapi.AddStaticRoute(c, req); err == nil {
+		t.Errorf("AddStaticRoute() without next hop must fail")
+	}
+
+	req.NextHopAddr = []byte{172, 16, 88, 1}
+	if _, err := nsxtapi.AddStaticRoute(c, req); err != nil {
+		t.Fatalf("AddStaticRoute() error = %v", err)
+	}
+	deleted, err := nsxtapi.DeleteStaticRoute(c, req)
+	if err != nil || !deleted {
+		t.Errorf("DeleteStaticRoute() = %v, %v", deleted, err)
+	}
+	deleted, err = nsxtapi.DeleteStaticRoute(c, req)
+	if err != nil || deleted {
+		t.Errorf("DeleteStaticRoute() of deleted route = %v, %v", deleted, err)
+	}
+}
+
+func TestRevision(t *testing.T) {
+
+	s, c := connect(t)
+	defer s.Close()
+
+	server, _, err := c.ServicesApi.ReadDhcpServer(c.Context, DhcpServerUuid)
+	if err != nil {
+		t.Fatalf("ReadDhcpServer() error = %v", err)
+	}
+	port, _, err := c.LogicalSwitchingApi.GetLogicalPort(c.Context, server.AttachedLogicalPortId)
+	if err != nil {
+		t.Fatalf("GetLogicalPort() error = %v", err)
+	}
+
+	port.Description = "first"
+	if _, _, err := c.LogicalSwitchingApi.UpdateLogicalPort(c.Context, port.Id, port); err != nil {
+		t.Fatalf("UpdateLogicalPort() error = %v", err)
+	}
+	port.Description = "stale"
+	if _, resp, err := c.LogicalSwitchingApi.UpdateLogicalPort(c.Context, port.Id, port); err == nil ||
+		resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("UpdateLogicalPort() with stale revision must fail with 412, got %v", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+
+	s, c := connect(t)
+	defer s.Close()
+
+	s.InjectFault(Fault{Method: http.MethodPost, Path: "/logical-switches", Status: http.StatusServiceUnavailable, Times: 1})
+	if _, err := nsxtapi.CreateLogicalSwitch(c, OverlayZoneName, "faulty", nil); err == nil {
+		t.Errorf("CreateLogicalSwitch() must fail with injected fault")
+	}
+	if _, err := nsxtapi.CreateLogicalSwitch(c, OverlayZoneName, "faulty", nil); err != nil {
+		t.Errorf("CreateLogicalSwitch() fault must fire once, error = %v", err)
+	}
+
+	s.InjectFault(Fault{Path: "/edge-clusters", Status: http.StatusInternalServerError})
+	for i := 0; i < 2; i++ {
+		if _, err := nsxtapi.FindEdgeCluster(c, nsxtapi.EdgeClusterCallback["name"], EdgeClusterName); err == nil {
+			t.Errorf("FindEdgeCluster() must fail with injected fault")
+		}
+	}
+	s.ClearFaults()
+	if _, err := nsxtapi.FindEdgeCluster(c, nsxtapi.EdgeClusterCallback["name"], EdgeClusterName); err != nil {
+		t.Errorf("FindEdgeCluster() error = %v", err)
+	}
+}
+
+func TestPaging(t *testing.T) {
+
+	s, c := connect(t)
+	defer s.Close()
+
+	for _, name := range []string{"sw1", "sw2", "sw3"} {
+		if _, err := nsxtapi.CreateLogicalSwitch(c, OverlayZoneName, name, nsxtapi.MakeDhcpTags(tenant)); err != nil {
+			t.Fatalf("CreateLogicalSwitch() error = %v", err)
+		}
+	}
+
+	s.SetPageSize(1)
+	switches, err := nsxtapi.FindLogicalSwitchesByTenant(c, tenant)
+	if err != nil {
+		t.Fatalf("FindLogicalSwitchesByTenant() error = %v", err)
+	}
+	if len(switches) != 3 {
+		t.Errorf("FindLogicalSwitchesByTenant() = %d switches, want 3", len(switches))
+	}
+	for i, name := range []string{"sw1", "sw2", "sw3"} {
+		if switches[i].DisplayName != name {
+			t.Errorf("switch %d = %s, want %s", i, switches[i].DisplayName, name)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+
+	s, c := connect(t)
+	defer s.Close()
+
+	if _, err := nsxtapi.CreateLogicalSwitch(c, OverlayZoneName, "tagged", nsxtapi.MakeDhcpTags(tenant)); err != nil {
+		t.Fatalf("CreateLogicalSwitch() error = %v", err)
+	}
+
+	tests := []struct {
+		query  string
+		want   int
+		status int
+	}{
+		{"resource_type:LogicalSwitch AND tags.tag:" + tenant, 1, http.StatusOK},
+		{"tags.scope:jettison AND tags.tag:SuperCluster", 1, http.StatusOK},
+		{"display_name:" + TierZeroName, 1, http.StatusOK},
+		{"resource_type:LogicalRouter", 2, http.StatusOK},
+		{"owner:" + tenant, 0, http.StatusBadRequest},
+		{"", 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+
+			r, _ := http.NewRequest(http.MethodGet,
+				s.URL+apiPrefix+"/search?query="+url.QueryEscape(tt.query), nil)
+			r.SetBasicAuth(s.Username, s.Password)
+			resp, err := s.Client().Do(r)
+			if err != nil {
+				t.Fatalf("search error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("search status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var result struct {
+				ResultCount int `json:"result_count"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("failed decode search result %v", err)
+			}
+			if result.ResultCount != tt.want {
+				t.Errorf("search %s = %d objects, want %d", tt.query, result.ResultCount, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package nsxtsim
+
+import (
+	"net/http"
+)
+
+/*
+  Logical switches. Switch must be in existing transport zone, switch with
+  ports deleted only with cascade, cascade deletes ports as well.
+*/
+func (s *Server) handleSwitches(r *http.Request, path []string, body object) (int, interface{}, *apiError) {
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, s.switches, func(o object) bool {
+				return match(r, o, "transport_zone_id", "transport_zone_id")
+			})
+		case http.MethodPost:
+			if _, ok := s.zones[body.str("transport_zone_id")]; !ok {
+				return 0, nil, errorf(http.StatusBadRequest,
+					"transport zone %s not found", body.str("transport_zone_id"))
+			}
+			if len(body.str("admin_state")) == 0 {
+				body["admin_state"] = "UP"
+			}
+			return http.StatusCreated, s.create(s.switches, body, "LogicalSwitch"), nil
+		}
+	}
+
+	if len(path) != 1 {
+		return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+	}
+
+	return s.item(r, s.switches, path[0], body, func(o object) *apiError {
+		var ports []object
+		for _, p := range s.ports {
+			if p.str("logical_switch_id") == o.str("id") {
+				ports = append(ports, p)
+			}
+		}
+		if len(ports) > 0 && !flag(r, "cascade") {
+			return errorf(http.StatusBadRequest,
+				"logical switch %s has %d ports, delete ports first", o.str("id"), len(ports))
+		}
+		for _, p := range ports {
+			s.deletePort(p)
+		}
+		return nil
+	})
+}
+
+/*
+  Logical ports. Port must be on existing switch, attached port deleted only
+  with detach.
+*/
+func (s *Server) handlePorts(r *http.Request, path []string, body object) (int, interface{}, *apiError) {
+
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			return s.list(r, s.ports, func(o object) bool {
+				return match(r, o, "logical_switch_id", "logical_switch_id")
+			})
+		case http.MethodPost:
+			if _, ok := s.switches[body.str("logical_switch_id")]; !ok {
+				return 0, nil, errorf(http.StatusBadRequest,
+					"logical switch %s not found", body.str("logical_switch_id"))
+			}
+			if len(body.str("admin_state")) == 0 {
+				body["admin_state"] = "UP"
+			}
+			return http.StatusCreated, s.create(s.ports, body, "LogicalPort"), nil
+		}
+	}
+
+	if len(path) != 1 {
+		return 0, nil, errorf(http.StatusNotFound, "unknown request %s %s", r.Method, r.URL.Path)
+	}
+
+	if r.Method == http.MethodPut {
+		if _, ok := s.switches[body.str("logical_switch_id")]; !ok {
+			return 0, nil, errorf(http.StatusBadRequest,
+				"logical switch %s not found", body.str("logical_switch_id"))
+		}
+		status, port, err := s.item(r, s.ports, path[0], body, nil)
+		if err == nil {
+			s.attachDhcp(port.(object))
+		}
+		return status, port, err
+	}
+
+	return s.item(r, s.ports, path[0], body, func(o object) *apiError {
+		if _, ok := o["attachment"]; ok && !flag(r, "detach") {
+			return errorf(http.StatusBadRequest, "logical port %s has attachment, detach it first", o.str("id"))
+		}
+		s.deletePort(o)
+		return nil
+	})
+}
+
+// Port attached to dhcp service becomes attached port of a dhcp server.
+func (s *Server) attachDhcp(port object) {
+
+	attachment, ok := port["attachment"].(map[string]interface{})
+	if !ok || attachment["attachment_type"] != "DHCP_SERVICE" {
+		return
+	}
+	id, _ := attachment["id"].(string)
+	if server, ok := s.servers[id]; ok {
+		server["attached_logical_port_id"] = port.str("id")
+	}
+}
+
+// Deletes a port and detaches whatever port attached to.
+func (s *Server) deletePort(port object) {
+
+	for _, server := range s.servers {
+		if server.str("attached_logical_port_id") == port.str("id") {
+			delete(server, "attached_logical_port_id")
+		}
+	}
+	for id, p := range s.routerPorts {
+		if p.ref("linked_logical_switch_port_id") == port.str("id") {
+			delete(s.routerPorts, id)
+		}
+	}
+	delete(s.ports, port.str("id"))
+}
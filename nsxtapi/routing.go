@@ -23,6 +23,7 @@ package nsxtapi
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"reflect"
 
@@ -206,6 +207,32 @@ func FindRoutedPortByTag(nsxClient *nsxt.APIClient,
 	return nil, &ObjectNotFound{"Port not found"}
 }
 
+/*
+  Search a downlink port that connects a router to a logical switch,
+  router of a segment is a router that owns a port.
+*/
+func FindSwitchRouterPort(nsxClient *nsxt.APIClient, switchID string) (*manager.LogicalRouterPort, error) {
+
+	if nsxClient == nil {
+		return nil, fmt.Errorf("nsxt client is nil")
+	}
+
+	filter := map[string]interface{}{
+		"logicalSwitchId": switchID,
+		"resourceType":    "LogicalRouterDownLinkPort",
+	}
+
+	ports, _, err := nsxClient.LogicalRoutingAndServicesApi.ListLogicalRouterPorts(nsxClient.Context, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed obtain router ports of switch %s: %v", switchID, err)
+	}
+	if len(ports.Results) == 0 {
+		return nil, &ObjectNotFound{"router port of switch " + switchID}
+	}
+
+	return &ports.Results[0], nil
+}
+
 /*
   Search a routed port on tier1.
 */
@@ -456,7 +483,7 @@ func FindDisconnectedPorts(nsxClient *nsxt.APIClient, routerID string) ([]string
 		nsxClient.LogicalRoutingAndServicesApi.ReadLogicalRouter(nsxClient.Context, routerID)
 	if err != nil {
 		logging.ErrorLogging(err)
-		return disconnectedPorts, fmt.Errorf("failed read router object %s", router)
+		return disconnectedPorts, fmt.Errorf("failed read router object %s", routerID)
 	}
 	if resp.StatusCode != http.StatusOK {
 		logging.ErrorLogging(err)
@@ -690,3 +717,61 @@ func AddStaticRoute(nsxClient *nsxt.APIClient, req AddStaticReq) (bool, error) {
 
 	return true, nil
 }
+
+//
+//  Deletes static route for a network via a next hop from a given tier 1 router,
+//  route to a same network via other next hop kept. Return false if router has
+//  no such route.
+//
+func DeleteStaticRoute(nsxClient *nsxt.APIClient, req AddStaticReq) (bool, error) {
+
+	if !IsUuid(req.RouterUuid) {
+		return false, fmt.Errorf("routed id must be valid uuid format")
+	}
+	if req.NextHopAddr == nil {
+		return false, fmt.Errorf("next hop must be valid ip address")
+	}
+
+	routes, resp, err := nsxClient.LogicalRoutingAndServicesApi.ListStaticRoutes(nsxClient.Context,
+		req.RouterUuid, nil)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := fmt.Errorf("nsx-t return unexpected status code for req list static routes")
+		logging.ErrorLogging(e)
+		return false, e
+	}
+
+	var deleted = false
+	for _, route := range routes.Results {
+		if route.Network != req.Network || !hasNextHop(route, req.NextHopAddr) {
+			continue
+		}
+		resp, err := nsxClient.LogicalRoutingAndServicesApi.DeleteStaticRoute(nsxClient.Context,
+			req.RouterUuid, route.Id)
+		if err != nil {
+			logging.ErrorLogging(err)
+			return false, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			e := fmt.Errorf("nsx-t return unexpected status code for req delete static route")
+			logging.ErrorLogging(e)
+			return false, e
+		}
+		deleted = true
+	}
+
+	return deleted, nil
+}
+
+// Returns true if one of next hops of a route is a given address.
+func hasNextHop(route manager.StaticRoute, addr net.IP) bool {
+	for _, hop := range route.NextHops {
+		if addr.Equal(net.ParseIP(hop.IpAddress)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+NSX-T API integration. Lookup of objects jettison tagged with a tenant.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+package nsxtapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/vmware/go-vmware-nsxt"
+	"github.com/vmware/go-vmware-nsxt/common"
+	"github.com/vmware/go-vmware-nsxt/manager"
+)
+
+// tag scope jettison sets on every object it creates, tag value is a tenant name
+const TenantScope = "jettison-tenant"
+
+/**
+  Returns a tenant of an object tagged by jettison, empty string if an object
+  has no jettison tag.
+*/
+func TenantTag(tags []common.Tag) string {
+	for _, t := range tags {
+		if t.Scope == TenantScope {
+			return t.Tag
+		}
+	}
+	return ""
+}
+
+// true if object tagged with a tenant, any jettison tenant matches empty tenant name
+func isTenantObject(tags []common.Tag, tenantName string) bool {
+	tenant := TenantTag(tags)
+	if len(tenant) == 0 {
+		return false
+	}
+	return len(tenantName) == 0 || tenant == tenantName
+}
+
+/**
+  Returns all logical switches tagged with a tenant, for empty tenant name
+  switches of all jettison tenants returned.
+*/
+func FindLogicalSwitchesByTenant(nsxClient *nsxt.APIClient, tenantName string) ([]manager.LogicalSwitch, error) {
+
+	var switches []manager.LogicalSwitch
+
+	if nsxClient == nil {
+		return nil, fmt.Errorf("nsxt client is nil")
+	}
+
+	opts := make(map[string]interface{})
+	for {
+		list, _, err := nsxClient.LogicalSwitchingApi.ListLogicalSwitches(nsxClient.Context, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed obtain switch list: %v", err)
+		}
+		for _, s := range list.Results {
+			if isTenantObject(s.Tags, tenantName) {
+				switches = append(switches, s)
+			}
+		}
+		if len(list.Cursor) == 0 {
+			break
+		}
+		opts["cursor"] = list.Cursor
+	}
+
+	return switches, nil
+}
+
+/**
+  Returns all logical routers tagged with a tenant, for empty tenant name
+  routers of all jettison tenants returned.
+*/
+func FindLogicalRoutersByTenant(nsxClient *nsxt.APIClient, tenantName string) ([]manager.LogicalRouter, error) {
+
+	var routers []manager.LogicalRouter
+
+	if nsxClient == nil {
+		return nil, fmt.Errorf("nsxt client is nil")
+	}
+
+	opts := make(map[string]interface{})
+	for {
+		list, _, err := nsxClient.LogicalRoutingAndServicesApi.ListLogicalRouters(nsxClient.Context, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed obtain routers list: %v", err)
+		}
+		for _, r := range list.Results {
+			if isTenantObject(r.Tags, tenantName) {
+				routers = append(routers, r)
+			}
+		}
+		if len(list.Cursor) == 0 {
+			break
+		}
+		opts["cursor"] = list.Cursor
+	}
+
+	return routers, nil
+}
+
+/**
+  Returns all dhcp servers tagged with a tenant, for empty tenant name
+  servers of all jettison tenants returned.
+*/
+func FindDhcpServersByTenant(nsxClient *nsxt.APIClient, tenantName string) ([]manager.LogicalDhcpServer, error) {
+
+	var servers []manager.LogicalDhcpServer
+
+	if nsxClient == nil {
+		return nil, fmt.Errorf("nsxt client is nil")
+	}
+
+	opts := make(map[string]interface{})
+	for {
+		list, _, err := nsxClient.ServicesApi.ListDhcpServers(nsxClient.Context, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed recieve dhcp server list: %v", err)
+		}
+		for _, s := range list.Results {
+			if isTenantObject(s.Tags, tenantName) {
+				servers = append(servers, s)
+			}
+		}
+		if len(list.Cursor) == 0 {
+			break
+		}
+		opts["cursor"] = list.Cursor
+	}
+
+	return servers, nil
+}
+
+/**
+  Returns all logical router ports tagged with a tenant, for empty tenant name
+  ports of all jettison tenants returned.
+*/
+func FindRouterPortsByTenant(nsxClient *nsxt.APIClient, tenantName string) ([]manager.LogicalRouterPort, error) {
+
+	var ports []manager.LogicalRouterPort
+
+	if nsxClient == nil {
+		return nil, fmt.Errorf("nsxt client is nil")
+	}
+
+	opts := make(map[string]interface{})
+	for {
+		list, _, err := nsxClient.LogicalRoutingAndServicesApi.ListLogicalRouterPorts(nsxClient.Context, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed obtain router ports list: %v", err)
+		}
+		for _, p := range list.Results {
+			if isTenantObject(p.Tags, tenantName) {
+				ports = append(ports, p)
+			}
+		}
+		if len(list.Cursor) == 0 {
+			break
+		}
+		opts["cursor"] = list.Cursor
+	}
+
+	return ports, nil
+}
+
+/**
+  Returns all dhcp profiles tagged with a tenant, for empty tenant name
+  profiles of all jettison tenants returned.
+*/
+func FindDhcpProfilesByTenant(nsxClient *nsxt.APIClient, tenantName string) ([]manager.DhcpProfile, error) {
+
+	var profiles []manager.DhcpProfile
+
+	if nsxClient == nil {
+		return nil, fmt.Errorf("nsxt client is nil")
+	}
+
+	opts := make(map[string]interface{})
+	for {
+		list, _, err := nsxClient.ServicesApi.ListDhcpProfiles(nsxClient.Context, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed recieve dhcp profile list: %v", err)
+		}
+		for _, p := range list.Results {
+			if isTenantObject(p.Tags, tenantName) {
+				profiles = append(profiles, p)
+			}
+		}
+		if len(list.Cursor) == 0 {
+			break
+		}
+		opts["cursor"] = list.Cursor
+	}
+
+	return profiles, nil
+}
+
+/**
+  Returns static bindings of a dhcp server tagged with a tenant, for empty
+  tenant name bindings of all jettison tenants returned.
+*/
+func FindStaticBindingsByTenant(nsxClient *nsxt.APIClient, serverId string, tenantName string) ([]manager.DhcpStaticBinding, error) {
+
+	var bindings []manager.DhcpStaticBinding
+
+	if nsxClient == nil {
+		return nil, fmt.Errorf("nsxt client is nil")
+	}
+
+	opts := make(map[string]interface{})
+	for {
+		list, _, err := nsxClient.ServicesApi.ListDhcpStaticBindings(nsxClient.Context, serverId, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed recieve dhcp static binding for server %s: %v", serverId, err)
+		}
+		for _, b := range list.Results {
+			if isTenantObject(b.Tags, tenantName) {
+				bindings = append(bindings, b)
+			}
+		}
+		if len(list.Cursor) == 0 {
+			break
+		}
+		opts["cursor"] = list.Cursor
+	}
+
+	return bindings, nil
+}
+
+/**
+  Deletes a static binding by id.
+*/
+func DeleteStaticBindingById(nsxClient *nsxt.APIClient, serverId string, bindingId string) error {
+
+	if nsxClient == nil {
+		return fmt.Errorf("nsxt client is nil")
+	}
+
+	resp, err := nsxClient.ServicesApi.DeleteDhcpStaticBinding(nsxClient.Context, serverId, bindingId)
+	if err != nil {
+		return fmt.Errorf("failed delete dhcp static binding %s: %v", bindingId, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nsx-t return unexpected status code: %v", resp.StatusCode)
+	}
+
+	return nil
+}
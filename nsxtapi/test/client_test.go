@@ -4,20 +4,35 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/vmware/go-vmware-nsxt"
 	"github.com/vmware/go-vmware-nsxt/manager"
 
 	"github.com/spyroot/jettison/nsxtapi"
+	"github.com/spyroot/jettison/nsxtapi/nsxtsim"
 )
 
+var (
+	simOnce sync.Once
+	sim     *nsxtsim.Server
+)
+
+// Connects to nsx-t manager NSXHOST points to, unset NSXHOST runs tests against nsxtsim.
 func setupTest() (nsxt.APIClient, func()) {
 
-	nsxtClient, err := nsxtapi.Connect(
-		os.Getenv("NSXHOST"),
-		os.Getenv("NSXUSERNAME"),
-		os.Getenv("NSXPASSWORD"))
+	host := os.Getenv("NSXHOST")
+	username := os.Getenv("NSXUSERNAME")
+	password := os.Getenv("NSXPASSWORD")
+	if len(host) == 0 {
+		simOnce.Do(func() {
+			sim = nsxtsim.NewServer()
+		})
+		host, username, password = sim.Host(), sim.Username, sim.Password
+	}
+
+	nsxtClient, err := nsxtapi.Connect(host, username, password)
 
 	if err != nil {
 		log.Println("setupTest() error = ", err)
@@ -113,7 +113,10 @@ func TestCreateDhcpServer(t *testing.T) {
 		want    *manager.LogicalDhcpServer
 		wantErr bool
 	}{
-		{},
+		{
+			name:    "nil request",
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -215,7 +218,13 @@ func TestGetStaticBinding(t *testing.T) {
 				t.Errorf("GetStaticBinding() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
+			if err != nil {
+				if got != nil {
+					t.Errorf("GetStaticBinding() return must be nil")
+				}
+				return
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
 				t.Errorf("GetStaticBinding() got = %v, want %v", got, tt.want)
 			}
 		})
@@ -405,3 +405,91 @@ func TestAddStaticRoute(t *testing.T) {
 		assert.Nil(t, err)
 	}
 }
+
+/**
+  Route deleted only if both network and next hop match.
+*/
+func TestDeleteStaticRoute(t *testing.T) {
+
+	nsxClient, teardown := setupTest()
+	defer teardown()
+
+	clusters, err := nsxtapi.FindEdgeCluster(&nsxClient, nsxtapi.EdgeClusterCallback["name"], "edge-cluster")
+	if err != nil || len(clusters) == 0 {
+		t.Fatal("No cluster defined")
+	}
+
+	tests := []struct {
+		name        string
+		network     string
+		nexthop     string
+		wantDeleted bool
+		wantErr     bool
+		wantRoutes  int
+	}{
+		{
+			name:        "same network and next hop",
+			network:     "10.20.0.0/24",
+			nexthop:     "172.16.88.10",
+			wantDeleted: true,
+		},
+		{
+			name:       "other next hop",
+			network:    "10.20.0.0/24",
+			nexthop:    "172.16.88.11",
+			wantRoutes: 1,
+		},
+		{
+			name:       "other network",
+			network:    "10.20.1.0/24",
+			nexthop:    "172.16.88.10",
+			wantRoutes: 1,
+		},
+		{
+			name:       "no next hop",
+			network:    "10.20.0.0/24",
+			wantErr:    true,
+			wantRoutes: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req := nsxtapi.RouterCreateReq{}
+			req.Name = "test"
+			req.RouterType = nsxtapi.RouteTypeTier1
+			req.ClusterID = clusters[0].Id
+			router, err := nsxtapi.CreateLogicalRouter(&nsxClient, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_, _ = nsxtapi.DeleteLogicalRouter(&nsxClient, router.Id)
+			}()
+
+			route := nsxtapi.AddStaticReq{RouterUuid: router.Id, Network: "10.20.0.0/24",
+				NextHopAddr: net.ParseIP("172.16.88.10")}
+			if _, err := nsxtapi.AddStaticRoute(&nsxClient, route); err != nil {
+				t.Fatal(err)
+			}
+
+			deleted, err := nsxtapi.DeleteStaticRoute(&nsxClient, nsxtapi.AddStaticReq{RouterUuid: router.Id,
+				Network: tt.network, NextHopAddr: net.ParseIP(tt.nexthop)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeleteStaticRoute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("DeleteStaticRoute() = %v, want %v", deleted, tt.wantDeleted)
+			}
+
+			routes, _, err := nsxClient.LogicalRoutingAndServicesApi.ListStaticRoutes(nsxClient.Context, router.Id, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(routes.Results) != tt.wantRoutes {
+				t.Errorf("DeleteStaticRoute() left %d routes, want %d", len(routes.Results), tt.wantRoutes)
+			}
+		})
+	}
+}
@@ -497,6 +497,9 @@ func TestFindLogicalSwitchByTag(t *testing.T) {
 				t.Errorf("FindLogicalSwitchByTag() return must be nil")
 				return
 			}
+			if err != nil {
+				return
+			}
 
 			if got == nil {
 				t.Errorf("FindLogicalSwitchByTag() error is not nil got must not nil")
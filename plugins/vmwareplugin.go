@@ -0,0 +1,35 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Vmware provider built as a shared object, example of out-of-tree provider
+loaded with providerPath. Build with go build -buildmode=plugin.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package main
+
+import (
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers/vmware"
+)
+
+//
+//  Main entry point for plugin
+//
+func Init() (jettypes.VimPlugin, error) {
+	return vmware.Init()
+}
@@ -0,0 +1,49 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Vmware provider as a separate executable, reference out-of-process provider
+jettison launches with providerCommand and talks to over stdin and stdout.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package main
+
+import (
+	"log"
+
+	"github.com/spyroot/jettison/providers"
+	"github.com/spyroot/jettison/providers/remote"
+	"github.com/spyroot/jettison/providers/vmware"
+)
+
+func main() {
+
+	plugin, err := vmware.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = remote.Serve(vmware.ProviderName, plugin,
+		providers.CapSegments,
+		providers.CapDhcpBindings,
+		providers.CapStaticRoutes,
+		providers.CapAffinityRules,
+		providers.CapLinkedClones)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,464 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spyroot/jettison/jettypes"
+)
+
+/**
+  Sets uuid, vim name, mac and networks of a template to a node,
+  same as vmware provider does.
+*/
+func (p *FakeVim) DiscoverVmTemplate(ctx context.Context, node *jettypes.NodeTemplate) error {
+
+	if node == nil {
+		return fmt.Errorf("node is nil")
+	}
+	if err := p.called("DiscoverVmTemplate", node.VmTemplateName); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	t, err := p.template(node.VmTemplateName)
+	if err != nil {
+		return err
+	}
+
+	if node.UUID == "" {
+		node.UUID = t.uuid
+	}
+	node.SetVimName(t.vimName)
+	node.Mac = append(node.Mac, t.mac)
+	node.NetworksRef = append(node.NetworksRef, t.networks...)
+
+	return nil
+}
+
+// Attaches a template of a node to a node switch.
+func (p *FakeVim) ConnectVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) (bool, error) {
+
+	if err := p.called("ConnectVm", node.Name); err != nil {
+		return false, err
+	}
+	if len(switchUuid(node)) == 0 {
+		return false, fmt.Errorf("node %s has no switch", node.Name)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	s, ok := p.switches[node.SwitchUuid()]
+	if !ok {
+		return false, fmt.Errorf("invalid logical switch: %s", node.SwitchUuid())
+	}
+
+	t, err := p.template(node.VmTemplateName)
+	if err != nil {
+		return false, err
+	}
+	if !contains(t.networks, s.name) {
+		t.networks = append(t.networks, s.name)
+	}
+
+	return true, nil
+}
+
+// Detaches a vm or a template of a node from all networks.
+func (p *FakeVim) DisconnectVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) (bool, error) {
+
+	if err := p.called("DisconnectVm", node.Name); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if node.IsTemplate() {
+		t, ok := p.templates[node.VmTemplateName]
+		if !ok {
+			return false, fmt.Errorf("vm %s not found", node.VmTemplateName)
+		}
+		t.networks = nil
+		return true, nil
+	}
+
+	v, ok := p.vms[node.Name]
+	if !ok {
+		return false, fmt.Errorf("vm %s not found", node.Name)
+	}
+	v.info.PoweredOn = false
+	v.info.Networks = nil
+	v.info.SwitchUuids = nil
+
+	return true, nil
+}
+
+/**
+  Clones each node from a template. Folder, hardware and clone mode of each
+  node checked before any vm cloned, each clone reported as a result per node.
+*/
+func (p *FakeVim) CloneVms(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
+
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	folderUuid := uuid.New().String()
+	for _, node := range nodes {
+		if err := p.DiscoverVmTemplate(ctx, node); err != nil {
+			return nil, err
+		}
+		folder, err := node.RenderFolder(projectName, folderUuid)
+		if err != nil {
+			return nil, err
+		}
+		node.SetFolderPath(folder)
+		if err = node.ValidateCloneMode(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.lock.Lock()
+	for _, node := range nodes {
+		p.folders[node.GetFolderPath()] = true
+		if node.LinkedClone() {
+			p.templates[node.VmTemplateName].snapshots[node.SnapshotName()] = true
+		}
+	}
+	p.lock.Unlock()
+
+	results := p.pool.RunNodes(ctx, jettypes.CloneJob, jettypes.OpClone, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			taskId, err := p.cloneVm(projectName, node)
+			return jettypes.NodeResult{TaskId: taskId, Err: err}
+		})
+
+	return results, results.Err()
+}
+
+// Clones a single vm, returns a reference of clone task.
+func (p *FakeVim) cloneVm(projectName string, node *jettypes.NodeTemplate) (string, error) {
+
+	if err := p.called("CloneVms", node.Name); err != nil {
+		return "", err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	taskId := p.nextRef("task")
+	if _, ok := p.vms[node.Name]; ok {
+		return taskId, fmt.Errorf("vm %s already exists", node.Name)
+	}
+
+	t := p.templates[node.VmTemplateName]
+	v := &vm{
+		info: jettypes.VmInfo{
+			Name:     node.Name,
+			Exists:   true,
+			UUID:     uuid.New().String(),
+			VimName:  p.nextRef("vm"),
+			Cluster:  node.VimCluster,
+			Mac:      []string{p.nextMac()},
+			Networks: append([]string(nil), t.networks...),
+		},
+		project: projectName,
+		folder:  node.GetFolderPath(),
+	}
+	if len(switchUuid(node)) > 0 {
+		v.info.SwitchUuids = []string{switchUuid(node)}
+	}
+
+	// static node addressed by guest customization
+	if node.Static {
+		v.ipv4 = node.IPv4AddrStr
+	}
+
+	p.vms[node.Name] = v
+
+	return taskId, nil
+}
+
+/**
+  Replaces mac of a template with mac of a vm, sets uuid, vim name and
+  networks of each cloned node.
+*/
+func (p *FakeVim) DiscoverVms(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	for _, node := range nodes {
+		if err := p.called("DiscoverVms", node.Name); err != nil {
+			return err
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, node := range nodes {
+
+		v, ok := p.vms[node.Name]
+		if !ok {
+			return fmt.Errorf("vm not found")
+		}
+
+		// since we cloned a VM old mac belong to a template
+		if len(node.Mac) > 0 {
+			node.Mac = node.Mac[1:]
+		}
+		node.Mac = append(node.Mac, v.info.Mac...)
+		node.UUID = v.info.UUID
+		node.SetVimName(v.info.VimName)
+		node.NetworksRef = append(node.NetworksRef, v.info.Networks...)
+	}
+
+	return nil
+}
+
+// Returns a copy of a vm state, address reported only while a vm is powered on.
+func (p *FakeVim) describe(v *vm) *jettypes.VmInfo {
+
+	info := v.info
+	info.Mac = append([]string(nil), v.info.Mac...)
+	info.Networks = append([]string(nil), v.info.Networks...)
+	info.SwitchUuids = append([]string(nil), v.info.SwitchUuids...)
+	info.Tags = append([]string(nil), v.info.Tags...)
+	info.IPv4Addr = ""
+	if v.info.PoweredOn {
+		info.IPv4Addr = p.vmAddress(v)
+	}
+
+	return &info
+}
+
+func (p *FakeVim) DescribeVm(ctx context.Context, node *jettypes.NodeTemplate) (*jettypes.VmInfo, error) {
+
+	if err := p.called("DescribeVm", node.Name); err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	v, ok := p.vms[node.Name]
+	if !ok {
+		return &jettypes.VmInfo{Name: node.Name}, nil
+	}
+
+	return p.describe(v), nil
+}
+
+// Returns each vm in a folder sorted by name.
+func (p *FakeVim) DiscoverFolder(ctx context.Context, folder string) ([]*jettypes.VmInfo, error) {
+
+	if err := p.called("DiscoverFolder", folder); err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.folders[folder] {
+		return nil, fmt.Errorf("failed find folder %s", folder)
+	}
+
+	var infos []*jettypes.VmInfo
+	for _, v := range p.vms {
+		if v.folder == folder {
+			infos = append(infos, p.describe(v))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos, nil
+}
+
+// Destroys a vm, caller holds a lock.
+func (p *FakeVim) destroyVm(name string) (string, error) {
+
+	if _, ok := p.vms[name]; !ok {
+		return "", fmt.Errorf("vm %s not found", name)
+	}
+	delete(p.vms, name)
+
+	return p.nextRef("task"), nil
+}
+
+func (p *FakeVim) DeleteVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) error {
+
+	if err := p.called("DeleteVm", node.Name); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, err := p.destroyVm(node.Name)
+	return err
+}
+
+// Deletes a folder only if it is empty, same as vmware provider.
+func (p *FakeVim) DeleteFolder(ctx context.Context, projectName string, folder string) error {
+
+	if err := p.called("DeleteFolder", folder); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.deleteFolder(folder)
+
+	return nil
+}
+
+// Deletes an empty folder, caller holds a lock.
+func (p *FakeVim) deleteFolder(folder string) {
+	for _, v := range p.vms {
+		if v.folder == folder {
+			return
+		}
+	}
+	delete(p.folders, folder)
+}
+
+// Destroys each vm and then each folder of nodes that is left empty.
+func (p *FakeVim) ComputeCleanup(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
+
+	results := p.pool.RunNodes(ctx, jettypes.CloneJob, jettypes.OpDestroy, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			if err := p.called("ComputeCleanup", node.Name); err != nil {
+				return jettypes.NodeResult{Err: err}
+			}
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			// vm already gone counts as destroyed, same as vmware provider
+			if _, ok := p.vms[node.Name]; !ok {
+				return jettypes.NodeResult{}
+			}
+			taskId, err := p.destroyVm(node.Name)
+			return jettypes.NodeResult{TaskId: taskId, Err: err}
+		})
+
+	p.lock.Lock()
+	for _, node := range nodes {
+		if len(node.GetFolderPath()) > 0 {
+			p.deleteFolder(node.GetFolderPath())
+		}
+	}
+	p.lock.Unlock()
+
+	return results, results.Err()
+}
+
+func (p *FakeVim) ChangePowerState(ctx context.Context, node *jettypes.NodeTemplate, state jettypes.PowerState) (bool, error) {
+
+	if err := p.called("ChangePowerState", node.Name); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	v, ok := p.vms[node.Name]
+	if !ok {
+		return false, fmt.Errorf("vm %s not found", node.Name)
+	}
+
+	switch state {
+	case jettypes.PowerOn:
+		v.info.PoweredOn = true
+	case jettypes.PowerOff:
+		v.info.PoweredOn = false
+	case jettypes.Reboot:
+		if !v.info.PoweredOn {
+			return false, fmt.Errorf("vm %s is powered off, guest can't reboot", node.Name)
+		}
+	case jettypes.Reset:
+		v.info.PoweredOn = true
+	default:
+		return false, fmt.Errorf("unkown command")
+	}
+
+	return true, nil
+}
+
+/**
+  Returns an address of a powered on vm once ip delay of a node passed.
+  Static node reports own address, other vm an address of a dhcp binding
+  of vm mac. Vm without address reported with false.
+*/
+func (p *FakeVim) AcquireIpAddress(ctx context.Context, node *jettypes.NodeTemplate) (bool, string, error) {
+
+	if len(node.Name) == 0 || len(node.VimCluster) == 0 {
+		return false, "", nil
+	}
+	if err := p.called("AcquireIpAddress", node.Name); err != nil {
+		return false, "", err
+	}
+
+	p.lock.Lock()
+	delay, ok := p.ipDelays[node.Name]
+	if !ok {
+		delay = p.ipDelays[""]
+	}
+	p.lock.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return false, "", fmt.Errorf("failed to acquire ip address, request timeout")
+		}
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	v, ok := p.vms[node.Name]
+	if !ok {
+		return false, "", fmt.Errorf("failed find a vm %s", node.Name)
+	}
+	if !v.info.PoweredOn {
+		return false, "", nil
+	}
+
+	ip := p.vmAddress(v)
+	if len(ip) == 0 {
+		return false, "", nil
+	}
+
+	return true, ip, nil
+}
+
+// Returns an address a guest of a vm reports, caller holds a lock.
+func (p *FakeVim) vmAddress(v *vm) string {
+
+	if len(v.ipv4) > 0 {
+		return v.ipv4
+	}
+
+	for _, mac := range v.info.Mac {
+		if _, b := p.findBinding(mac); b != nil {
+			return b.ip
+		}
+	}
+
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,691 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spyroot/jettison/jettypes"
+)
+
+// a logical switch of a segment
+type fakeSwitch struct {
+	id         string
+	name       string
+	project    string
+	segment    string
+	gateway    string
+	prefixLen  int
+	dhcpUuid   string
+	routerUuid string
+	// logical port of a switch router downlink attached to
+	portUuid string
+	created  time.Time
+}
+
+// a tier 1 router, static routes keyed by a network hold a next hop
+type fakeRouter struct {
+	id      string
+	name    string
+	project string
+	ports   map[string]*fakeObject
+	routes  map[string]string
+	created time.Time
+}
+
+type fakeBinding struct {
+	id      string
+	mac     string
+	ip      string
+	host    string
+	project string
+	created time.Time
+}
+
+type fakeDhcpServer struct {
+	id         string
+	name       string
+	project    string
+	switchUuid string
+	profileId  string
+	dnsServers []string
+	domain     string
+	bindings   map[string]*fakeBinding
+	created    time.Time
+}
+
+// any other object tagged with a project
+type fakeObject struct {
+	id      string
+	name    string
+	project string
+	parent  string
+	created time.Time
+}
+
+// Returns uuid of a node switch, node without a switch has empty uuid.
+func switchUuid(node *jettypes.NodeTemplate) string {
+	if node.GenericSwitch() == nil {
+		return ""
+	}
+	return node.SwitchUuid()
+}
+
+// Returns uuid of a node dhcp server, node without a switch has empty uuid.
+func dhcpUuid(node *jettypes.NodeTemplate) string {
+	if node.GenericSwitch() == nil {
+		return ""
+	}
+	return node.DhcpServerUuid()
+}
+
+// Returns uuid of a node router, node without a router has empty uuid.
+func routerUuid(node *jettypes.NodeTemplate) string {
+	if node.GenericRouter() == nil {
+		return ""
+	}
+	return node.RouterUuid()
+}
+
+// Returns a segment as vim reports it, caller holds a lock.
+func (p *FakeVim) segment(s *fakeSwitch) (*jettypes.GenericSwitch, *jettypes.GenericRouter) {
+
+	logicalSwitch := jettypes.NewGenericSwitch(s.name, s.id, s.dhcpUuid, s.routerUuid)
+	logicalSwitch.SetRouterPortUuid(s.portUuid)
+
+	logicalRouter := jettypes.NewGenericRouter("", s.routerUuid)
+	if r, ok := p.routers[s.routerUuid]; ok {
+		logicalRouter.SetName(r.name)
+		for id, port := range r.ports {
+			if port.parent == s.id {
+				logicalRouter.SetSwitchPortUuid(id)
+			}
+		}
+	}
+
+	return logicalSwitch, logicalRouter
+}
+
+/**
+  Creates a switch, a router, a downlink port, a dhcp server and a dhcp profile
+  of a segment, dhcp server and profile only if asked. Segment of a project
+  deployed once, a second call returns existing segment.
+*/
+func (p *FakeVim) DeploySegment(ctx context.Context, projectName string, segmentName string,
+	gateway string, prefixLen int, dhcp bool, dnsServers []string, domain string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	if err := p.called("DeploySegment", segmentName); err != nil {
+		return nil, nil, err
+	}
+	if net.ParseIP(gateway) == nil {
+		return nil, nil, fmt.Errorf("invalid gateway format")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, s := range p.switches {
+		if s.project == projectName && s.segment == segmentName {
+			sw, router := p.segment(s)
+			return sw, router, nil
+		}
+	}
+
+	now := time.Now()
+	s := &fakeSwitch{
+		id:        uuid.New().String(),
+		name:      uuid.New().String(),
+		project:   projectName,
+		segment:   segmentName,
+		gateway:   gateway,
+		prefixLen: prefixLen,
+		portUuid:  uuid.New().String(),
+		created:   now,
+	}
+
+	r := &fakeRouter{
+		id:      uuid.New().String(),
+		name:    uuid.New().String(),
+		project: projectName,
+		ports:   make(map[string]*fakeObject),
+		routes:  make(map[string]string),
+		created: now,
+	}
+	downlink := &fakeObject{id: uuid.New().String(), name: projectName + "-" + segmentName,
+		project: projectName, parent: s.id, created: now}
+	r.ports[downlink.id] = downlink
+
+	s.routerUuid = r.id
+	p.switches[s.id] = s
+	p.routers[r.id] = r
+
+	if dhcp {
+		profile := &fakeObject{id: uuid.New().String(), name: projectName + "-" + segmentName,
+			project: projectName, created: now}
+		server := &fakeDhcpServer{
+			id:         uuid.New().String(),
+			name:       projectName + "-" + segmentName,
+			project:    projectName,
+			switchUuid: s.id,
+			profileId:  profile.id,
+			dnsServers: dnsServers,
+			domain:     domain,
+			bindings:   make(map[string]*fakeBinding),
+			created:    now,
+		}
+		s.dhcpUuid = server.id
+		p.dhcpServers[server.id] = server
+		p.profiles[profile.id] = profile
+	}
+
+	sw, router := p.segment(s)
+	return sw, router, nil
+}
+
+func (p *FakeVim) DescribeSegment(ctx context.Context, switchUuid string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	if err := p.called("DescribeSegment", switchUuid); err != nil {
+		return nil, nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	s, ok := p.switches[switchUuid]
+	if !ok {
+		return nil, nil, fmt.Errorf("can't find logical switch %s", switchUuid)
+	}
+
+	// segment without dhcp server reported with empty dhcp server uuid
+	sw, router := p.segment(s)
+	if _, ok := p.dhcpServers[s.dhcpUuid]; !ok {
+		sw.SetDhcpUuid("")
+	}
+
+	return sw, router, nil
+}
+
+/**
+  Adds a segment created outside of jettison, segment has no project tag.
+  Router and dhcp server created only if asked, returns a switch uuid.
+*/
+func (p *FakeVim) AddSegment(name string, router bool, dhcp bool) string {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	s := &fakeSwitch{id: uuid.New().String(), name: name, segment: name, created: now}
+	if router {
+		r := &fakeRouter{id: uuid.New().String(), name: name, ports: make(map[string]*fakeObject),
+			routes: make(map[string]string), created: now}
+		port := &fakeObject{id: uuid.New().String(), name: name, parent: s.id, created: now}
+		r.ports[port.id] = port
+		p.routers[r.id] = r
+		s.routerUuid = r.id
+		s.portUuid = uuid.New().String()
+	}
+	if dhcp {
+		server := &fakeDhcpServer{id: uuid.New().String(), name: name, switchUuid: s.id,
+			bindings: make(map[string]*fakeBinding), created: now}
+		p.dhcpServers[server.id] = server
+		s.dhcpUuid = server.id
+	}
+	p.switches[s.id] = s
+
+	return s.id
+}
+
+// Sets switch uuid and dhcp server uuid of each node, switch looked up by a name.
+func (p *FakeVim) DiscoverClusterDhcpServer(ctx context.Context, projectName string, nodes *[]*jettypes.NodeTemplate) (bool, error) {
+
+	if err := p.called("DiscoverClusterDhcpServer", ""); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, node := range *nodes {
+		if node.GenericSwitch() == nil {
+			return false, fmt.Errorf("node %s has no switch", node.Name)
+		}
+
+		var found *fakeSwitch
+		for _, s := range p.switches {
+			if s.name == node.GenericSwitch().Name() || s.id == node.GenericSwitch().Name() {
+				found = s
+				break
+			}
+		}
+		if found == nil {
+			return false, fmt.Errorf("can't find logical switch %s. please check configuration",
+				node.GenericSwitch().Name())
+		}
+		if _, ok := p.dhcpServers[found.dhcpUuid]; !ok {
+			return false, fmt.Errorf("can't find a dhcp server attached to logical switch %s", found.name)
+		}
+
+		node.GenericSwitch().SetUuid(found.id)
+		node.GenericSwitch().SetDhcpUuid(found.dhcpUuid)
+	}
+
+	return true, nil
+}
+
+/**
+  Creates a static binding of each node mac and address. An address bound
+  to another host is a dhcp conflict, a binding a node already has is kept.
+*/
+func (p *FakeVim) CreateDhcpBindings(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	for _, node := range nodes {
+		if err := p.called("CreateDhcpBindings", node.Name); err != nil {
+			return err
+		}
+		if err := p.createBinding(projectName, node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *FakeVim) createBinding(projectName string, node *jettypes.NodeTemplate) error {
+
+	if len(node.Mac) == 0 || len(node.Mac[0]) == 0 {
+		return fmt.Errorf("node has no mac address")
+	}
+	if node.IPv4Addr == nil {
+		return fmt.Errorf("node %s has no ip address", node.Name)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	server, ok := p.dhcpServers[dhcpUuid(node)]
+	if !ok {
+		return fmt.Errorf("failed find dhcp server %s", dhcpUuid(node))
+	}
+
+	ip := node.IPv4Addr.String()
+	for _, b := range server.bindings {
+		if b.mac == node.Mac[0] && b.ip == ip {
+			node.DhcpStatus = jettypes.Created
+			return nil
+		}
+		if b.ip != ip {
+			continue
+		}
+		if b.host != node.Name {
+			return fmt.Errorf("failed %s create binding another host dhcp conflict", ip)
+		}
+		// left over of a same node
+		node.DhcpStatus = jettypes.Created
+		return nil
+	}
+
+	b := &fakeBinding{
+		id:      uuid.New().String(),
+		mac:     node.Mac[0],
+		ip:      ip,
+		host:    node.Name,
+		project: projectName,
+		created: time.Now(),
+	}
+	server.bindings[b.id] = b
+	node.DhcpStatus = jettypes.Created
+
+	return nil
+}
+
+// Returns a binding of a mac and a server that holds it, caller holds a lock.
+func (p *FakeVim) findBinding(mac string) (*fakeDhcpServer, *fakeBinding) {
+	for _, server := range p.dhcpServers {
+		for _, b := range server.bindings {
+			if b.mac == mac {
+				return server, b
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (p *FakeVim) DescribeBinding(ctx context.Context, node *jettypes.NodeTemplate) (string, bool, error) {
+
+	if len(node.Mac) == 0 || len(node.Mac[0]) == 0 {
+		return "", false, fmt.Errorf("node has no mac address")
+	}
+	if err := p.called("DescribeBinding", node.Name); err != nil {
+		return "", false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	server, ok := p.dhcpServers[dhcpUuid(node)]
+	if !ok {
+		return "", false, fmt.Errorf("failed find dhcp server %s", dhcpUuid(node))
+	}
+	for _, b := range server.bindings {
+		if b.mac == node.Mac[0] {
+			return b.ip, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// Removes a binding of each node mac, node without a mac skipped.
+func (p *FakeVim) DhcpCleanup(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	for _, node := range nodes {
+		if err := p.called("DhcpCleanup", node.Name); err != nil {
+			return err
+		}
+		if len(node.Mac) == 0 {
+			continue
+		}
+
+		p.lock.Lock()
+		for _, mac := range node.Mac {
+			if server, b := p.findBinding(mac); b != nil {
+				delete(server.bindings, b.id)
+			}
+		}
+		p.lock.Unlock()
+	}
+
+	return nil
+}
+
+// Deletes a dhcp server of a node and a profile of a server.
+func (p *FakeVim) DeleteDhcpServer(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+
+	if err := p.called("DeleteDhcpServer", node.Name); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	server, ok := p.dhcpServers[dhcpUuid(node)]
+	if !ok {
+		return false, fmt.Errorf("failed find dhcp server %s", dhcpUuid(node))
+	}
+	delete(p.dhcpServers, server.id)
+	delete(p.profiles, server.profileId)
+
+	return true, nil
+}
+
+// Deletes a router of a node with all ports and static routes.
+func (p *FakeVim) DeleteRouter(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+
+	if err := p.called("DeleteRouter", node.Name); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.routers[routerUuid(node)]; !ok {
+		return false, fmt.Errorf("failed find logical router %s", routerUuid(node))
+	}
+	delete(p.routers, routerUuid(node))
+
+	return true, nil
+}
+
+// Deletes a downlink port of a node router, node without a router skipped.
+func (p *FakeVim) DeleteRouterPort(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+
+	if node.GenericRouter() == nil {
+		return false, nil
+	}
+	if err := p.called("DeleteRouterPort", node.Name); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	portUuid := node.GenericRouter().SwitchPortUuid()
+	for _, r := range p.routers {
+		if _, ok := r.ports[portUuid]; ok {
+			delete(r.ports, portUuid)
+			return true, nil
+		}
+	}
+
+	return false, fmt.Errorf("failed find router port %s", portUuid)
+}
+
+// Deletes a switch of a node.
+func (p *FakeVim) DeleteSwitch(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+
+	if err := p.called("DeleteSwitch", node.Name); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.switches[switchUuid(node)]; !ok {
+		return false, fmt.Errorf("failed find logical switch %s", switchUuid(node))
+	}
+	delete(p.switches, switchUuid(node))
+
+	return true, nil
+}
+
+/**
+  Adds a static route of a pod network via a node address to a node router.
+  Route that already exists with same next hop is kept, different next hop
+  is a conflict.
+*/
+func (p *FakeVim) AddStaticRoute(ctx context.Context, projectName string,
+	node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
+
+	if err := p.called("AddStaticRoute", node.Name); err != nil {
+		return false, err
+	}
+	if _, _, err := net.ParseCIDR(podNetwork); err != nil {
+		return false, fmt.Errorf("invalid pod network %s", podNetwork)
+	}
+	if node.IPv4Addr == nil {
+		return false, fmt.Errorf("node %s has no ip address", node.Name)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	r, ok := p.routers[routerUuid(node)]
+	if !ok {
+		return false, fmt.Errorf("failed find logical router %s", routerUuid(node))
+	}
+
+	nextHop := node.IPv4Addr.String()
+	if hop, ok := r.routes[podNetwork]; ok && hop != nextHop {
+		return false, fmt.Errorf("route %s already exists via %s", podNetwork, hop)
+	}
+	r.routes[podNetwork] = nextHop
+
+	return true, nil
+}
+
+// Deletes a static route of a pod network via node address, route that not found
+// or routed via other next hop reported with false.
+func (p *FakeVim) DeleteStaticRoute(ctx context.Context, projectName string,
+	node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
+
+	if err := p.called("DeleteStaticRoute", node.Name); err != nil {
+		return false, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	r, ok := p.routers[routerUuid(node)]
+	if !ok {
+		return false, fmt.Errorf("failed find logical router %s", routerUuid(node))
+	}
+	if hop, ok := r.routes[podNetwork]; !ok || hop != node.IPv4Addr.String() {
+		return false, nil
+	}
+	delete(r.routes, podNetwork)
+
+	return true, nil
+}
+
+// Returns static routes of a router, each route a network and a next hop.
+func (p *FakeVim) StaticRoutes(routerUuid string) map[string]string {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	routes := make(map[string]string)
+	if r, ok := p.routers[routerUuid]; ok {
+		for network, hop := range r.routes {
+			routes[network] = hop
+		}
+	}
+
+	return routes
+}
+
+// Returns name servers and a domain a dhcp server hands out, false if server not found.
+func (p *FakeVim) DhcpOptions(dhcpUuid string) ([]string, string, bool) {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	server, ok := p.dhcpServers[dhcpUuid]
+	if !ok {
+		return nil, "", false
+	}
+
+	return append([]string(nil), server.dnsServers...), server.domain, true
+}
+
+func (p *FakeVim) TaggedObjects(ctx context.Context, projectName string) ([]jettypes.NetworkObject, error) {
+
+	if err := p.called("TaggedObjects", ""); err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var objects []jettypes.NetworkObject
+	tagged := func(project string) bool {
+		return len(projectName) == 0 || project == projectName
+	}
+
+	for _, s := range p.switches {
+		if tagged(s.project) {
+			objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectSwitch, Id: s.id,
+				Name: s.name, Project: s.project, Created: s.created})
+		}
+	}
+	for _, r := range p.routers {
+		if tagged(r.project) {
+			objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectRouter, Id: r.id,
+				Name: r.name, Project: r.project, Created: r.created})
+		}
+		for _, port := range r.ports {
+			if tagged(port.project) {
+				objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectRouterPort, Id: port.id,
+					Name: port.name, Parent: r.id, Project: port.project, Created: port.created})
+			}
+		}
+	}
+	for _, s := range p.dhcpServers {
+		if tagged(s.project) {
+			objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectDhcpServer, Id: s.id,
+				Name: s.name, Project: s.project, Created: s.created})
+		}
+		for _, b := range s.bindings {
+			if tagged(b.project) {
+				objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectDhcpBinding, Id: b.id,
+					Name: b.host, Parent: s.id, Project: b.project, Created: b.created})
+			}
+		}
+	}
+	for _, v := range p.profiles {
+		if tagged(v.project) {
+			objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectDhcpProfile, Id: v.id,
+				Name: v.name, Project: v.project, Created: v.created})
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Kind != objects[j].Kind {
+			return objects[i].Kind < objects[j].Kind
+		}
+		return objects[i].Id < objects[j].Id
+	})
+
+	return objects, nil
+}
+
+// Deletes a single object returned by TaggedObjects, object that not found is an error.
+func (p *FakeVim) DeleteObject(ctx context.Context, object jettypes.NetworkObject) error {
+
+	if err := p.called("DeleteObject", object.Id); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	notFound := fmt.Errorf("%s %s not found", object.Kind, object.Id)
+
+	switch object.Kind {
+	case jettypes.ObjectDhcpBinding:
+		s, ok := p.dhcpServers[object.Parent]
+		if !ok {
+			return notFound
+		}
+		if _, ok := s.bindings[object.Id]; !ok {
+			return notFound
+		}
+		delete(s.bindings, object.Id)
+	case jettypes.ObjectDhcpServer:
+		if _, ok := p.dhcpServers[object.Id]; !ok {
+			return notFound
+		}
+		delete(p.dhcpServers, object.Id)
+	case jettypes.ObjectDhcpProfile:
+		if _, ok := p.profiles[object.Id]; !ok {
+			return notFound
+		}
+		delete(p.profiles, object.Id)
+	case jettypes.ObjectRouterPort:
+		r, ok := p.routers[object.Parent]
+		if !ok {
+			return notFound
+		}
+		if _, ok := r.ports[object.Id]; !ok {
+			return notFound
+		}
+		delete(r.ports, object.Id)
+	case jettypes.ObjectRouter:
+		if _, ok := p.routers[object.Id]; !ok {
+			return notFound
+		}
+		delete(p.routers, object.Id)
+	case jettypes.ObjectSwitch:
+		if _, ok := p.switches[object.Id]; !ok {
+			return notFound
+		}
+		delete(p.switches, object.Id)
+	default:
+		return fmt.Errorf("unknown object kind %s", object.Kind)
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+In-memory vim provider. Fake keeps vms, folders, segments, dhcp bindings,
+static routes and drs rules in memory and follows semantics of vmware provider,
+so tests run jettison end-to-end without vCenter or nsx-t. Faults and ip address
+delays injected per method and per node. Fake is not linked into jettison binary,
+only tests import it.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers"
+)
+
+// name fake registers with once a test imports it
+const ProviderName = "fake"
+
+// vmware oui, generated mac addresses look like vCenter assigned them
+const macPrefix = "00:50:56"
+
+// network every template attached to
+const DefaultNetwork = "VM Network"
+
+var _ jettypes.VimPlugin = (*FakeVim)(nil)
+
+func init() {
+	providers.Register(ProviderName, Init)
+}
+
+/*
+  A fault injected into a method. Empty node matches every node, fault
+  with zero times fires on each call, otherwise only given number of times.
+*/
+type Fault struct {
+	Method string
+	Node   string
+	Err    error
+	Times  int
+}
+
+type vmTemplate struct {
+	name      string
+	uuid      string
+	vimName   string
+	mac       string
+	networks  []string
+	snapshots map[string]bool
+}
+
+type vm struct {
+	info    jettypes.VmInfo
+	project string
+	folder  string
+	// address guest reports once powered on, empty takes dhcp binding of vm mac
+	ipv4 string
+}
+
+type FakeVim struct {
+	lock sync.Mutex
+
+	pool     *jettypes.WorkerPool
+	endpoint jettypes.VimEndpoint
+
+	templates map[string]*vmTemplate
+	vms       map[string]*vm
+	folders   map[string]bool
+
+	switches    map[string]*fakeSwitch
+	routers     map[string]*fakeRouter
+	dhcpServers map[string]*fakeDhcpServer
+	profiles    map[string]*fakeObject
+
+	hostGroups map[string]bool
+	rules      map[string]*jettypes.AffinityRule
+
+	faults   []*Fault
+	ipDelays map[string]time.Duration
+	calls    []string
+
+	// sequence of generated mac addresses and vim references
+	seq int
+}
+
+// Creates an empty fake, vm template created on a first lookup.
+func New() *FakeVim {
+	return &FakeVim{
+		pool:        jettypes.NewWorkerPool(jettypes.DefaultParallelJobs, nil),
+		templates:   make(map[string]*vmTemplate),
+		vms:         make(map[string]*vm),
+		folders:     make(map[string]bool),
+		switches:    make(map[string]*fakeSwitch),
+		routers:     make(map[string]*fakeRouter),
+		dhcpServers: make(map[string]*fakeDhcpServer),
+		profiles:    make(map[string]*fakeObject),
+		hostGroups:  make(map[string]bool),
+		rules:       make(map[string]*jettypes.AffinityRule),
+		ipDelays:    make(map[string]time.Duration),
+	}
+}
+
+// Factory of a provider registry.
+func Init() (jettypes.VimPlugin, error) {
+	return New(), nil
+}
+
+func (p *FakeVim) SetWorkerPool(pool *jettypes.WorkerPool) {
+	if pool != nil {
+		p.pool = pool
+	}
+}
+
+func (p *FakeVim) InitPlugin(ctx context.Context, endpoint jettypes.VimEndpoint) error {
+
+	if endpoint == nil {
+		return fmt.Errorf("can't initilize plugin with nil arguments")
+	}
+	if err := p.called("InitPlugin", ""); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	p.endpoint = endpoint
+	p.lock.Unlock()
+
+	return nil
+}
+
+// Injects a fault, faults checked in order they injected.
+func (p *FakeVim) InjectFault(fault Fault) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	f := fault
+	p.faults = append(p.faults, &f)
+}
+
+// Removes all injected faults.
+func (p *FakeVim) ClearFaults() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.faults = nil
+}
+
+// Sets a time a vm takes to report ip address, empty node sets a default of all vms.
+func (p *FakeVim) SetIpDelay(node string, delay time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.ipDelays[node] = delay
+}
+
+// Returns calls in order fake received them, each call is a method and a node if call has one.
+func (p *FakeVim) Calls() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+/*
+  Records a call and returns a fault injected for a method and a node.
+  Caller must not hold a lock.
+*/
+func (p *FakeVim) called(method string, node string) error {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	call := method
+	if len(node) > 0 {
+		call += " " + node
+	}
+	p.calls = append(p.calls, call)
+
+	for i, f := range p.faults {
+		if f.Method != method || (len(f.Node) > 0 && f.Node != node) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				p.faults = append(p.faults[:i], p.faults[i+1:]...)
+			}
+		}
+		return f.Err
+	}
+
+	return nil
+}
+
+// Returns next generated mac address, caller holds a lock.
+func (p *FakeVim) nextMac() string {
+	p.seq++
+	return fmt.Sprintf("%s:%02x:%02x:%02x", macPrefix, (p.seq>>16)&0xff, (p.seq>>8)&0xff, p.seq&0xff)
+}
+
+// Returns next vim reference of a kind, caller holds a lock.
+func (p *FakeVim) nextRef(kind string) string {
+	p.seq++
+	return fmt.Sprintf("%s-%d", kind, p.seq)
+}
+
+// Returns a template, template created on a first lookup. Caller holds a lock.
+func (p *FakeVim) template(name string) (*vmTemplate, error) {
+
+	if len(name) == 0 {
+		return nil, fmt.Errorf("failed to retrieve vm template. err: template name is empty")
+	}
+
+	t, ok := p.templates[name]
+	if !ok {
+		t = &vmTemplate{
+			name:      name,
+			uuid:      uuid.New().String(),
+			vimName:   p.nextRef("vm"),
+			mac:       p.nextMac(),
+			networks:  []string{DefaultNetwork},
+			snapshots: make(map[string]bool),
+		}
+		p.templates[name] = t
+	}
+
+	return t, nil
+}
+
+/*
+  Adds a vm jettison didn't create, such as a vm of a deployment
+  created by hand. Vm gets generated uuid, reference and mac.
+*/
+func (p *FakeVim) AddVm(folder string, info jettypes.VmInfo) error {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.vms[info.Name]; ok {
+		return fmt.Errorf("vm %s already exists", info.Name)
+	}
+
+	info.Exists = true
+	if len(info.UUID) == 0 {
+		info.UUID = uuid.New().String()
+	}
+	if len(info.VimName) == 0 {
+		info.VimName = p.nextRef("vm")
+	}
+	if len(info.Mac) == 0 {
+		info.Mac = []string{p.nextMac()}
+	}
+
+	p.vms[info.Name] = &vm{info: info, folder: folder, ipv4: info.IPv4Addr}
+	if len(folder) > 0 {
+		p.folders[folder] = true
+	}
+
+	return nil
+}
+
+// Adds a host group of a cluster drs rules of kind host-group refer to.
+func (p *FakeVim) AddHostGroup(cluster string, name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.hostGroups[cluster+"/"+name] = true
+}
+
+// Returns drs rules sorted by name.
+func (p *FakeVim) AffinityRules() []*jettypes.AffinityRule {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var rules []*jettypes.AffinityRule
+	for _, r := range p.rules {
+		rule := *r
+		rules = append(rules, &rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+
+	return rules
+}
+
+// Returns names of snapshots of a template.
+func (p *FakeVim) Snapshots(vmTemplate string) []string {
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var names []string
+	if t, ok := p.templates[vmTemplate]; ok {
+		for name := range t.snapshots {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (p *FakeVim) CreateAffinityRule(ctx context.Context, rule *jettypes.AffinityRule) error {
+
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	if err := p.called("CreateAffinityRule", rule.Name); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if rule.Kind == jettypes.AffinityHostGroup && !p.hostGroups[rule.Cluster+"/"+rule.HostGroup] {
+		return fmt.Errorf("host group %s not found in cluster %s", rule.HostGroup, rule.Cluster)
+	}
+	// rule refers to vms by vim name
+	vimNames := make(map[string]bool)
+	for _, v := range p.vms {
+		vimNames[v.info.VimName] = true
+	}
+	for _, name := range rule.Vms {
+		if !vimNames[name] {
+			return fmt.Errorf("vm %s of drs rule %s not found", name, rule.Name)
+		}
+	}
+
+	r := *rule
+	r.Vms = append([]string(nil), rule.Vms...)
+	p.rules[rule.Cluster+"/"+rule.Name] = &r
+
+	return nil
+}
+
+func (p *FakeVim) DeleteAffinityRule(ctx context.Context, rule *jettypes.AffinityRule) error {
+
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	if err := p.called("DeleteAffinityRule", rule.Name); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.rules, rule.Cluster+"/"+rule.Name)
+
+	return nil
+}
+
+func (p *FakeVim) DeleteTemplateSnapshot(ctx context.Context, vmTemplate string, snapshot string) error {
+
+	if err := p.called("DeleteTemplateSnapshot", vmTemplate); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	t, ok := p.templates[vmTemplate]
+	if !ok {
+		return fmt.Errorf("failed find template %s", vmTemplate)
+	}
+	delete(t.snapshots, snapshot)
+
+	return nil
+}
@@ -0,0 +1,400 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers"
+)
+
+const project = "test"
+
+func newNode(name string, ip string) *jettypes.NodeTemplate {
+	node := &jettypes.NodeTemplate{
+		Name:           name,
+		Prefix:         "worker",
+		VmTemplateName: "ubuntu",
+		VimCluster:     "cluster",
+		IPv4AddrStr:    ip,
+		IPv4Addr:       net.ParseIP(ip),
+	}
+	return node
+}
+
+// Deploys a segment and clones nodes attached to it.
+func deploy(t *testing.T, p *FakeVim, nodes ...*jettypes.NodeTemplate) {
+
+	ctx := context.Background()
+	sw, router, err := p.DeploySegment(ctx, project, "segment", "172.16.81.1", 24, true, nil, "")
+	if err != nil {
+		t.Fatalf("DeploySegment() error = %v", err)
+	}
+	for _, node := range nodes {
+		node.SetGenericSwitch(jettypes.NewGenericSwitch(sw.Name(), sw.Uuid(), sw.DhcpUuid(), sw.RouterUuid()))
+		node.SetGenericRouter(jettypes.NewGenericRouter(router.Name(), router.Uuid()))
+		node.GenericRouter().SetSwitchPortUuid(router.SwitchPortUuid())
+	}
+
+	if _, err := p.CloneVms(ctx, project, nodes); err != nil {
+		t.Fatalf("CloneVms() error = %v", err)
+	}
+	if err := p.DiscoverVms(ctx, project, nodes); err != nil {
+		t.Fatalf("DiscoverVms() error = %v", err)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	plugin, err := providers.New(ProviderName)
+	if err != nil {
+		t.Fatalf("New(%s) error = %v", ProviderName, err)
+	}
+	if _, ok := plugin.(*FakeVim); !ok {
+		t.Errorf("New(%s) = %T", ProviderName, plugin)
+	}
+}
+
+func TestCloneAndDiscover(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	a, b := newNode("worker-1", "172.16.81.10"), newNode("worker-2", "172.16.81.11")
+	deploy(t, p, a, b)
+
+	if len(a.Mac) != 1 || len(b.Mac) != 1 || a.Mac[0] == b.Mac[0] {
+		t.Fatalf("mac addresses of clones %v %v", a.Mac, b.Mac)
+	}
+	if !strings.HasPrefix(a.Mac[0], macPrefix) {
+		t.Errorf("mac %s expected prefix %s", a.Mac[0], macPrefix)
+	}
+	if len(a.UUID) == 0 || a.UUID == b.UUID || a.GetVimName() == b.GetVimName() {
+		t.Errorf("uuid and vim name of clones %s %s %s %s", a.UUID, b.UUID, a.GetVimName(), b.GetVimName())
+	}
+
+	vms, err := p.DiscoverFolder(ctx, a.GetFolderPath())
+	if err != nil {
+		t.Fatalf("DiscoverFolder() error = %v", err)
+	}
+	if len(vms) != 2 || vms[0].Name != "worker-1" || vms[1].Name != "worker-2" {
+		t.Errorf("DiscoverFolder() = %v", vms)
+	}
+
+	// clone of existing vm fails
+	c := newNode("worker-1", "172.16.81.12")
+	results, err := p.CloneVms(ctx, project, []*jettypes.NodeTemplate{c})
+	if err == nil || len(results) != 1 || results[0].Ok() {
+		t.Errorf("CloneVms() of existing vm results = %v error = %v", results, err)
+	}
+
+	info, err := p.DescribeVm(ctx, newNode("missing", ""))
+	if err != nil || info.Exists {
+		t.Errorf("DescribeVm() of missing vm = %v error = %v", info, err)
+	}
+}
+
+func TestDhcpBindings(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	a, b := newNode("worker-1", "172.16.81.10"), newNode("worker-2", "172.16.81.10")
+	deploy(t, p, a, b)
+
+	if err := p.CreateDhcpBindings(ctx, project, []*jettypes.NodeTemplate{a}); err != nil {
+		t.Fatalf("CreateDhcpBindings() error = %v", err)
+	}
+	if a.DhcpStatus != jettypes.Created {
+		t.Errorf("DhcpStatus = %v, want created", a.DhcpStatus)
+	}
+
+	// binding that node already has is kept
+	if err := p.CreateDhcpBindings(ctx, project, []*jettypes.NodeTemplate{a}); err != nil {
+		t.Errorf("CreateDhcpBindings() of existing binding error = %v", err)
+	}
+
+	// same address for another host
+	err := p.CreateDhcpBindings(ctx, project, []*jettypes.NodeTemplate{b})
+	if err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Errorf("CreateDhcpBindings() error = %v, want dhcp conflict", err)
+	}
+
+	ip, ok, err := p.DescribeBinding(ctx, a)
+	if err != nil || !ok || ip != "172.16.81.10" {
+		t.Errorf("DescribeBinding() = %s %v %v", ip, ok, err)
+	}
+
+	if err := p.DhcpCleanup(ctx, project, []*jettypes.NodeTemplate{a}); err != nil {
+		t.Fatalf("DhcpCleanup() error = %v", err)
+	}
+	if _, ok, _ := p.DescribeBinding(ctx, a); ok {
+		t.Errorf("DescribeBinding() found binding after cleanup")
+	}
+}
+
+func TestAcquireIpAddress(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	node := newNode("worker-1", "172.16.81.10")
+	deploy(t, p, node)
+
+	// powered off vm has no address
+	ok, _, err := p.AcquireIpAddress(ctx, node)
+	if ok || err != nil {
+		t.Errorf("AcquireIpAddress() of powered off vm = %v %v", ok, err)
+	}
+
+	if _, err := p.ChangePowerState(ctx, node, jettypes.PowerOn); err != nil {
+		t.Fatalf("ChangePowerState() error = %v", err)
+	}
+
+	// no dhcp binding yet
+	ok, _, _ = p.AcquireIpAddress(ctx, node)
+	if ok {
+		t.Errorf("AcquireIpAddress() of vm without binding = true")
+	}
+
+	if err := p.CreateDhcpBindings(ctx, project, []*jettypes.NodeTemplate{node}); err != nil {
+		t.Fatalf("CreateDhcpBindings() error = %v", err)
+	}
+	ok, ip, err := p.AcquireIpAddress(ctx, node)
+	if !ok || ip != "172.16.81.10" || err != nil {
+		t.Errorf("AcquireIpAddress() = %v %s %v", ok, ip, err)
+	}
+
+	// delay longer than a deadline
+	p.SetIpDelay(node.Name, time.Second)
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.AcquireIpAddress(tctx, node); err == nil {
+		t.Errorf("AcquireIpAddress() expected timeout")
+	}
+
+	p.SetIpDelay(node.Name, 10*time.Millisecond)
+	if ok, _, err := p.AcquireIpAddress(ctx, node); !ok || err != nil {
+		t.Errorf("AcquireIpAddress() after delay = %v %v", ok, err)
+	}
+}
+
+func TestStaticNode(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	node := newNode("controller-1", "172.16.81.5")
+	node.Static = true
+	deploy(t, p, node)
+
+	if _, err := p.ChangePowerState(ctx, node, jettypes.PowerOn); err != nil {
+		t.Fatalf("ChangePowerState() error = %v", err)
+	}
+	ok, ip, err := p.AcquireIpAddress(ctx, node)
+	if !ok || ip != "172.16.81.5" || err != nil {
+		t.Errorf("AcquireIpAddress() of static node = %v %s %v", ok, ip, err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	a, b := newNode("worker-1", "172.16.81.10"), newNode("worker-2", "172.16.81.11")
+
+	p.InjectFault(Fault{Method: "CloneVms", Node: "worker-2", Err: fmt.Errorf("no space left"), Times: 1})
+
+	if _, _, err := p.DeploySegment(ctx, project, "segment", "172.16.81.1", 24, true, nil, ""); err != nil {
+		t.Fatalf("DeploySegment() error = %v", err)
+	}
+	results, err := p.CloneVms(ctx, project, []*jettypes.NodeTemplate{a, b})
+	if _, ok := err.(*jettypes.MultiError); !ok {
+		t.Fatalf("CloneVms() error = %v, want MultiError", err)
+	}
+	if !results[0].Ok() || results[1].Ok() {
+		t.Errorf("CloneVms() results = %v", results)
+	}
+
+	// fault fired once, retry succeeds
+	b.Mac = nil
+	if _, err := p.CloneVms(ctx, project, []*jettypes.NodeTemplate{b}); err != nil {
+		t.Errorf("CloneVms() retry error = %v", err)
+	}
+
+	p.InjectFault(Fault{Method: "DescribeVm", Err: fmt.Errorf("vcenter unavailable")})
+	for i := 0; i < 2; i++ {
+		if _, err := p.DescribeVm(ctx, a); err == nil {
+			t.Errorf("DescribeVm() expected injected fault")
+		}
+	}
+	p.ClearFaults()
+	if _, err := p.DescribeVm(ctx, a); err != nil {
+		t.Errorf("DescribeVm() error = %v after ClearFaults", err)
+	}
+
+	calls := p.Calls()
+	if calls[len(calls)-1] != "DescribeVm worker-1" {
+		t.Errorf("Calls() last = %s", calls[len(calls)-1])
+	}
+}
+
+func TestCleanup(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	a, b := newNode("worker-1", "172.16.81.10"), newNode("worker-2", "172.16.81.11")
+	deploy(t, p, a, b)
+
+	podNetwork := "10.200.1.0/24"
+	if ok, err := p.AddStaticRoute(ctx, project, a, podNetwork); !ok || err != nil {
+		t.Fatalf("AddStaticRoute() = %v %v", ok, err)
+	}
+	if _, err := p.AddStaticRoute(ctx, project, b, podNetwork); err == nil {
+		t.Errorf("AddStaticRoute() expected error of route via another next hop")
+	}
+	if routes := p.StaticRoutes(a.RouterUuid()); routes[podNetwork] != "172.16.81.10" {
+		t.Errorf("StaticRoutes() = %v", routes)
+	}
+
+	// folder with vms kept
+	if err := p.DeleteFolder(ctx, project, a.GetFolderPath()); err != nil {
+		t.Fatalf("DeleteFolder() error = %v", err)
+	}
+	if _, err := p.DiscoverFolder(ctx, a.GetFolderPath()); err != nil {
+		t.Errorf("DiscoverFolder() error = %v, folder with vms deleted", err)
+	}
+
+	results, err := p.ComputeCleanup(ctx, project, []*jettypes.NodeTemplate{a, b})
+	if err != nil || len(results) != 2 {
+		t.Fatalf("ComputeCleanup() = %v error = %v", results, err)
+	}
+	if _, err := p.DiscoverFolder(ctx, a.GetFolderPath()); err == nil {
+		t.Errorf("DiscoverFolder() expected error, empty folder kept")
+	}
+	if results, err := p.ComputeCleanup(ctx, project, []*jettypes.NodeTemplate{a}); err != nil || len(results.Failed()) > 0 {
+		t.Errorf("ComputeCleanup() of deleted vm = %v error = %v", results, err)
+	}
+	if err := p.DeleteVm(ctx, project, a); err == nil {
+		t.Errorf("DeleteVm() expected error of deleted vm")
+	}
+
+	other := *a
+	other.IPv4Addr = net.ParseIP("172.16.81.99")
+	if ok, err := p.DeleteStaticRoute(ctx, project, &other, podNetwork); ok || err != nil {
+		t.Errorf("DeleteStaticRoute() via other next hop = %v %v", ok, err)
+	}
+	if ok, err := p.DeleteStaticRoute(ctx, project, a, podNetwork); !ok || err != nil {
+		t.Errorf("DeleteStaticRoute() = %v %v", ok, err)
+	}
+	if ok, err := p.DeleteStaticRoute(ctx, project, a, podNetwork); ok || err != nil {
+		t.Errorf("DeleteStaticRoute() of missing route = %v %v", ok, err)
+	}
+
+	for _, fn := range []func(context.Context, *jettypes.NodeTemplate) (bool, error){
+		p.DeleteDhcpServer, p.DeleteRouterPort, p.DeleteRouter, p.DeleteSwitch,
+	} {
+		if ok, err := fn(ctx, a); !ok || err != nil {
+			t.Errorf("delete of segment object = %v %v", ok, err)
+		}
+	}
+
+	objects, err := p.TaggedObjects(ctx, project)
+	if err != nil || len(objects) != 0 {
+		t.Errorf("TaggedObjects() = %v %v, want none", objects, err)
+	}
+}
+
+func TestTaggedObjects(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	node := newNode("worker-1", "172.16.81.10")
+	deploy(t, p, node)
+	if err := p.CreateDhcpBindings(ctx, project, []*jettypes.NodeTemplate{node}); err != nil {
+		t.Fatalf("CreateDhcpBindings() error = %v", err)
+	}
+
+	objects, err := p.TaggedObjects(ctx, project)
+	if err != nil {
+		t.Fatalf("TaggedObjects() error = %v", err)
+	}
+	kinds := make(map[string]int)
+	for _, object := range objects {
+		kinds[object.Kind]++
+	}
+	for _, kind := range []string{jettypes.ObjectSwitch, jettypes.ObjectRouter, jettypes.ObjectRouterPort,
+		jettypes.ObjectDhcpServer, jettypes.ObjectDhcpProfile, jettypes.ObjectDhcpBinding} {
+		if kinds[kind] != 1 {
+			t.Errorf("TaggedObjects() has %d of %s, want 1", kinds[kind], kind)
+		}
+	}
+
+	if other, _ := p.TaggedObjects(ctx, "other"); len(other) != 0 {
+		t.Errorf("TaggedObjects() of other project = %v", other)
+	}
+
+	// bindings and ports deleted before a server and a router that hold them
+	sort.SliceStable(objects, func(i, j int) bool {
+		return len(objects[i].Parent) > 0 && len(objects[j].Parent) == 0
+	})
+	for _, object := range objects {
+		if err := p.DeleteObject(ctx, object); err != nil {
+			t.Errorf("DeleteObject(%s) error = %v", object.Kind, err)
+		}
+	}
+	if err := p.DeleteObject(ctx, objects[0]); err == nil {
+		t.Errorf("DeleteObject() expected error of deleted object")
+	}
+	if left, _ := p.TaggedObjects(ctx, ""); len(left) != 0 {
+		t.Errorf("TaggedObjects() after delete = %v", left)
+	}
+}
+
+func TestAffinityAndSnapshots(t *testing.T) {
+
+	p := New()
+	ctx := context.Background()
+	a, b := newNode("controller-1", "172.16.81.10"), newNode("controller-2", "172.16.81.11")
+	a.CloneMode, b.CloneMode = jettypes.CloneLinked, jettypes.CloneLinked
+	deploy(t, p, a, b)
+
+	if snapshots := p.Snapshots("ubuntu"); len(snapshots) != 1 || snapshots[0] != jettypes.DefaultSnapshot {
+		t.Errorf("Snapshots() = %v", snapshots)
+	}
+	if err := p.DeleteTemplateSnapshot(ctx, "ubuntu", jettypes.DefaultSnapshot); err != nil {
+		t.Errorf("DeleteTemplateSnapshot() error = %v", err)
+	}
+	if snapshots := p.Snapshots("ubuntu"); len(snapshots) != 0 {
+		t.Errorf("Snapshots() after delete = %v", snapshots)
+	}
+
+	rule := &jettypes.AffinityRule{Name: "controllers", Cluster: "cluster",
+		Kind: jettypes.AffinityAnti, Vms: []string{a.GetVimName(), b.GetVimName()}}
+	if err := p.CreateAffinityRule(ctx, rule); err != nil {
+		t.Fatalf("CreateAffinityRule() error = %v", err)
+	}
+
+	hostRule := &jettypes.AffinityRule{Name: "edge", Cluster: "cluster",
+		Kind: jettypes.AffinityHostGroup, HostGroup: "edge-hosts", Vms: []string{a.GetVimName()}}
+	if err := p.CreateAffinityRule(ctx, hostRule); err == nil {
+		t.Errorf("CreateAffinityRule() expected error of missing host group")
+	}
+	p.AddHostGroup("cluster", "edge-hosts")
+	if err := p.CreateAffinityRule(ctx, hostRule); err != nil {
+		t.Errorf("CreateAffinityRule() error = %v", err)
+	}
+
+	if rules := p.AffinityRules(); len(rules) != 2 || rules[0].Name != "controllers" {
+		t.Errorf("AffinityRules() = %v", rules)
+	}
+	if err := p.DeleteAffinityRule(ctx, rule); err != nil {
+		t.Errorf("DeleteAffinityRule() error = %v", err)
+	}
+	if err := p.DeleteAffinityRule(ctx, rule); err != nil {
+		t.Errorf("DeleteAffinityRule() of missing rule error = %v", err)
+	}
+	if rules := p.AffinityRules(); len(rules) != 1 {
+		t.Errorf("AffinityRules() = %v", rules)
+	}
+}
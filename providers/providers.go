@@ -0,0 +1,159 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Registry of vim providers. Provider compiled into jettison registers itself
+by name in init, out-of-tree provider loaded from a shared object that
+exports Init.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package providers
+
+import (
+	"fmt"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+// provider used when configuration sets none
+const DefaultProvider = "vmware"
+
+// symbol a shared object of out-of-tree provider exports
+const InitSymbol = "Init"
+
+// capabilities of a provider, provider that doesn't report own capabilities supports all
+const (
+	// segments, routers and dhcp servers
+	CapSegments = "segments"
+	// static dhcp bindings of node mac
+	CapDhcpBindings = "dhcp-bindings"
+	// static routes of pod networks
+	CapStaticRoutes = "static-routes"
+	// drs rules of a cluster
+	CapAffinityRules = "affinity-rules"
+	// linked clones from a template snapshot
+	CapLinkedClones = "linked-clones"
+)
+
+// provider that reports what it supports, out-of-process provider reports it during handshake
+type Capable interface {
+	Supports(capability string) bool
+}
+
+// Returns all capabilities a provider can report.
+func Capabilities() []string {
+	return []string{CapSegments, CapDhcpBindings, CapStaticRoutes, CapAffinityRules, CapLinkedClones}
+}
+
+// Returns true if a provider supports a capability.
+func Supports(plugin jettypes.VimPlugin, capability string) bool {
+	if capable, ok := plugin.(Capable); ok {
+		return capable.Supports(capability)
+	}
+	return true
+}
+
+// creates a new instance of a provider
+type Factory func() (jettypes.VimPlugin, error)
+
+var (
+	lock      sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+/**
+  Registers a provider by name. Called from init of a provider package,
+  register same name twice is a programming error and panics.
+*/
+func Register(name string, factory Factory) {
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if factory == nil {
+		panic("providers: register of nil factory " + name)
+	}
+	if _, dup := factories[name]; dup {
+		panic("providers: register called twice for " + name)
+	}
+
+	factories[name] = factory
+}
+
+// Returns sorted names of registered providers.
+func Names() []string {
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var names []string
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+/**
+  Creates a registered provider, empty name is a default provider.
+*/
+func New(name string) (jettypes.VimPlugin, error) {
+
+	if len(name) == 0 {
+		name = DefaultProvider
+	}
+
+	lock.RLock()
+	factory, ok := factories[name]
+	lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown vim provider %s, registered providers: %s",
+			name, strings.Join(Names(), ", "))
+	}
+
+	return factory()
+}
+
+/**
+  Loads out-of-tree provider from a shared object and creates it with Init
+  symbol of the object. Object must be built with same dependencies as jettison.
+*/
+func Open(path string) (jettypes.VimPlugin, error) {
+
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed load vim provider %s %v", path, err)
+	}
+
+	symbol, err := p.Lookup(InitSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("vim provider %s has no %s %v", path, InitSymbol, err)
+	}
+
+	factory, ok := symbol.(func() (jettypes.VimPlugin, error))
+	if !ok {
+		return nil, fmt.Errorf("vim provider %s %s has unexpected type %T", path, InitSymbol, symbol)
+	}
+
+	return factory()
+}
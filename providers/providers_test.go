@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+func TestRegistry(t *testing.T) {
+
+	Register("test", func() (jettypes.VimPlugin, error) {
+		return nil, nil
+	})
+
+	names := Names()
+	if len(names) != 1 || names[0] != "test" {
+		t.Errorf("Names() = %v, want [test]", names)
+	}
+
+	if _, err := New("test"); err != nil {
+		t.Errorf("New(test) error = %v", err)
+	}
+	if _, err := New("missing"); err == nil {
+		t.Errorf("New(missing) expected error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register() twice expected panic")
+		}
+	}()
+	Register("test", func() (jettypes.VimPlugin, error) {
+		return nil, nil
+	})
+}
+
+func TestOpenMissing(t *testing.T) {
+	if _, err := Open("/nonexistent/provider.so"); err == nil {
+		t.Errorf("Open() expected error for missing object")
+	}
+}
@@ -0,0 +1,832 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+	"github.com/spyroot/jettison/providers"
+)
+
+const (
+	// interval between two health checks of a provider
+	DefaultHealthInterval = 30 * time.Second
+
+	// time a provider has to answer a handshake or a health check
+	DefaultHealthTimeout = 10 * time.Second
+
+	// number of times a provider that exited restarted
+	DefaultMaxRestarts = 3
+)
+
+/*
+  Executable of a provider and how jettison supervises it. Zero health
+  interval or max restarts takes a default, negative disables it.
+*/
+type Config struct {
+	Path string
+	Args []string
+	// environment of a provider in addition to environment of jettison
+	Env            []string
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+	MaxRestarts    int
+}
+
+/*
+  A vim plugin backed by a provider process. Client launches a provider,
+  health-checks it and restarts it when it exits. After a restart a worker
+  pool and a vim endpoint replayed to a new provider. A call that was in
+  flight when a provider exited fails and never retried, call such as a clone
+  is not idempotent. Provider runs until client closed, a call which context
+  done cancelled in a provider so a provider returns nodes it changed so far.
+*/
+type Client struct {
+	// id of a last call, first field so it stays aligned for atomic access
+	// on 32-bit platforms
+	seq uint64
+
+	config Config
+	ctx    context.Context
+
+	lock         sync.Mutex
+	cmd          *exec.Cmd
+	rpc          *rpc.Client
+	exited       chan struct{}
+	name         string
+	capabilities map[string]bool
+	restarts     int
+	closed       bool
+
+	// state replayed to a restarted provider
+	endpoint *Endpoint
+	pool     *PoolArgs
+
+	stop chan struct{}
+}
+
+var _ jettypes.VimPlugin = (*Client)(nil)
+
+// stdout and stdin of a provider, close of a connection closes stdin so provider exits
+type pipes struct {
+	io.ReadCloser
+	stdin io.WriteCloser
+}
+
+func (p pipes) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+func (p pipes) Close() error {
+	return p.stdin.Close()
+}
+
+/*
+  Launches a provider and negotiates a protocol version and capabilities.
+  Health checks stop when ctx done, provider itself stops only by Close so
+  calls cancelled by ctx still get a reply.
+*/
+func Start(ctx context.Context, config Config) (*Client, error) {
+
+	if len(config.Path) == 0 {
+		return nil, fmt.Errorf("vim provider executable is empty")
+	}
+	if config.HealthInterval == 0 {
+		config.HealthInterval = DefaultHealthInterval
+	}
+	if config.HealthTimeout <= 0 {
+		config.HealthTimeout = DefaultHealthTimeout
+	}
+	if config.MaxRestarts == 0 {
+		config.MaxRestarts = DefaultMaxRestarts
+	}
+
+	c := &Client{
+		config: config,
+		ctx:    ctx,
+		stop:   make(chan struct{}),
+	}
+
+	c.lock.Lock()
+	err := c.launch()
+	c.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.HealthInterval > 0 {
+		go c.healthCheck()
+	}
+
+	return c, nil
+}
+
+// Launches a provider process, caller holds a lock.
+func (c *Client) launch() error {
+
+	cmd := exec.Command(c.config.Path, c.config.Args...)
+	cmd.Env = append(append(os.Environ(), c.config.Env...), CookieKey+"="+CookieValue)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed start vim provider %s %v", c.config.Path, err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	client := rpc.NewClientWithCodec(jsonrpc.NewClientCodec(pipes{ReadCloser: stdout, stdin: stdin}))
+
+	kill := func(err error) error {
+		_ = client.Close()
+		_ = cmd.Process.Kill()
+		return err
+	}
+
+	var hello HandshakeReply
+	args := &HandshakeArgs{Version: ProtocolVersion, Capabilities: providers.Capabilities()}
+	err = c.invoke(client, exited, c.config.HealthTimeout, "Handshake", args, &hello)
+	if err != nil {
+		return kill(fmt.Errorf("handshake with vim provider %s failed %v", c.config.Path, err))
+	}
+	if hello.Version != ProtocolVersion {
+		return kill(fmt.Errorf("vim provider %s speaks protocol version %d, jettison %d",
+			c.config.Path, hello.Version, ProtocolVersion))
+	}
+
+	capabilities := make(map[string]bool)
+	for _, capability := range hello.Capabilities {
+		capabilities[capability] = true
+	}
+
+	// provider that restarted gets same pool and endpoint
+	if c.pool != nil {
+		var reply Reply
+		err = c.invoke(client, exited, 0, "SetWorkerPool", c.pool, &reply)
+		if err == nil {
+			err = reply.err()
+		}
+		if err != nil {
+			return kill(fmt.Errorf("failed set worker pool of vim provider %s %v", hello.Name, err))
+		}
+	}
+	if c.endpoint != nil {
+		var reply Reply
+		err = c.invoke(client, exited, 0, "InitPlugin", &InitArgs{Endpoint: *c.endpoint}, &reply)
+		if err == nil {
+			err = reply.err()
+		}
+		if err != nil {
+			return kill(fmt.Errorf("failed initialize vim provider %s %v", hello.Name, err))
+		}
+	}
+
+	c.cmd = cmd
+	c.rpc = client
+	c.exited = exited
+	c.name = hello.Name
+	c.capabilities = capabilities
+
+	return nil
+}
+
+// Invokes a method and waits for a reply, a provider exit or a timeout.
+func (c *Client) invoke(client *rpc.Client, exited chan struct{},
+	timeout time.Duration, method string, args interface{}, reply interface{}) error {
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	call := client.Go(ServiceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-exited:
+		return fmt.Errorf("vim provider exited")
+	case <-expired:
+		return fmt.Errorf("vim provider didn't reply %s in %v", method, timeout)
+	}
+}
+
+/*
+  Returns a connection to a running provider, a provider that exited
+  restarted unless it restarted max restarts times.
+*/
+func (c *Client) connection() (*rpc.Client, chan struct{}, error) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return nil, nil, fmt.Errorf("vim provider %s closed", c.name)
+	}
+
+	if c.rpc != nil {
+		select {
+		case <-c.exited:
+		default:
+			return c.rpc, c.exited, nil
+		}
+		_ = c.rpc.Close()
+		c.rpc = nil
+	}
+
+	if c.config.MaxRestarts > 0 && c.restarts >= c.config.MaxRestarts {
+		return nil, nil, fmt.Errorf("vim provider %s exited, restarted %d times already", c.name, c.restarts)
+	}
+	if c.config.MaxRestarts < 0 {
+		return nil, nil, fmt.Errorf("vim provider %s exited", c.name)
+	}
+
+	c.restarts++
+	logging.CriticalMessage("restarting vim provider", c.config.Path)
+
+	err := c.launch()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.rpc, c.exited, nil
+}
+
+/*
+  Calls a provider method, ctx deadline passed to a provider. When ctx done
+  a call cancelled in a provider and a reply awaited for health timeout,
+  so nodes a provider already changed still reach a caller.
+*/
+func (c *Client) call(ctx context.Context, method string, args interface{}, reply *Reply) error {
+
+	client, exited, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	var id uint64
+	if r, ok := args.(interface{ request() *Request }); ok {
+		id = atomic.AddUint64(&c.seq, 1)
+		r.request().Id = id
+		r.request().setDeadline(ctx)
+	}
+
+	call := client.Go(ServiceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-exited:
+		if !replied(call) {
+			return fmt.Errorf("vim provider %s exited during %s", c.Name(), method)
+		}
+	case <-ctx.Done():
+		if id == 0 {
+			return ctx.Err()
+		}
+		client.Go(ServiceName+".Cancel", &CancelArgs{Id: id}, &Reply{}, make(chan *rpc.Call, 1))
+		select {
+		case <-call.Done:
+		case <-exited:
+			if !replied(call) {
+				return fmt.Errorf("vim provider %s exited during %s", c.Name(), method)
+			}
+		case <-time.After(c.config.HealthTimeout):
+			return fmt.Errorf("vim provider %s didn't return cancelled %s %v", c.Name(), method, ctx.Err())
+		}
+	}
+
+	if call.Error != nil {
+		if shutdown(call.Error) {
+			c.reap(exited)
+		}
+		return fmt.Errorf("vim provider %s call %s failed %v", c.Name(), method, call.Error)
+	}
+
+	return nil
+}
+
+// Returns true if a call a provider exited during got a reply, reply may
+// arrive right before a provider exits.
+func replied(call *rpc.Call) bool {
+	select {
+	case <-call.Done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns true if error is an error of a connection to a provider rather than
+// an error a provider returned.
+func shutdown(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := err.(rpc.ServerError)
+	return !ok
+}
+
+/*
+  Kills a provider that closed a connection and waits until it exits,
+  so a next call restarts it. Connection may close before a provider exits.
+*/
+func (c *Client) reap(exited chan struct{}) {
+
+	c.lock.Lock()
+	if c.exited == exited && c.cmd != nil && c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	c.lock.Unlock()
+
+	<-exited
+}
+
+// Returns a name provider reported during handshake.
+func (c *Client) Name() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.name
+}
+
+// Returns true if provider reported capability during handshake.
+func (c *Client) Supports(capability string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.capabilities[capability]
+}
+
+// Returns an error if provider doesn't support capability a method requires.
+func (c *Client) require(method string, capability string) error {
+	if !c.Supports(capability) {
+		return &NotSupportedError{Provider: c.Name(), Method: method, Capability: capability}
+	}
+	return nil
+}
+
+// Checks that a provider is alive and answers calls.
+func (c *Client) Ping(ctx context.Context) error {
+
+	client, exited, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	var reply Reply
+	err = c.invoke(client, exited, c.config.HealthTimeout, "Ping", &Request{}, &reply)
+	if shutdown(err) {
+		c.reap(exited)
+	}
+
+	return err
+}
+
+// Checks a provider each health interval, provider that fails a check killed
+// and restarted by a next call.
+func (c *Client) healthCheck() {
+
+	ticker := time.NewTicker(c.config.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := c.Ping(c.ctx)
+		if err == nil {
+			continue
+		}
+
+		logging.CriticalMessage("vim provider failed health check", c.config.Path, err.Error())
+		c.lock.Lock()
+		if c.cmd != nil && c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill()
+		}
+		c.lock.Unlock()
+	}
+}
+
+/*
+  Closes stdin of a provider so it exits, provider that doesn't exit
+  in health timeout killed.
+*/
+func (c *Client) Close() error {
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.stop)
+	client, cmd, exited := c.rpc, c.cmd, c.exited
+	c.lock.Unlock()
+
+	if client == nil {
+		return nil
+	}
+
+	err := client.Close()
+	select {
+	case <-exited:
+	case <-time.After(c.config.HealthTimeout):
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+
+	return err
+}
+
+func (c *Client) InitPlugin(ctx context.Context, endpoint jettypes.VimEndpoint) error {
+
+	if endpoint == nil {
+		return fmt.Errorf("can't initilize plugin with nil arguments")
+	}
+
+	e := newEndpoint(endpoint)
+	var reply Reply
+	err := c.call(ctx, "InitPlugin", &InitArgs{Endpoint: e}, &reply)
+	if err != nil {
+		return err
+	}
+	if err = reply.err(); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.endpoint = &e
+	c.lock.Unlock()
+
+	return nil
+}
+
+// Passes a pool size and limits to a provider, provider bounds own jobs with a pool of same size.
+func (c *Client) SetWorkerPool(pool *jettypes.WorkerPool) {
+
+	if pool == nil {
+		return
+	}
+
+	args := &PoolArgs{Size: pool.Size(), Limits: make(map[jettypes.JobKind]int)}
+	for _, kind := range []jettypes.JobKind{jettypes.CloneJob, jettypes.PowerJob, jettypes.NetworkJob} {
+		args.Limits[kind] = pool.Limit(kind)
+	}
+
+	var reply Reply
+	err := c.call(c.ctx, "SetWorkerPool", args, &reply)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return
+	}
+
+	c.lock.Lock()
+	c.pool = args
+	c.lock.Unlock()
+}
+
+// Calls a method that changes a single node, changes copied back to node.
+func (c *Client) callNode(ctx context.Context, method string, args *NodeArgs, node *jettypes.NodeTemplate) (*Reply, error) {
+
+	if node == nil {
+		return nil, fmt.Errorf("node is nil")
+	}
+
+	args.Node = newNode(node)
+	var reply Reply
+	err := c.call(ctx, method, args, &reply)
+	if err != nil {
+		return nil, err
+	}
+
+	updateNodes([]*jettypes.NodeTemplate{node}, reply.Nodes)
+	return &reply, reply.err()
+}
+
+// Calls a method that changes group of nodes, changes copied back to nodes.
+func (c *Client) callNodes(ctx context.Context, method string, projectName string, nodes []*jettypes.NodeTemplate) (*Reply, error) {
+
+	var reply Reply
+	err := c.call(ctx, method, &NodesArgs{Project: projectName, Nodes: newNodes(nodes)}, &reply)
+	if err != nil {
+		return nil, err
+	}
+
+	updateNodes(nodes, reply.Nodes)
+	return &reply, reply.err()
+}
+
+func (c *Client) ConnectVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) (bool, error) {
+	reply, err := c.callNode(ctx, "ConnectVm", &NodeArgs{Project: projectName}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) DisconnectVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) (bool, error) {
+	reply, err := c.callNode(ctx, "DisconnectVm", &NodeArgs{Project: projectName}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) ComputeCleanup(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
+	reply, err := c.callNodes(ctx, "ComputeCleanup", projectName, nodes)
+	if reply == nil {
+		return nil, err
+	}
+	results := decodeResults(reply.Results)
+	return results, resultsErr(results, reply)
+}
+
+func (c *Client) DhcpCleanup(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+	_, err := c.callNodes(ctx, "DhcpCleanup", projectName, nodes)
+	return err
+}
+
+func (c *Client) DeploySegment(ctx context.Context, projectName string, segmentName string,
+	gateway string, prefixLen int, dhcp bool, dnsServers []string, domain string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	if err := c.require("DeploySegment", providers.CapSegments); err != nil {
+		return nil, nil, err
+	}
+
+	var reply Reply
+	args := &SegmentArgs{Project: projectName, Segment: segmentName, Gateway: gateway, PrefixLen: prefixLen,
+		Dhcp: dhcp, DnsServers: dnsServers, Domain: domain}
+	err := c.call(ctx, "DeploySegment", args, &reply)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reply.Switch.generic(), reply.Router.generic(), reply.err()
+}
+
+func (c *Client) CreateDhcpBindings(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+	if err := c.require("CreateDhcpBindings", providers.CapDhcpBindings); err != nil {
+		return err
+	}
+	_, err := c.callNodes(ctx, "CreateDhcpBindings", projectName, nodes)
+	return err
+}
+
+func (c *Client) DiscoverClusterDhcpServer(ctx context.Context, projectName string, nodes *[]*jettypes.NodeTemplate) (bool, error) {
+
+	if nodes == nil {
+		return false, fmt.Errorf("nodes is nil")
+	}
+
+	reply, err := c.callNodes(ctx, "DiscoverClusterDhcpServer", projectName, *nodes)
+	if reply == nil {
+		return false, err
+	}
+
+	// provider may add nodes it discovered
+	for i := len(*nodes); i < len(reply.Nodes); i++ {
+		*nodes = append(*nodes, reply.Nodes[i].node())
+	}
+
+	return reply.Ok, err
+}
+
+func (c *Client) DiscoverVmTemplate(ctx context.Context, node *jettypes.NodeTemplate) error {
+	_, err := c.callNode(ctx, "DiscoverVmTemplate", &NodeArgs{}, node)
+	return err
+}
+
+func (c *Client) DiscoverVms(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+	_, err := c.callNodes(ctx, "DiscoverVms", projectName, nodes)
+	return err
+}
+
+func (c *Client) DescribeVm(ctx context.Context, node *jettypes.NodeTemplate) (*jettypes.VmInfo, error) {
+	reply, err := c.callNode(ctx, "DescribeVm", &NodeArgs{}, node)
+	if reply == nil {
+		return nil, err
+	}
+	return reply.Vm, err
+}
+
+func (c *Client) DiscoverFolder(ctx context.Context, folder string) ([]*jettypes.VmInfo, error) {
+	var reply Reply
+	err := c.call(ctx, "DiscoverFolder", &FolderArgs{Folder: folder}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Vms, reply.err()
+}
+
+func (c *Client) DescribeSegment(ctx context.Context, switchUuid string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	if err := c.require("DescribeSegment", providers.CapSegments); err != nil {
+		return nil, nil, err
+	}
+
+	var reply Reply
+	err := c.call(ctx, "DescribeSegment", &SegmentArgs{SwitchUuid: switchUuid}, &reply)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reply.Switch.generic(), reply.Router.generic(), reply.err()
+}
+
+func (c *Client) DescribeBinding(ctx context.Context, node *jettypes.NodeTemplate) (string, bool, error) {
+	if err := c.require("DescribeBinding", providers.CapDhcpBindings); err != nil {
+		return "", false, err
+	}
+	reply, err := c.callNode(ctx, "DescribeBinding", &NodeArgs{}, node)
+	if reply == nil {
+		return "", false, err
+	}
+	return reply.Value, reply.Ok, err
+}
+
+func (c *Client) TaggedObjects(ctx context.Context, projectName string) ([]jettypes.NetworkObject, error) {
+	var reply Reply
+	err := c.call(ctx, "TaggedObjects", &ObjectArgs{Project: projectName}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Objects, reply.err()
+}
+
+func (c *Client) DeleteObject(ctx context.Context, object jettypes.NetworkObject) error {
+	var reply Reply
+	err := c.call(ctx, "DeleteObject", &ObjectArgs{Object: object}, &reply)
+	if err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *Client) CloneVms(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
+
+	for _, node := range nodes {
+		if node.LinkedClone() {
+			if err := c.require("CloneVms", providers.CapLinkedClones); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	reply, err := c.callNodes(ctx, "CloneVms", projectName, nodes)
+	if reply == nil {
+		return nil, err
+	}
+	results := decodeResults(reply.Results)
+	return results, resultsErr(results, reply)
+}
+
+func (c *Client) DeleteVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) error {
+	_, err := c.callNode(ctx, "DeleteVm", &NodeArgs{Project: projectName}, node)
+	return err
+}
+
+func (c *Client) DeleteFolder(ctx context.Context, projectName string, folder string) error {
+	var reply Reply
+	err := c.call(ctx, "DeleteFolder", &FolderArgs{Project: projectName, Folder: folder}, &reply)
+	if err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *Client) DeleteTemplateSnapshot(ctx context.Context, vmTemplate string, snapshot string) error {
+
+	if err := c.require("DeleteTemplateSnapshot", providers.CapLinkedClones); err != nil {
+		return err
+	}
+
+	var reply Reply
+	err := c.call(ctx, "DeleteTemplateSnapshot", &SnapshotArgs{VmTemplate: vmTemplate, Snapshot: snapshot}, &reply)
+	if err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *Client) CreateAffinityRule(ctx context.Context, rule *jettypes.AffinityRule) error {
+
+	if err := c.require("CreateAffinityRule", providers.CapAffinityRules); err != nil {
+		return err
+	}
+
+	var reply Reply
+	err := c.call(ctx, "CreateAffinityRule", &RuleArgs{Rule: rule}, &reply)
+	if err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *Client) DeleteAffinityRule(ctx context.Context, rule *jettypes.AffinityRule) error {
+
+	if err := c.require("DeleteAffinityRule", providers.CapAffinityRules); err != nil {
+		return err
+	}
+
+	var reply Reply
+	err := c.call(ctx, "DeleteAffinityRule", &RuleArgs{Rule: rule}, &reply)
+	if err != nil {
+		return err
+	}
+	return reply.err()
+}
+
+func (c *Client) ChangePowerState(ctx context.Context, node *jettypes.NodeTemplate, state jettypes.PowerState) (bool, error) {
+	reply, err := c.callNode(ctx, "ChangePowerState", &NodeArgs{State: state}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) AcquireIpAddress(ctx context.Context, node *jettypes.NodeTemplate) (bool, string, error) {
+	reply, err := c.callNode(ctx, "AcquireIpAddress", &NodeArgs{}, node)
+	if reply == nil {
+		return false, "", err
+	}
+	return reply.Ok, reply.Value, err
+}
+
+func (c *Client) DeleteDhcpServer(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+	reply, err := c.callNode(ctx, "DeleteDhcpServer", &NodeArgs{}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) DeleteRouter(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+	reply, err := c.callNode(ctx, "DeleteRouter", &NodeArgs{}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) DeleteRouterPort(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+	reply, err := c.callNode(ctx, "DeleteRouterPort", &NodeArgs{}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) DeleteSwitch(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+	reply, err := c.callNode(ctx, "DeleteSwitch", &NodeArgs{}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) AddStaticRoute(ctx context.Context, projectName string, node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
+	if err := c.require("AddStaticRoute", providers.CapStaticRoutes); err != nil {
+		return false, err
+	}
+	reply, err := c.callNode(ctx, "AddStaticRoute", &NodeArgs{Project: projectName, PodNetwork: podNetwork}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
+
+func (c *Client) DeleteStaticRoute(ctx context.Context, projectName string, node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
+	if err := c.require("DeleteStaticRoute", providers.CapStaticRoutes); err != nil {
+		return false, err
+	}
+	reply, err := c.callNode(ctx, "DeleteStaticRoute", &NodeArgs{Project: projectName, PodNetwork: podNetwork}, node)
+	if reply == nil {
+		return false, err
+	}
+	return reply.Ok, err
+}
@@ -0,0 +1,404 @@
+/*
+Copyright (c) 2019 VMware, Inc. All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+Protocol of out-of-process vim provider. A provider is a separate executable
+jettison launches, jettison and a provider exchange JSON-RPC messages over
+stdin and stdout of a provider. First call is always a handshake where both
+sides agree on a protocol version and a provider reports own capabilities.
+
+Author Mustafa Bayramov
+mbaraymov@vmware.com
+*/
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+// version of a protocol, provider that speaks other version rejected during handshake
+const ProtocolVersion = 1
+
+// name of rpc service a provider registers
+const ServiceName = "Provider"
+
+// jettison sets cookie in environment of a provider, provider started by hand refuses to run
+const (
+	CookieKey   = "JETTISON_PROVIDER_COOKIE"
+	CookieValue = "d2a0c6e4-jettison-vim-provider"
+)
+
+/*
+  An error of a call a provider didn't report a capability for,
+  call never reaches a provider.
+*/
+type NotSupportedError struct {
+	Provider   string
+	Method     string
+	Capability string
+}
+
+func (e *NotSupportedError) Error() string {
+	return fmt.Sprintf("vim provider %s doesn't support %s, %s requires it", e.Provider, e.Capability, e.Method)
+}
+
+// Returns true if err is an error of a call a provider doesn't support.
+func IsNotSupported(err error) bool {
+	_, ok := err.(*NotSupportedError)
+	return ok
+}
+
+type HandshakeArgs struct {
+	Version      int
+	Capabilities []string
+}
+
+type HandshakeReply struct {
+	Version      int
+	Name         string
+	Capabilities []string
+}
+
+// deadline of a caller context, zero has no deadline. Id of a call
+// jettison cancels a call by, zero call can't be cancelled.
+type Request struct {
+	Deadline time.Time
+	Id       uint64
+}
+
+func (r *Request) request() *Request {
+	return r
+}
+
+func (r *Request) setDeadline(ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Deadline = deadline
+	}
+}
+
+// Returns a context provider executes a call with.
+func (r *Request) context() (context.Context, context.CancelFunc) {
+	if r.Deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), r.Deadline)
+}
+
+// Id of a call jettison no longer waits for
+type CancelArgs struct {
+	Id uint64
+}
+
+type InitArgs struct {
+	Request
+	Endpoint Endpoint
+}
+
+type PoolArgs struct {
+	Request
+	Size   int
+	Limits map[jettypes.JobKind]int
+}
+
+type NodeArgs struct {
+	Request
+	Project    string
+	Node       Node
+	State      jettypes.PowerState
+	PodNetwork string
+}
+
+type NodesArgs struct {
+	Request
+	Project string
+	Nodes   []Node
+}
+
+type SegmentArgs struct {
+	Request
+	Project    string
+	Segment    string
+	Gateway    string
+	PrefixLen  int
+	Dhcp       bool
+	DnsServers []string
+	Domain     string
+	SwitchUuid string
+}
+
+type FolderArgs struct {
+	Request
+	Project string
+	Folder  string
+}
+
+type ObjectArgs struct {
+	Request
+	Project string
+	Object  jettypes.NetworkObject
+}
+
+type SnapshotArgs struct {
+	Request
+	VmTemplate string
+	Snapshot   string
+}
+
+type RuleArgs struct {
+	Request
+	Rule *jettypes.AffinityRule
+}
+
+/*
+  A reply of every call. An error of a provider carried in Err so results
+  and nodes a provider changed before it failed still reach jettison.
+*/
+type Reply struct {
+	Err     string
+	Ok      bool
+	Value   string
+	Nodes   []Node
+	Results []Result
+	Vm      *jettypes.VmInfo
+	Vms     []*jettypes.VmInfo
+	Objects []jettypes.NetworkObject
+	Switch  *Switch
+	Router  *Router
+}
+
+// Returns an error a provider reported.
+func (r *Reply) err() error {
+	if len(r.Err) == 0 {
+		return nil
+	}
+	return errors.New(r.Err)
+}
+
+func (r *Reply) setErr(err error) {
+	if err != nil {
+		r.Err = err.Error()
+	}
+}
+
+// vim endpoint passed to a provider
+type Endpoint struct {
+	Hostname   string
+	Username   string
+	Password   string
+	Datacenter string
+}
+
+func (e *Endpoint) Endpoint() string      { return e.Hostname }
+func (e *Endpoint) VimUsername() string   { return e.Username }
+func (e *Endpoint) VimPassword() string   { return e.Password }
+func (e *Endpoint) VimDatacenter() string { return e.Datacenter }
+
+func newEndpoint(endpoint jettypes.VimEndpoint) Endpoint {
+	return Endpoint{
+		Hostname:   endpoint.Endpoint(),
+		Username:   endpoint.VimUsername(),
+		Password:   endpoint.VimPassword(),
+		Datacenter: endpoint.VimDatacenter(),
+	}
+}
+
+type Switch struct {
+	Name           string
+	Uuid           string
+	DhcpUuid       string
+	RouterUuid     string
+	RouterPortUuid string
+	Attached       bool
+}
+
+type Router struct {
+	Name           string
+	Uuid           string
+	SwitchPortUuid string
+}
+
+func newSwitch(s *jettypes.GenericSwitch) *Switch {
+	if s == nil {
+		return nil
+	}
+	return &Switch{
+		Name:           s.Name(),
+		Uuid:           s.Uuid(),
+		DhcpUuid:       s.DhcpUuid(),
+		RouterUuid:     s.RouterUuid(),
+		RouterPortUuid: s.RouterPortUuid(),
+		Attached:       s.Attached(),
+	}
+}
+
+func (s *Switch) generic() *jettypes.GenericSwitch {
+	if s == nil {
+		return nil
+	}
+	g := jettypes.NewGenericSwitch(s.Name, s.Uuid, s.DhcpUuid, s.RouterUuid)
+	g.SetRouterPortUuid(s.RouterPortUuid)
+	g.SetAttached(s.Attached)
+	return g
+}
+
+func newRouter(r *jettypes.GenericRouter) *Router {
+	if r == nil {
+		return nil
+	}
+	return &Router{Name: r.Name(), Uuid: r.Uuid(), SwitchPortUuid: r.SwitchPortUuid()}
+}
+
+func (r *Router) generic() *jettypes.GenericRouter {
+	if r == nil {
+		return nil
+	}
+	g := jettypes.NewGenericRouter(r.Name, r.Uuid)
+	g.SetSwitchPortUuid(r.SwitchPortUuid)
+	return g
+}
+
+/*
+  A node on a wire. Json of node template carries only exported fields,
+  state a node keeps in unexported fields carried next to it.
+*/
+type Node struct {
+	Template        *jettypes.NodeTemplate
+	Switch          *Switch
+	Router          *Router
+	PodCidr         string
+	PodAllocation   int
+	UserData        string
+	MetaData        string
+	IsTemplate      bool
+	ExistingNetwork bool
+}
+
+func newNode(node *jettypes.NodeTemplate) Node {
+
+	userData, metaData := node.CloudInitData()
+
+	return Node{
+		Template:        node,
+		Switch:          newSwitch(node.GenericSwitch()),
+		Router:          newRouter(node.GenericRouter()),
+		PodCidr:         node.GetCidr(),
+		PodAllocation:   node.GetAllocation(),
+		UserData:        userData,
+		MetaData:        metaData,
+		IsTemplate:      node.IsTemplate(),
+		ExistingNetwork: node.IsExistingNetwork(),
+	}
+}
+
+// Returns a node template a wire node describes.
+func (n Node) node() *jettypes.NodeTemplate {
+
+	node := n.Template
+	if node == nil {
+		node = &jettypes.NodeTemplate{}
+	}
+
+	// json decodes an empty ip as zero length slice rather than nil
+	if len(node.IPv4Addr) == 0 {
+		node.IPv4Addr = nil
+	}
+	if node.IPv4Net != nil && len(node.IPv4Net.IP) == 0 {
+		node.IPv4Net = nil
+	}
+
+	node.SetGenericSwitch(n.Switch.generic())
+	node.SetGenericRouter(n.Router.generic())
+	node.SetPodCidr(n.PodCidr)
+	node.PodAllocationSize(n.PodAllocation)
+	node.SetCloudInitData(n.UserData, n.MetaData)
+	node.SetTemplate(n.IsTemplate)
+	node.SetExistingNetwork(n.ExistingNetwork)
+
+	return node
+}
+
+func newNodes(nodes []*jettypes.NodeTemplate) []Node {
+	wire := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		wire = append(wire, newNode(node))
+	}
+	return wire
+}
+
+func decodeNodes(wire []Node) []*jettypes.NodeTemplate {
+	nodes := make([]*jettypes.NodeTemplate, 0, len(wire))
+	for _, n := range wire {
+		nodes = append(nodes, n.node())
+	}
+	return nodes
+}
+
+/*
+  Copies nodes a provider returned into nodes of a caller, so a caller
+  sees a change a provider made same way as with in-process provider.
+*/
+func updateNodes(nodes []*jettypes.NodeTemplate, wire []Node) {
+	for i, n := range wire {
+		if i < len(nodes) && nodes[i] != nil {
+			*nodes[i] = *n.node()
+		}
+	}
+}
+
+// a result of an operation of a node on a wire
+type Result struct {
+	Op       string
+	Node     string
+	TaskId   string
+	Duration time.Duration
+	Err      string
+}
+
+func newResults(results jettypes.NodeResults) []Result {
+	wire := make([]Result, 0, len(results))
+	for _, r := range results {
+		w := Result{Op: r.Op, Node: r.Node, TaskId: r.TaskId, Duration: r.Duration}
+		if r.Err != nil {
+			w.Err = r.Err.Error()
+		}
+		wire = append(wire, w)
+	}
+	return wire
+}
+
+func decodeResults(wire []Result) jettypes.NodeResults {
+	var results jettypes.NodeResults
+	for _, w := range wire {
+		r := jettypes.NodeResult{Op: w.Op, Node: w.Node, TaskId: w.TaskId, Duration: w.Duration}
+		if len(w.Err) > 0 {
+			r.Err = errors.New(w.Err)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// Returns error of a group of operations, a MultiError if results has failed node.
+func resultsErr(results jettypes.NodeResults, reply *Reply) error {
+	if err := results.Err(); err != nil {
+		return err
+	}
+	return reply.err()
+}
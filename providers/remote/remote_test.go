@@ -0,0 +1,255 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/providers"
+)
+
+// set in environment of a test binary that acts as a provider
+const helperKey = "JETTISON_TEST_PROVIDER"
+
+// test provider, methods a test doesn't call left to a nil interface
+type fakePlugin struct {
+	jettypes.VimPlugin
+	endpoint jettypes.VimEndpoint
+	pool     *jettypes.WorkerPool
+}
+
+func (f *fakePlugin) InitPlugin(ctx context.Context, endpoint jettypes.VimEndpoint) error {
+	f.endpoint = endpoint
+	return nil
+}
+
+func (f *fakePlugin) SetWorkerPool(pool *jettypes.WorkerPool) {
+	f.pool = pool
+}
+
+func (f *fakePlugin) DescribeVm(ctx context.Context, node *jettypes.NodeTemplate) (*jettypes.VmInfo, error) {
+	info := &jettypes.VmInfo{Name: node.Name, Exists: true}
+	if f.endpoint != nil {
+		info.VimName = f.endpoint.Endpoint()
+	}
+	if f.pool != nil {
+		info.Cluster = fmt.Sprint(f.pool.Size())
+	}
+	return info, nil
+}
+
+func (f *fakePlugin) CloneVms(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
+
+	var results jettypes.NodeResults
+	for _, node := range nodes {
+		r := jettypes.NodeResult{Op: jettypes.OpClone, Node: node.Name}
+		if node.Name == "bad" {
+			r.Err = fmt.Errorf("no space left")
+		} else if node.Name == "slow" {
+			// clone runs until jettison cancels it
+			<-ctx.Done()
+			r.Err = ctx.Err()
+		} else {
+			node.UUID = "uuid-" + node.Name
+			node.SetVimName("VirtualMachine:vm-" + node.Name)
+			node.Mac = []string{"00:50:56:00:00:01"}
+			node.SetGenericSwitch(jettypes.NewGenericSwitch("segment", "switch-uuid", "dhcp-uuid", "router-uuid"))
+		}
+		results = append(results, r)
+	}
+
+	return results, results.Err()
+}
+
+func (f *fakePlugin) DeleteVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) error {
+	if node.Name == "crash" {
+		os.Exit(3)
+	}
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperKey) == "1" {
+		err := Serve("fake", &fakePlugin{}, providers.CapSegments)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func startFake(t *testing.T) *Client {
+	return startFakeContext(t, context.Background())
+}
+
+func startFakeContext(t *testing.T, ctx context.Context) *Client {
+
+	client, err := Start(ctx, Config{
+		Path:           os.Args[0],
+		Env:            []string{helperKey + "=1"},
+		HealthInterval: -1,
+		MaxRestarts:    1,
+	})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	return client
+}
+
+type endpoint struct{}
+
+func (endpoint) Endpoint() string      { return "vcenter.lab" }
+func (endpoint) VimUsername() string   { return "admin" }
+func (endpoint) VimPassword() string   { return "secret" }
+func (endpoint) VimDatacenter() string { return "dc" }
+
+func TestHandshake(t *testing.T) {
+
+	client := startFake(t)
+	defer client.Close()
+
+	if client.Name() != "fake" {
+		t.Errorf("Name() = %s, want fake", client.Name())
+	}
+	if !client.Supports(providers.CapSegments) {
+		t.Errorf("Supports(%s) = false", providers.CapSegments)
+	}
+	if client.Supports(providers.CapAffinityRules) {
+		t.Errorf("Supports(%s) = true", providers.CapAffinityRules)
+	}
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+
+	err := client.CreateAffinityRule(context.Background(), &jettypes.AffinityRule{Name: "rule"})
+	if !IsNotSupported(err) {
+		t.Errorf("CreateAffinityRule() error = %v, want not supported", err)
+	}
+}
+
+func TestCloneVms(t *testing.T) {
+
+	client := startFake(t)
+	defer client.Close()
+
+	good := &jettypes.NodeTemplate{Name: "good"}
+	good.SetPodCidr("10.200.1.0")
+	good.PodAllocationSize(24)
+	bad := &jettypes.NodeTemplate{Name: "bad"}
+
+	results, err := client.CloneVms(context.Background(), "project", []*jettypes.NodeTemplate{good, bad})
+	if _, ok := err.(*jettypes.MultiError); !ok {
+		t.Fatalf("CloneVms() error = %v, want MultiError", err)
+	}
+	if len(results) != 2 || !results[0].Ok() || results[1].Ok() {
+		t.Errorf("CloneVms() results = %v", results)
+	}
+
+	if good.UUID != "uuid-good" || good.GetVimName() != "vm-good" || good.MacAddress() != "00:50:56:00:00:01" {
+		t.Errorf("node not updated %s %s %v", good.UUID, good.GetVimName(), good.Mac)
+	}
+	if good.SwitchUuid() != "switch-uuid" || good.DhcpServerUuid() != "dhcp-uuid" {
+		t.Errorf("switch of node not updated %v", good.GenericSwitch())
+	}
+	if good.GetCidr() != "10.200.1.0" || good.GetAllocation() != 24 {
+		t.Errorf("pod network of node lost %s/%d", good.GetCidr(), good.GetAllocation())
+	}
+}
+
+//
+//  Interrupt cancels a context jettison started a provider with and a context
+//  of a running clone. Provider keeps running and returns vms it cloned.
+//
+func TestCancel(t *testing.T) {
+
+	root, cancelRoot := context.WithCancel(context.Background())
+	client := startFakeContext(t, root)
+	defer client.Close()
+
+	cancelRoot()
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error = %v, provider must outlive a context it started with", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	good := &jettypes.NodeTemplate{Name: "good"}
+	slow := &jettypes.NodeTemplate{Name: "slow"}
+	results, err := client.CloneVms(ctx, "project", []*jettypes.NodeTemplate{good, slow})
+	if err == nil {
+		t.Fatalf("CloneVms() expected error of cancelled clone")
+	}
+	if len(results) != 2 || !results[0].Ok() || results[1].Ok() {
+		t.Fatalf("CloneVms() results = %v, want results of cancelled call", results)
+	}
+	if good.UUID != "uuid-good" {
+		t.Errorf("node cloned before cancel not updated %s", good.UUID)
+	}
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v after cancelled call", err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+
+	client := startFake(t)
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.InitPlugin(ctx, endpoint{}); err != nil {
+		t.Fatalf("InitPlugin() error = %v", err)
+	}
+	client.SetWorkerPool(jettypes.NewWorkerPool(5, nil))
+
+	err := client.DeleteVm(ctx, "project", &jettypes.NodeTemplate{Name: "crash"})
+	if err == nil {
+		t.Fatalf("DeleteVm() expected error of provider that exited")
+	}
+
+	// provider restarted, endpoint and pool replayed
+	info, err := client.DescribeVm(ctx, &jettypes.NodeTemplate{Name: "node"})
+	if err != nil {
+		t.Fatalf("DescribeVm() error = %v", err)
+	}
+	if info.VimName != "vcenter.lab" || info.Cluster != "5" {
+		t.Errorf("DescribeVm() = %+v, want endpoint and pool replayed", info)
+	}
+
+	// max restarts reached
+	_ = client.DeleteVm(ctx, "project", &jettypes.NodeTemplate{Name: "crash"})
+	_, err = client.DescribeVm(ctx, &jettypes.NodeTemplate{Name: "node"})
+	if err == nil {
+		t.Errorf("DescribeVm() expected error after max restarts")
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+
+	server, conn := net.Pipe()
+	go ServeConn("fake", &fakePlugin{}, server)
+
+	client := rpc.NewClientWithCodec(jsonrpc.NewClientCodec(conn))
+	defer client.Close()
+
+	var reply HandshakeReply
+	call := client.Go(ServiceName+".Handshake", &HandshakeArgs{Version: ProtocolVersion + 1}, &reply, nil)
+	select {
+	case <-call.Done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handshake() timed out")
+	}
+	if call.Error == nil {
+		t.Errorf("Handshake() expected error of version mismatch")
+	}
+}
@@ -0,0 +1,413 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+// stdin and stdout of a provider process as a single connection
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+func (s stdio) Close() error {
+	return nil
+}
+
+/*
+  Serves a provider over stdin and stdout until jettison closes stdin.
+  Called from main of a provider executable, name and capabilities reported
+  to jettison during handshake. Output a provider prints redirected to stderr,
+  stdout belongs to a protocol. Provider ignores an interrupt a terminal sends
+  to jettison too, jettison cancels running calls and provider returns what
+  they changed so far.
+*/
+func Serve(name string, plugin jettypes.VimPlugin, capabilities ...string) error {
+
+	if os.Getenv(CookieKey) != CookieValue {
+		return fmt.Errorf("%s is a jettison vim provider and must be launched by jettison", name)
+	}
+
+	signal.Ignore(os.Interrupt)
+
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	log.SetOutput(os.Stderr)
+
+	return ServeConn(name, plugin, stdio{Reader: os.Stdin, Writer: stdout}, capabilities...)
+}
+
+// Serves a provider over a connection until a connection closed.
+func ServeConn(name string, plugin jettypes.VimPlugin, conn io.ReadWriteCloser, capabilities ...string) error {
+
+	if plugin == nil {
+		return fmt.Errorf("vim provider %s is nil", name)
+	}
+
+	server := rpc.NewServer()
+	err := server.RegisterName(ServiceName, &service{
+		name:         name,
+		plugin:       plugin,
+		capabilities: capabilities,
+		ctx:          context.Background(),
+		calls:        make(map[uint64]context.CancelFunc),
+	})
+	if err != nil {
+		return err
+	}
+
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+// rpc service that dispatches each call to a plugin
+type service struct {
+	name         string
+	plugin       jettypes.VimPlugin
+	capabilities []string
+
+	// context plugin initialized with, lives as long as a provider
+	ctx context.Context
+
+	// running calls jettison can cancel
+	lock  sync.Mutex
+	calls map[uint64]context.CancelFunc
+}
+
+// Returns a context a call executes with, call registered so jettison can cancel it.
+func (s *service) context(r *Request) (context.Context, context.CancelFunc) {
+
+	ctx, cancel := r.context()
+	if r.Id == 0 {
+		return ctx, cancel
+	}
+
+	s.lock.Lock()
+	s.calls[r.Id] = cancel
+	s.lock.Unlock()
+
+	return ctx, func() {
+		s.lock.Lock()
+		delete(s.calls, r.Id)
+		s.lock.Unlock()
+		cancel()
+	}
+}
+
+// Cancels a running call, call returns what it changed so far.
+func (s *service) Cancel(args *CancelArgs, reply *Reply) error {
+
+	s.lock.Lock()
+	cancel, ok := s.calls[args.Id]
+	s.lock.Unlock()
+
+	if ok {
+		cancel()
+	}
+
+	return nil
+}
+
+func (s *service) Handshake(args *HandshakeArgs, reply *HandshakeReply) error {
+
+	reply.Version = ProtocolVersion
+	reply.Name = s.name
+	reply.Capabilities = s.capabilities
+
+	if args.Version != ProtocolVersion {
+		return fmt.Errorf("vim provider %s speaks protocol version %d, jettison %d",
+			s.name, ProtocolVersion, args.Version)
+	}
+
+	return nil
+}
+
+func (s *service) Ping(args *Request, reply *Reply) error {
+	return nil
+}
+
+func (s *service) InitPlugin(args *InitArgs, reply *Reply) error {
+	reply.setErr(s.plugin.InitPlugin(s.ctx, &args.Endpoint))
+	return nil
+}
+
+func (s *service) SetWorkerPool(args *PoolArgs, reply *Reply) error {
+	s.plugin.SetWorkerPool(jettypes.NewWorkerPool(args.Size, args.Limits))
+	return nil
+}
+
+// Runs fn that changes a single node, node returned to jettison.
+func (s *service) node(args *NodeArgs, reply *Reply, fn func(ctx context.Context, node *jettypes.NodeTemplate) error) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	node := args.Node.node()
+	reply.setErr(fn(ctx, node))
+	reply.Nodes = []Node{newNode(node)}
+
+	return nil
+}
+
+// Runs fn that changes group of nodes, nodes returned to jettison.
+func (s *service) nodes(args *NodesArgs, reply *Reply, fn func(ctx context.Context, nodes []*jettypes.NodeTemplate) error) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	nodes := decodeNodes(args.Nodes)
+	reply.setErr(fn(ctx, nodes))
+	reply.Nodes = newNodes(nodes)
+
+	return nil
+}
+
+func (s *service) ConnectVm(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.ConnectVm(ctx, args.Project, node)
+		return err
+	})
+}
+
+func (s *service) DisconnectVm(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.DisconnectVm(ctx, args.Project, node)
+		return err
+	})
+}
+
+func (s *service) ComputeCleanup(args *NodesArgs, reply *Reply) error {
+	return s.nodes(args, reply, func(ctx context.Context, nodes []*jettypes.NodeTemplate) error {
+		results, err := s.plugin.ComputeCleanup(ctx, args.Project, nodes)
+		reply.Results = newResults(results)
+		return err
+	})
+}
+
+func (s *service) DhcpCleanup(args *NodesArgs, reply *Reply) error {
+	return s.nodes(args, reply, func(ctx context.Context, nodes []*jettypes.NodeTemplate) error {
+		return s.plugin.DhcpCleanup(ctx, args.Project, nodes)
+	})
+}
+
+func (s *service) DeploySegment(args *SegmentArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	sw, router, err := s.plugin.DeploySegment(ctx, args.Project, args.Segment, args.Gateway, args.PrefixLen,
+		args.Dhcp, args.DnsServers, args.Domain)
+	reply.Switch = newSwitch(sw)
+	reply.Router = newRouter(router)
+	reply.setErr(err)
+
+	return nil
+}
+
+func (s *service) CreateDhcpBindings(args *NodesArgs, reply *Reply) error {
+	return s.nodes(args, reply, func(ctx context.Context, nodes []*jettypes.NodeTemplate) error {
+		return s.plugin.CreateDhcpBindings(ctx, args.Project, nodes)
+	})
+}
+
+func (s *service) DiscoverClusterDhcpServer(args *NodesArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	nodes := decodeNodes(args.Nodes)
+	ok, err := s.plugin.DiscoverClusterDhcpServer(ctx, args.Project, &nodes)
+	reply.Ok = ok
+	reply.Nodes = newNodes(nodes)
+	reply.setErr(err)
+
+	return nil
+}
+
+func (s *service) DiscoverVmTemplate(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) error {
+		return s.plugin.DiscoverVmTemplate(ctx, node)
+	})
+}
+
+func (s *service) DiscoverVms(args *NodesArgs, reply *Reply) error {
+	return s.nodes(args, reply, func(ctx context.Context, nodes []*jettypes.NodeTemplate) error {
+		return s.plugin.DiscoverVms(ctx, args.Project, nodes)
+	})
+}
+
+func (s *service) DescribeVm(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Vm, err = s.plugin.DescribeVm(ctx, node)
+		return err
+	})
+}
+
+func (s *service) DiscoverFolder(args *FolderArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	vms, err := s.plugin.DiscoverFolder(ctx, args.Folder)
+	reply.Vms = vms
+	reply.setErr(err)
+
+	return nil
+}
+
+func (s *service) DescribeSegment(args *SegmentArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	sw, router, err := s.plugin.DescribeSegment(ctx, args.SwitchUuid)
+	reply.Switch = newSwitch(sw)
+	reply.Router = newRouter(router)
+	reply.setErr(err)
+
+	return nil
+}
+
+func (s *service) DescribeBinding(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Value, reply.Ok, err = s.plugin.DescribeBinding(ctx, node)
+		return err
+	})
+}
+
+func (s *service) TaggedObjects(args *ObjectArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	objects, err := s.plugin.TaggedObjects(ctx, args.Project)
+	reply.Objects = objects
+	reply.setErr(err)
+
+	return nil
+}
+
+func (s *service) DeleteObject(args *ObjectArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	reply.setErr(s.plugin.DeleteObject(ctx, args.Object))
+	return nil
+}
+
+func (s *service) CloneVms(args *NodesArgs, reply *Reply) error {
+	return s.nodes(args, reply, func(ctx context.Context, nodes []*jettypes.NodeTemplate) error {
+		results, err := s.plugin.CloneVms(ctx, args.Project, nodes)
+		reply.Results = newResults(results)
+		return err
+	})
+}
+
+func (s *service) DeleteVm(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) error {
+		return s.plugin.DeleteVm(ctx, args.Project, node)
+	})
+}
+
+func (s *service) DeleteFolder(args *FolderArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	reply.setErr(s.plugin.DeleteFolder(ctx, args.Project, args.Folder))
+	return nil
+}
+
+func (s *service) DeleteTemplateSnapshot(args *SnapshotArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	reply.setErr(s.plugin.DeleteTemplateSnapshot(ctx, args.VmTemplate, args.Snapshot))
+	return nil
+}
+
+func (s *service) CreateAffinityRule(args *RuleArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	reply.setErr(s.plugin.CreateAffinityRule(ctx, args.Rule))
+	return nil
+}
+
+func (s *service) DeleteAffinityRule(args *RuleArgs, reply *Reply) error {
+
+	ctx, cancel := s.context(&args.Request)
+	defer cancel()
+
+	reply.setErr(s.plugin.DeleteAffinityRule(ctx, args.Rule))
+	return nil
+}
+
+func (s *service) ChangePowerState(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.ChangePowerState(ctx, node, args.State)
+		return err
+	})
+}
+
+func (s *service) AcquireIpAddress(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, reply.Value, err = s.plugin.AcquireIpAddress(ctx, node)
+		return err
+	})
+}
+
+func (s *service) DeleteDhcpServer(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.DeleteDhcpServer(ctx, node)
+		return err
+	})
+}
+
+func (s *service) DeleteRouter(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.DeleteRouter(ctx, node)
+		return err
+	})
+}
+
+func (s *service) DeleteRouterPort(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.DeleteRouterPort(ctx, node)
+		return err
+	})
+}
+
+func (s *service) DeleteSwitch(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.DeleteSwitch(ctx, node)
+		return err
+	})
+}
+
+func (s *service) AddStaticRoute(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.AddStaticRoute(ctx, args.Project, node, args.PodNetwork)
+		return err
+	})
+}
+
+func (s *service) DeleteStaticRoute(args *NodeArgs, reply *Reply) error {
+	return s.node(args, reply, func(ctx context.Context, node *jettypes.NodeTemplate) (err error) {
+		reply.Ok, err = s.plugin.DeleteStaticRoute(ctx, args.Project, node, args.PodNetwork)
+		return err
+	})
+}
@@ -0,0 +1,201 @@
+package vmware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+)
+
+// Returns a cluster of a rule, empty cluster name is a default cluster of datacenter.
+func (p *VmwareVim) ruleCluster(ctx context.Context, name string) (*object.ClusterComputeResource, error) {
+
+	if p.datacenter == nil {
+		return nil, fmt.Errorf("datacenter is nil")
+	}
+
+	finder := find.NewFinder(p.VimClient(), true)
+	finder.SetDatacenter(p.datacenter)
+
+	cluster, err := finder.ClusterComputeResourceOrDefault(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed find cluster %s %v", name, err)
+	}
+
+	return cluster, nil
+}
+
+// name of a vm group host-group rule created with
+func ruleVmGroup(rule *jettypes.AffinityRule) string {
+	return rule.Name + "-vms"
+}
+
+// Returns a rule and a group of a cluster config by name, nil if not found.
+func findClusterRule(config *types.ClusterConfigInfoEx, ruleName string, groupName string) (*types.ClusterRuleInfo, *types.ClusterGroupInfo) {
+
+	var (
+		rule  *types.ClusterRuleInfo
+		group *types.ClusterGroupInfo
+	)
+	for _, r := range config.Rule {
+		if r.GetClusterRuleInfo().Name == ruleName {
+			rule = r.GetClusterRuleInfo()
+		}
+	}
+	for _, g := range config.Group {
+		if g.GetClusterGroupInfo().Name == groupName {
+			group = g.GetClusterGroupInfo()
+		}
+	}
+
+	return rule, group
+}
+
+// Returns true if a cluster has a host group.
+func hasHostGroup(config *types.ClusterConfigInfoEx, name string) bool {
+	for _, g := range config.Group {
+		if _, ok := g.(*types.ClusterHostGroup); ok && g.GetClusterGroupInfo().Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+  Returns cluster spec that adds a rule or updates existing one. Host-group rule
+  places vms into own vm group, host group itself must exist in a cluster.
+*/
+func affinityRuleSpec(config *types.ClusterConfigInfoEx, rule *jettypes.AffinityRule) (*types.ClusterConfigSpecEx, error) {
+
+	var vms []types.ManagedObjectReference
+	for _, vimName := range rule.Vms {
+		vms = append(vms, types.ManagedObjectReference{Type: "VirtualMachine", Value: vimName})
+	}
+
+	existingRule, existingGroup := findClusterRule(config, rule.Name, ruleVmGroup(rule))
+
+	ruleInfo := types.ClusterRuleInfo{Name: rule.Name, Enabled: types.NewBool(true)}
+	ruleOp := types.ArrayUpdateOperationAdd
+	if existingRule != nil {
+		ruleInfo.Key = existingRule.Key
+		ruleOp = types.ArrayUpdateOperationEdit
+	}
+
+	spec := &types.ClusterConfigSpecEx{}
+
+	var info types.BaseClusterRuleInfo
+	switch rule.Kind {
+	case jettypes.AffinityAnti:
+		info = &types.ClusterAntiAffinityRuleSpec{ClusterRuleInfo: ruleInfo, Vm: vms}
+	case jettypes.AffinityTogether:
+		info = &types.ClusterAffinityRuleSpec{ClusterRuleInfo: ruleInfo, Vm: vms}
+	case jettypes.AffinityHostGroup:
+		if !hasHostGroup(config, rule.HostGroup) {
+			return nil, fmt.Errorf("cluster %s has no host group %s", rule.Cluster, rule.HostGroup)
+		}
+		groupOp := types.ArrayUpdateOperationAdd
+		if existingGroup != nil {
+			groupOp = types.ArrayUpdateOperationEdit
+		}
+		spec.GroupSpec = []types.ClusterGroupSpec{{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: groupOp},
+			Info:            &types.ClusterVmGroup{ClusterGroupInfo: types.ClusterGroupInfo{Name: ruleVmGroup(rule)}, Vm: vms},
+		}}
+		ruleInfo.Mandatory = types.NewBool(false)
+		info = &types.ClusterVmHostRuleInfo{
+			ClusterRuleInfo:     ruleInfo,
+			VmGroupName:         ruleVmGroup(rule),
+			AffineHostGroupName: rule.HostGroup,
+		}
+	default:
+		return nil, fmt.Errorf("unknown rule kind %s", rule.Kind)
+	}
+
+	spec.RulesSpec = []types.ClusterRuleSpec{{ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: ruleOp}, Info: info}}
+
+	return spec, nil
+}
+
+// Applies a spec to a cluster and waits for a task.
+func reconfigureCluster(ctx context.Context, cluster *object.ClusterComputeResource, spec *types.ClusterConfigSpecEx) error {
+
+	task, err := cluster.Reconfigure(ctx, spec, true)
+	if err != nil {
+		return fmt.Errorf("failed reconfigure cluster %s %v", cluster.Name(), err)
+	}
+
+	return task.Wait(ctx)
+}
+
+/**
+  Creates a drs rule of a cluster, rule with same name updated with vms of a rule.
+*/
+func (p *VmwareVim) CreateAffinityRule(ctx context.Context, rule *jettypes.AffinityRule) error {
+
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+
+	cluster, err := p.ruleCluster(ctx, rule.Cluster)
+	if err != nil {
+		return err
+	}
+
+	config, err := cluster.Configuration(ctx)
+	if err != nil {
+		return fmt.Errorf("failed read cluster %s configuration %v", rule.Cluster, err)
+	}
+
+	spec, err := affinityRuleSpec(config, rule)
+	if err != nil {
+		return err
+	}
+
+	logging.Notification("Creating drs rule", rule.Name, "in cluster", cluster.Name())
+	return reconfigureCluster(ctx, cluster, spec)
+}
+
+/**
+  Deletes a drs rule and a vm group of a rule from a cluster.
+*/
+func (p *VmwareVim) DeleteAffinityRule(ctx context.Context, rule *jettypes.AffinityRule) error {
+
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+
+	cluster, err := p.ruleCluster(ctx, rule.Cluster)
+	if err != nil {
+		return err
+	}
+
+	config, err := cluster.Configuration(ctx)
+	if err != nil {
+		return fmt.Errorf("failed read cluster %s configuration %v", rule.Cluster, err)
+	}
+
+	existingRule, existingGroup := findClusterRule(config, rule.Name, ruleVmGroup(rule))
+	if existingRule == nil && existingGroup == nil {
+		return nil
+	}
+
+	spec := &types.ClusterConfigSpecEx{}
+	if existingRule != nil {
+		spec.RulesSpec = []types.ClusterRuleSpec{{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationRemove, RemoveKey: existingRule.Key},
+		}}
+	}
+	if existingGroup != nil {
+		spec.GroupSpec = []types.ClusterGroupSpec{{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationRemove, RemoveKey: existingGroup.Name},
+		}}
+	}
+
+	logging.Notification("Deleting drs rule", rule.Name, "from cluster", cluster.Name())
+	return reconfigureCluster(ctx, cluster, spec)
+}
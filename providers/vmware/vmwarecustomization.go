@@ -0,0 +1,94 @@
+package vmware
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+// Returns a prefix of node network, taken from node network or desired address of a template.
+func nodeNetmask(node *jettypes.NodeTemplate) (net.IPMask, error) {
+
+	if node.IPv4Net != nil {
+		return node.IPv4Net.Mask, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(node.DesiredAddress)
+	if err != nil {
+		return nil, fmt.Errorf("node %s has no network prefix %v", node.Name, err)
+	}
+
+	return ipNet.Mask, nil
+}
+
+// Returns a host label of a node, node name without domain suffix and dots.
+func nodeHostname(node *jettypes.NodeTemplate) string {
+	name := strings.TrimSuffix(node.GetHostname(), "."+node.DomainSuffix)
+	return strings.Replace(name, ".", "-", -1)
+}
+
+/**
+  Returns linux customization that sets hostname, static address, gateway
+  and name servers of a first adapter of a clone.
+*/
+func linuxCustomization(node *jettypes.NodeTemplate) (*types.CustomizationSpec, error) {
+
+	if node.IPv4Addr == nil || node.IPv4Addr.To4() == nil {
+		return nil, fmt.Errorf("static node %s has no ipv4 address", node.Name)
+	}
+
+	mask, err := nodeNetmask(node)
+	if err != nil {
+		return nil, err
+	}
+
+	ipSettings := types.CustomizationIPSettings{
+		Ip:            &types.CustomizationFixedIp{IpAddress: node.IPv4Addr.String()},
+		SubnetMask:    net.IP(mask).String(),
+		DnsServerList: node.DnsServers,
+	}
+	if len(node.Gateway) > 0 {
+		ipSettings.Gateway = []string{node.Gateway}
+	}
+
+	spec := &types.CustomizationSpec{
+		Identity: &types.CustomizationLinuxPrep{
+			HostName:   &types.CustomizationFixedName{Name: nodeHostname(node)},
+			Domain:     node.DomainSuffix,
+			HwClockUTC: types.NewBool(true),
+		},
+		GlobalIPSettings: types.CustomizationGlobalIPSettings{
+			DnsServerList: node.DnsServers,
+		},
+		NicSettingMap: []types.CustomizationAdapterMapping{{Adapter: ipSettings}},
+	}
+	if len(node.DomainSuffix) > 0 {
+		spec.GlobalIPSettings.DnsSuffixList = []string{node.DomainSuffix}
+	}
+
+	return spec, nil
+}
+
+/**
+  Returns extraConfig options that pass rendered cloud-init data of a node to
+  guestinfo datasource, empty if a node has no cloud-init data.
+*/
+func cloudInitExtraConfig(node *jettypes.NodeTemplate) []types.BaseOptionValue {
+
+	userData, metaData := node.CloudInitData()
+	if len(userData) == 0 {
+		return nil
+	}
+
+	return []types.BaseOptionValue{
+		&types.OptionValue{Key: "guestinfo.userdata", Value: base64.StdEncoding.EncodeToString([]byte(userData))},
+		&types.OptionValue{Key: "guestinfo.userdata.encoding", Value: "base64"},
+		&types.OptionValue{Key: "guestinfo.metadata", Value: base64.StdEncoding.EncodeToString([]byte(metaData))},
+		&types.OptionValue{Key: "guestinfo.metadata.encoding", Value: "base64"},
+	}
+}
@@ -0,0 +1,98 @@
+package vmware
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+func Test_linuxCustomization(t *testing.T) {
+
+	_, ipNet, _ := net.ParseCIDR("172.16.81.0/24")
+
+	tests := []struct {
+		name         string
+		node         *jettypes.NodeTemplate
+		wantHostname string
+		wantMask     string
+		wantGateway  []string
+		wantSuffix   []string
+		wantErr      bool
+	}{
+		{
+			name: "prefix of node network",
+			node: &jettypes.NodeTemplate{Name: "worker.0a1b.vmwarelab.edu", DomainSuffix: "vmwarelab.edu",
+				IPv4Addr: net.ParseIP("172.16.81.11"), IPv4Net: ipNet, Gateway: "172.16.81.1",
+				DnsServers: []string{"8.8.8.8"}},
+			wantHostname: "worker-0a1b",
+			wantMask:     "255.255.255.0",
+			wantGateway:  []string{"172.16.81.1"},
+			wantSuffix:   []string{"vmwarelab.edu"},
+		},
+		{
+			name: "prefix of template desired address",
+			node: &jettypes.NodeTemplate{Name: "worker", IPv4Addr: net.ParseIP("172.16.81.11"),
+				DesiredAddress: "172.16.81.0/20"},
+			wantHostname: "worker",
+			wantMask:     "255.255.240.0",
+		},
+		{
+			name:    "no address",
+			node:    &jettypes.NodeTemplate{Name: "worker", IPv4Net: ipNet},
+			wantErr: true,
+		},
+		{
+			name:    "ipv6 address",
+			node:    &jettypes.NodeTemplate{Name: "worker", IPv4Addr: net.ParseIP("fd00::1"), IPv4Net: ipNet},
+			wantErr: true,
+		},
+		{
+			name:    "no prefix",
+			node:    &jettypes.NodeTemplate{Name: "worker", IPv4Addr: net.ParseIP("172.16.81.11")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := linuxCustomization(tt.node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("linuxCustomization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			prep, ok := got.Identity.(*types.CustomizationLinuxPrep)
+			if !ok {
+				t.Fatalf("linuxCustomization() identity = %T, want linux prep", got.Identity)
+			}
+			if name := prep.HostName.(*types.CustomizationFixedName).Name; name != tt.wantHostname {
+				t.Errorf("linuxCustomization() hostname = %s, want %s", name, tt.wantHostname)
+			}
+
+			if len(got.NicSettingMap) != 1 {
+				t.Fatalf("linuxCustomization() adapters = %d, want 1", len(got.NicSettingMap))
+			}
+			adapter := got.NicSettingMap[0].Adapter
+			if ip := adapter.Ip.(*types.CustomizationFixedIp).IpAddress; ip != tt.node.IPv4Addr.String() {
+				t.Errorf("linuxCustomization() ip = %s, want %s", ip, tt.node.IPv4Addr)
+			}
+			if adapter.SubnetMask != tt.wantMask {
+				t.Errorf("linuxCustomization() mask = %s, want %s", adapter.SubnetMask, tt.wantMask)
+			}
+			if !reflect.DeepEqual(adapter.Gateway, tt.wantGateway) {
+				t.Errorf("linuxCustomization() gateway = %v, want %v", adapter.Gateway, tt.wantGateway)
+			}
+			if !reflect.DeepEqual(got.GlobalIPSettings.DnsServerList, tt.node.DnsServers) {
+				t.Errorf("linuxCustomization() dns = %v, want %v", got.GlobalIPSettings.DnsServerList, tt.node.DnsServers)
+			}
+			if !reflect.DeepEqual(got.GlobalIPSettings.DnsSuffixList, tt.wantSuffix) {
+				t.Errorf("linuxCustomization() dns suffix = %v, want %v", got.GlobalIPSettings.DnsSuffixList, tt.wantSuffix)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package vmware
+
+import (
+	"testing"
+
+	"github.com/spyroot/jettison/nsxtapi"
+)
+
+// Returns vmware vim with nsx-t manager, on vcsim nsx-t manager is nsxtsim.
+func setupNsxTest(t *testing.T) (*testingEnv, func(t *testing.T)) {
+
+	env, teardown := setupTest(t)
+	if env.TestVim.nsxtConfig == nil {
+		teardown(t)
+		t.Skip("test requires nsx-t manager")
+	}
+
+	return env, teardown
+}
+
+func Test_discoveryCluster(t *testing.T) {
+
+	env, teardown := setupNsxTest(t)
+	defer teardown(t)
+
+	tests := []struct {
+		name    string
+		vim     *VmwareVim
+		wantErr bool
+	}{
+		{
+			name:    "baseline",
+			vim:     env.TestVim,
+			wantErr: false,
+		},
+		{
+			name:    "rediscover",
+			vim:     env.TestVim,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.vim.discoveryCluster(); (err != nil) != tt.wantErr {
+				t.Errorf("discoveryCluster() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tt.vim.nsxtConfig.EdgeClusterUuid()) == 0 {
+				t.Errorf("discoveryCluster() expected cluster id set")
+			}
+			if len(tt.vim.nsxtConfig.TierZero()) == 0 {
+				t.Errorf("discoveryCluster() expect number of t0 > 0")
+			}
+			if len(tt.vim.nsxtConfig.TierOne()) == 0 {
+				t.Errorf("discoveryCluster() expect number of t1 > 0")
+			}
+		})
+	}
+}
+
+/*
+   discover all tier0/-tier1, add new one / re-discover
+*/
+func Test_AddReDiscoveryCluster(t *testing.T) {
+
+	env, teardown := setupNsxTest(t)
+	defer teardown(t)
+
+	vim := env.TestVim
+	config := vim.nsxtConfig
+
+	// discover
+	if err := vim.discoveryCluster(); err != nil {
+		t.Fatalf("discoveryCluster() error = %v", err)
+	}
+	if len(config.EdgeClusterUuid()) == 0 {
+		t.Fatalf("discoveryCluster() expected cluster id set")
+	}
+
+	tierZeroCount := len(config.TierZero())
+	tierOneCount := len(config.TierOne())
+
+	tier0, err := nsxtapi.CreateLogicalRouter(vim.GetNsx(), nsxtapi.RouterCreateReq{
+		Name:       "jettison-test1",
+		RouterType: nsxtapi.RouteTypeTier0,
+		ClusterID:  config.EdgeClusterUuid(),
+	})
+	if err != nil {
+		t.Fatalf("CreateLogicalRouter() error = %v", err)
+	}
+	defer func() {
+		if _, err := nsxtapi.DeleteLogicalRouter(vim.GetNsx(), tier0.Id); err != nil {
+			t.Errorf("DeleteLogicalRouter() error = %v", err)
+		}
+	}()
+
+	tier1, err := nsxtapi.CreateLogicalRouter(vim.GetNsx(), nsxtapi.RouterCreateReq{
+		Name:       "jettison-test2",
+		RouterType: nsxtapi.RouteTypeTier1,
+		ClusterID:  config.EdgeClusterUuid(),
+	})
+	if err != nil {
+		t.Fatalf("CreateLogicalRouter() error = %v", err)
+	}
+	defer func() {
+		if _, err := nsxtapi.DeleteLogicalRouter(vim.GetNsx(), tier1.Id); err != nil {
+			t.Errorf("DeleteLogicalRouter() error = %v", err)
+		}
+	}()
+
+	// re-discover
+	if err := vim.discoveryCluster(); err != nil {
+		t.Fatalf("discoveryCluster() error = %v", err)
+	}
+	if len(config.TierZero()) != tierZeroCount+1 {
+		t.Errorf("discoveryCluster() old t0 count = %d, new count %d", tierZeroCount, len(config.TierZero()))
+	}
+	if len(config.TierOne()) != tierOneCount+1 {
+		t.Errorf("discoveryCluster() old t1 count = %d, new count %d", tierOneCount, len(config.TierOne()))
+	}
+	if _, ok := config.TierZero()[tier0.Id]; !ok {
+		t.Errorf("discoveryCluster() didn't discover t0 %s", tier0.Id)
+	}
+	if _, ok := config.TierOne()[tier1.Id]; !ok {
+		t.Errorf("discoveryCluster() didn't discover t1 %s", tier1.Id)
+	}
+}
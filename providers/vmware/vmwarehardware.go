@@ -0,0 +1,123 @@
+package vmware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/spyroot/jettison/jettypes"
+)
+
+const kbInGb = 1024 * 1024
+
+// Returns hardware of a vm template and its devices, root disk is a first disk.
+func templateHardware(ctx context.Context, template *object.VirtualMachine) (jettypes.Hardware, object.VirtualDeviceList, error) {
+
+	var hw jettypes.Hardware
+
+	var mvm mo.VirtualMachine
+	err := template.Properties(ctx, template.Reference(), []string{"config.hardware"}, &mvm)
+	if err != nil {
+		return hw, nil, fmt.Errorf("failed retrieve template %s hardware %v", template.Name(), err)
+	}
+	if mvm.Config == nil {
+		return hw, nil, fmt.Errorf("template %s has no config", template.Name())
+	}
+
+	hw.Cpus = mvm.Config.Hardware.NumCPU
+	hw.CoresPerSocket = mvm.Config.Hardware.NumCoresPerSocket
+	hw.MemoryMB = int64(mvm.Config.Hardware.MemoryMB)
+
+	devices := object.VirtualDeviceList(mvm.Config.Hardware.Device)
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) > 0 {
+		hw.DiskGB = disks[0].(*types.VirtualDisk).CapacityInKB / kbInGb
+	}
+
+	return hw, devices, nil
+}
+
+// Returns datastore of a template root disk, nil if template has no disk.
+func rootDatastore(devices object.VirtualDeviceList) *types.ManagedObjectReference {
+
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil
+	}
+
+	backing, ok := disks[0].(*types.VirtualDisk).Backing.(types.BaseVirtualDeviceFileBackingInfo)
+	if !ok {
+		return nil
+	}
+
+	return backing.GetVirtualDeviceFileBackingInfo().Datastore
+}
+
+/**
+  Returns config spec a clone created with. Root disk grown in place, data disks
+  added to controller of root disk on a datastore of a clone, nil datastore
+  is datastore of root disk.
+*/
+func cloneConfigSpec(hw jettypes.Hardware, devices object.VirtualDeviceList,
+	datastore *types.ManagedObjectReference) (*types.VirtualMachineConfigSpec, error) {
+
+	spec := &types.VirtualMachineConfigSpec{
+		NumCPUs:           hw.Cpus,
+		NumCoresPerSocket: hw.CoresPerSocket,
+		MemoryMB:          hw.MemoryMB,
+	}
+
+	if hw.DiskGB == 0 && len(hw.DataDisksGB) == 0 {
+		return spec, nil
+	}
+
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("template has no disk")
+	}
+
+	root := *disks[0].(*types.VirtualDisk)
+	if capacity := hw.DiskGB * kbInGb; capacity > root.CapacityInKB {
+		root.CapacityInKB = capacity
+		root.CapacityInBytes = capacity * 1024
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    &root,
+		})
+	}
+
+	if len(hw.DataDisksGB) == 0 {
+		return spec, nil
+	}
+
+	controller, ok := devices.FindByKey(root.ControllerKey).(types.BaseVirtualController)
+	if !ok {
+		return nil, fmt.Errorf("controller of template root disk not found")
+	}
+
+	if datastore == nil {
+		datastore = rootDatastore(devices)
+	}
+	if datastore == nil {
+		return nil, fmt.Errorf("datastore of template root disk not found")
+	}
+	ds := *datastore
+
+	// each new disk takes next free unit, keys of new devices must be unique negatives
+	for i, size := range hw.DataDisksGB {
+		disk := devices.CreateDisk(controller, ds, "")
+		disk.Key = int32(-100 - i)
+		disk.CapacityInKB = size * kbInGb
+		devices = append(devices, disk)
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation:     types.VirtualDeviceConfigSpecOperationAdd,
+			FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
+			Device:        disk,
+		})
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,482 @@
+package vmware
+
+import (
+	"context"
+	"fmt"
+	"github.com/spyroot/jettison/vcenter"
+	"log"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+	"github.com/spyroot/jettison/netpool"
+	"github.com/spyroot/jettison/nsxtapi"
+)
+
+//  Dhcp clean up process check each node struct mac address if mac address in the struct
+//  it will use that to remove dhcp binding, if not it uses vm id to find object and
+//  figure out mac allocated for that vm. Each node cleaned up as a network job, binding
+//  that already gone is not a failure.
+func (p *VmwareVim) DhcpCleanup(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	results := p.pool.RunNodes(ctx, jettypes.NetworkJob, jettypes.OpUnbind, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			if len(node.Mac) == 0 {
+				logging.CriticalMessage("node", node.Name, "has no mac address associated")
+				return jettypes.NodeResult{}
+			}
+			err := nsxtapi.DhcpCleanupEntry(p.nsx(ctx), node)
+			if err == nil {
+				return jettypes.NodeResult{}
+			}
+
+			_, lookupErr := nsxtapi.GetStaticBinding(p.nsx(ctx), node.DhcpServerUuid(),
+				node.IPv4Addr.String(), nsxtapi.DhcpLookupHandler["ip"])
+			if _, ok := lookupErr.(*nsxtapi.ObjectNotFound); !ok {
+				log.Println("failed delete dhcp bind for", node.Name, err)
+				return jettypes.NodeResult{Err: err}
+			}
+
+			// get the VM from VIM and check if VM has a device with mac
+			_, _, vm, err := vcenter.VmFromCluster(ctx, p.VimClient(), node.Name, node.VimCluster)
+			if err == nil {
+				dev, _ := vm.Device(ctx)
+				if dev.PrimaryMacAddress() == node.Mac[0] {
+					logging.CriticalMessage("No dhcp bindings but mac address attached to VM")
+				}
+			}
+			return jettypes.NodeResult{}
+		})
+
+	return results.Err()
+}
+
+//
+//  a) Deploys network element a logical switch per segment or single logical switch
+//     shared by entire deployment.
+//
+//   b) A logical route tier 1
+//
+//   c) A dhcp server, unless nodes of a segment addressed statically. Dhcp server
+//      hands out given name servers and domain.
+func (p *VmwareVim) DeploySegment(ctx context.Context, projectName string, segmentName string,
+	gateway string, prefixLen int, dhcp bool, dnsServers []string, domain string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	tenantName := projectName
+	overlayID := p.nsxtConfig.OverlayTransportUuid()
+	clusterID := p.nsxtConfig.EdgeClusterUuid()
+	switchName := uuid.New().String()
+	routerName := uuid.New().String()
+
+	tierZero, err := p.nsxtConfig.GetActiveTierZero()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// create logical switch if need
+	switchID, switchName, err := nsxtapi.CreateSwitchIfNeed(p.nsx(ctx),
+		projectName, segmentName, overlayID, switchName)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return nil, nil, err
+	}
+
+	// create logical router if need
+	routerID, err := nsxtapi.CreateRouterIfNeed(p.nsx(ctx), tenantName, segmentName, routerName, clusterID)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return nil, nil, err
+	}
+
+	// bind a switch to a router downlink
+	logicalPortId, err := nsxtapi.CreateLogicalPortIfNeed(p.nsx(ctx), tenantName, switchID, routerID)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return nil, nil, err
+	}
+
+	// bind router to a switch that out downlink per segment
+	downlinkPort, err := nsxtapi.CreateRoutedPortIfNeed(p.nsx(ctx),
+		tenantName, routerID, switchID, logicalPortId, gateway, int64(prefixLen))
+	if err != nil {
+		logging.ErrorLogging(err)
+		return nil, nil, err
+	}
+
+	_, _, err = nsxtapi.ConnectTier1IfNeed(p.nsx(ctx), tenantName, routerID, tierZero)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return nil, nil, err
+	}
+
+	_, err = nsxtapi.DefaultRoutingAdvertisement(p.nsx(ctx), routerID)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return nil, nil, err
+	}
+
+	gwAddr := net.ParseIP(gateway)
+	if gwAddr == nil {
+		e := fmt.Errorf("invalid gateway format")
+		logging.ErrorLogging(err)
+		return nil, nil, e
+	}
+
+	var dhcpServerId string
+	if dhcp {
+		dhcpAddr := netpool.NextIP(gwAddr, 1)
+		fmtDhcp := fmt.Sprintf("%s/%d", dhcpAddr.String(), prefixLen)
+
+		var dhcpReq = &nsxtapi.DhcpServerCreateReq{
+			ServerName:     tenantName + "-" + segmentName,
+			DhcpServerIp:   fmtDhcp,
+			DnsNameservers: dnsServers,
+			DomainName:     domain,
+			GatewayIp:      gateway,
+			ClusterId:      clusterID,
+			SwitchId:       switchID,
+			TenantId:       tenantName,
+			Segment:        segmentName,
+		}
+
+		dhcpServerId, err = nsxtapi.CreateDhcpServiceIfNeed(p.nsx(ctx), dhcpReq)
+		if err != nil {
+			logging.ErrorLogging(err)
+			return nil, nil, err
+		}
+	}
+
+	logicalSwitch := jettypes.NewGenericSwitch(switchName, switchID, dhcpServerId, routerID)
+	logicalSwitch.SetRouterPortUuid(logicalPortId)
+
+	logicalRouter := jettypes.NewGenericRouter(routerName, routerID)
+	logicalRouter.SetSwitchPortUuid(downlinkPort)
+
+	return logicalSwitch, logicalRouter, err
+}
+
+/**
+
+ */
+func (p *VmwareVim) DiscoverClusterDhcpServer(ctx context.Context, projectName string, nodes *[]*jettypes.NodeTemplate) (bool, error) {
+
+	// find target logical switch for a k8s cluster -- this setting read global DHCP shared
+	// by entire cluster
+	for k, node := range *nodes {
+		logicalSwitch, err := nsxtapi.FindLogicalSwitch(p.nsx(ctx), node.GenericSwitch().Name(), nil)
+		if err != nil {
+			return false, fmt.Errorf("can't find logical switch %s error %s. "+
+				"please check configuration", node.GenericSwitch().Name(), err)
+		}
+
+		// we set switch id after discovery
+		(*nodes)[k].GenericSwitch().SetUuid(logicalSwitch.Id)
+		log.Println("Discovery...", node.GenericSwitch().Name(), " uuid ", logicalSwitch.Id)
+		// find a dhcp server for k8s cluster
+		logicalDhcpServer, err := nsxtapi.FindAttachedDhcpServerProfile(p.nsx(ctx), logicalSwitch.Id)
+		if err != nil {
+			return false, fmt.Errorf("can't find a dhcp server attached to logical switch error: %s", err)
+		}
+
+		// set dhcp server id
+		(*nodes)[k].GenericSwitch().SetDhcpUuid(logicalDhcpServer.Id)
+		// TODO set tier-1 router/ check default gateway IP/ tier-1 router must be attached to lds.
+	}
+
+	return true, nil
+}
+
+/**
+  Create dhcp binding for all nodes, each node bound as a network job.
+*/
+func (p *VmwareVim) CreateDhcpBindings(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	results := p.pool.RunNodes(ctx, jettypes.NetworkJob, jettypes.OpBind, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			err := p.SelectDhcpBinding(ctx, projectName, node)
+			if err != nil {
+				log.Println("failed create dhcp bind for", node.Name, err)
+			}
+			return jettypes.NodeResult{Err: err}
+		})
+
+	return results.Err()
+}
+
+//
+// Return existing DHCP binding
+//
+func (p *VmwareVim) SelectDhcpBinding(ctx context.Context, projectName string, node *jettypes.NodeTemplate) error {
+
+	if len(node.Mac[0]) == 0 {
+		return fmt.Errorf("node has no mac address")
+	}
+
+	dhcpId := node.GenericSwitch().DhcpUuid()
+	log.Println("Creating binding for node ", node.Name, node.Mac, node.IPv4Addr.String())
+
+	// lookup dhcp binding
+	dhcpBinding, err := nsxtapi.GetStaticBinding(p.nsx(ctx), dhcpId, node.Mac[0], nsxtapi.DhcpLookupHandler["mac"])
+	// binding already in system.
+	if err == nil {
+		node.DhcpStatus = jettypes.Created
+		if dhcpBinding.IpAddress == node.IPv4Addr.String() {
+			logging.Notification("Found existing binding for controller node: " + node.Name)
+			node.DhcpStatus = jettypes.Created
+			return nil
+		}
+	}
+
+	// check that we don't have IP attached to anything
+	val, err := nsxtapi.GetStaticBinding(p.nsx(ctx), dhcpId, node.IPv4Addr.String(), nsxtapi.DhcpLookupHandler["ip"])
+	// TODO refactor to object not found
+	if err != nil {
+		// IP attached not in use, we can create static binding
+		_, err := nsxtapi.CreateStaticBinding(p.nsx(ctx),
+			dhcpId,
+			node.Mac[0],
+			node.IPv4Addr.String(),
+			node.Name,
+			node.Gateway,
+			projectName)
+		log.Println("Created binding for", node.Name)
+		if err != nil {
+			return fmt.Errorf("failed create static dhcp binding")
+		}
+	} else {
+		// we can two case a) left over for a same node b) someone already allocate same IP to a node
+		if val.HostName != node.Name {
+			logging.CriticalMessage("Failed create binding. Another host",
+				val.HostName, "mac address", val.MacAddress, "already has a binding", node.DesiredAddress)
+			return fmt.Errorf("failed %s create binding another host dhcp conflict", node.DesiredAddress)
+		} else {
+			log.Println("Host", val.HostName, "already has dhcp static binding", node.DesiredAddress)
+		}
+	}
+
+	return nil
+}
+
+func (p *VmwareVim) DeleteDhcpServer(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+	profileId, _, err := nsxtapi.DeleteDhcpServer(p.nsx(ctx), node.DhcpServerUuid())
+	if err != nil {
+		return false, err
+	}
+
+	ok, _, err := nsxtapi.DeleteDhcpProfile(p.nsx(ctx), profileId)
+	if err != nil {
+		return false, err
+	}
+
+	return ok, err
+}
+
+// Implementation of plugin that use nsx-t api interface in router
+// delete semantics. It deletes a logical router with force flag
+// that will remove all attached ports
+func (p *VmwareVim) DeleteRouter(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+	return nsxtapi.DeleteLogicalRouter(p.nsx(ctx), node.RouterUuid())
+}
+
+// Implementation that use nsx-t to delete a router downlink port
+// that connects tier 1 router to a segment switch
+func (p *VmwareVim) DeleteRouterPort(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+
+	if node.GenericRouter() == nil {
+		return false, nil
+	}
+
+	err := nsxtapi.DeleteRoutedPort(p.nsx(ctx), node.GenericRouter().SwitchPortUuid())
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Implementation that use nsx-t to delete a logical switch with force flag
+// that will remove all attached ports
+// TODO split logic between nsx or dvs
+func (p *VmwareVim) DeleteSwitch(ctx context.Context, node *jettypes.NodeTemplate) (bool, error) {
+	return nsxtapi.DeleteLogicalSwitch(p.nsx(ctx), node.SwitchUuid())
+}
+
+// Implementation that use nsx-t to add a static
+// route to a given tier 1 router
+func (p *VmwareVim) AddStaticRoute(ctx context.Context, projectName string,
+	node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
+
+	req := nsxtapi.AddStaticReq{}
+	req.RouterUuid = node.GenericRouter().Uuid()
+	req.Network = podNetwork
+	req.NextHopAddr = node.IPv4Addr
+
+	return nsxtapi.AddStaticRoute(p.nsx(ctx), req)
+}
+
+// Implementation that use nsx-t to delete a static
+// route for a pod network from a given tier 1 router
+func (p *VmwareVim) DeleteStaticRoute(ctx context.Context, projectName string,
+	node *jettypes.NodeTemplate, podNetwork string) (bool, error) {
+
+	req := nsxtapi.AddStaticReq{}
+	req.RouterUuid = node.RouterUuid()
+	req.Network = podNetwork
+	req.NextHopAddr = node.IPv4Addr
+
+	return nsxtapi.DeleteStaticRoute(p.nsx(ctx), req)
+}
+
+// Returns ip address of a static binding of node mac on node dhcp server.
+// Node without a binding is not an error.
+func (p *VmwareVim) DescribeBinding(ctx context.Context, node *jettypes.NodeTemplate) (string, bool, error) {
+
+	if len(node.Mac) == 0 || len(node.Mac[0]) == 0 {
+		return "", false, fmt.Errorf("node has no mac address")
+	}
+
+	binding, err := nsxtapi.GetStaticBinding(p.nsx(ctx), node.DhcpServerUuid(),
+		node.Mac[0], nsxtapi.DhcpLookupHandler[nsxtapi.DhcpMacLookup])
+	if err != nil {
+		if _, ok := err.(*nsxtapi.ObjectNotFound); ok {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	return binding.IpAddress, true, nil
+}
+
+// Returns a switch, an attached dhcp server and a router of a segment created
+// outside of jettison. Segment without a router reported with empty router uuid,
+// segment without nsx-t dhcp server reported with empty dhcp server uuid.
+func (p *VmwareVim) DescribeSegment(ctx context.Context, switchUuid string) (*jettypes.GenericSwitch, *jettypes.GenericRouter, error) {
+
+	logicalSwitch, err := nsxtapi.FindLogicalSwitch(p.nsx(ctx), switchUuid, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't find logical switch %s error %s", switchUuid, err)
+	}
+
+	dhcpUuid := ""
+	dhcpServer, err := nsxtapi.FindAttachedDhcpServerProfile(p.nsx(ctx), logicalSwitch.Id)
+	if err != nil {
+		if _, ok := err.(*nsxtapi.ObjectNotFound); !ok {
+			return nil, nil, fmt.Errorf("can't find a dhcp server attached to logical switch %s error: %s",
+				logicalSwitch.DisplayName, err)
+		}
+		log.Println("Segment", logicalSwitch.DisplayName, "has no dhcp server")
+	} else {
+		dhcpUuid = dhcpServer.Id
+	}
+
+	router := jettypes.NewGenericRouter("", "")
+	port, err := nsxtapi.FindSwitchRouterPort(p.nsx(ctx), logicalSwitch.Id)
+	if err != nil {
+		if _, ok := err.(*nsxtapi.ObjectNotFound); !ok {
+			return nil, nil, err
+		}
+		log.Println("Segment", logicalSwitch.DisplayName, "not attached to a router")
+	} else {
+		router.SetUuid(port.LogicalRouterId)
+		router.SetSwitchPortUuid(port.Id)
+	}
+
+	segment := jettypes.NewGenericSwitch(logicalSwitch.DisplayName, logicalSwitch.Id, dhcpUuid, router.Uuid())
+
+	return segment, router, nil
+}
+
+// Returns switches, routers, router ports, dhcp servers, profiles and static
+// bindings tagged with a project, nsx-t create time reported in milliseconds since epoch.
+func (p *VmwareVim) TaggedObjects(ctx context.Context, projectName string) ([]jettypes.NetworkObject, error) {
+
+	var objects []jettypes.NetworkObject
+
+	created := func(ms int64) time.Time {
+		return time.Unix(0, ms*int64(time.Millisecond))
+	}
+
+	switches, err := nsxtapi.FindLogicalSwitchesByTenant(p.nsx(ctx), projectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range switches {
+		objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectSwitch, Id: s.Id,
+			Name: s.DisplayName, Project: nsxtapi.TenantTag(s.Tags), Created: created(s.CreateTime)})
+	}
+
+	routers, err := nsxtapi.FindLogicalRoutersByTenant(p.nsx(ctx), projectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range routers {
+		objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectRouter, Id: r.Id,
+			Name: r.DisplayName, Project: nsxtapi.TenantTag(r.Tags), Created: created(r.CreateTime)})
+	}
+
+	servers, err := nsxtapi.FindDhcpServersByTenant(p.nsx(ctx), projectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range servers {
+		objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectDhcpServer, Id: s.Id,
+			Name: s.DisplayName, Project: nsxtapi.TenantTag(s.Tags), Created: created(s.CreateTime)})
+
+		bindings, err := nsxtapi.FindStaticBindingsByTenant(p.nsx(ctx), s.Id, projectName)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bindings {
+			objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectDhcpBinding, Id: b.Id,
+				Name: b.HostName, Parent: s.Id, Project: nsxtapi.TenantTag(b.Tags), Created: created(b.CreateTime)})
+		}
+	}
+
+	profiles, err := nsxtapi.FindDhcpProfilesByTenant(p.nsx(ctx), projectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range profiles {
+		objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectDhcpProfile, Id: v.Id,
+			Name: v.DisplayName, Project: nsxtapi.TenantTag(v.Tags), Created: created(v.CreateTime)})
+	}
+
+	ports, err := nsxtapi.FindRouterPortsByTenant(p.nsx(ctx), projectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range ports {
+		objects = append(objects, jettypes.NetworkObject{Kind: jettypes.ObjectRouterPort, Id: v.Id,
+			Name: v.DisplayName, Parent: v.LogicalRouterId, Project: nsxtapi.TenantTag(v.Tags), Created: created(v.CreateTime)})
+	}
+
+	return objects, nil
+}
+
+// Deletes a single nsx-t object. Dhcp server deleted with a port
+// that attaches it to a switch, a switch deleted with all own ports.
+func (p *VmwareVim) DeleteObject(ctx context.Context, object jettypes.NetworkObject) error {
+
+	var err error
+
+	switch object.Kind {
+	case jettypes.ObjectDhcpBinding:
+		err = nsxtapi.DeleteStaticBindingById(p.nsx(ctx), object.Parent, object.Id)
+	case jettypes.ObjectDhcpServer:
+		_, _, err = nsxtapi.DeleteDhcpServer(p.nsx(ctx), object.Id)
+	case jettypes.ObjectDhcpProfile:
+		_, _, err = nsxtapi.DeleteDhcpProfile(p.nsx(ctx), object.Id)
+	case jettypes.ObjectRouterPort:
+		err = nsxtapi.DeleteRoutedPort(p.nsx(ctx), object.Id)
+	case jettypes.ObjectRouter:
+		_, err = nsxtapi.DeleteLogicalRouter(p.nsx(ctx), object.Id)
+	case jettypes.ObjectSwitch:
+		_, err = nsxtapi.DeleteLogicalSwitch(p.nsx(ctx), object.Id)
+	default:
+		err = fmt.Errorf("unknown object kind %s", object.Kind)
+	}
+
+	return err
+}
@@ -0,0 +1,255 @@
+package vmware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+)
+
+const bytesInGb = 1024 * 1024 * 1024
+
+// placement of a clone resolved to vim objects, nil keeps placement of a template
+type clonePlacement struct {
+	folder    string
+	datastore *types.ManagedObjectReference
+	pool      *types.ManagedObjectReference
+	host      *types.ManagedObjectReference
+}
+
+// Returns relocate spec of a clone.
+func (c *clonePlacement) relocateSpec() types.VirtualMachineRelocateSpec {
+	return types.VirtualMachineRelocateSpec{
+		Datastore: c.datastore,
+		Pool:      c.pool,
+		Host:      c.host,
+	}
+}
+
+// Returns a finder that resolves relative paths inside of plugin datacenter.
+func (p *VmwareVim) datacenterFinder() *find.Finder {
+	finder := find.NewFinder(p.VimClient(), true)
+	finder.SetDatacenter(p.datacenter)
+	return finder
+}
+
+// Returns absolute inventory path of a folder, relative path is relative to vm folder of datacenter.
+func (p *VmwareVim) folderInventoryPath(folder string) string {
+	if strings.HasPrefix(folder, "/") {
+		return folder
+	}
+	return p.datacenter.InventoryPath + "/vm/" + folder
+}
+
+/**
+  Returns a datastore of a placement. For datastore cluster a member
+  datastore with most free space taken.
+*/
+func (p *VmwareVim) findPlacementDatastore(ctx context.Context, finder *find.Finder, name string) (*object.Datastore, error) {
+
+	ds, err := finder.Datastore(ctx, name)
+	if err == nil {
+		return ds, nil
+	}
+	if _, ok := err.(*find.NotFoundError); !ok {
+		return nil, fmt.Errorf("failed find datastore %s %v", name, err)
+	}
+
+	pod, err := finder.DatastoreCluster(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("datastore or datastore cluster %s not found", name)
+	}
+
+	children, err := pod.Children(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed list datastore cluster %s %v", name, err)
+	}
+
+	var (
+		best     *object.Datastore
+		bestFree int64
+	)
+	for _, child := range children {
+		if child.Reference().Type != "Datastore" {
+			continue
+		}
+		member := object.NewDatastore(p.VimClient(), child.Reference())
+		var mds mo.Datastore
+		err = member.Properties(ctx, member.Reference(), []string{"summary"}, &mds)
+		if err != nil {
+			return nil, fmt.Errorf("failed read datastore of cluster %s %v", name, err)
+		}
+		if mds.Summary.Accessible && (best == nil || mds.Summary.FreeSpace > bestFree) {
+			best, bestFree = member, mds.Summary.FreeSpace
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("datastore cluster %s has no accessible datastore", name)
+	}
+
+	return best, nil
+}
+
+/**
+  Resolves datastore, resource pool and host of a node and renders its folder.
+  Node placed on a host without resource pool gets resource pool of a host.
+*/
+func (p *VmwareVim) nodePlacement(ctx context.Context, finder *find.Finder,
+	projectName string, uuid string, node *jettypes.NodeTemplate) (*clonePlacement, error) {
+
+	var err error
+	placement := &clonePlacement{}
+
+	placement.folder, err = node.RenderFolder(projectName, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := node.Placement
+	if len(spec.Datastore) > 0 {
+		ds, err := p.findPlacementDatastore(ctx, finder, spec.Datastore)
+		if err != nil {
+			return nil, err
+		}
+		ref := ds.Reference()
+		placement.datastore = &ref
+	}
+
+	if len(spec.ResourcePool) > 0 {
+		pool, err := finder.ResourcePool(ctx, spec.ResourcePool)
+		if err != nil {
+			return nil, fmt.Errorf("resource pool %s of %s not found %v", spec.ResourcePool, node.Name, err)
+		}
+		ref := pool.Reference()
+		placement.pool = &ref
+	}
+
+	if len(spec.Host) > 0 {
+		host, err := finder.HostSystem(ctx, spec.Host)
+		if err != nil {
+			return nil, fmt.Errorf("host %s of %s not found %v", spec.Host, node.Name, err)
+		}
+		ref := host.Reference()
+		placement.host = &ref
+
+		if placement.pool == nil {
+			pool, err := host.ResourcePool(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed find resource pool of host %s %v", spec.Host, err)
+			}
+			poolRef := pool.Reference()
+			placement.pool = &poolRef
+		}
+	}
+
+	return placement, nil
+}
+
+// Returns space a clone needs on a datastore, disks counted as thick.
+func requiredSpace(hw jettypes.Hardware) int64 {
+	required := hw.DiskGB
+	for _, size := range hw.DataDisksGB {
+		required += size
+	}
+	return required * bytesInGb
+}
+
+/**
+  Checks that each datastore has free space for all clones placed on it.
+*/
+func (p *VmwareVim) checkFreeSpace(ctx context.Context, usage map[types.ManagedObjectReference]int64) error {
+
+	for ref, required := range usage {
+		var mds mo.Datastore
+		ds := object.NewDatastore(p.VimClient(), ref)
+		err := ds.Properties(ctx, ref, []string{"summary"}, &mds)
+		if err != nil {
+			return fmt.Errorf("failed read datastore %s %v", ref.Value, err)
+		}
+		if !mds.Summary.Accessible {
+			return fmt.Errorf("datastore %s is not accessible", mds.Summary.Name)
+		}
+		if mds.Summary.FreeSpace < required {
+			return fmt.Errorf("datastore %s has %dGB free, clones require %dGB",
+				mds.Summary.Name, mds.Summary.FreeSpace/bytesInGb, required/bytesInGb)
+		}
+	}
+
+	return nil
+}
+
+/**
+  Returns a folder for a path, each missing folder of a path created.
+*/
+func (p *VmwareVim) createFolderPath(ctx context.Context, finder *find.Finder, root *object.Folder, path string) (*object.Folder, error) {
+
+	folder := root
+	current := p.folderInventoryPath("")
+	for _, elem := range strings.Split(path, "/") {
+		current = strings.TrimSuffix(current, "/") + "/" + elem
+
+		next, err := finder.Folder(ctx, current)
+		if err == nil {
+			folder = next
+			continue
+		}
+		if _, ok := err.(*find.NotFoundError); !ok {
+			return nil, fmt.Errorf("failed find folder %s %v", current, err)
+		}
+
+		folder, err = folder.CreateFolder(ctx, elem)
+		if err != nil {
+			return nil, fmt.Errorf("failed create folder %s %v", current, err)
+		}
+		folder.InventoryPath = current
+	}
+
+	return folder, nil
+}
+
+/**
+  Destroys a folder only if it is empty, folder shared with other vms is kept.
+*/
+func (p *VmwareVim) deleteFolder(ctx context.Context, path string) error {
+
+	if len(path) == 0 {
+		return nil
+	}
+
+	if p.datacenter == nil {
+		if err := p.discoverDatacenter(ctx); err != nil {
+			return err
+		}
+	}
+
+	folder, err := p.datacenterFinder().Folder(ctx, p.folderInventoryPath(path))
+	if err != nil {
+		if _, ok := err.(*find.NotFoundError); ok {
+			return nil
+		}
+		return fmt.Errorf("failed find folder %s %v", path, err)
+	}
+
+	children, err := folder.Children(ctx)
+	if err != nil {
+		return fmt.Errorf("failed list folder %s %v", path, err)
+	}
+	if len(children) > 0 {
+		logging.Notification("Folder", path, "is not empty, folder kept")
+		return nil
+	}
+
+	task, err := folder.Destroy(ctx)
+	if err != nil {
+		return fmt.Errorf("failed delete folder %s %v", path, err)
+	}
+
+	return task.Wait(ctx)
+}
@@ -0,0 +1,123 @@
+package vmware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/spyroot/jettison/logging"
+)
+
+// description of a snapshot jettison created, only such snapshot removed by jettison
+const jettisonSnapshotDescription = "created by jettison for linked clones"
+
+// Returns a snapshot of a tree by name, nil if not found.
+func findSnapshot(tree []types.VirtualMachineSnapshotTree, name string) *types.VirtualMachineSnapshotTree {
+	for i := range tree {
+		if tree[i].Name == name {
+			return &tree[i]
+		}
+		if s := findSnapshot(tree[i].ChildSnapshotList, name); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// Returns a snapshot of a vm by name and true if vm is marked as template.
+func vmSnapshot(ctx context.Context, vm *object.VirtualMachine, name string) (*types.VirtualMachineSnapshotTree, bool, error) {
+
+	var mvm mo.VirtualMachine
+	err := vm.Properties(ctx, vm.Reference(), []string{"snapshot", "config.template"}, &mvm)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed read snapshots of %s %v", vm.Name(), err)
+	}
+
+	isTemplate := mvm.Config != nil && mvm.Config.Template
+	if mvm.Snapshot == nil {
+		return nil, isTemplate, nil
+	}
+
+	return findSnapshot(mvm.Snapshot.RootSnapshotList, name), isTemplate, nil
+}
+
+/**
+  Returns a snapshot of a template linked clones created from. Missing snapshot
+  created, vm marked as template can't be snapshotted so its snapshot must exist.
+*/
+func templateSnapshot(ctx context.Context, template *object.VirtualMachine, name string) (*types.ManagedObjectReference, error) {
+
+	snapshot, isTemplate, err := vmSnapshot(ctx, template, name)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		return &snapshot.Snapshot, nil
+	}
+	if isTemplate {
+		return nil, fmt.Errorf("template %s has no snapshot %s, create it before vm marked as template",
+			template.Name(), name)
+	}
+
+	logging.Notification("Creating snapshot", name, "of template", template.Name())
+	task, err := template.CreateSnapshot(ctx, name, jettisonSnapshotDescription, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed create snapshot %s of %s %v", name, template.Name(), err)
+	}
+
+	err = task.Wait(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed create snapshot %s of %s %v", name, template.Name(), err)
+	}
+
+	snapshot, _, err = vmSnapshot(ctx, template, name)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("snapshot %s of %s not found after create", name, template.Name())
+	}
+
+	return &snapshot.Snapshot, nil
+}
+
+/**
+  Removes a snapshot of a template linked clones created from. Snapshot
+  that jettison didn't create is kept.
+*/
+func (p *VmwareVim) DeleteTemplateSnapshot(ctx context.Context, vmTemplate string, name string) error {
+
+	template, err := p.datacenterFinder().VirtualMachine(ctx, vmTemplate)
+	if err != nil {
+		return fmt.Errorf("failed find template %s %v", vmTemplate, err)
+	}
+
+	snapshot, _, err := vmSnapshot(ctx, template, name)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return nil
+	}
+	if snapshot.Description != jettisonSnapshotDescription {
+		logging.Notification("Snapshot", name, "of template", vmTemplate, "not created by jettison, snapshot kept")
+		return nil
+	}
+
+	logging.Notification("Deleting snapshot", name, "of template", vmTemplate)
+	req := types.RemoveSnapshot_Task{
+		This:           snapshot.Snapshot,
+		RemoveChildren: false,
+		Consolidate:    types.NewBool(true),
+	}
+	res, err := methods.RemoveSnapshot_Task(ctx, p.VimClient(), &req)
+	if err != nil {
+		return fmt.Errorf("failed delete snapshot %s of %s %v", name, vmTemplate, err)
+	}
+
+	return object.NewTask(p.VimClient(), res.Returnval).Wait(ctx)
+}
@@ -0,0 +1,1003 @@
+package vmware
+
+import (
+	"context"
+	"fmt"
+	"github.com/vmware/govmomi/vim25"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmware/go-vmware-nsxt"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/logging"
+	"github.com/spyroot/jettison/nsxtapi"
+	"github.com/spyroot/jettison/providers"
+	"github.com/spyroot/jettison/vcenter"
+)
+
+// name vmware provider registered with
+const ProviderName = "vmware"
+
+func init() {
+	providers.Register(ProviderName, Init)
+}
+
+/*
+   Main Vmware vCenter VIM implementation
+*/
+type VmwareVim struct {
+	// compute layer
+	vimApi *govmomi.Client
+
+	VimFinder *find.Finder
+
+	datacenter *object.Datacenter
+
+	// root context
+	ctx context.Context
+
+	// Active nsx connection
+	nsxApi nsxt.APIClient
+
+	// nsxt config
+	nsxtConfig *NsxtConfig
+
+	dcName string
+
+	// vim credentials, tagging api needs own session
+	endpoint jettypes.VimEndpoint
+
+	// bounds concurrent clone and destroy tasks
+	pool *jettypes.WorkerPool
+}
+
+// Returns vim client
+func (p *VmwareVim) GetNsx() *nsxt.APIClient {
+	if p != nil {
+		return &p.nsxApi
+	}
+	return nil
+}
+
+// Returns nsx client bound to a context of a call
+func (p *VmwareVim) nsx(ctx context.Context) *nsxt.APIClient {
+	return nsxtapi.WithContext(ctx, &p.nsxApi)
+}
+
+/*
+   Discovers baseline network element. For nsx-t it transport zone
+   edge cluster.
+*/
+func (p *VmwareVim) discoverNetwork() error {
+
+	err := p.DiscoverNetworkElements()
+	if err != nil {
+		e := fmt.Errorf("failed discover nsx-t: error %v", err)
+		logging.ErrorLogging(e)
+		return e
+	}
+
+	// validate that discovery process able find a target transport zone
+	if len(p.nsxtConfig.OverlayTransportUuid()) == 0 {
+		return fmt.Errorf("failed discover overlay transpot")
+	}
+	if len(p.nsxtConfig.EdgeClusterUuid()) == 0 {
+		return fmt.Errorf("failed discover overlay transpot")
+	}
+
+	log.Print("Discovered overlay transport zone\t", p.nsxtConfig.OverlayTransportUuid())
+	log.Print("Discovered edge cluster\t\t\t\t", p.nsxtConfig.EdgeClusterUuid())
+
+	return nil
+}
+
+// TODO add cluster
+//
+func (p *VmwareVim) discoverDatacenter(ctx context.Context) error {
+
+	p.VimFinder = find.NewFinder(p.vimApi.Client, true)
+	if p.VimFinder == nil {
+		return fmt.Errorf("couldn't acquire finder object")
+	}
+
+	if len(p.dcName) != 0 {
+		var err error
+		p.datacenter, err = p.VimFinder.Datacenter(ctx, p.dcName)
+		if err != nil {
+			return fmt.Errorf("failed to get data center details check config and vim")
+		}
+
+		return nil
+	}
+
+	// last resort
+	var err error
+	p.datacenter, err = find.NewFinder(p.vimApi.Client).DefaultDatacenter(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get data center details check config and vim")
+	}
+
+	return nil
+}
+
+// Sets worker pool shared with vim
+func (p *VmwareVim) SetWorkerPool(pool *jettypes.WorkerPool) {
+	if pool != nil {
+		p.pool = pool
+	}
+}
+
+/*
+  Initialize a plugin based on config passed from VIM
+*/
+func (p *VmwareVim) InitPlugin(ctx context.Context, vimEndpoint jettypes.VimEndpoint) error {
+
+	if vimEndpoint == nil {
+		return fmt.Errorf("can't initilize plugin with nil arguments")
+	}
+
+	log.Print("VMware vim loaded ",
+		vimEndpoint.Endpoint(), " ", vimEndpoint.VimUsername())
+
+	// open connection to vCenter or ESXi
+	p.ctx = ctx
+	p.endpoint = vimEndpoint
+	vsphereClient, err := vcenter.Connect(p.ctx,
+		vimEndpoint.Endpoint(),
+		vimEndpoint.VimUsername(),
+		vimEndpoint.VimPassword())
+	if err != nil {
+		return fmt.Errorf("failed to connect to vCenter. %s", err)
+	}
+	p.vimApi = vsphereClient
+
+	log.Print("Vmware vim version: ", p.vimApi.Version)
+
+	p.VimFinder = find.NewFinder(p.vimApi.Client, true)
+	if p.VimFinder == nil {
+		return fmt.Errorf("couldn't acquire finder")
+	}
+
+	p.datacenter, err = p.VimFinder.Datacenter(ctx, vimEndpoint.VimDatacenter())
+	if err != nil {
+		return fmt.Errorf("failed to get data center details check config and vim")
+	}
+
+	// read plugin configuration, unless configuration already set
+	if p.nsxtConfig == nil {
+		nsxConfig, err := NewNsxtConfig()
+		if err != nil {
+			return fmt.Errorf("failed to get data center details check config and vim")
+		}
+		p.nsxtConfig = nsxConfig
+	}
+
+	// open connection to vCenter or NSX-T
+	nsxtClient, nsxError := nsxtapi.Connect(p.nsxtConfig.Hostname(),
+		p.nsxtConfig.Username(),
+		p.nsxtConfig.Password())
+	if nsxError != nil {
+		return fmt.Errorf("failed to connect to nsx-t manager")
+	}
+	p.nsxApi = nsxtClient
+
+	err = p.discoverNetwork()
+	if err != nil {
+		return fmt.Errorf("failed discover nsx-t network elements")
+	}
+
+	return nil
+}
+
+//
+//  Main entry point for provider, creates vCenter and NSX-T vim
+//
+func Init() (jettypes.VimPlugin, error) {
+
+	vmwareVim := &VmwareVim{}
+	vmwareVim.pool = jettypes.NewWorkerPool(jettypes.DefaultParallelJobs, nil)
+
+	log.Print("Loaded")
+
+	return vmwareVim, nil
+}
+
+// Returns vim client
+func (p *VmwareVim) VimClient() *vim25.Client {
+	if p != nil {
+		if p.vimApi == nil {
+			log.Print("Wrong state")
+		}
+		return p.vimApi.Client
+	}
+	return nil
+}
+
+/*
+   Function check that switch indicate by switchName present in vCenter.
+   TODO Add check based on UUID.  Not clear from Opaqu how to extract that.
+*/
+func checkNetworkAttachment(networks *[]mo.Network, switchName string) bool {
+
+	for _, network := range *networks {
+		if network.Name == switchName {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+  vCenter object finder in path
+*/
+func (p *VmwareVim) findVmObject(ctx context.Context, vmName string) (*object.VirtualMachine, error) {
+
+	vimPath := p.datacenter.InventoryPath + "/*/" + vmName
+	vm, err := p.VimFinder.VirtualMachine(ctx, vimPath)
+	if err != nil {
+		newErr := fmt.Errorf("failed retrieve vm %s details from infrastracture", err)
+		logging.ErrorLogging(newErr)
+		return nil, newErr
+	}
+
+	return vm, nil
+}
+
+/*
+   Function searches a VM template and returns respected
+   *object.VirtualMachine, *[]mo.Network,
+
+   It sets a template node uuid to vim uuid value
+*/
+func (p *VmwareVim) discoverVmTemplate(ctx context.Context, node *jettypes.NodeTemplate) (*object.VirtualMachine, *[]mo.Network, error) {
+
+	if node == nil {
+		return nil, nil, fmt.Errorf("node is nil")
+	}
+
+	vmSummary, err := vcenter.GetVmAttr(ctx, p.VimClient(), vcenter.VmSearchHandler["name"], node.VmTemplateName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve vm template. err: %s", err)
+	}
+
+	if node.UUID == "" {
+		node.UUID = vmSummary.Summary.Config.Uuid
+	}
+
+	vm, err := p.findVmObject(ctx, node.VmTemplateName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find vm template object. err: %s", err)
+	}
+
+	node.SetVimName(vm.Reference().Value)
+
+	devs, err := vm.Device(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, dev := range devs {
+		if nic, ok := dev.(types.BaseVirtualEthernetCard); ok {
+			node.Mac = append(node.Mac, nic.GetVirtualEthernetCard().MacAddress)
+		}
+	}
+
+	networks, err := vcenter.GetNetworkAttr(ctx, p.VimClient(), vm.Reference().Value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find vm template networks, err: %s", err)
+	}
+
+	for _, net := range *networks {
+		node.NetworksRef = append(node.NetworksRef, net.Name)
+	}
+
+	return vm, networks, nil
+}
+
+/**
+  Function fetch a template VM from vCenter
+  Note template must have ethernet adapter attached to correct logical switch.
+*/
+func (p *VmwareVim) DiscoverVmTemplate(ctx context.Context, node *jettypes.NodeTemplate) error {
+
+	_, _, err := p.discoverVmTemplate(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/**
+  Function fetch a template VM from vCenter
+  Note template must have ethernet adapter attached to correct logical switch.
+*/
+func (p *VmwareVim) DiscoverVmTemplates(ctx context.Context, node *jettypes.NodeTemplate) (*object.VirtualMachine, *[]mo.Network, error) {
+
+	if node == nil {
+		return nil, nil, fmt.Errorf("node is nil")
+	}
+
+	vm, networks, err := p.discoverVmTemplate(ctx, node)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retieve template %s", err)
+	}
+
+	if len(*networks) == 0 {
+		//		p.ConnectVm()
+		return nil, nil, fmt.Errorf("vm has no network attached %s. "+
+			"Please check that template has network adapter and attached to correct switch",
+			node.GenericSwitch().Name())
+	}
+
+	// TODO do re-binding here
+	// check template attached to correct logical switch
+	if checkNetworkAttachment(networks, node.GenericSwitch().Name()) == false {
+		return nil, nil, fmt.Errorf("vm has no network adapter attached to %s", node.GenericSwitch().Name())
+	}
+
+	return vm, networks, nil
+}
+
+//
+func (p *VmwareVim) disconnectVm(ctx context.Context, vmName string, node *jettypes.NodeTemplate) (bool, error) {
+
+	vm, err := find.NewFinder(p.VimClient()).VirtualMachine(ctx, vmName)
+	if err != nil {
+		return false, err
+	}
+
+	_, _ = p.ChangePowerState(ctx, node, jettypes.PowerOff)
+	//	vm.PowerOff()
+
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < len(devices); i++ {
+		if devices.Type(devices[i]) == "ethernet" {
+			card := devices[i].(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+			err = devices.Disconnect(card)
+			if err != nil {
+				logging.CriticalMessage("failed disconnect adapter")
+			}
+			logging.Notification("Disconnected and removing a device")
+			err = vm.RemoveDevice(ctx, false, card)
+			if err != nil {
+				logging.CriticalMessage("failed remove adapter")
+			}
+		}
+	}
+
+	return true, nil
+}
+
+//
+//
+//
+func (p *VmwareVim) DisconnectVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) (bool, error) {
+
+	if node.IsTemplate() {
+		return p.disconnectVm(ctx, node.VmTemplateName, node)
+	}
+	return p.disconnectVm(ctx, node.Name, node)
+}
+
+//
+//  Function checks that vm attached to a target logical switch
+//  if vm not found it returns false in case or problem with request error
+//  otherwise true
+func (p *VmwareVim) isAttached(ctx context.Context, vmName string, switchName string) (bool, error) {
+
+	net, err := vcenter.GetNetworks(ctx, p.VimClient(), switchName)
+	if err != nil {
+		if _, ok := err.(*vcenter.VmNotFound); ok {
+			return false, nil
+		}
+		return false, fmt.Errorf("invalid logical switch: %s", switchName)
+	}
+
+	for _, v := range net.Vm {
+		if vmName == v.Value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// clean up routine, powers off and destroys a vm. Returns a reference of destroy task,
+// vm already gone counts as destroyed.
+func (p *VmwareVim) cleanupNode(ctx context.Context, projectName string,
+	node *jettypes.NodeTemplate) (string, error) {
+
+	logging.Notification("Trying deleting vm", node.Name, " folder ", node.GetFolderPath())
+	_, _, vm, err := vcenter.VmFromCluster(ctx, p.VimClient(), node.Name, node.VimCluster)
+	if err != nil {
+		if _, ok := err.(*vcenter.VmNotFound); ok {
+			logging.Notification("Vm", node.Name, " already deleted")
+			return "", nil
+		}
+		return "", err
+	}
+
+	//acquire  create a task and block
+	pState, err := vm.PowerState(ctx)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return "", err
+	}
+	// check power state
+	if pState == types.VirtualMachinePowerStatePoweredOn {
+		powerOfTask, err := vm.PowerOff(ctx)
+		if err != nil {
+			logging.ErrorLogging(err)
+			return "", err
+		}
+		// wait for result and block
+		_, err = powerOfTask.WaitForResult(ctx, nil)
+		if err != nil {
+			logging.ErrorLogging(err)
+			return powerOfTask.Reference().Value, err
+		}
+	}
+	// destroy vm and wait for result
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return "", err
+	}
+	_, err = task.WaitForResult(ctx, nil)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return task.Reference().Value, err
+	}
+
+	logging.Notification("VM successfully deleted ")
+
+	return task.Reference().Value, nil
+}
+
+// Powers off and destroys a single vm, unlike compute cleanup the folder
+// is not deleted since it holds rest of deployment.
+func (p *VmwareVim) DeleteVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) error {
+	_, err := p.cleanupNode(ctx, projectName, node)
+	return err
+}
+
+// Destroys a folder created by clone routine, all vm left in a folder destroyed as well.
+func (p *VmwareVim) DeleteFolder(ctx context.Context, projectName string, folder string) error {
+	return p.deleteFolder(ctx, folder)
+}
+
+/**
+  vCenter Cleanup routine that tries to delete all object from old deployment.
+  Each vm destroyed concurrently and reported as a result per node.
+  TODO move that to vim clean up routine and de-couple vCenter logic from deployer.
+*/
+func (p *VmwareVim) ComputeCleanup(ctx context.Context,
+	projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
+
+	folders := make(map[string]bool)
+	for _, node := range nodes {
+		if len(node.GetFolderPath()) > 0 {
+			folders[node.GetFolderPath()] = true
+		}
+	}
+
+	results := p.pool.RunNodes(ctx, jettypes.CloneJob, jettypes.OpDestroy, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			taskId, err := p.cleanupNode(ctx, projectName, node)
+			return jettypes.NodeResult{TaskId: taskId, Err: err}
+		})
+
+	for _, v := range results {
+		log.Println("job", v.String())
+	}
+
+	if len(results.Failed()) == 0 {
+		logging.Notification("All vm destroyed")
+	}
+
+	// All VM deleted, cleanup all folders now.
+	for f := range folders {
+		err := p.deleteFolder(ctx, f)
+		if err != nil {
+			logging.CriticalMessage("Deployment", projectName, " failed delete folder", f)
+		}
+	}
+
+	return results, results.Err()
+}
+
+/**
+  Clones a single vm from a template with a given hardware config and
+  guest customization. Returns a reference of clone task.
+  TODO add timeout for a thread in context
+*/
+func (p *VmwareVim) runInstantiateTask(ctx context.Context, f *object.Folder,
+	name string, template *object.VirtualMachine, vmConfigSpec types.VirtualMachineCloneSpec) (string, error) {
+
+	t, err := template.Clone(ctx, f, name, vmConfigSpec)
+	if err != nil {
+		return "", err
+	}
+
+	taskInfo, err := t.WaitForResult(ctx, nil)
+	if err != nil {
+		if taskInfo != nil && taskInfo.Error != nil {
+			return t.Reference().Value, fmt.Errorf("clone vm task failed: %s", taskInfo.Error.LocalizedMessage)
+		}
+		return t.Reference().Value, fmt.Errorf("clone vm task failed: %v", err)
+	}
+
+	return t.Reference().Value, nil
+}
+
+//
+// Clone set of VM from a node templates, each clone reported as a result per node.
+//
+func (p *VmwareVim) CloneVms(ctx context.Context,
+	projectName string, nodes []*jettypes.NodeTemplate) (jettypes.NodeResults, error) {
+
+	if p == nil {
+		return nil, fmt.Errorf("vim is nil")
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	if p.datacenter == nil {
+		err := p.discoverDatacenter(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// get root data center root folder
+	dataCenterFolder, err := p.datacenter.Folders(ctx)
+	if err != nil {
+		logging.ErrorLogging(err)
+		return nil, fmt.Errorf("failed retriev folder list, err: %s", err)
+	}
+
+	// default folder of a deployment is a deployment name and uuid
+	folderUuid := uuid.New().String()
+	finder := p.datacenterFinder()
+
+	// hardware and placement of each node validated before any vm cloned
+	vmTemplates := make(map[string]*object.VirtualMachine)
+	cloneSpecs := make(map[string]types.VirtualMachineCloneSpec)
+	usage := make(map[types.ManagedObjectReference]int64)
+	for _, node := range nodes {
+		// get the template for a node
+		vmTemplate, _, err := p.DiscoverVmTemplates(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		vmTemplates[node.Name] = vmTemplate
+
+		placement, err := p.nodePlacement(ctx, finder, projectName, folderUuid, node)
+		if err != nil {
+			return nil, err
+		}
+		node.SetFolderPath(placement.folder)
+
+		hw, devices, err := templateHardware(ctx, vmTemplate)
+		if err != nil {
+			return nil, err
+		}
+		err = node.Hardware.Validate(hw)
+		if err != nil {
+			return nil, fmt.Errorf("node %s hardware doesn't fit template %s: %v", node.Name, node.VmTemplateName, err)
+		}
+		if err = node.ValidateCloneMode(); err != nil {
+			return nil, err
+		}
+		// child disk of a linked clone can't outgrow a snapshot disk
+		if node.LinkedClone() && node.Hardware.DiskGB > hw.DiskGB {
+			return nil, fmt.Errorf("node %s is a linked clone, root disk can't grow", node.Name)
+		}
+		config, err := cloneConfigSpec(node.Hardware, devices, placement.datastore)
+		if err != nil {
+			return nil, fmt.Errorf("node %s hardware: %v", node.Name, err)
+		}
+		config.ExtraConfig = append(config.ExtraConfig, cloudInitExtraConfig(node)...)
+		cloneSpec := types.VirtualMachineCloneSpec{Config: config, Location: placement.relocateSpec()}
+
+		// linked clone shares a snapshot disk of a template and writes own child disk
+		if node.LinkedClone() {
+			cloneSpec.Snapshot, err = templateSnapshot(ctx, vmTemplate, node.SnapshotName())
+			if err != nil {
+				return nil, err
+			}
+			cloneSpec.Location.DiskMoveType = string(types.VirtualMachineRelocateDiskMoveOptionsCreateNewChildDiskBacking)
+		}
+
+		// static node addressed by guest customization instead of dhcp binding
+		if node.Static {
+			cloneSpec.Customization, err = linuxCustomization(node)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cloneSpecs[node.Name] = cloneSpec
+		// node reports hardware it cloned with
+		node.Hardware = node.Hardware.Merge(hw)
+
+		datastore := placement.datastore
+		if datastore == nil {
+			datastore = rootDatastore(devices)
+		}
+		if datastore != nil && node.LinkedClone() {
+			usage[*datastore] += requiredSpace(jettypes.Hardware{DataDisksGB: node.Hardware.DataDisksGB})
+		} else if datastore != nil {
+			usage[*datastore] += requiredSpace(node.Hardware)
+		}
+	}
+
+	err = p.checkFreeSpace(ctx, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make(map[string]*object.Folder)
+	for _, node := range nodes {
+		path := node.GetFolderPath()
+		if _, ok := folders[path]; ok {
+			continue
+		}
+		folders[path], err = p.createFolderPath(ctx, finder, dataCenterFolder.VmFolder, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed create a folder for deployement %v", err)
+		}
+	}
+
+	results := p.pool.RunNodes(ctx, jettypes.CloneJob, jettypes.OpClone, nodes,
+		func(node *jettypes.NodeTemplate) jettypes.NodeResult {
+			taskId, err := p.runInstantiateTask(ctx, folders[node.GetFolderPath()], node.Name, vmTemplates[node.Name], cloneSpecs[node.Name])
+			return jettypes.NodeResult{TaskId: taskId, Err: err}
+		})
+
+	for _, v := range results {
+		log.Println("job", v.String())
+	}
+
+	return results, results.Err()
+}
+
+//
+//  Function connects a vm to a target network switch, in case VM has no
+//  adapter it will add network adapter.
+//
+func (p *VmwareVim) ConnectVm(ctx context.Context, projectName string, node *jettypes.NodeTemplate) (bool, error) {
+
+	ok, err := p.isAttached(ctx, node.GetVimName(), node.GenericSwitch().Name())
+	if err != nil {
+		logging.CriticalMessage("failed to check vm attachment error: " + err.Error())
+		return false, err
+	}
+
+	if !ok {
+		deadline, cancel := context.WithDeadline(ctx, time.Now().Add(10*time.Second))
+		defer cancel()
+		_, _, err := vcenter.AddNetworkAdapter(deadline,
+			p.VimClient(),
+			node.GenericSwitch().Uuid(),
+			node.VmTemplateName)
+		if err != nil {
+			if deadline.Err() != context.DeadlineExceeded {
+				return false, fmt.Errorf("failed to connect vm, request timeout %s", err)
+			}
+		}
+	} else {
+		log.Print("VM already attached to network segment ",
+			node.GenericSwitch().Name(), " uuid: ", node.GenericSwitch().Uuid())
+	}
+
+	return true, nil
+}
+
+//
+//   Function synchronize a node  after all VM deployed
+//
+func (p *VmwareVim) DiscoverVms(ctx context.Context, projectName string, nodes []*jettypes.NodeTemplate) error {
+
+	for i, node := range nodes {
+
+		// since we cloned a VM old mac belong to a template
+		nodes[i].Mac = append(nodes[i].Mac[:0], nodes[i].Mac[1:]...)
+		_, _, m, err := vcenter.VmFromCluster(ctx, p.VimClient(), node.Name, node.VimCluster)
+		if err != nil {
+			return fmt.Errorf("vm not found")
+		}
+
+		// set new uuid and vm name
+		nodes[i].UUID = m.UUID(ctx)
+		nodes[i].SetVimName(m.Reference().Value)
+
+		devs, err := m.Device(ctx)
+		if err != nil {
+			return fmt.Errorf("failed get device list")
+		}
+
+		// append actual mac addresses to a node struct
+		for _, dev := range devs {
+			if nic, ok := dev.(types.BaseVirtualEthernetCard); ok {
+				nodes[i].Mac = append(node.Mac, nic.GetVirtualEthernetCard().MacAddress)
+			}
+		}
+
+		networks, err := vcenter.GetNetworkAttr(ctx, p.VimClient(), m.Reference().Value)
+		if err != nil {
+			return fmt.Errorf("failed to find deployed VM networks, err: %s", err)
+		}
+
+		for _, net := range *networks {
+			nodes[i].NetworksRef = append(node.NetworksRef, net.Name)
+		}
+	}
+
+	return nil
+}
+
+//
+//  Returns live state of a vm. Vm looked up by name in entire inventory, so a vm
+//  that migrated to another cluster still found and reported with own cluster.
+//
+func (p *VmwareVim) DescribeVm(ctx context.Context, node *jettypes.NodeTemplate) (*jettypes.VmInfo, error) {
+
+	info := &jettypes.VmInfo{Name: node.Name}
+
+	vm, err := find.NewFinder(p.VimClient()).VirtualMachine(ctx, node.Name)
+	if err != nil {
+		if _, ok := err.(*find.NotFoundError); ok {
+			return info, nil
+		}
+		return nil, err
+	}
+
+	err = p.describeVm(ctx, vm, info)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Fills info with properties, cluster, networks and switches of a vm.
+func (p *VmwareVim) describeVm(ctx context.Context, vm *object.VirtualMachine, info *jettypes.VmInfo) error {
+
+	info.Exists = true
+	info.VimName = vm.Reference().Value
+
+	var mvm mo.VirtualMachine
+	err := vm.Properties(ctx, vm.Reference(),
+		[]string{"config.uuid", "config.hardware.device", "runtime.powerState", "runtime.host", "guest.ipAddress", "network"}, &mvm)
+	if err != nil {
+		return fmt.Errorf("failed retrieve vm %s properties %v", info.Name, err)
+	}
+
+	if mvm.Config != nil {
+		info.UUID = mvm.Config.Uuid
+		for _, dev := range mvm.Config.Hardware.Device {
+			if nic, ok := dev.(types.BaseVirtualEthernetCard); ok {
+				info.Mac = append(info.Mac, nic.GetVirtualEthernetCard().MacAddress)
+			}
+		}
+	}
+	if mvm.Guest != nil {
+		info.IPv4Addr = mvm.Guest.IpAddress
+	}
+	info.PoweredOn = mvm.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn
+
+	// cluster is a parent of a host
+	if mvm.Runtime.Host != nil {
+		var host mo.HostSystem
+		err = vm.Properties(ctx, *mvm.Runtime.Host, []string{"parent"}, &host)
+		if err != nil {
+			return fmt.Errorf("failed retrieve vm %s host %v", info.Name, err)
+		}
+		if host.Parent != nil {
+			info.Cluster, err = object.NewCommon(p.VimClient(), *host.Parent).ObjectName(ctx)
+			if err != nil {
+				return fmt.Errorf("failed retrieve vm %s cluster %v", info.Name, err)
+			}
+		}
+	}
+
+	for _, ref := range mvm.Network {
+		name, err := object.NewCommon(p.VimClient(), ref).ObjectName(ctx)
+		if err != nil {
+			return fmt.Errorf("failed retrieve vm %s network name %v", info.Name, err)
+		}
+		info.Networks = append(info.Networks, name)
+	}
+
+	adapters, err := vcenter.GetSwitchUuid(ctx, p.VimClient(), info.Name)
+	if err != nil {
+		return fmt.Errorf("failed retrieve vm %s adapters %v", info.Name, err)
+	}
+	for _, a := range adapters {
+		info.SwitchUuids = append(info.SwitchUuids, a.SwitchUuid())
+	}
+
+	return nil
+}
+
+//
+//  Returns live state of each vm in a folder. Folder is an inventory path
+//  relative to a datacenter vm folder, names of vSphere tags of each vm included
+//  if tagging api available.
+//
+func (p *VmwareVim) DiscoverFolder(ctx context.Context, folder string) ([]*jettypes.VmInfo, error) {
+
+	var infos []*jettypes.VmInfo
+
+	finder := find.NewFinder(p.VimClient(), true)
+	finder.SetDatacenter(p.datacenter)
+
+	f, err := finder.Folder(ctx, folder)
+	if err != nil {
+		return nil, fmt.Errorf("failed find folder %s %v", folder, err)
+	}
+
+	vms, err := finder.VirtualMachineList(ctx, f.InventoryPath+"/*")
+	if err != nil {
+		if _, ok := err.(*find.NotFoundError); ok {
+			return infos, nil
+		}
+		return nil, fmt.Errorf("failed list vms in folder %s %v", folder, err)
+	}
+
+	vmTags, err := p.vmTags(ctx, vms)
+	if err != nil {
+		logging.CriticalMessage("failed read vSphere tags", err.Error())
+	}
+
+	for _, vm := range vms {
+		info := &jettypes.VmInfo{Name: vm.Name()}
+		err = p.describeVm(ctx, vm, info)
+		if err != nil {
+			return nil, err
+		}
+		info.Tags = vmTags[vm.Reference().Value]
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// Returns names of vSphere tags attached to vms keyed by vm reference.
+func (p *VmwareVim) vmTags(ctx context.Context, vms []*object.VirtualMachine) (map[string][]string, error) {
+
+	vmTags := make(map[string][]string)
+	if len(vms) == 0 || p.endpoint == nil {
+		return vmTags, nil
+	}
+
+	c := rest.NewClient(p.VimClient())
+	err := c.Login(ctx, url.UserPassword(p.endpoint.VimUsername(), p.endpoint.VimPassword()))
+	if err != nil {
+		return vmTags, err
+	}
+	defer func() {
+		if err := c.Logout(ctx); err != nil {
+			log.Println("failed logout from tagging api", err)
+		}
+	}()
+
+	refs := make([]mo.Reference, len(vms))
+	for i, vm := range vms {
+		refs[i] = vm.Reference()
+	}
+
+	attached, err := tags.NewManager(c).GetAttachedTagsOnObjects(ctx, refs)
+	if err != nil {
+		return vmTags, err
+	}
+
+	for _, a := range attached {
+		ref := a.ObjectID.Reference().Value
+		for _, t := range a.Tags {
+			vmTags[ref] = append(vmTags[ref], t.Name)
+		}
+	}
+
+	return vmTags, nil
+}
+
+//
+//
+//
+func (p *VmwareVim) ChangePowerState(ctx context.Context, node *jettypes.NodeTemplate, state jettypes.PowerState) (bool, error) {
+
+	logging.Notification("Powering on vm", node.Name)
+
+	_, _, vm, err := vcenter.VmFromCluster(ctx, p.VimClient(), node.Name, node.VimCluster)
+	if err != nil {
+		return false, err
+	}
+
+	var task *object.Task
+	switch state {
+	case jettypes.PowerOn:
+		task, err = vm.PowerOn(ctx)
+		if err != nil {
+			return false, err
+		}
+	case jettypes.PowerOff:
+		task, err = vm.PowerOn(ctx)
+		if err != nil {
+			return false, err
+		}
+	case jettypes.Reboot:
+		err = vm.RebootGuest(ctx)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	case jettypes.Reset:
+		task, err = vm.Reset(ctx)
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("unkown command")
+	}
+
+	deadline, cancel := context.WithDeadline(ctx, time.Now().Add(60*time.Second))
+	defer cancel()
+	_, err = task.WaitForResult(deadline, nil)
+	if err != nil {
+		if deadline.Err() != context.DeadlineExceeded {
+			return false, fmt.Errorf("failed to acquire ip address, request timeout")
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AcquireIpAddress of VM
+func (p *VmwareVim) AcquireIpAddress(ctx context.Context, node *jettypes.NodeTemplate) (bool, string, error) {
+
+	if len(node.Name) == 0 || len(node.VimCluster) == 0 {
+		return false, "", nil
+	}
+	_, _, vm, err := vcenter.VmFromCluster(ctx, p.VimClient(), node.Name, node.VimCluster)
+	if err != nil {
+		return false, "", fmt.Errorf("failed find a vm %s %v", node.Name, err)
+	}
+
+	deadline, cancel := context.WithDeadline(ctx, time.Now().Add(60*time.Second))
+	defer cancel()
+	ip, err := vm.WaitForIP(deadline)
+	if err != nil {
+		if deadline.Err() != context.DeadlineExceeded {
+			return false, "", fmt.Errorf("failed to acquire ip address, request timeout")
+		}
+	}
+
+	if ip != "" {
+		if node.IPv4AddrStr != ip {
+			logging.CriticalMessage(
+				" vm booted with different address, expected ", node.IPv4AddrStr, " actual ", ip)
+		}
+		return true, ip, nil
+	}
+
+	return false, "", nil
+}
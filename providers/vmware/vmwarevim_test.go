@@ -1,8 +1,10 @@
-package main
+package vmware
 
 import (
 	"context"
 	"github.com/spyroot/jettison/jettypes"
+	"github.com/spyroot/jettison/nsxtapi"
+	"github.com/spyroot/jettison/nsxtapi/nsxtsim"
 	"github.com/spyroot/jettison/vcenter"
 	"github.com/stretchr/testify/assert"
 	"github.com/vmware/go-vmware-nsxt"
@@ -12,18 +14,79 @@ import (
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
 	"log"
+	"net"
+	"net/http"
 	"reflect"
+	"sync"
 	"testing"
 )
 
-func setupTest(t *testing.T) (*vcenter.TestingEnv, func(t *testing.T)) {
+var (
+	simOnce sync.Once
+	sim     *nsxtsim.Server
+)
+
+type testingEnv struct {
+	*vcenter.TestingEnv
+	TestVim *VmwareVim
+}
+
+// Returns nsx-t config of nsxtsim, simulator started once.
+func simNsxtConfig() *NsxtConfig {
+
+	simOnce.Do(func() {
+		sim = nsxtsim.NewServer()
+	})
+
+	return &NsxtConfig{
+		NsxtConfig: Config{
+			Hostname:      sim.Host(),
+			Username:      sim.Username,
+			Password:      sim.Password,
+			LogicalSwitch: nsxtsim.SegmentName,
+			EdgeCluster:   nsxtsim.EdgeClusterName,
+			OverlayTzName: nsxtsim.OverlayZoneName,
+		},
+	}
+}
+
+// Builds vmware vim on top of vcsim or vCenter VC_URL points to, on vcsim
+// nsx-t half served by nsxtsim, otherwise nsx-t config is read if present.
+func setupTest(t *testing.T) (*testingEnv, func(t *testing.T)) {
+
+	vimHelper, client := vcenter.VimSetupHelper()
 
-	vimHelper, _, err := vcenter.VimSetupHelper()
+	ctx := context.Background()
+	finder := find.NewFinder(client.Client, true)
+	dc, err := finder.Datacenter(ctx, vcenter.TestDatacenter)
 	if err != nil {
-		log.Fatal("Failed VimSetupHelper()", err)
+		log.Fatal("Failed find test datacenter ", err)
+	}
+	finder.SetDatacenter(dc)
+
+	vim := &VmwareVim{
+		vimApi:     client,
+		VimFinder:  finder,
+		datacenter: dc,
+		ctx:        ctx,
+		dcName:     vcenter.TestDatacenter,
+	}
+
+	if vcenter.IsSimulator() {
+		vim.nsxtConfig = simNsxtConfig()
+	} else if nsxtConfig, err := NewNsxtConfig(); err == nil {
+		vim.nsxtConfig = nsxtConfig
+	}
+
+	if vim.nsxtConfig != nil {
+		vim.nsxApi, err = nsxtapi.Connect(vim.nsxtConfig.Hostname(),
+			vim.nsxtConfig.Username(), vim.nsxtConfig.Password())
+		if err != nil {
+			log.Fatal("Failed connect to nsx-t manager ", err)
+		}
 	}
 
-	return vimHelper, func(t *testing.T) {
+	return &testingEnv{vimHelper, vim}, func(t *testing.T) {
 		t.Log("teardown test")
 	}
 }
@@ -52,44 +115,48 @@ func TestInit(t *testing.T) {
 }
 
 func TestVmwareVim_ComputeCleanup(t *testing.T) {
-	type fields struct {
-		VimApi     *govmomi.Client
-		VimFinder  *find.Finder
-		datacenter *object.Datacenter
-		ctx        context.Context
-		nsxApi     nsxt.APIClient
-		nsxtConfig *NsxtConfig
-	}
+
+	env, teardown := setupTest(t)
+	defer teardown(t)
+	env.TestVim.SetWorkerPool(jettypes.NewWorkerPool(jettypes.DefaultParallelJobs, nil))
+
 	type args struct {
 		projectName string
-		nodes       *[]*jettypes.NodeTemplate
+		nodes       []*jettypes.NodeTemplate
 	}
 	tests := []struct {
 		name    string
-		fields  fields
 		args    args
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name: "vm already deleted",
+			args: args{
+				projectName: "test",
+				nodes:       []*jettypes.NodeTemplate{{Name: "jettison-missing-vm", VimCluster: "DC0_C0"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "no nodes",
+			args:    args{projectName: "test"},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			p := &VmwareVim{
-				vimApi:     tt.fields.VimApi,
-				VimFinder:  tt.fields.VimFinder,
-				datacenter: tt.fields.datacenter,
-				ctx:        tt.fields.ctx,
-				nsxApi:     tt.fields.nsxApi,
-				nsxtConfig: tt.fields.nsxtConfig,
-			}
-			if err := p.ComputeCleanup(tt.args.projectName, tt.args.nodes); (err != nil) != tt.wantErr {
+			results, err := env.TestVim.ComputeCleanup(context.Background(), tt.args.projectName, tt.args.nodes)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("ComputeCleanup() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if len(results) != len(tt.args.nodes) || len(results.Failed()) > 0 {
+				t.Errorf("ComputeCleanup() results = %v", results)
+			}
 		})
 	}
 }
 
-func TestVmwareVim_CreateVms(t *testing.T) {
+func TestVmwareVim_CloneVms(t *testing.T) {
 
 	type fields struct {
 		VimApi     *govmomi.Client
@@ -121,8 +188,8 @@ func TestVmwareVim_CreateVms(t *testing.T) {
 				nsxApi:     tt.fields.nsxApi,
 				nsxtConfig: tt.fields.nsxtConfig,
 			}
-			if err := p.CreateVms(tt.args.projectName, tt.args.nodes); (err != nil) != tt.wantErr {
-				t.Errorf("CreateVms() error = %v, wantErr %v", err, tt.wantErr)
+			if _, err := p.CloneVms(context.Background(), tt.args.projectName, tt.args.nodes); (err != nil) != tt.wantErr {
+				t.Errorf("CloneVms() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -183,8 +250,13 @@ func TestVmwareVim_InitPlugin(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
+			// plugin discovers nsx-t elements, on vcsim nsx-t manager is nsxtsim,
+			// otherwise config of nsx-t manager read from a file
 			p := &VmwareVim{}
-			err := p.InitPlugin(tt.args.vimEndpoint)
+			if vcenter.IsSimulator() {
+				p.nsxtConfig = simNsxtConfig()
+			}
+			err := p.InitPlugin(context.Background(), tt.args.vimEndpoint)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("InitPlugin() error = %v, wantErr %v", err, tt.wantErr)
@@ -320,7 +392,7 @@ func TestVmwareVim_discoverNetwork(t *testing.T) {
 	}
 }
 
-func TestVmwareVim_findTemplate(t *testing.T) {
+func TestVmwareVim_discoverVmTemplate(t *testing.T) {
 
 	//env, teardown := setupTest(t)
 	//defer teardown(t)
@@ -370,9 +442,9 @@ func TestVmwareVim_findTemplate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			got, got1, err := tt.args.vim.findTemplate(tt.args.node)
+			got, got1, err := tt.args.vim.discoverVmTemplate(context.Background(), tt.args.node)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("findTemplate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("discoverVmTemplate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
@@ -389,10 +461,10 @@ func TestVmwareVim_findTemplate(t *testing.T) {
 			}
 
 			//if !reflect.DeepEqual(got, tt.want) {
-			//	t.Errorf("findTemplate() got = %v, want %v", got, tt.want)
+			//	t.Errorf("discoverVmTemplate() got = %v, want %v", got, tt.want)
 			//}
 			//if !reflect.DeepEqual(got1, tt.want1) {
-			//	t.Errorf("findTemplate() got1 = %v, want %v", got1, tt.want1)
+			//	t.Errorf("discoverVmTemplate() got1 = %v, want %v", got1, tt.want1)
 			//}
 
 		})
@@ -430,7 +502,7 @@ func TestVmwareVim_findVmObject(t *testing.T) {
 				nsxApi:     tt.fields.nsxApi,
 				nsxtConfig: tt.fields.nsxtConfig,
 			}
-			got, err := p.findVmObject(tt.args.vmName)
+			got, err := p.findVmObject(context.Background(), tt.args.vmName)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("findVmObject() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -442,54 +514,6 @@ func TestVmwareVim_findVmObject(t *testing.T) {
 	}
 }
 
-func TestVmwareVim_getTemplateData(t *testing.T) {
-	type fields struct {
-		VimApi     *govmomi.Client
-		VimFinder  *find.Finder
-		datacenter *object.Datacenter
-		ctx        context.Context
-		nsxApi     nsxt.APIClient
-		nsxtConfig *NsxtConfig
-	}
-	type args struct {
-		ctx  context.Context
-		node *jettypes.NodeTemplate
-	}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    *object.VirtualMachine
-		want1   *[]mo.Network
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			p := &VmwareVim{
-				vimApi:     tt.fields.VimApi,
-				VimFinder:  tt.fields.VimFinder,
-				datacenter: tt.fields.datacenter,
-				ctx:        tt.fields.ctx,
-				nsxApi:     tt.fields.nsxApi,
-				nsxtConfig: tt.fields.nsxtConfig,
-			}
-			got, got1, err := p.getTemplateData(tt.args.ctx, tt.args.node)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("getTemplateData() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getTemplateData() got = %v, want %v", got, tt.want)
-			}
-			if !reflect.DeepEqual(got1, tt.want1) {
-				t.Errorf("getTemplateData() got1 = %v, want %v", got1, tt.want1)
-			}
-		})
-	}
-}
-
 func TestVmwareVim_isAttached(t *testing.T) {
 	type fields struct {
 		VimApi     *govmomi.Client
@@ -522,7 +546,7 @@ func TestVmwareVim_isAttached(t *testing.T) {
 				nsxApi:     tt.fields.nsxApi,
 				nsxtConfig: tt.fields.nsxtConfig,
 			}
-			got, err := p.isAttached(tt.args.vmName, tt.args.switchUuid)
+			got, err := p.isAttached(context.Background(), tt.args.vmName, tt.args.switchUuid)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("isAttached() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -554,3 +578,216 @@ func Test_checkNetworkAttachment(t *testing.T) {
 		})
 	}
 }
+
+func TestVmwareVim_DescribeSegment(t *testing.T) {
+
+	env, teardown := setupTest(t)
+	defer teardown(t)
+	if !vcenter.IsSimulator() {
+		t.Skip("test creates logical switch, runs only against nsxtsim")
+	}
+
+	ctx := context.Background()
+	plain, err := nsxtapi.CreateLogicalSwitch(env.TestVim.nsx(ctx), nsxtsim.OverlayZoneName, "plain-segment", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = nsxtapi.DeleteLogicalSwitch(env.TestVim.nsx(ctx), plain.Id)
+	}()
+
+	tests := []struct {
+		name       string
+		switchUuid string
+		wantDhcp   string
+		wantErr    bool
+	}{
+		{
+			name:       "segment with dhcp server",
+			switchUuid: nsxtsim.SegmentUuid,
+			wantDhcp:   nsxtsim.DhcpServerUuid,
+		},
+		{
+			name:       "segment without dhcp server",
+			switchUuid: plain.Id,
+			wantDhcp:   "",
+		},
+		{
+			name:       "unknown segment",
+			switchUuid: "unknown",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, err := env.TestVim.DescribeSegment(ctx, tt.switchUuid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DescribeSegment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if s.Uuid() != tt.switchUuid || s.DhcpUuid() != tt.wantDhcp {
+				t.Errorf("DescribeSegment() = %s dhcp %s, want %s dhcp %s",
+					s.Uuid(), s.DhcpUuid(), tt.switchUuid, tt.wantDhcp)
+			}
+		})
+	}
+}
+
+func TestVmwareVim_DhcpCleanup(t *testing.T) {
+
+	env, teardown := setupTest(t)
+	defer teardown(t)
+	if !vcenter.IsSimulator() {
+		t.Skip("test creates dhcp bindings, runs only against nsxtsim")
+	}
+
+	tests := []struct {
+		name        string
+		bound       bool
+		fault       *nsxtsim.Fault
+		wantErr     bool
+		wantBinding bool
+	}{
+		{
+			name:  "binding deleted",
+			bound: true,
+		},
+		{
+			name: "binding already deleted",
+		},
+		{
+			name:  "delete failed",
+			bound: true,
+			fault: &nsxtsim.Fault{Method: http.MethodDelete,
+				Path: "/dhcp/servers/" + nsxtsim.DhcpServerUuid + "/static-bindings/", Status: http.StatusForbidden},
+			wantErr:     true,
+			wantBinding: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctx := context.Background()
+			node := &jettypes.NodeTemplate{
+				Name:       "dhcp-cleanup-1",
+				VimCluster: "cluster",
+				Mac:        []string{"00:50:56:aa:bb:01"},
+				IPv4Addr:   net.ParseIP("172.16.81.51"),
+				Gateway:    "172.16.81.1",
+			}
+			node.SetGenericSwitch(jettypes.NewGenericSwitch(nsxtsim.SegmentName,
+				nsxtsim.SegmentUuid, nsxtsim.DhcpServerUuid, ""))
+
+			if tt.bound {
+				if err := env.TestVim.CreateDhcpBindings(ctx, "test", []*jettypes.NodeTemplate{node}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer func() {
+				sim.ClearFaults()
+				_ = nsxtapi.DhcpCleanupEntry(env.TestVim.nsx(ctx), node)
+			}()
+
+			if tt.fault != nil {
+				sim.InjectFault(*tt.fault)
+			}
+
+			err := env.TestVim.DhcpCleanup(ctx, "test", []*jettypes.NodeTemplate{node})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DhcpCleanup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(*jettypes.MultiError); err != nil && !ok {
+				t.Errorf("DhcpCleanup() error = %T, want per node results", err)
+			}
+
+			sim.ClearFaults()
+			_, err = nsxtapi.GetStaticBinding(env.TestVim.nsx(ctx), nsxtsim.DhcpServerUuid,
+				node.IPv4Addr.String(), nsxtapi.DhcpLookupHandler["ip"])
+			if (err == nil) != tt.wantBinding {
+				t.Errorf("DhcpCleanup() binding left = %v, want %v", err == nil, tt.wantBinding)
+			}
+		})
+	}
+}
+
+func TestVmwareVim_DeploySegment(t *testing.T) {
+
+	env, teardown := setupTest(t)
+	defer teardown(t)
+	if !vcenter.IsSimulator() {
+		t.Skip("test creates segments, runs only against nsxtsim")
+	}
+	if err := env.TestVim.discoverNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		segment    string
+		dhcp       bool
+		dnsServers []string
+		domain     string
+		fault      *nsxtsim.Fault
+		wantErr    bool
+	}{
+		{
+			name:       "dhcp server of segment",
+			segment:    "dns-segment",
+			dhcp:       true,
+			dnsServers: []string{"10.0.0.53", "10.0.1.53"},
+			domain:     "example.test",
+		},
+		{
+			name:    "static segment",
+			segment: "static-segment",
+		},
+		{
+			name:    "dhcp server failed",
+			segment: "failed-segment",
+			dhcp:    true,
+			domain:  "example.test",
+			fault:   &nsxtsim.Fault{Method: http.MethodPost, Path: "/dhcp/servers", Status: http.StatusForbidden},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctx := context.Background()
+			if tt.fault != nil {
+				sim.InjectFault(*tt.fault)
+				defer sim.ClearFaults()
+			}
+
+			sw, _, err := env.TestVim.DeploySegment(ctx, "test", tt.segment, "172.16.90.1", 24,
+				tt.dhcp, tt.dnsServers, tt.domain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeploySegment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !tt.dhcp {
+				if len(sw.DhcpUuid()) != 0 {
+					t.Errorf("DeploySegment() dhcp server = %s, want none", sw.DhcpUuid())
+				}
+				return
+			}
+
+			nsx := env.TestVim.nsx(ctx)
+			server, _, err := nsx.ServicesApi.ReadDhcpServer(nsx.Context, sw.DhcpUuid())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(server.Ipv4DhcpServer.DnsNameservers, tt.dnsServers) {
+				t.Errorf("DeploySegment() name servers = %v, want %v", server.Ipv4DhcpServer.DnsNameservers, tt.dnsServers)
+			}
+			if server.Ipv4DhcpServer.DomainName != tt.domain {
+				t.Errorf("DeploySegment() domain = %s, want %s", server.Ipv4DhcpServer.DomainName, tt.domain)
+			}
+		})
+	}
+}
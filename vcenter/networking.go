@@ -44,6 +44,16 @@ type NetworkAdapter struct {
 	deviceKey  string
 }
 
+// Returns uuid of nsx-t logical switch adapter attached to
+func (n NetworkAdapter) SwitchUuid() string {
+	return n.switchUuid
+}
+
+// Returns true if adapter connected
+func (n NetworkAdapter) Connected() bool {
+	return n.connected
+}
+
 // Returns all network VM attach to
 //
 func GetNetworkAttr(ctx context.Context, c *vim25.Client, vmVimName string) (*[]mo.Network, error) {
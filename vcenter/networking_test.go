@@ -6,24 +6,15 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/vim25"
-	"log"
 	"net"
-	"os"
 	"reflect"
 	"testing"
 )
 
+// Connects to vcsim or to vCenter VC_URL points to.
 func setupTest(t *testing.T) (*govmomi.Client, context.Context, func(t *testing.T)) {
 
-	ctx := context.Background()
-	vsphereClient, err := Connect(ctx,
-		os.Getenv("VCENTER_HOSTNAME"),
-		os.Getenv("VCENTER_USERNAME"),
-		os.Getenv("VCENTER_PASSWORD"))
-
-	if err != nil {
-		log.Fatal("Failed VimSetupHelper()", err)
-	}
+	vsphereClient, ctx := connect()
 
 	return vsphereClient, ctx, func(t *testing.T) {
 		t.Log("teardown test")
@@ -32,6 +23,11 @@ func setupTest(t *testing.T) (*govmomi.Client, context.Context, func(t *testing.
 
 func TestAddNetworkAdapter(t *testing.T) {
 
+	// vcsim generates same mac address for each adapter of a vm
+	if IsSimulator() {
+		t.Skip("vcsim doesn't generate unique mac address of an adapter, set VC_URL")
+	}
+
 	client, ctx, teardown := setupTest(t)
 	_ = teardown
 
@@ -48,7 +44,7 @@ func TestAddNetworkAdapter(t *testing.T) {
 	}{
 		{
 			name:    "valid nsxt-t uuid and vm name",
-			args:    args{ctx, client.Client, "91c9a86e-20f2-410f-9561-55e5161c1842", "jettison-test"},
+			args:    args{ctx, client.Client, TestSegmentUuid, TestImageName},
 			wantErr: false,
 		},
 	}
@@ -93,10 +89,15 @@ func TestAddNetworkAdapter(t *testing.T) {
 
 func TestGetNetworkAttr(t *testing.T) {
 
+	// network attributes read from back references of each network to a vm
+	if IsSimulator() {
+		t.Skip("vcsim doesn't track vm of a network, set VC_URL")
+	}
+
 	client, ctx, teardown := setupTest(t)
 	_ = teardown
 
-	vm, err := GetVmAttr(ctx, client.Client, VmSearchHandler["name"], "jettison-test")
+	vm, err := GetVmAttr(ctx, client.Client, VmSearchHandler["name"], TestImageName)
 	if err != nil {
 		t.Errorf("Failed to find vm in order execute a test")
 		return
@@ -119,8 +120,8 @@ func TestGetNetworkAttr(t *testing.T) {
 			args: args{ctx,
 				client.Client,
 				vm.Summary.Vm.Value,
-				"jettison-test",
-				"57f91737-2bc6-41b7-9b78-34d595135043"}, // we choose network that vm not attached
+				TestImageName,
+				TestAttachUuid}, // we choose network that vm not attached
 			wantErr: false,
 		},
 	}
@@ -211,7 +212,7 @@ func TestGetOpaqueByUuid(t *testing.T) {
 		},
 		{
 			name:    "valid nsxt-t uuid",
-			args:    args{ctx, client.Client, "91c9a86e-20f2-410f-9561-55e5161c1842"},
+			args:    args{ctx, client.Client, TestSegmentUuid},
 			wantErr: false,
 		},
 	}
@@ -288,6 +289,10 @@ func TestGetOpaqueNetworks(t *testing.T) {
 }
 
 func TestGetSwitchUuid(t *testing.T) {
+
+	client, ctx, teardown := setupTest(t)
+	_ = teardown
+
 	type args struct {
 		ctx    context.Context
 		c      *vim25.Client
@@ -296,10 +301,18 @@ func TestGetSwitchUuid(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
-		want    []NetworkAdapter
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name:    "test vm",
+			args:    args{ctx, client.Client, TestImageName},
+			wantErr: false,
+		},
+		{
+			name:    "vm not found",
+			args:    args{ctx, client.Client, TestImageName + "123"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -308,8 +321,10 @@ func TestGetSwitchUuid(t *testing.T) {
 				t.Errorf("GetSwitchUuid() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetSwitchUuid() got = %v, want %v", got, tt.want)
+			for _, adapter := range got {
+				if !nsxtapi.IsUuid(adapter.SwitchUuid()) {
+					t.Errorf("GetSwitchUuid() adapter with invalid switch uuid %s", adapter.SwitchUuid())
+				}
 			}
 		})
 	}
@@ -333,12 +348,12 @@ func Test_isOpaqueNetwork(t *testing.T) {
 	}{
 		{
 			name:    "valid request",
-			args:    args{ctx, client.Client, "test-segment"},
+			args:    args{ctx, client.Client, TestSegmentName},
 			wantErr: false,
 		},
 		{
 			name:    "invalid request",
-			args:    args{ctx, client.Client, "test-segment123"},
+			args:    args{ctx, client.Client, TestSegmentName + "123"},
 			wantErr: false,
 		},
 	}
@@ -45,6 +45,14 @@ var (
 	TestDataStoreName string = "vsanMgmtDatastore"
 	// name of vm
 	TestImageName = "jettison-test"
+	// datacenter of a cluster
+	TestDatacenter = "Datacenter"
+	// nsx-t segment vm attached to, a name and an uuid of an opaque network
+	TestSegmentName = "test-segment"
+	TestSegmentUuid = "91c9a86e-20f2-410f-9561-55e5161c1842"
+	// segment vm not attached to, vcsim has a standard network with a name
+	TestAttachName = "test-attach"
+	TestAttachUuid = "57f91737-2bc6-41b7-9b78-34d595135043"
 )
 
 /**
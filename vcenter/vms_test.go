@@ -23,11 +23,7 @@ package vcenter
 
 import (
 	"context"
-	"fmt"
-	"github.com/spyroot/jettison/internal"
-	"github.com/spyroot/jettison/logging"
 	"github.com/stretchr/testify/assert"
-	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
@@ -36,12 +32,6 @@ import (
 	"testing"
 )
 
-//var (
-//	TestVim       *internal.Vim
-//	TestImageName = ""
-//	TestVmUuid    = ""
-//)
-
 //
 //
 func setupSubTest(t *testing.T) func(t *testing.T) {
@@ -99,8 +89,6 @@ func TestDeleteFolder(t *testing.T) {
  */
 func TestChangePowerState(t *testing.T) {
 
-	env, c := VimSetupHelper()
-
 	type args struct {
 		ctx  context.Context
 		c    *vim25.Client
@@ -130,105 +118,39 @@ func TestChangePowerState(t *testing.T) {
 
 func TestConnect(t *testing.T) {
 
-	env, c := VimSetupHelper()
-
 	type args struct {
 		ctx      context.Context
 		hostname string
 		username string
 		password string
 	}
-	tests := []struct {
-		name    string
-		args    args
-		want    *govmomi.Client
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := Connect(tt.args.ctx, tt.args.hostname, tt.args.username, tt.args.password)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Connect() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Connect() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestGetNetworkAttr(t *testing.T) {
-
-	env, c := VimSetupHelper()
-
-	type args struct {
-		ctx       context.Context
-		c         *vim25.Client
-		vmVimName string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    *[]mo.Network
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetNetworkAttr(tt.args.ctx, tt.args.c, tt.args.vmVimName)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("GetNetworkAttr() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetNetworkAttr() got = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestGetSwitchUuid(t *testing.T) {
-
-	env, c := VimSetupHelper()
-
-	type args struct {
-		ctx        context.Context
-		c          *vim25.Client
-		switchName string
-	}
 	tests := []struct {
 		name    string
 		args    args
 		wantErr bool
 	}{
 		{
-			name: "nil test1",
-			args: args{
-				ctx:        TestVim.Ctx,
-				c:          TestVim.GetVimClient(),
-				switchName: "test-segment",
-			},
+			name:    "test vCenter",
+			args:    args{ctx: context.Background(), hostname: TestUrl()},
 			wantErr: false,
 		},
+		{
+			name:    "invalid url",
+			args:    args{ctx: context.Background(), hostname: "http://127.0.0.1:1/sdk"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetSwitchUuid(tt.args.ctx, tt.args.c, tt.args.switchName)
+			got, err := Connect(tt.args.ctx, tt.args.hostname, tt.args.username, tt.args.password)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("GetSwitchUuid() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Connect() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-
-			if tt.wantErr != true && got != nil {
-				log.Println(got)
+			if err == nil {
+				assert.NotNil(t, got.Client)
+				assert.Contains(t, got.Version, ".")
 			}
-			//if !reflect.DeepEqual(got, tt.want) {
-			//	t.Errorf("GetNetworkAttr() got = %v, want %v", got, tt.want)
-			//}
 		})
 	}
 }
@@ -252,7 +174,7 @@ func TestGetNetworks(t *testing.T) {
 			name: "nil test1",
 			args: args{
 				ctx:  nil,
-				c:    TestVim.GetVimClient(),
+				c:    c.Client,
 				uuid: "edge01.vmwarelab.edu",
 			},
 			wantErr: true,
@@ -262,8 +184,8 @@ func TestGetNetworks(t *testing.T) {
 			name: "wrong name",
 			args: args{
 				ctx:  env.ctx,
-				c:    TestVim.GetVimClient(),
-				uuid: TestVmUuid,
+				c:    c.Client,
+				uuid: env.TestImageId,
 			},
 			wantErr: true,
 			want:    "",
@@ -272,11 +194,11 @@ func TestGetNetworks(t *testing.T) {
 			name: "correct name",
 			args: args{
 				ctx:  env.ctx,
-				c:    TestVim.GetVimClient(),
-				uuid: "test-segment",
+				c:    c.Client,
+				uuid: TestSegmentName,
 			},
 			wantErr: false,
-			want:    "test-segment",
+			want:    TestSegmentName,
 		},
 	}
 	for _, tt := range tests {
@@ -346,10 +268,10 @@ func TestGetSummaryVm(t *testing.T) {
 			args: args{
 				ctx:  env.ctx,
 				c:    c.Client,
-				name: TestVmUuid,
+				name: env.TestImageId,
 			},
 			wantErr: false,
-			want:    TestVmUuid,
+			want:    env.TestImageId,
 		},
 	}
 	for _, tt := range tests {
@@ -413,7 +335,7 @@ func TestGetVmAttr(t *testing.T) {
 			args: args{ctx: env.ctx,
 				c:            c.Client,
 				vmComparator: cmpUuid,
-				attrValue:    TestVmUuid,
+				attrValue:    env.TestImageId,
 			},
 			wantErr: false,
 		},
@@ -458,7 +380,7 @@ func TestVmFromCluster(t *testing.T) {
 			args{ctx,
 				client.Client,
 				TestImageName,
-				"mgmt",
+				TestImageCluster,
 			},
 			false,
 		},